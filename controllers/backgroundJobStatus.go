@@ -2,8 +2,13 @@ package controllers
 
 import (
 	"server/models"
+	"context"
+	"errors"
 	"fmt"
+	"log"
 	"os"
+	"sync"
+	"time"
 	"database/sql"
 	_ "github.com/lib/pq"
 )
@@ -30,4 +35,359 @@ func GetBackgroundJobStatus(jobID uint) (models.BackgroundJob, bool, error) {
 	}
 
 	return job, true, nil
-}
\ No newline at end of file
+}
+
+// GetBackgroundJobs gets up to limit jobs matching a filter, newest first,
+// skipping the first offset. hasMore is true when there are more jobs after
+// this page
+func GetBackgroundJobs(filter models.BackgroundJobFilter, limit uint, offset uint) ([]models.BackgroundJob, bool, error) {
+	connectionString := os.Getenv("DATABASE_URL")
+
+	db, err := sql.Open("postgres", connectionString)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Couldn't connect to db", err)
+
+		return nil, false, err
+	}
+	defer db.Close()
+
+	// get one extra job to find out if there is another page
+	jobs, err := models.GetBackgroundJobs(filter, limit + 1, offset, db)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if uint(len(jobs)) > limit {
+		return jobs[:limit], true, nil
+	}
+
+	return jobs, false, nil
+}
+
+// runningJobs are the jobs running in this process, which can be cancelled
+var runningJobs = struct {
+	sync.Mutex
+	jobs map[uint]runningJob
+}{jobs: make(map[uint]runningJob)}
+
+// runningJobKey is the key of the runningJob of a job's context
+type runningJobKey struct{}
+
+// runningJob cancels the context of an attempt of a job. done is closed once
+// the attempt has finished, and lost once its lease has been lost
+type runningJob struct {
+	attempt uint
+	cancel  context.CancelFunc
+	done    chan struct{}
+	lost    chan struct{}
+}
+
+// jobCancelWait is how long cancelling a job waits for it to stop
+var jobCancelWait = 10 * time.Second
+
+// jobHeartbeatInterval is how often a running job renews its lease, so a few
+// heartbeats can be missed before the lease expires
+const jobHeartbeatInterval = models.JobLease / 4
+
+// defaultMaxJobDuration is how long a job can run for when MAX_JOB_DURATION
+// isn't set
+const defaultMaxJobDuration = 6 * time.Hour
+
+// maxJobDuration is how long a job of a type without a timeout can run for
+// before it is stopped and failed
+var maxJobDuration = parseMaxJobDuration(os.Getenv("MAX_JOB_DURATION"))
+
+// parseMaxJobDuration parses a duration such as 90m or 6h, falling back to
+// defaultMaxJobDuration when it is empty or invalid
+func parseMaxJobDuration(value string) time.Duration {
+	if value == "" {
+		return defaultMaxJobDuration
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		fmt.Fprintln(os.Stderr, "Invalid MAX_JOB_DURATION, using", defaultMaxJobDuration, value)
+
+		return defaultMaxJobDuration
+	}
+
+	return duration
+}
+
+// jobRetryDelay is how long a job of a type waits before its first retry
+func jobRetryDelay(name string) time.Duration {
+	jobType, _ := getBackgroundJobType(name)
+
+	return jobType.retryDelay
+}
+
+// ErrJobNotRunning is returned when cancelling a job which has already finished
+var ErrJobNotRunning = errors.New("Job is not running")
+
+// startBackgroundJob gets the context of a claimed job and starts renewing the
+// lease of its attempt and saving its log. The context is cancelled when the
+// job is cancelled, loses its lease or has run for timeout
+func startBackgroundJob(claimed models.BackgroundJob, timeout time.Duration) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	ctx, jobLog := withJobLog(ctx, claimed.ID)
+	job := runningJob{attempt: claimed.Attempts, cancel: cancel, done: make(chan struct{}), lost: make(chan struct{})}
+	ctx = context.WithValue(ctx, runningJobKey{}, job)
+
+	runningJobs.Lock()
+	runningJobs.jobs[claimed.ID] = job
+	runningJobs.Unlock()
+
+	go heartbeatBackgroundJob(claimed.ID, job)
+	go jobLog.flushEvery(job.done)
+
+	return ctx
+}
+
+// stopRunningJob cancels the context of an attempt of a job once it has
+// finished. The job is only removed from runningJobs while it is this attempt,
+// as a job which lost its lease may have been claimed again by this process
+func stopRunningJob(jobID uint, job runningJob) {
+	if job.done == nil {
+		return
+	}
+
+	job.cancel()
+	close(job.done)
+
+	runningJobs.Lock()
+	if running, ok := runningJobs.jobs[jobID]; ok && running.attempt == job.attempt {
+		delete(runningJobs.jobs, jobID)
+	}
+	runningJobs.Unlock()
+}
+
+// heartbeatBackgroundJob renews the lease of a running job every
+// jobHeartbeatInterval until it finishes. The job is cancelled and its lease
+// lost once it is no longer RUNNING this attempt, such as when it was cancelled
+// by another process or queued again because its heartbeats were missed
+func heartbeatBackgroundJob(jobID uint, job runningJob) {
+	ticker := time.NewTicker(jobHeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+			case <-job.done:
+				return
+			case <-ticker.C:
+		}
+
+		db, err := sql.Open("postgres", os.Getenv("DATABASE_URL"))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Couldn't connect to db", err)
+			continue
+		}
+
+		running, err := models.HeartbeatBackgroundJob(jobID, job.attempt, db)
+		db.Close()
+
+		if err == nil && !running {
+			log.Println("Background job is no longer running, stopping it", jobID, job.attempt)
+
+			close(job.lost)
+			job.cancel()
+			return
+		}
+	}
+}
+
+// RecoverBackgroundJobs requeues the jobs left RUNNING by processes which have
+// stopped, or fails them when they have no attempts left, and keeps checking
+// for them every jobHeartbeatInterval. A job is recovered once it has missed
+// heartbeats for models.JobLease
+func RecoverBackgroundJobs() {
+	expireBackgroundJobs()
+
+	go func() {
+		for range time.Tick(jobHeartbeatInterval) {
+			expireBackgroundJobs()
+		}
+	}()
+}
+
+func expireBackgroundJobs() {
+	db, err := sql.Open("postgres", os.Getenv("DATABASE_URL"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Couldn't connect to db", err)
+		return
+	}
+	defer db.Close()
+
+	jobs, err := models.ExpireBackgroundJobs(db)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to recover background jobs", err)
+		return
+	}
+
+	for _, job := range jobs {
+		log.Println("Recovered background job left running by a stopped process", job.ID, job.Status)
+
+		if job.Status == "FAILED" {
+			notifyWebhooks(job.ID)
+		}
+	}
+}
+
+// finishBackgroundJob marks a job COMPLETE, FAILED with the reason when jobErr
+// isn't nil, or CANCELLED when it stopped because ctx was cancelled, and saves
+// its result and the rest of its log, sending webhooks the job once it has
+// finished. A job stopped for running longer than the timeout of its type is
+// FAILED. A job which failed with a transientError is queued again while it has
+// attempts left, unless it has been asked to cancel. A job whose lease was lost is left as it is, since it has
+// already been finished or queued again by another process
+func finishBackgroundJob(ctx context.Context, job models.BackgroundJob, result models.BackgroundJobResult, jobErr error) {
+	running, _ := ctx.Value(runningJobKey{}).(runningJob)
+	defer stopRunningJob(job.ID, running)
+
+	select {
+		case <-running.lost:
+			logJob(ctx, "WARN", "Job is no longer running this attempt, leaving it as it is", models.JobLogFields{"Attempt": job.Attempts})
+
+			if jobLog, ok := ctx.Value(jobLogKey{}).(*jobLog); ok {
+				jobLog.flush()
+			}
+
+			return
+		default:
+	}
+
+	status := "COMPLETE"
+	reason := ""
+	if jobErr != nil && ctx.Err() == context.Canceled {
+		status = "CANCELLED"
+	} else if jobErr != nil && ctx.Err() == context.DeadlineExceeded {
+		status = "FAILED"
+		jobType, _ := getBackgroundJobType(job.Type)
+		reason = fmt.Sprintf("Job exceeded the maximum duration of %v", jobTimeout(jobType))
+	} else if jobErr != nil {
+		status = "FAILED"
+		reason = jobErr.Error()
+	}
+
+	retry := status == "FAILED" && isTransientError(jobErr) && job.Attempts < job.MaxAttempts
+
+	if retry {
+		delay := retryDelay(jobRetryDelay(job.Type), job.Attempts)
+		logJob(ctx, "WARN", "Job failed, retrying", models.JobLogFields{"Attempt": job.Attempts, "RetryIn": delay.String(), "Error": reason})
+	} else if reason != "" {
+		logJob(ctx, "ERROR", "Job failed", models.JobLogFields{"Attempt": job.Attempts, "Error": reason})
+	} else {
+		logJob(ctx, "INFO", "Job finished", models.JobLogFields{"Attempt": job.Attempts, "Status": status})
+	}
+
+	// the log is saved before the job finishes so following it gets every entry
+	if jobLog, ok := ctx.Value(jobLogKey{}).(*jobLog); ok {
+		jobLog.flush()
+	}
+
+	connectionString := os.Getenv("DATABASE_URL")
+
+	db, err := sql.Open("postgres", connectionString)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Couldn't connect to db", err)
+		return
+	}
+
+	if retry {
+		delay := retryDelay(jobRetryDelay(job.Type), job.Attempts)
+
+		queued, err := models.RetryBackgroundJob(job.ID, job.Attempts, reason, delay, db)
+		if err != nil || queued {
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "Failed to retry background job")
+			}
+
+			db.Close()
+			return
+		}
+
+		// the job was asked to cancel while this attempt was failing, so it is
+		// cancelled instead of being queued again
+		status = "CANCELLED"
+		reason = ""
+	}
+
+	finished, err := models.FinishBackgroundJob(job.ID, job.Attempts, status, reason, result, db)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to update background job")
+	}
+
+	db.Close()
+
+	if finished {
+		notifyWebhooks(job.ID)
+	}
+}
+
+// CancelBackgroundJob cancels a queued or running job. A running job is waited
+// on for up to jobCancelWait to stop, rolling back the writes it hadn't
+// committed. The job is returned as it is after waiting, so is still RUNNING if
+// it hasn't stopped yet. found is false when there is no job with the id of
+// jobID and ErrJobNotRunning is returned when the job has already finished
+func CancelBackgroundJob(jobID uint) (models.BackgroundJob, bool, error) {
+	connectionString := os.Getenv("DATABASE_URL")
+
+	db, err := sql.Open("postgres", connectionString)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Couldn't connect to db", err)
+
+		return models.BackgroundJob{}, false, err
+	}
+	defer db.Close()
+
+	job, err := models.GetBackgroundJob(jobID, db)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return models.BackgroundJob{}, false, nil
+		}
+
+		return models.BackgroundJob{}, false, err
+	}
+
+	if job.Status != "QUEUED" && job.Status != "RUNNING" {
+		return job, true, ErrJobNotRunning
+	}
+
+	// the request is recorded first so an attempt which is failing at the same
+	// time isn't queued again, and a job running in another process stops at its
+	// next heartbeat
+	if err := models.RequestBackgroundJobCancel(jobID, db); err != nil {
+		return models.BackgroundJob{}, true, err
+	}
+
+	runningJobs.Lock()
+	running, ok := runningJobs.jobs[jobID]
+	runningJobs.Unlock()
+
+	if ok {
+		running.cancel()
+
+		select {
+			case <-running.done:
+			case <-time.After(jobCancelWait):
+		}
+	} else {
+		// the job is queued, running in another process which stops it once its
+		// next heartbeat finds it CANCELLED, or was left running by a process
+		// which has stopped
+		finished, err := models.CancelBackgroundJob(jobID, db)
+		if err != nil {
+			return models.BackgroundJob{}, true, err
+		}
+
+		if finished {
+			go notifyWebhooks(jobID)
+		}
+	}
+
+	job, err = models.GetBackgroundJob(jobID, db)
+	if err != nil {
+		return models.BackgroundJob{}, true, err
+	}
+
+	return job, true, nil
+}
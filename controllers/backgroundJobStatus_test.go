@@ -0,0 +1,42 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_parseMaxJobDuration(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  time.Duration
+	}{
+		{
+			name: "Defaults when MAX_JOB_DURATION isn't set",
+			value: "",
+			want: defaultMaxJobDuration,
+		},
+		{
+			name: "Parses a duration",
+			value: "90m",
+			want: 90 * time.Minute,
+		},
+		{
+			name: "Defaults when the duration is invalid",
+			value: "6 hours",
+			want: defaultMaxJobDuration,
+		},
+		{
+			name: "Defaults when the duration isn't positive",
+			value: "-1h",
+			want: defaultMaxJobDuration,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseMaxJobDuration(tt.value); got != tt.want {
+				t.Errorf("parseMaxJobDuration() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,494 +1,600 @@
 package controllers
 
 import (
+	"archive/zip"
+	"context"
 	"server/utils"
 	"server/models"
+	"server/types"
 	"encoding/json"
 	"io/ioutil"
 	"time"
 	"net/url"
+	"net/http"
 	"strings"
 	"os"
-	"log"
 	"fmt"
-	"encoding/xml"
 	"strconv"
 	"errors"
-	"database/sql"
 	_ "github.com/lib/pq"
 )
 
-func GetRoute(lineName string, direction string, operatorID string) (models.Route, error) {
-	route, err := models.GetBusRoute(lineName, direction, operatorID)
+// GetRoute gets every route variant of a line in a direction
+func GetRoute(lineName string, direction string, operatorID string) ([]models.Route, error) {
+	routes, err := models.GetBusRoute(lineName, direction, operatorID)
 	if err != nil {
-		return models.Route{}, err
+		return nil, err
 	}
 
-	return route, nil
+	return routes, nil
 }
 
-func UpdateRoute(datasetID uint, httpClient httpClient, busRoute models.BusRoute) (models.BackgroundJob, error) {
-	// start a job
-	connectionString := os.Getenv("DATABASE_URL")
-
-	db, err := sql.Open("postgres", connectionString)
+// GetSimplifiedRoute gets every route variant of a line in a direction with the
+// stops of each route replaced by a path of their coordinates, simplified so no
+// stop is further than tolerance metres from it. When zoom is set the tolerance
+// is the width of a pixel at that zoom level instead. The path is encoded as a
+// polyline when encodePolyline is set
+func GetSimplifiedRoute(lineName string, direction string, operatorID string, tolerance float64, zoom *uint, encodePolyline bool) ([]models.Route, error) {
+	routes, err := GetRoute(lineName, direction, operatorID)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Couldn't connect to db", err)
-		return models.BackgroundJob{}, err
+		return nil, err
 	}
-	defer db.Close()
 
-	job, err := models.CreateBackgroundJob("UPDATE ROUTES BY DATASET ID", db)
-	if err != nil {
-		return models.BackgroundJob{}, err
-	}
+	for routeIndex, route := range routes {
+		path := make([]types.Coordinate, 0)
+		for _, busStop := range route.Stops {
+			path = append(path, types.Coordinate{Longitude: busStop.Longitude, Latitude: busStop.Latitude})
+		}
+
+		routeTolerance := tolerance
+		if zoom != nil && len(path) > 0 {
+			routeTolerance = utils.ZoomTolerance(*zoom, path[0].Latitude)
+		}
+
+		path = utils.Simplify(path, routeTolerance)
 
-	go backgroundJobWrapper(datasetID, job.ID, httpClient, busRoute)
+		routes[routeIndex].Stops = nil
+		if encodePolyline {
+			routes[routeIndex].Polyline = utils.EncodePolyline(path)
+		} else {
+			routes[routeIndex].Path = path
+		}
+	}
 
-	return job, nil
+	return routes, nil
 }
 
-func backgroundJobWrapper(datasetID uint, jobID uint, httpClient httpClient, busRoute models.BusRoute) {
-	connectionString := os.Getenv("DATABASE_URL")
-	status := "COMPLETE"
+// updateRouteParameters are the parameters of an UPDATE ROUTES BY DATASET ID
+// job
+type updateRouteParameters struct {
+	DatasetID uint
+	Force     bool
+}
 
-	if err := updateRouteByDataset(datasetID, httpClient, busRoute); err != nil {
-		status = "FAILED"
+func (parameters *updateRouteParameters) validate() error {
+	if parameters.DatasetID == 0 {
+		return errors.New("DatasetID must be a positive integer")
 	}
 
-	db, err := sql.Open("postgres", connectionString)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "Couldn't connect to db", err)
-		return
-	}
-	defer db.Close()
+	return nil
+}
+
+// uniqueKey lets datasets import in parallel, while a dataset is only imported
+// by one job at a time
+func (parameters *updateRouteParameters) uniqueKey() string {
+	return fmt.Sprintf("DatasetID=%d", parameters.DatasetID)
+}
 
-	if err := models.UpdateBackgroundJob(jobID, status, db); err != nil {
-		fmt.Fprintln(os.Stderr, "Failed to update background job")
+// UpdateRoute queues a job updating the routes of a dataset. The dataset is
+// skipped without being downloaded when it hasn't been modified since it was
+// imported, unless force is set
+func UpdateRoute(datasetID uint, force bool) (models.BackgroundJob, error) {
+	return enqueueBackgroundJob(updateRouteJobType, &updateRouteParameters{
+		DatasetID: datasetID,
+		Force: force,
+	})
+}
+
+func runUpdateRoute(ctx context.Context, job models.BackgroundJob, jobDatasets models.JobDataset) (models.BackgroundJobResult, error) {
+	var parameters updateRouteParameters
+	if err := json.Unmarshal(job.Parameters, &parameters); err != nil {
+		return nil, fmt.Errorf("Invalid job parameters: %v", err)
 	}
+
+	httpClient := contextHTTPClient{ctx: ctx, client: &http.Client{}}
+	result := models.BackgroundJobResult{}
+	err := updateRouteByDataset(ctx, parameters.DatasetID, parameters.Force, httpClient, &models.BusRoutes{}, jobDatasets, result)
+
+	return result, err
 }
 
-func updateRouteByDataset(datasetID uint, httpClient httpClient, busRoute models.BusRoute) error {
+func updateRouteByDataset(ctx context.Context, datasetID uint, force bool, httpClient httpClient, busRoute models.BusRoute, jobDatasets models.JobDataset, result models.BackgroundJobResult) error {
+	jobDatasets.UpdateProgress(models.BackgroundJobProgress{Phase: "download"})
+
 	baseUrl := "https://data.bus-data.dft.gov.uk/api/v1/dataset"
 	v := url.Values{}
 	v.Set("api_key", os.Getenv("DFT_SECRET"))
 
 	datasetIDString := strconv.FormatUint(uint64(datasetID), 10)
 
-	log.Println(baseUrl + "/" + datasetIDString + "?" + v.Encode())
+	logJob(ctx, "INFO", "Getting dataset", models.JobLogFields{"DatasetID": datasetID})
 
 	resp, err := httpClient.Get(baseUrl + "/" + datasetIDString + "?" + v.Encode())
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Couldn't get dft timetable", err)
+		logJob(ctx, "ERROR", "Couldn't get dft timetable", models.JobLogFields{"DatasetID": datasetID, "Error": err.Error()})
 
-		return err
+		return transientError{err: err}
 	}
 
 	if resp.StatusCode != 200 {
-		fmt.Fprintln(os.Stderr, "DFT returned non 200 status of: ", resp.StatusCode)
-		return errors.New("DFT returned non 200 status")
+		resp.Body.Close()
+
+		logJob(ctx, "ERROR", "DFT returned non 200 status", models.JobLogFields{"DatasetID": datasetID, "StatusCode": resp.StatusCode})
+		return dftStatusError(resp.StatusCode)
 	}
 
 	defer resp.Body.Close()
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Failed to read DFT response", err)
+		logJob(ctx, "ERROR", "Failed to read DFT response", models.JobLogFields{"DatasetID": datasetID, "Error": err.Error()})
 		return errors.New("Failed to read DFT response")
 	}
 
 	timetable := timetableResults{}
 	if err := json.Unmarshal(body, &timetable); err != nil {
-		fmt.Fprintln(os.Stderr, "Unmarshal failed", err)
+		logJob(ctx, "ERROR", "Unmarshal failed", models.JobLogFields{"DatasetID": datasetID, "Error": err.Error()})
 		return err
 	}
 
-	if strings.ToUpper(timetable.Extension) != "ZIP" {
-		fmt.Fprintln(os.Stderr, "Folder extension was not ZIP")
-		return errors.New("Folder extension was not ZIP")
+	if err := jobDatasets.AddDatasets([]uint{ timetable.ID }); err != nil {
+		return err
 	}
 
-	return parseTimetables([]string{ timetable.URL }, httpClient, busRoute)
+	jobDatasets.UpdateProgress(models.BackgroundJobProgress{Phase: "import", Total: 1})
+
+	if err := updateDataset(ctx, timetable, force, time.Time{}, httpClient, busRoute, jobDatasets, result); err != nil {
+		return err
+	}
+
+	jobDatasets.UpdateProgress(models.BackgroundJobProgress{Phase: "import", Processed: 1, Total: 1})
+
+	return nil
+}
+
+// updateRoutesParameters are the parameters of an UPDATE ALL PUBLISHED ROUTES
+// job
+type updateRoutesParameters struct {
+	NOC       string `json:",omitempty"`
+	AdminArea string `json:",omitempty"`
+	Force     bool
+}
+
+func (*updateRoutesParameters) validate() error {
+	return nil
+}
+
+// UpdateRoutes queues a job updating the routes of every published BODS
+// timetable dataset, optionally filtered by operator NOC and admin area.
+// Datasets which haven't been modified since they were imported are skipped,
+// unless force is set
+func UpdateRoutes(noc string, adminArea string, force bool) (models.BackgroundJob, error) {
+	return enqueueBackgroundJob(updateRoutesJobType, &updateRoutesParameters{
+		NOC: noc,
+		AdminArea: adminArea,
+		Force: force,
+	})
 }
 
-func UpdateRoutes(offset uint, limit uint, httpClient httpClient, busRoute models.BusRoute) error {
+func runUpdateRoutes(ctx context.Context, job models.BackgroundJob, jobDatasets models.JobDataset) (models.BackgroundJobResult, error) {
+	var parameters updateRoutesParameters
+	if err := json.Unmarshal(job.Parameters, &parameters); err != nil {
+		return nil, fmt.Errorf("Invalid job parameters: %v", err)
+	}
+
+	httpClient := contextHTTPClient{ctx: ctx, client: &http.Client{}}
+	result := models.BackgroundJobResult{}
+	err := updateRoutes(
+		ctx, parameters.NOC, parameters.AdminArea, parameters.Force, job.CreatedAt,
+		httpClient, &models.BusRoutes{}, jobDatasets, result,
+	)
+
+	return result, err
+}
+
+const datasetPageLimit = 100
+
+// datasetRequestDelay is the pause between downloading datasets to stay within
+// the BODS rate limit
+var datasetRequestDelay = 2 * time.Second
+
+// updateRoutes imports every published dataset, adding the datasets and rows
+// imported to result. Datasets imported before ctx is cancelled are kept, and
+// datasets imported since resumeFrom are skipped even when force is set, so a
+// retried job carries on from the datasets its earlier attempts imported
+func updateRoutes(ctx context.Context, noc string, adminArea string, force bool, resumeFrom time.Time, httpClient httpClient, busRoute models.BusRoute, jobDatasets models.JobDataset, result models.BackgroundJobResult) error {
 	t := time.Now()
 	timeString := fmt.Sprintf("%d-%02d-%02dT00:00:00", t.Year(), t.Month(), t.Day())
 
-	baseUrl := "https://data.bus-data.dft.gov.uk/api/v1/dataset"
+	baseUrl := "https://data.bus-data.dft.gov.uk/api/v1/dataset/"
 	v := url.Values{}
 	v.Set("api_key", os.Getenv("DFT_SECRET"))
-	v.Set("limit", fmt.Sprint(offset))
-	v.Set("offset", fmt.Sprint(limit))
+	v.Set("limit", fmt.Sprint(datasetPageLimit))
+	v.Set("offset", "0")
 	v.Set("status", "published")
 	v.Set("startDateEnd", timeString)
 	v.Set("endDateStart", timeString)
 
-	resp, err := httpClient.Get(baseUrl + "?" + v.Encode())
+	if noc != "" {
+		v.Set("noc", noc)
+	}
+	if adminArea != "" {
+		v.Set("adminArea", adminArea)
+	}
+
+	jobDatasets.UpdateProgress(models.BackgroundJobProgress{Phase: "list"})
+
+	datasets, err := getDatasets(baseUrl + "?" + v.Encode(), httpClient)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Couldn't get dft timetable", err)
+		logJob(ctx, "ERROR", "Failed to list datasets", models.JobLogFields{"Error": err.Error()})
 		return err
 	}
 
-	if resp.StatusCode != 200 {
-		fmt.Fprintln(os.Stderr, "DFT returned non 200 status of: ", resp.StatusCode)
-		return errors.New("DFT returned non 200 status")
-	}
+	logJob(ctx, "INFO", "Listed published datasets", models.JobLogFields{"Datasets": len(datasets), "NOC": noc, "AdminArea": adminArea})
 
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "Failed to read DFT response", err)
-		return errors.New("Failed to read DFT response")
+	datasetIDs := make([]uint, 0)
+	for _, dataset := range datasets {
+		datasetIDs = append(datasetIDs, dataset.ID)
 	}
 
-	timetable := timetableResponse{}
-	if err := json.Unmarshal(body, &timetable); err != nil {
-		fmt.Fprintln(os.Stderr, "Unmarshal failed", err)
+	if err := jobDatasets.AddDatasets(datasetIDs); err != nil {
 		return err
 	}
 
-	timetableUrls := make([]string, 0)
-	for _, result := range timetable.Results {
-		if strings.ToUpper(result.Extension) == "ZIP" {
-			timetableUrls = append(timetableUrls, result.URL)
-		}
-	}
+	failedDatasets := 0
+	for datasetIndex, dataset := range datasets {
+		jobDatasets.UpdateProgress(models.BackgroundJobProgress{
+			Phase: "import",
+			Processed: uint(datasetIndex),
+			Total: uint(len(datasets)),
+		})
 
-	return parseTimetables(timetableUrls, httpClient, busRoute)
-}
+		if datasetIndex > 0 {
+			select {
+				case <-time.After(datasetRequestDelay):
+				case <-ctx.Done():
+			}
+		}
 
-func parseTimetables(timetableURIs []string, httpClient httpClient, busRoute models.BusRoute) error {
-	for _, timetableURL := range timetableURIs {
-		zippedFolder, err := getTimetable(timetableURL, httpClient)
-		if err != nil {
+		if err := ctx.Err(); err != nil {
 			return err
 		}
 
-		zippedFiles, err := utils.UnZipFile(zippedFolder)
-		if err != nil {
-			fmt.Fprintln(os.Stderr, "Failed to unzip folder", err)
-
-			return err
+		if err := updateDataset(ctx, dataset, force, resumeFrom, httpClient, busRoute, jobDatasets, result); err != nil {
+			failedDatasets++
 		}
+	}
 
-		for _, zippedFile := range zippedFiles {
-			rawFile, err := zippedFile.Open()
-			if err != nil {
-				fmt.Fprintln(os.Stderr, "Failed to open file", err)
+	jobDatasets.UpdateProgress(models.BackgroundJobProgress{
+		Phase: "import",
+		Processed: uint(len(datasets)),
+		Total: uint(len(datasets)),
+	})
 
-				return err
-			}
+	if failedDatasets > 0 {
+		return fmt.Errorf("%d of %d datasets failed to update", failedDatasets, len(datasets))
+	}
 
-			file, err := ioutil.ReadAll(rawFile)
-			if err != nil {
-				rawFile.Close()
+	return nil
+}
 
-				fmt.Fprintln(os.Stderr, "Failed to read file", err)
+// updateDataset imports a single dataset recording its progress and any
+// skipped files or records on the job, and counts the dataset and the rows it
+// wrote in result. A dataset whose BODS modified time is the modified time of
+// the version last imported is skipped without being downloaded, unless force
+// is set and the version wasn't imported since resumeFrom. A zero resumeFrom
+// never skips a forced import
+func updateDataset(ctx context.Context, dataset timetableResults, force bool, resumeFrom time.Time, httpClient httpClient, busRoute models.BusRoute, jobDatasets models.JobDataset, result models.BackgroundJobResult) error {
+	jobDatasets.UpdateDataset(dataset.ID, "RUNNING")
+
+	if strings.ToUpper(dataset.Extension) != "ZIP" {
+		logJob(ctx, "ERROR", "Folder extension was not ZIP", models.JobLogFields{"DatasetID": dataset.ID, "Extension": dataset.Extension})
+
+		jobDatasets.UpdateDataset(dataset.ID, "FAILED")
+		result["FailedDatasets"]++
+		return fmt.Errorf("Dataset %d folder extension was not ZIP", dataset.ID)
+	}
 
-				return err
-			}
-			rawFile.Close()
+	modified, err := time.Parse(time.RFC3339, dataset.Modified)
+	if err != nil {
+		logJob(ctx, "ERROR", "Invalid dataset modified time", models.JobLogFields{"DatasetID": dataset.ID, "Error": err.Error()})
 
-			transXChange, err := parseTransXChange(2.4, file)
-			if err != nil {
-				return err
-			}
+		jobDatasets.UpdateDataset(dataset.ID, "FAILED")
+		result["FailedDatasets"]++
+		return fmt.Errorf("Dataset %d has an invalid modified time", dataset.ID)
+	}
 
-			if err := updateRouteTable(transXChange, busRoute); err != nil {
-				fmt.Fprintln(os.Stderr, "Failed to update tables", err)
-				return err
-			}
+	if !force || !resumeFrom.IsZero() {
+		importedDataset, found, err := busRoute.GetDataset(dataset.ID)
+		if err != nil {
+			logJob(ctx, "WARN", "Failed to get imported dataset", models.JobLogFields{"DatasetID": dataset.ID, "Error": err.Error()})
+		} else if found && importedDataset.Modified.Equal(modified) && !force {
+			logJob(ctx, "INFO", "Dataset hasn't been modified since it was imported", models.JobLogFields{"DatasetID": dataset.ID, "Modified": dataset.Modified})
+
+			jobDatasets.UpdateDataset(dataset.ID, "SKIPPED")
+			result["SkippedDatasets"]++
+			return nil
+		} else if found && importedDataset.Modified.Equal(modified) && !importedDataset.ImportedAt.Before(resumeFrom) {
+			logJob(ctx, "INFO", "Dataset was imported since the job was created", models.JobLogFields{"DatasetID": dataset.ID, "ImportedAt": importedDataset.ImportedAt})
+
+			jobDatasets.UpdateDataset(dataset.ID, "SKIPPED")
+			result["SkippedDatasets"]++
+			return nil
 		}
-
-		time.Sleep(2 * time.Second)
 	}
 
-	return nil
-}
+	logJob(ctx, "INFO", "Importing dataset", models.JobLogFields{"DatasetID": dataset.ID, "Name": dataset.Name})
 
-func updateRouteTable(transXChange parsedTransXChange, busRoute models.BusRoute) error {
-	if err := busRoute.InsertOperators(transXChange.operators); err != nil {
-		return err
-	}
-	if err := busRoute.InsertLines(transXChange.lines); err != nil {
-		return err
+	importErrors, err := parseTimetable(ctx, dataset, modified, httpClient, busRoute, result)
+
+	if len(importErrors) > 0 {
+		logJob(ctx, "WARN", "Skipped files and records of dataset", models.JobLogFields{"DatasetID": dataset.ID, "ImportErrors": len(importErrors)})
+
+		if err := jobDatasets.AddImportErrors(importErrors); err != nil {
+			logJob(ctx, "ERROR", "Failed to save import errors", models.JobLogFields{"DatasetID": dataset.ID, "Error": err.Error()})
+		}
 	}
-	if err := busRoute.InsertJourneys(transXChange.journeys); err != nil {
-		return err
+
+	if err != nil && ctx.Err() != nil {
+		logJob(ctx, "WARN", "Dataset import cancelled", models.JobLogFields{"DatasetID": dataset.ID})
+
+		jobDatasets.UpdateDataset(dataset.ID, "CANCELLED")
+		return ctx.Err()
 	}
-	if err := busRoute.InsertJourneyStops(transXChange.journeyStops); err != nil {
-		return err
+
+	if err != nil {
+		logJob(ctx, "ERROR", "Failed to update dataset", models.JobLogFields{"DatasetID": dataset.ID, "Error": err.Error()})
+
+		jobDatasets.UpdateDataset(dataset.ID, "FAILED")
+		result["FailedDatasets"]++
+		return fmt.Errorf("Dataset %d failed to import: %w", dataset.ID, err)
 	}
 
+	logJob(ctx, "INFO", "Imported dataset", models.JobLogFields{"DatasetID": dataset.ID})
+
+	jobDatasets.UpdateDataset(dataset.ID, "COMPLETE")
+	result["CompleteDatasets"]++
+
 	return nil
 }
 
-func getTimetable(url string, httpClient httpClient) ([]byte, error) {
-	resp, err := httpClient.Get(url)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "Couldn't get dft timetable", err)
+// getDatasets gets every dataset from the BODS dataset listing starting at
+// pageURL and following the next page until there are none left
+func getDatasets(pageURL string, httpClient httpClient) ([]timetableResults, error) {
+	datasets := make([]timetableResults, 0)
 
-		return nil, err
-	}
+	for pageURL != "" {
+		resp, err := httpClient.Get(pageURL)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Couldn't get dft timetable", err)
+			return nil, transientError{err: err}
+		}
 
-	defer resp.Body.Close()
+		if resp.StatusCode != 200 {
+			resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		fmt.Fprintln(os.Stderr, "DFT returned non 200 status of: ", resp.StatusCode)
-		return nil, errors.New("DFT returned non 200 status")
-	}
+			fmt.Fprintln(os.Stderr, "DFT returned non 200 status of: ", resp.StatusCode)
+			return nil, dftStatusError(resp.StatusCode)
+		}
 
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "Failed to read DFT response", err)
-		return nil, errors.New("Failed to read DFT response")
-	}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to read DFT response", err)
+			return nil, errors.New("Failed to read DFT response")
+		}
 
-	return body, nil
-}
+		timetable := timetableResponse{}
+		if err := json.Unmarshal(body, &timetable); err != nil {
+			fmt.Fprintln(os.Stderr, "Unmarshal failed", err)
+			return nil, err
+		}
 
-type timetableResponse struct {
-	Count    uint               `json:"count"`
-	Next     string             `json:"next"`
-	Previous string             `json:"previous"`
-	Results  []timetableResults `json:"results"`
-}
+		datasets = append(datasets, timetable.Results...)
+		pageURL = timetable.Next
+	}
 
-type timetableResults struct {
-	ID             uint   `json:"id"`
-	Created        string `json:"created"`
-	Modified       string `json:"modified"`
-	OperatorName   string `json:"operatorName"`
-	Name           string `json:"name"`
-	Description    string `json:"description"`
-	Comment        string `json:"comment"`
-	Status         string `json:"status"`
-	URL            string `json:"url"`
-	Extension      string `json:"extension"`
-	FirstStartDate string `json:"firstStartDate"`
-	FirstEndDate   string `json:"firstEndDate"`
-	LastEndDate    string `json:"lastEndDate"`
+	return datasets, nil
 }
 
-type parsedTransXChange struct {
-	operators    []models.Operator
-	lines        []models.Line
-	journeys     []models.Journey
-	journeyStops []models.JourneyStop
-}
+// parseTimetable downloads a dataset, decodes each TransXChange file within it
+// and replaces the previous version of the dataset in a single transaction.
+// Files and records which can't be imported are skipped and returned as import
+// errors. An error is returned when the dataset can't be read or written, in
+// which case the previous version of the dataset is left unchanged. The rows
+// written are added to result. Nothing is written if ctx is cancelled
+func parseTimetable(ctx context.Context, dataset timetableResults, modified time.Time, httpClient httpClient, busRoute models.BusRoute, result models.BackgroundJobResult) ([]models.ImportError, error) {
+	importErrors := make([]models.ImportError, 0)
 
-func parseTransXChange(version float32, rawXML []byte) (parsedTransXChange, error) {
-	if version != 2.4 {
-		return parsedTransXChange{}, errors.New("Unsupported transXChange version. Supports: 2.4")
+	zippedFolder, err := getTimetable(dataset.URL, httpClient)
+	if err != nil {
+		return importErrors, err
 	}
 
-	var transXChange transXChange
-	err := xml.Unmarshal(rawXML, &transXChange)
+	zippedFiles, err := utils.UnZipFile(zippedFolder)
 	if err != nil {
-		return parsedTransXChange{}, err
-	}
+		logJob(ctx, "ERROR", "Failed to unzip folder", models.JobLogFields{"DatasetID": dataset.ID, "Error": err.Error()})
 
-	if version != transXChange.Version {
-		return parsedTransXChange{}, errors.New("Incorrect transXChange version")
+		return importErrors, err
 	}
 
-	// operators
-	operators := make([]models.Operator, 0)
-	for _, operator := range transXChange.Operators {
-		operators = append(operators, models.Operator{
-			ID: operator.OperatorID,
-			Name: operator.Name,
-			ShortName: operator.ShortName,
-		})
+	timetable := models.Timetable{
+		Dataset: models.Dataset{
+			ID: dataset.ID,
+			Name: dataset.Name,
+			OperatorName: dataset.OperatorName,
+			Modified: modified,
+		},
 	}
 
-	// services and lines
-	lines := make([]models.Line, 0)
-	journeys := make([]models.Journey, 0)
-	for _, service := range transXChange.Services {
-		// Use the LocalOperatorID to get the operatorID
-		operatorID := ""
-		operatorIndex := 0
-		for operatorID == "" && operatorIndex < len(transXChange.Operators) {
-			if transXChange.Operators[operatorIndex].LocalID == service.LocalOperatorID {
-				operatorID = transXChange.Operators[operatorIndex].OperatorID
-			}
-
-			operatorIndex++
+	for _, zippedFile := range zippedFiles {
+		if err := ctx.Err(); err != nil {
+			return importErrors, err
 		}
 
-		if operatorID == "" {
-			fmt.Fprintln(os.Stderr, "Couldn't find a operatorID", transXChange.FileName)
+		transXChange, err := parseTimetableFile(zippedFile)
+		if err != nil {
+			logJob(ctx, "WARN", "Skipped TransXChange file", models.JobLogFields{"DatasetID": dataset.ID, "FileName": zippedFile.Name, "Error": err.Error()})
 
-			return parsedTransXChange{}, errors.New("Couldn't find a operatorID ")
+			importErrors = append(importErrors, models.ImportError{
+				DatasetID: dataset.ID,
+				FileName: zippedFile.Name,
+				Reason: err.Error(),
+			})
+
+			continue
 		}
 
-		lines = append(lines, models.Line{
-			ID:         service.Line.ID,
-			OperatorID: operatorID,
-			Name:       service.Line.Name,
-		})
+		for _, importError := range transXChange.importErrors {
+			importError.DatasetID = dataset.ID
+			importError.FileName = zippedFile.Name
 
-		for _, journeyPattern := range service.JourneyPattern {
-			journeys = append(journeys, models.Journey{
-				LineID: service.Line.ID,
-				RouteID: journeyPattern.RouteID,
-				Direction: strings.ToUpper(journeyPattern.Direction),
-				Description: journeyPattern.Description,
-			})
+			importErrors = append(importErrors, importError)
 		}
-	}
 
-	journeyStops := make([]models.JourneyStop, 0)
-	for _, routeSection := range transXChange.RouteSections {
-		// get a route ID
-		var routeID string
-		routeIndex := 0
-		for routeID == "" && routeIndex < len(transXChange.Routes) {
-			if transXChange.Routes[routeIndex].RouteSectionID == routeSection.ID {
-				routeID = transXChange.Routes[routeIndex].ID
-			}
+		for _, journeyStop := range transXChange.journeyStops {
+			journeyStop.LineID = datasetLineID(dataset.ID, journeyStop.LineID)
+			journeyStop.FileName = zippedFile.Name
 
-			routeIndex++
+			timetable.JourneyStops = append(timetable.JourneyStops, journeyStop)
 		}
 
-		if routeID == "" {
-			fmt.Fprintln(os.Stderr, "Couldn't find a routeID", routeSection.ID, transXChange.FileName)
+		for _, line := range transXChange.lines {
+			line.ID = datasetLineID(dataset.ID, line.ID)
+			line.FileName = zippedFile.Name
 
-			return parsedTransXChange{}, errors.New("Couldn't find a routeID ")
+			timetable.Lines = append(timetable.Lines, line)
 		}
 
-		// find the LineID from the Journey with the same RouteID
-		var lineID string
-		journeyIndex := 0
-		for lineID == "" && journeyIndex < len(journeys) {
-			if journeys[journeyIndex].RouteID == routeID {
-				lineID = journeys[journeyIndex].LineID
-			}
+		for _, journey := range transXChange.journeys {
+			journey.LineID = datasetLineID(dataset.ID, journey.LineID)
+			journey.FileName = zippedFile.Name
 
-			journeyIndex++
+			timetable.Journeys = append(timetable.Journeys, journey)
 		}
 
-		if lineID == "" {
-			fmt.Fprintln(os.Stderr, "Couldn't find a LineID", routeSection.ID, transXChange.FileName)
+		for _, trip := range transXChange.trips {
+			trip.LineID = datasetLineID(dataset.ID, trip.LineID)
+			trip.FileName = zippedFile.Name
 
-			return parsedTransXChange{}, errors.New("Couldn't find a LineID ")
+			timetable.Trips = append(timetable.Trips, trip)
 		}
 
-		previousStopDestination := ""
-		for routeIndex, routeLink := range routeSection.RouteLinks {
-			if routeIndex > 0 && routeLink.From != previousStopDestination {
-				fmt.Fprintln(os.Stderr, "Previous stop destination does not match current stop", transXChange.FileName)
+		// The dataset revision is the latest revision of any of its files
+		if transXChange.revision > timetable.Dataset.Revision {
+			timetable.Dataset.Revision = transXChange.revision
+		}
 
-				return parsedTransXChange{}, errors.New("Previous stop destination does not match current stop")
-			}
+		timetable.Operators = append(timetable.Operators, transXChange.operators...)
+		timetable.Files = append(timetable.Files, models.TimetableFile{
+			DatasetID: dataset.ID,
+			FileName: zippedFile.Name,
+			SchemaVersion: transXChange.schemaVersion,
+			Revision: transXChange.revision,
+			CreatedAt: transXChange.createdAt,
+			ModifiedAt: transXChange.modifiedAt,
+		})
+	}
 
-			journeyStops = append(journeyStops, models.JourneyStop{
-				LineID: lineID,
-				RouteID: routeID,
-				StopNumber: uint(routeIndex),
-				BusStopID: routeLink.From,
-			})
+	rejectedStops, err := busRoute.ReplaceDataset(ctx, dataset.ID, timetable)
+	if err != nil {
+		logJob(ctx, "ERROR", "Failed to replace dataset", models.JobLogFields{"DatasetID": dataset.ID, "Error": err.Error()})
 
-			if routeIndex == len(routeSection.RouteLinks) - 1 {
-				journeyStops = append(journeyStops, models.JourneyStop{
-					LineID: lineID,
-					RouteID: routeID,
-					StopNumber: uint(routeIndex + 1),
-					BusStopID: routeLink.To,
-				})
-			}
-			previousStopDestination = routeLink.To
+		if isDatabaseConflict(err) {
+			return importErrors, transientError{err: err}
 		}
+
+		return importErrors, err
 	}
 
-	return parsedTransXChange{
-		operators:    operators,
-		lines:        lines,
-		journeys:     journeys,
-		journeyStops: journeyStops,
-	}, nil
-}
+	result["Lines"] += uint(len(timetable.Lines))
+	result["Journeys"] += uint(len(timetable.Journeys))
+	result["JourneyStops"] += uint(len(timetable.JourneyStops) - len(rejectedStops))
+	result["Trips"] += uint(len(timetable.Trips))
 
-// TransXChange
-type transXChange struct {
-	XMLName       xml.Name                   `xml:"TransXChange"`
-	FileName      string                     `xml:"FileName"` // used for debugging
-	CreatedAt     string                     `xml:"CreationDateTime,attr"`
-	UpdatedAt     string                     `xml:"ModificationDateTime,attr"`
-	Version       float32                    `xml:"SchemaVersion,attr"`
-	Revision      uint                       `xml:"RevisionNumber,attr"`
-	RouteSections []transXChangeRouteSection `xml:"RouteSections>RouteSection"`
-	Routes        []transXChangeRoute        `xml:"Routes>Route"`
-	Operators     []transXChangeOperator     `xml:"Operators>Operator"`
-	Services      []transXChangeService      `xml:"Services>Service"`
+	return append(importErrors, rejectedStops...), nil
 }
 
-type transXChangeRoute struct {
-	XML            xml.Name `xml:"Route"`
-	ID             string   `xml:"id,attr"`
-	CreatedAt      string   `xml:"CreationDateTime,attr"`
-	UpdatedAt      string   `xml:"ModificationDateTime,attr"`
-	Revision       uint     `xml:"RevisionNumber,attr"`
-	RouteSectionID string   `xml:"RouteSectionRef"`
-	Description    string   `xml:"Description"`
+// datasetLineID prefixes the ID of a line with the dataset it was imported
+// from. Datasets can publish the same line, so without the prefix importing one
+// dataset would take over the lines, journeys and journey stops of another
+func datasetLineID(datasetID uint, lineID string) string {
+	return fmt.Sprintf("%d:%s", datasetID, lineID)
 }
 
-type transXChangeRouteSection struct {
-	XML        xml.Name                `xml:"RouteSection"`
-	ID         string                  `xml:"id,attr"`
-	RouteLinks []transXChangeRouteLink `xml:"RouteLink"`
-}
+// parseTimetableFile decodes a single TransXChange file
+func parseTimetableFile(zippedFile *zip.File) (parsedTransXChange, error) {
+	rawFile, err := zippedFile.Open()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to open file", err)
 
-type transXChangeRouteLink struct {
-	XML  xml.Name `xml:"RouteLink"`
-	From string   `xml:"From>StopPointRef"`
-	To   string   `xml:"To>StopPointRef"`
-}
+		return parsedTransXChange{}, errors.New("Failed to open file")
+	}
 
-type transXChangeOperator struct {
-	XML        xml.Name `xml:"Operator"`
-	OperatorID string   `xml:"NationalOperatorCode"`
-	LocalID    string   `xml:"id,attr"` // local ID to this file
-	ShortName  string   `xml:"OperatorShortName"`
-	Name       string   `xml:"TradingName"`
-}
+	file, err := ioutil.ReadAll(rawFile)
+	rawFile.Close()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to read file", err)
 
-type transXChangeService struct {
-	XML             xml.Name                     `xml:"Service"`
-	LocalOperatorID string                       `xml:"RegisteredOperatorRef"`
-	Line            transXChangeServiceLine    	 `xml:"Lines>Line"`
-	Origin          string                       `xml:"StandardService>Origin"`
-	Destination     string                       `xml:"StandardService>Destination"`
-	JourneyPattern  []transXChangeJourneyPattern `xml:"StandardService>JourneyPattern"`
-}
+		return parsedTransXChange{}, errors.New("Failed to read file")
+	}
 
-type transXChangeServiceLine struct {
-	XML      xml.Name	                       `xml:"Line"`
-	ID       string                          `xml:"id,attr"`
-	Name     string                          `xml:"LineName"`
-	OutBound transXChangeServiceLineOutBound `xml:"OutboundDescription"`
-	InBound  transXChangeServiceLineInBound  `xml:"InboundDescription"`
+	return parseTransXChange(file)
 }
 
-type transXChangeServiceLineOutBound struct {
-	XML         xml.Name `xml:"OutboundDescription"`
-	Origin      string   `xml:"Origin"`
-	Destination string   `xml:"Destination"`
-	Description string   `xml:"Description"`
+func getTimetable(url string, httpClient httpClient) ([]byte, error) {
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Couldn't get dft timetable", err)
+
+		return nil, transientError{err: err}
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		fmt.Fprintln(os.Stderr, "DFT returned non 200 status of: ", resp.StatusCode)
+		return nil, dftStatusError(resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to read DFT response", err)
+		return nil, errors.New("Failed to read DFT response")
+	}
+
+	return body, nil
 }
 
-type transXChangeServiceLineInBound struct {
-	XML         xml.Name `xml:"InboundDescription"`
-	Origin      string   `xml:"Origin"`
-	Destination string   `xml:"Destination"`
-	Description string   `xml:"Description"`
+type timetableResponse struct {
+	Count    uint               `json:"count"`
+	Next     string             `json:"next"`
+	Previous string             `json:"previous"`
+	Results  []timetableResults `json:"results"`
 }
 
-type transXChangeJourneyPattern struct {
-	XML                xml.Name `xml:"JourneyPattern"`
-	DestinationDisplay string   `xml:"DestinationDisplay"`
-	Direction          string   `xml:"Direction"`
-	Description        string   `xml:"Description"`
-	RouteID            string   `xml:"RouteRef"`
+type timetableResults struct {
+	ID             uint   `json:"id"`
+	Created        string `json:"created"`
+	Modified       string `json:"modified"`
+	OperatorName   string `json:"operatorName"`
+	Name           string `json:"name"`
+	Description    string `json:"description"`
+	Comment        string `json:"comment"`
+	Status         string `json:"status"`
+	URL            string `json:"url"`
+	Extension      string `json:"extension"`
+	NOCs           []string `json:"noc"`
+	FirstStartDate string `json:"firstStartDate"`
+	FirstEndDate   string `json:"firstEndDate"`
+	LastEndDate    string `json:"lastEndDate"`
 }
\ No newline at end of file
@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"context"
 	"errors"
 	"io/ioutil"
 	"net/http"
@@ -9,26 +10,40 @@ import (
 	"testing"
 	"bytes"
 	"fmt"
+	"strings"
+	"time"
 )
 
-var insertOperatorsMock func(operators []models.Operator) error
-var insertLinesMock func (lines []models.Line) error
-var insertJourneysMock func (journeys []models.Journey) error
-var insertJourneyStopsMock func (journeyStops []models.JourneyStop) error
+var getDatasetMock func(datasetID uint) (models.Dataset, bool, error)
+var replaceDatasetMock func(ctx context.Context, datasetID uint, timetable models.Timetable) ([]models.ImportError, error)
 
 type busRouteMock struct{}
 
-func (busRoute busRouteMock) InsertOperators(operators []models.Operator) error {
-	return insertOperatorsMock(operators)
+func (busRoute busRouteMock) GetDataset(datasetID uint) (models.Dataset, bool, error) {
+	return getDatasetMock(datasetID)
 }
-func (busRoute busRouteMock) InsertLines(lines []models.Line) error {
-	return insertLinesMock(lines)
+func (busRoute busRouteMock) ReplaceDataset(ctx context.Context, datasetID uint, timetable models.Timetable) ([]models.ImportError, error) {
+	return replaceDatasetMock(ctx, datasetID, timetable)
 }
-func (busRoute busRouteMock) InsertJourneys(journeys []models.Journey) error {
-	return insertJourneysMock(journeys)
+
+var updateProgressMock func(progress models.BackgroundJobProgress) error
+var addDatasetsMock func(datasetIDs []uint) error
+var updateDatasetMock func(datasetID uint, status string) error
+var addImportErrorsMock func(importErrors []models.ImportError) error
+
+type jobDatasetMock struct{}
+
+func (jobDataset jobDatasetMock) UpdateProgress(progress models.BackgroundJobProgress) error {
+	return updateProgressMock(progress)
+}
+func (jobDataset jobDatasetMock) AddDatasets(datasetIDs []uint) error {
+	return addDatasetsMock(datasetIDs)
+}
+func (jobDataset jobDatasetMock) UpdateDataset(datasetID uint, status string) error {
+	return updateDatasetMock(datasetID, status)
 }
-func (busRoute busRouteMock) InsertJourneyStops(journeyStops []models.JourneyStop) error {
-	return insertJourneyStopsMock(journeyStops)
+func (jobDataset jobDatasetMock) AddImportErrors(importErrors []models.ImportError) error {
+	return addImportErrorsMock(importErrors)
 }
 
 func TestUpdateRoutes(t *testing.T) {
@@ -37,53 +52,445 @@ func TestUpdateRoutes(t *testing.T) {
 		BodyDir    string
 	}
 	type args struct {
-		offset                uint
-		limit                 uint
+		noc                   string
+		adminArea             string
+		force                 bool
+		importedDatasets      []uint
+		importedModified      time.Time
+		importedAt            time.Time
+		resumeFrom            time.Time
 		getResponse           []httpResponse
 		getError              bool
-		insertOperatorsErr    bool
-		insertLinesErr        bool
-		insertJourneysErr     bool
-		insertJourneyStopsErr bool
+		replaceDatasetErr     uint
+		cancelDataset         uint
 	}
 	tests := []struct {
-		name    string
-		args    args
-		wantErr bool
+		name         string
+		args         args
+		wantStatuses     map[uint]string
+		wantImportErrors []models.ImportError
+		wantProgress     models.BackgroundJobProgress
+		wantResult       models.BackgroundJobResult
+		wantErr          bool
 	}{
 		{
-			name: "Updates routes",
+			name: "Updates routes from every page of datasets",
 			args: args{
-				offset: 0,
-				limit: 1,
+				noc: "SCEK",
+				adminArea: "",
 				getResponse: []httpResponse{
 					httpResponse{
 						StatusCode: 200,
 						BodyDir: "testdata/dft-timetable-query.json",
 					},
+					httpResponse{
+						StatusCode: 200,
+						BodyDir: "testdata/dft-timetable-query-last.json",
+					},
+					httpResponse{
+						StatusCode: 200,
+						BodyDir: "testdata/dft-timetable.zip",
+					},
 					httpResponse{
 						StatusCode: 200,
 						BodyDir: "testdata/dft-timetable.zip",
 					},
 				},
 				getError: false,
-				insertOperatorsErr: false,
-				insertLinesErr: false,
-				insertJourneysErr: false,
-				insertJourneyStopsErr: false,
+				replaceDatasetErr: 0,
+			},
+			wantStatuses: map[uint]string{
+				256: "COMPLETE",
+				2022: "COMPLETE",
+			},
+			wantImportErrors: []models.ImportError{
+				models.ImportError{
+					DatasetID: 256,
+					FileName:  "dft-timetable.xml",
+					ElementID: "240098892",
+					Reason:    "Bus stop not found",
+				},
+				models.ImportError{
+					DatasetID: 2022,
+					FileName:  "dft-timetable.xml",
+					ElementID: "240098892",
+					Reason:    "Bus stop not found",
+				},
+			},
+			wantProgress: models.BackgroundJobProgress{Phase: "import", Processed: 2, Total: 2},
+			wantResult: models.BackgroundJobResult{
+				"CompleteDatasets": 2,
+				"Lines": 2,
+				"Journeys": 4,
+				"JourneyStops": 18,
+				"Trips": 10,
 			},
 			wantErr: false,
 		},
+		{
+			name: "Continues past a dataset which fails to download",
+			args: args{
+				noc: "",
+				adminArea: "110",
+				getResponse: []httpResponse{
+					httpResponse{
+						StatusCode: 200,
+						BodyDir: "testdata/dft-timetable-query.json",
+					},
+					httpResponse{
+						StatusCode: 200,
+						BodyDir: "testdata/dft-timetable-query-last.json",
+					},
+					httpResponse{
+						StatusCode: 500,
+						BodyDir: "testdata/dft-timetable.zip",
+					},
+					httpResponse{
+						StatusCode: 200,
+						BodyDir: "testdata/dft-timetable.zip",
+					},
+				},
+				getError: false,
+				replaceDatasetErr: 0,
+			},
+			wantStatuses: map[uint]string{
+				256: "FAILED",
+				2022: "COMPLETE",
+			},
+			wantImportErrors: []models.ImportError{
+				models.ImportError{
+					DatasetID: 2022,
+					FileName:  "dft-timetable.xml",
+					ElementID: "240098892",
+					Reason:    "Bus stop not found",
+				},
+			},
+			wantProgress: models.BackgroundJobProgress{Phase: "import", Processed: 2, Total: 2},
+			wantResult: models.BackgroundJobResult{
+				"CompleteDatasets": 1,
+				"FailedDatasets": 1,
+				"Lines": 1,
+				"Journeys": 2,
+				"JourneyStops": 9,
+				"Trips": 5,
+			},
+			wantErr: true,
+		},
+		{
+			name: "Leaves a dataset unchanged when it fails to be replaced",
+			args: args{
+				noc: "",
+				adminArea: "",
+				getResponse: []httpResponse{
+					httpResponse{
+						StatusCode: 200,
+						BodyDir: "testdata/dft-timetable-query.json",
+					},
+					httpResponse{
+						StatusCode: 200,
+						BodyDir: "testdata/dft-timetable-query-last.json",
+					},
+					httpResponse{
+						StatusCode: 200,
+						BodyDir: "testdata/dft-timetable.zip",
+					},
+					httpResponse{
+						StatusCode: 200,
+						BodyDir: "testdata/dft-timetable.zip",
+					},
+				},
+				getError: false,
+				replaceDatasetErr: 256,
+			},
+			wantStatuses: map[uint]string{
+				256: "FAILED",
+				2022: "COMPLETE",
+			},
+			wantImportErrors: []models.ImportError{
+				models.ImportError{
+					DatasetID: 2022,
+					FileName:  "dft-timetable.xml",
+					ElementID: "240098892",
+					Reason:    "Bus stop not found",
+				},
+			},
+			wantProgress: models.BackgroundJobProgress{Phase: "import", Processed: 2, Total: 2},
+			wantResult: models.BackgroundJobResult{
+				"CompleteDatasets": 1,
+				"FailedDatasets": 1,
+				"Lines": 1,
+				"Journeys": 2,
+				"JourneyStops": 9,
+				"Trips": 5,
+			},
+			wantErr: true,
+		},
+		{
+			name: "Skips datasets which haven't been modified since they were imported without downloading them",
+			args: args{
+				noc: "SCEK",
+				adminArea: "",
+				importedDatasets: []uint{256},
+				importedModified: time.Date(2021, 2, 5, 16, 2, 58, 0, time.UTC),
+				getResponse: []httpResponse{
+					httpResponse{
+						StatusCode: 200,
+						BodyDir: "testdata/dft-timetable-query.json",
+					},
+					httpResponse{
+						StatusCode: 200,
+						BodyDir: "testdata/dft-timetable-query-last.json",
+					},
+					httpResponse{
+						StatusCode: 200,
+						BodyDir: "testdata/dft-timetable.zip",
+					},
+				},
+				getError: false,
+				replaceDatasetErr: 0,
+			},
+			wantStatuses: map[uint]string{
+				256: "SKIPPED",
+				2022: "COMPLETE",
+			},
+			wantImportErrors: []models.ImportError{
+				models.ImportError{
+					DatasetID: 2022,
+					FileName:  "dft-timetable.xml",
+					ElementID: "240098892",
+					Reason:    "Bus stop not found",
+				},
+			},
+			wantProgress: models.BackgroundJobProgress{Phase: "import", Processed: 2, Total: 2},
+			wantResult: models.BackgroundJobResult{
+				"CompleteDatasets": 1,
+				"SkippedDatasets": 1,
+				"Lines": 1,
+				"Journeys": 2,
+				"JourneyStops": 9,
+				"Trips": 5,
+			},
+			wantErr: false,
+		},
+		{
+			name: "Imports datasets which have been modified since they were imported",
+			args: args{
+				noc: "SCEK",
+				adminArea: "",
+				importedDatasets: []uint{256, 2022},
+				importedModified: time.Date(2021, 1, 12, 9, 30, 0, 0, time.UTC),
+				getResponse: []httpResponse{
+					httpResponse{
+						StatusCode: 200,
+						BodyDir: "testdata/dft-timetable-query.json",
+					},
+					httpResponse{
+						StatusCode: 200,
+						BodyDir: "testdata/dft-timetable-query-last.json",
+					},
+					httpResponse{
+						StatusCode: 200,
+						BodyDir: "testdata/dft-timetable.zip",
+					},
+					httpResponse{
+						StatusCode: 200,
+						BodyDir: "testdata/dft-timetable.zip",
+					},
+				},
+				getError: false,
+				replaceDatasetErr: 0,
+			},
+			wantStatuses: map[uint]string{
+				256: "COMPLETE",
+				2022: "COMPLETE",
+			},
+			wantImportErrors: []models.ImportError{
+				models.ImportError{
+					DatasetID: 256,
+					FileName:  "dft-timetable.xml",
+					ElementID: "240098892",
+					Reason:    "Bus stop not found",
+				},
+				models.ImportError{
+					DatasetID: 2022,
+					FileName:  "dft-timetable.xml",
+					ElementID: "240098892",
+					Reason:    "Bus stop not found",
+				},
+			},
+			wantProgress: models.BackgroundJobProgress{Phase: "import", Processed: 2, Total: 2},
+			wantResult: models.BackgroundJobResult{
+				"CompleteDatasets": 2,
+				"Lines": 2,
+				"Journeys": 4,
+				"JourneyStops": 18,
+				"Trips": 10,
+			},
+			wantErr: false,
+		},
+		{
+			name: "Forces an import of datasets which haven't been modified since they were imported",
+			args: args{
+				noc: "SCEK",
+				adminArea: "",
+				force: true,
+				importedDatasets: []uint{256, 2022},
+				importedModified: time.Date(2021, 2, 5, 16, 2, 58, 0, time.UTC),
+				importedAt: time.Date(2021, 4, 6, 9, 0, 0, 0, time.UTC),
+				resumeFrom: time.Date(2021, 4, 6, 21, 33, 48, 0, time.UTC),
+				getResponse: []httpResponse{
+					httpResponse{
+						StatusCode: 200,
+						BodyDir: "testdata/dft-timetable-query.json",
+					},
+					httpResponse{
+						StatusCode: 200,
+						BodyDir: "testdata/dft-timetable-query-last.json",
+					},
+					httpResponse{
+						StatusCode: 200,
+						BodyDir: "testdata/dft-timetable.zip",
+					},
+					httpResponse{
+						StatusCode: 200,
+						BodyDir: "testdata/dft-timetable.zip",
+					},
+				},
+				getError: false,
+				replaceDatasetErr: 0,
+			},
+			wantStatuses: map[uint]string{
+				256: "COMPLETE",
+				2022: "COMPLETE",
+			},
+			wantImportErrors: []models.ImportError{
+				models.ImportError{
+					DatasetID: 256,
+					FileName:  "dft-timetable.xml",
+					ElementID: "240098892",
+					Reason:    "Bus stop not found",
+				},
+				models.ImportError{
+					DatasetID: 2022,
+					FileName:  "dft-timetable.xml",
+					ElementID: "240098892",
+					Reason:    "Bus stop not found",
+				},
+			},
+			wantProgress: models.BackgroundJobProgress{Phase: "import", Processed: 2, Total: 2},
+			wantResult: models.BackgroundJobResult{
+				"CompleteDatasets": 2,
+				"Lines": 2,
+				"Journeys": 4,
+				"JourneyStops": 18,
+				"Trips": 10,
+			},
+			wantErr: false,
+		},
+		{
+			name: "Skips forced datasets which were imported since the job was created",
+			args: args{
+				noc: "SCEK",
+				adminArea: "",
+				force: true,
+				importedDatasets: []uint{256},
+				importedModified: time.Date(2021, 2, 5, 16, 2, 58, 0, time.UTC),
+				importedAt: time.Date(2021, 4, 6, 21, 40, 0, 0, time.UTC),
+				resumeFrom: time.Date(2021, 4, 6, 21, 33, 48, 0, time.UTC),
+				getResponse: []httpResponse{
+					httpResponse{
+						StatusCode: 200,
+						BodyDir: "testdata/dft-timetable-query.json",
+					},
+					httpResponse{
+						StatusCode: 200,
+						BodyDir: "testdata/dft-timetable-query-last.json",
+					},
+					httpResponse{
+						StatusCode: 200,
+						BodyDir: "testdata/dft-timetable.zip",
+					},
+				},
+				getError: false,
+				replaceDatasetErr: 0,
+			},
+			wantStatuses: map[uint]string{
+				256: "SKIPPED",
+				2022: "COMPLETE",
+			},
+			wantImportErrors: []models.ImportError{
+				models.ImportError{
+					DatasetID: 2022,
+					FileName:  "dft-timetable.xml",
+					ElementID: "240098892",
+					Reason:    "Bus stop not found",
+				},
+			},
+			wantProgress: models.BackgroundJobProgress{Phase: "import", Processed: 2, Total: 2},
+			wantResult: models.BackgroundJobResult{
+				"CompleteDatasets": 1,
+				"SkippedDatasets": 1,
+				"Lines": 1,
+				"Journeys": 2,
+				"JourneyStops": 9,
+				"Trips": 5,
+			},
+			wantErr: false,
+		},
+		{
+			name: "Rolls back the dataset being imported and stops when cancelled",
+			args: args{
+				noc: "SCEK",
+				adminArea: "",
+				getResponse: []httpResponse{
+					httpResponse{
+						StatusCode: 200,
+						BodyDir: "testdata/dft-timetable-query.json",
+					},
+					httpResponse{
+						StatusCode: 200,
+						BodyDir: "testdata/dft-timetable-query-last.json",
+					},
+					httpResponse{
+						StatusCode: 200,
+						BodyDir: "testdata/dft-timetable.zip",
+					},
+				},
+				getError: false,
+				cancelDataset: 256,
+			},
+			wantStatuses: map[uint]string{
+				256: "CANCELLED",
+				2022: "PENDING",
+			},
+			wantImportErrors: []models.ImportError{},
+			wantProgress: models.BackgroundJobProgress{Phase: "import", Processed: 1, Total: 2},
+			wantResult: models.BackgroundJobResult{},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			var httpClient httpClientMock
 			requestCount := 0
+			datasetRequestDelay = 0
 			getMock = func(url string) (*http.Response, error) {
 				if tt.args.getError {
 					return &http.Response{}, errors.New("")
 				}
 
+				if requestCount == 0 {
+					if !strings.Contains(url, "limit=100") || !strings.Contains(url, "offset=0") {
+						t.Errorf("UpdateRoutes() requested %v, want limit=100 and offset=0", url)
+					}
+					if tt.args.noc != "" && !strings.Contains(url, "noc=" + tt.args.noc) {
+						t.Errorf("UpdateRoutes() requested %v, want noc=%v", url, tt.args.noc)
+					}
+					if tt.args.adminArea != "" && !strings.Contains(url, "adminArea=" + tt.args.adminArea) {
+						t.Errorf("UpdateRoutes() requested %v, want adminArea=%v", url, tt.args.adminArea)
+					}
+				}
+
 				body, err := ioutil.ReadFile(tt.args.getResponse[requestCount].BodyDir)
 				if err != nil {
 					t.Fatal(err)
@@ -113,479 +520,115 @@ func TestUpdateRoutes(t *testing.T) {
 			}
 
 			var busRoute busRouteMock
-			insertOperatorsMock = func(operators []models.Operator) error {
-				if tt.args.insertOperatorsErr {
-					return errors.New("")
+			getDatasetMock = func(datasetID uint) (models.Dataset, bool, error) {
+				for _, importedDataset := range tt.args.importedDatasets {
+					if importedDataset == datasetID {
+						// the revision is the same, which doesn't matter when the
+						// dataset has been modified
+						return models.Dataset{
+							ID: datasetID,
+							Modified: tt.args.importedModified,
+							Revision: 67,
+							ImportedAt: tt.args.importedAt,
+						}, true, nil
+					}
 				}
-				return nil
+
+				return models.Dataset{}, false, nil
 			}
-			insertLinesMock = func(lines []models.Line) error {
-				if tt.args.insertLinesErr {
-					return errors.New("")
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			replaceDatasetMock = func(ctx context.Context, datasetID uint, timetable models.Timetable) ([]models.ImportError, error) {
+				if tt.args.cancelDataset == datasetID {
+					cancel()
+					return nil, ctx.Err()
 				}
-				return nil
-			}
-			insertJourneysMock = func(journey []models.Journey) error {
-				if tt.args.insertJourneysErr {
-					return errors.New("")
+
+				if tt.args.replaceDatasetErr == datasetID {
+					return nil, errors.New("")
+				}
+
+				if len(timetable.Files) != 1 || timetable.Files[0].SchemaVersion != "2.4" {
+					t.Errorf("UpdateRoutes() timetable files = %v, want one 2.4 file", timetable.Files)
 				}
+
+				if timetable.Dataset.ID != datasetID || timetable.Dataset.Revision != 67 {
+					t.Errorf("UpdateRoutes() timetable dataset = %v, want dataset %v revision 67", timetable.Dataset, datasetID)
+				}
+
+				// the modified time of the listing is stored to skip the dataset
+				// until it is modified again
+				if !timetable.Dataset.Modified.Equal(time.Date(2021, 2, 5, 16, 2, 58, 0, time.UTC)) {
+					t.Errorf("UpdateRoutes() timetable dataset modified = %v, want 2021-02-05T16:02:58Z", timetable.Dataset.Modified)
+				}
+
+				// line IDs are prefixed with the dataset so datasets publishing the
+				// same line don't take over each other's rows
+				linePrefix := fmt.Sprintf("%d:", datasetID)
+				if !strings.HasPrefix(timetable.Lines[0].ID, linePrefix) ||
+					!strings.HasPrefix(timetable.Journeys[0].LineID, linePrefix) ||
+					!strings.HasPrefix(timetable.JourneyStops[0].LineID, linePrefix) ||
+					!strings.HasPrefix(timetable.Trips[0].LineID, linePrefix) {
+					t.Errorf("UpdateRoutes() line IDs not prefixed with %v", linePrefix)
+				}
+
+				if timetable.Lines[0].FileName != "dft-timetable.xml" || timetable.Journeys[0].FileName != "dft-timetable.xml" {
+					t.Errorf("UpdateRoutes() lines and journeys not linked to dft-timetable.xml")
+				}
+
+				return []models.ImportError{
+					models.ImportError{
+						DatasetID: datasetID,
+						FileName:  timetable.JourneyStops[0].FileName,
+						ElementID: timetable.JourneyStops[0].BusStopID,
+						Reason:    "Bus stop not found",
+					},
+				}, nil
+			}
+
+			var jobDatasets jobDatasetMock
+			var gotProgress models.BackgroundJobProgress
+			updateProgressMock = func(progress models.BackgroundJobProgress) error {
+				gotProgress = progress
 				return nil
 			}
-			insertJourneyStopsMock = func(journeyStop []models.JourneyStop) error {
-				if tt.args.insertJourneyStopsErr {
-					return errors.New("")
+			gotStatuses := map[uint]string{}
+			addDatasetsMock = func(datasetIDs []uint) error {
+				for _, datasetID := range datasetIDs {
+					gotStatuses[datasetID] = "PENDING"
 				}
 				return nil
 			}
-
-			if err := UpdateRoutes(tt.args.offset, tt.args.limit, httpClient, busRoute); (err != nil) != tt.wantErr {
-				t.Errorf("UpdateRoutes() error = %v, wantErr %v", err, tt.wantErr)
-			}
-		})
-	}
-}
-
-func Test_parseTransXChange(t *testing.T) {
-	type args struct {
-		version float32
-		xmlFile string
-	}
-	tests := []struct {
-		name    string
-		args    args
-		want    parsedTransXChange
-		wantErr bool
-	}{
-		{
-			name: "Gets two routes from SCEK-953",
-			args: args{
-				version: 2.4,
-				xmlFile: "./testdata/dft-timetable.xml",
-			},
-			want: parsedTransXChange{
-				operators: []models.Operator{
-					models.Operator{
-						ID:        "SCEK",
-						Name:      "Stagecoach in East Kent",
-						ShortName: "Stagecoach",
-					},
-				},
-				lines: []models.Line{
-					models.Line{
-						ID:         "SCEK:PK0000098:84_953_953:953:",
-						OperatorID: "SCEK",
-						Name:       "953",
-					},
-				},
-				journeys: []models.Journey{
-					models.Journey{
-						LineID:      "SCEK:PK0000098:84_953_953:953:",
-						RouteID:     "RT131",
-						Direction:   "OUTBOUND",
-						Description: "Bus Station - High School",
-					},
-					models.Journey{
-						LineID:      "SCEK:PK0000098:84_953_953:953:",
-						RouteID:     "RT132",
-						Direction:   "INBOUND",
-						Description: "Bus Station - High School",
-					},
-				},
-				journeyStops: []models.JourneyStop{
-					models.JourneyStop{
-						LineID:     "SCEK:PK0000098:84_953_953:953:",
-						RouteID:    "RT131",
-						StopNumber: 0,
-						BusStopID:  "240098892",
-					},
-					models.JourneyStop{
-						LineID:     "SCEK:PK0000098:84_953_953:953:",
-						RouteID:    "RT131",
-						StopNumber: 1,
-						BusStopID:  "2400A049530A",
-					},
-					models.JourneyStop{
-						LineID:     "SCEK:PK0000098:84_953_953:953:",
-						RouteID:    "RT131",
-						StopNumber: 2,
-						BusStopID:  "240096713",
-					},
-					models.JourneyStop{
-						LineID:     "SCEK:PK0000098:84_953_953:953:",
-						RouteID:    "RT131",
-						StopNumber: 3,
-						BusStopID:  "2400A050490A",
-					},
-					models.JourneyStop{
-						LineID:     "SCEK:PK0000098:84_953_953:953:",
-						RouteID:    "RT131",
-						StopNumber: 4,
-						BusStopID:  "2400A050530A",
-					},
-					models.JourneyStop{
-						LineID:     "SCEK:PK0000098:84_953_953:953:",
-						RouteID:    "RT132",
-						StopNumber: 0,
-						BusStopID:  "2400A050510A",
-					},
-					models.JourneyStop{
-						LineID:     "SCEK:PK0000098:84_953_953:953:",
-						RouteID:    "RT132",
-						StopNumber: 1,
-						BusStopID:  "2400A050500A",
-					},
-					models.JourneyStop{
-						LineID:     "SCEK:PK0000098:84_953_953:953:",
-						RouteID:    "RT132",
-						StopNumber: 2,
-						BusStopID:  "240096711",
-					},
-					models.JourneyStop{
-						LineID:     "SCEK:PK0000098:84_953_953:953:",
-						RouteID:    "RT132",
-						StopNumber: 3,
-						BusStopID:  "2400A049550A",
-					},
-					models.JourneyStop{
-						LineID:     "SCEK:PK0000098:84_953_953:953:",
-						RouteID:    "RT132",
-						StopNumber: 4,
-						BusStopID:  "2400A039640A",
-					},
-				},
-			},
-			wantErr: false,
-		},
-		{
-			name: "Gets two routes from uni1",
-			args: args{
-				version: 2.4,
-				xmlFile: "./testdata/dft-timetable-uni1.xml",
-			},
-			want: parsedTransXChange{
-				operators: []models.Operator{
-					models.Operator{
-						ID:        "SCEK",
-						Name:      "Stagecoach in East Kent",
-						ShortName: "Stagecoach",
-					},
-				},
-				lines: []models.Line{
-					models.Line{
-						ID:         "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
-						OperatorID: "SCEK",
-						Name:       "Uni1",
-					},
-				},
-				journeys: []models.Journey{
-					models.Journey{
-						LineID:      "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
-						RouteID:     "RT197",
-						Direction:   "OUTBOUND",
-						Description: "City Centre - University",
-					},
-					models.Journey{
-						LineID:      "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
-						RouteID:     "RT199",
-						Direction:   "INBOUND",
-						Description: "City Centre - University",
-					},
-					models.Journey{
-						LineID:      "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
-						RouteID:     "RT198",
-						Direction:   "INBOUND",
-						Description: "City Centre - University",
-					},
-					models.Journey{
-						LineID:      "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
-						RouteID:     "RT199",
-						Direction:   "INBOUND",
-						Description: "City Centre - University",
-					},
-					models.Journey{
-						LineID:      "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
-						RouteID:     "RT199",
-						Direction:   "INBOUND",
-						Description: "City Centre - University",
-					},
-					models.Journey{
-						LineID:      "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
-						RouteID:     "RT199",
-						Direction:   "INBOUND",
-						Description: "City Centre - University",
-					},
-				},
-				journeyStops: []models.JourneyStop{
-					models.JourneyStop{
-						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
-						RouteID:    "RT197",
-						StopNumber: 0,
-						BusStopID:  "240098906",
-					},
-					models.JourneyStop{
-						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
-						RouteID:    "RT197",
-						StopNumber: 1,
-						BusStopID:  "2400A049530A",
-					},
-					models.JourneyStop{
-						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
-						RouteID:    "RT197",
-						StopNumber: 2,
-						BusStopID:  "2400A048110A",
-					},
-					models.JourneyStop{
-						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
-						RouteID:    "RT197",
-						StopNumber: 3,
-						BusStopID:  "2400A048140A",
-					},
-					models.JourneyStop{
-						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
-						RouteID:    "RT197",
-						StopNumber: 4,
-						BusStopID:  "2400A048160A",
-					},
-					models.JourneyStop{
-						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
-						RouteID:    "RT197",
-						StopNumber: 5,
-						BusStopID:  "2400A048170A",
-					},
-					models.JourneyStop{
-						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
-						RouteID:    "RT197",
-						StopNumber: 6,
-						BusStopID:  "2400A050260A",
-					},
-					models.JourneyStop{
-						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
-						RouteID:    "RT197",
-						StopNumber: 7,
-						BusStopID:  "2400A050270A",
-					},
-					models.JourneyStop{
-						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
-						RouteID:    "RT197",
-						StopNumber: 8,
-						BusStopID:  "2400A050290A",
-					},
-					models.JourneyStop{
-						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
-						RouteID:    "RT197",
-						StopNumber: 9,
-						BusStopID:  "2400100704",
-					},
-					models.JourneyStop{
-						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
-						RouteID:    "RT197",
-						StopNumber: 10,
-						BusStopID:  "2400A040360A",
-					},
-					models.JourneyStop{
-						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
-						RouteID:    "RT197",
-						StopNumber: 11,
-						BusStopID:  "240095612",
-					},
-					models.JourneyStop{
-						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
-						RouteID:    "RT198",
-						StopNumber: 0,
-						BusStopID:  "240095612",
-					},
-					models.JourneyStop{
-						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
-						RouteID:    "RT198",
-						StopNumber: 1,
-						BusStopID:  "2400A040370A",
-					},
-					models.JourneyStop{
-						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
-						RouteID:    "RT198",
-						StopNumber: 2,
-						BusStopID:  "240075428",
-					},
-					models.JourneyStop{
-						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
-						RouteID:    "RT198",
-						StopNumber: 3,
-						BusStopID:  "240097602",
-					},
-					models.JourneyStop{
-						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
-						RouteID:    "RT198",
-						StopNumber: 4,
-						BusStopID:  "240097597",
-					},
-					models.JourneyStop{
-						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
-						RouteID:    "RT198",
-						StopNumber: 5,
-						BusStopID:  "2400100702",
-					},
-					models.JourneyStop{
-						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
-						RouteID:    "RT198",
-						StopNumber: 6,
-						BusStopID:  "2400A050300A",
-					},
-					models.JourneyStop{
-						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
-						RouteID:    "RT198",
-						StopNumber: 7,
-						BusStopID:  "2400A050280A",
-					},
-					models.JourneyStop{
-						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
-						RouteID:    "RT198",
-						StopNumber: 8,
-						BusStopID:  "2400A048180A",
-					},
-					models.JourneyStop{
-						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
-						RouteID:    "RT198",
-						StopNumber: 9,
-						BusStopID:  "2400A048150A",
-					},
-					models.JourneyStop{
-						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
-						RouteID:    "RT198",
-						StopNumber: 10,
-						BusStopID:  "2400105752",
-					},
-					models.JourneyStop{
-						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
-						RouteID:    "RT198",
-						StopNumber: 11,
-						BusStopID:  "2400A040380A",
-					},
-					models.JourneyStop{
-						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
-						RouteID:    "RT198",
-						StopNumber: 12,
-						BusStopID:  "240096711",
-					},
-					models.JourneyStop{
-						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
-						RouteID:    "RT198",
-						StopNumber: 13,
-						BusStopID:  "2400A049550A",
-					},
-					models.JourneyStop{
-						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
-						RouteID:    "RT198",
-						StopNumber: 14,
-						BusStopID:  "2400A039640A",
-					},
-					models.JourneyStop{
-						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
-						RouteID:    "RT199",
-						StopNumber: 0,
-						BusStopID:  "240095612",
-					},
-					models.JourneyStop{
-						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
-						RouteID:    "RT199",
-						StopNumber: 1,
-						BusStopID:  "2400A040370A",
-					},
-					models.JourneyStop{
-						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
-						RouteID:    "RT199",
-						StopNumber: 2,
-						BusStopID:  "2400100702",
-					},
-					models.JourneyStop{
-						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
-						RouteID:    "RT199",
-						StopNumber: 3,
-						BusStopID:  "2400A050300A",
-					},
-					models.JourneyStop{
-						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
-						RouteID:    "RT199",
-						StopNumber: 4,
-						BusStopID:  "2400A050280A",
-					},
-					models.JourneyStop{
-						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
-						RouteID:    "RT199",
-						StopNumber: 5,
-						BusStopID:  "2400A048180A",
-					},
-					models.JourneyStop{
-						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
-						RouteID:    "RT199",
-						StopNumber: 6,
-						BusStopID:  "2400A048150A",
-					},
-					models.JourneyStop{
-						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
-						RouteID:    "RT199",
-						StopNumber: 7,
-						BusStopID:  "2400105752",
-					},
-					models.JourneyStop{
-						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
-						RouteID:    "RT199",
-						StopNumber: 8,
-						BusStopID:  "2400A040380A",
-					},
-					models.JourneyStop{
-						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
-						RouteID:    "RT199",
-						StopNumber: 9,
-						BusStopID:  "240096711",
-					},
-					models.JourneyStop{
-						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
-						RouteID:    "RT199",
-						StopNumber: 10,
-						BusStopID:  "2400A049550A",
-					},
-					models.JourneyStop{
-						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
-						RouteID:    "RT199",
-						StopNumber: 11,
-						BusStopID:  "2400A039640A",
-					},
-				},
-			},
-			wantErr: false,
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			xml, err := ioutil.ReadFile(tt.args.xmlFile)
-			if err != nil {
-				t.Fatal(err)
+			updateDatasetMock = func(datasetID uint, status string) error {
+				gotStatuses[datasetID] = status
+				return nil
 			}
-
-			got, err := parseTransXChange(tt.args.version, xml)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("parseTransXChange() error = %v, wantErr %v", err, tt.wantErr)
-				return
+			gotImportErrors := make([]models.ImportError, 0)
+			addImportErrorsMock = func(importErrors []models.ImportError) error {
+				gotImportErrors = append(gotImportErrors, importErrors...)
+				return nil
 			}
 
-			if !reflect.DeepEqual(got.operators, tt.want.operators) {
-				t.Errorf("parseTransXChange() = Operators{ %v }, want Operators{ %v }", got.operators, tt.want.operators)
+			gotResult := models.BackgroundJobResult{}
+			if err := updateRoutes(ctx, tt.args.noc, tt.args.adminArea, tt.args.force, tt.args.resumeFrom, httpClient, busRoute, jobDatasets, gotResult); (err != nil) != tt.wantErr {
+				t.Errorf("UpdateRoutes() error = %v, wantErr %v", err, tt.wantErr)
 			}
 
-			if !reflect.DeepEqual(got.lines, tt.want.lines) {
-				t.Errorf("parseTransXChange() = Lines{ %v }, want Lines{ %v }", got.lines, tt.want.lines)
+			if gotProgress != tt.wantProgress {
+				t.Errorf("UpdateRoutes() progress = %v, want %v", gotProgress, tt.wantProgress)
 			}
 
-			if !reflect.DeepEqual(got.journeys, tt.want.journeys) {
-				t.Errorf("parseTransXChange() = Journeys{ %v }, want Journeys{ %v }", got.journeys, tt.want.journeys)
+			if !reflect.DeepEqual(gotResult, tt.wantResult) {
+				t.Errorf("UpdateRoutes() result = %v, want %v", gotResult, tt.wantResult)
 			}
 
-			if !reflect.DeepEqual(got.journeyStops, tt.want.journeyStops) {
-				t.Errorf("parseTransXChange() = JourneyStops{ %v }, want JourneyStops{ %v }", got.journeyStops, tt.want.journeyStops)
+			if !reflect.DeepEqual(gotStatuses, tt.wantStatuses) {
+				t.Errorf("UpdateRoutes() dataset statuses = %v, want %v", gotStatuses, tt.wantStatuses)
 			}
 
-			if !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("parseTransXChange() = %v, want %v", got, tt.want)
+			if !reflect.DeepEqual(gotImportErrors, tt.wantImportErrors) {
+				t.Errorf("UpdateRoutes() import errors = %v, want %v", gotImportErrors, tt.wantImportErrors)
 			}
 		})
 	}
@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"server/models"
+	"database/sql"
+	_ "github.com/lib/pq"
+)
+
+// GetOperators gets every operator
+func GetOperators() ([]models.Operator, error) {
+	operators, err := models.GetOperators()
+	if err != nil {
+		return nil, err
+	}
+
+	return operators, nil
+}
+
+// GetOperatorLines gets every line run by the operator noc. found is false when
+// there isn't an operator with the code
+func GetOperatorLines(noc string) ([]models.LineSummary, bool, error) {
+	if _, err := models.GetOperator(noc); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, false, nil
+		}
+
+		return nil, false, err
+	}
+
+	lines, err := models.GetOperatorLines(noc)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return lines, true, nil
+}
+
+// GetLinesWithinBounds gets every line serving a bus stop within the bounds
+func GetLinesWithinBounds(minLongitude float32, minLatitude float32, maxLongitude float32, maxLatitude float32) ([]models.LineSummary, error) {
+	lines, err := models.GetLinesWithinBounds(minLongitude, minLatitude, maxLongitude, maxLatitude)
+	if err != nil {
+		return nil, err
+	}
+
+	return lines, nil
+}
+
+// GetBusStop gets a bus stop by its ATCO code. found is false when there isn't
+// a bus stop with the code
+func GetBusStop(atcoCode string) (models.BusStop, bool, error) {
+	busStop, err := models.GetBusStop(atcoCode)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return models.BusStop{}, false, nil
+		}
+
+		return models.BusStop{}, false, err
+	}
+
+	return busStop, true, nil
+}
+
+// GetBusStopLines gets every route which calls at a bus stop. found is false
+// when there isn't a bus stop with the code
+func GetBusStopLines(atcoCode string) ([]models.BusStopLine, bool, error) {
+	if _, found, err := GetBusStop(atcoCode); !found || err != nil {
+		return nil, found, err
+	}
+
+	lines, err := models.GetBusStopLines(atcoCode)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return lines, true, nil
+}
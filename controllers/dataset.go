@@ -0,0 +1,15 @@
+package controllers
+
+import (
+	"server/models"
+)
+
+// GetDatasets gets every imported timetable dataset
+func GetDatasets() ([]models.Dataset, error) {
+	datasets, err := models.GetDatasets()
+	if err != nil {
+		return nil, err
+	}
+
+	return datasets, nil
+}
@@ -0,0 +1,659 @@
+package controllers
+
+import (
+	"archive/zip"
+	"context"
+	"bytes"
+	"encoding/csv"
+	"server/models"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"database/sql"
+	_ "github.com/lib/pq"
+)
+
+// gtfsAgencyURL is the agency_url of every operator as the operators' own
+// websites aren't imported
+const gtfsAgencyURL = "https://www.bus-data.dft.gov.uk/"
+
+// gtfsRouteTypeBus is the GTFS route_type of a bus service
+const gtfsRouteTypeBus = "3"
+
+// gtfsOpenEndedServiceDays is how many days after the export a trip without an
+// end date is exported as running, as a GTFS calendar must have an end date
+const gtfsOpenEndedServiceDays = 365
+
+// gtfsSampleSkippedTrips is how many of the trips left out of a feed are logged
+const gtfsSampleSkippedTrips = 5
+
+// gtfsDays are the calendar.txt day columns in order
+var gtfsDays = []struct {
+	name string
+	bit  uint8
+}{
+	{"monday", models.Monday},
+	{"tuesday", models.Tuesday},
+	{"wednesday", models.Wednesday},
+	{"thursday", models.Thursday},
+	{"friday", models.Friday},
+	{"saturday", models.Saturday},
+	{"sunday", models.Sunday},
+}
+
+// ExportGTFS queues a job exporting every imported timetable as a GTFS feed
+func ExportGTFS() (models.BackgroundJob, error) {
+	return enqueueBackgroundJob(exportGTFSJobType, nil)
+}
+
+func runGTFSExport(ctx context.Context, job models.BackgroundJob, jobDatasets models.JobDataset) (models.BackgroundJobResult, error) {
+	return exportGTFS(ctx, job.ID, jobDatasets)
+}
+
+// exportGTFS builds a GTFS feed of every imported timetable and replaces the
+// previous feed with it, unless ctx is cancelled first
+func exportGTFS(ctx context.Context, jobID uint, jobDatasets models.JobDataset) (models.BackgroundJobResult, error) {
+	jobDatasets.UpdateProgress(models.BackgroundJobProgress{Phase: "read"})
+
+	timetable, err := models.GetGTFSTimetable()
+	if err != nil {
+		logJob(ctx, "ERROR", "Failed to read timetables", models.JobLogFields{"Error": err.Error()})
+		return nil, fmt.Errorf("Failed to read timetables: %v", err)
+	}
+
+	jobDatasets.UpdateProgress(models.BackgroundJobProgress{Phase: "build", Total: uint(len(timetable.Trips))})
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	feed, exportErrors, err := buildGTFSFeed(timetable, time.Now().In(timetableLocation))
+	if len(exportErrors) > 0 {
+		logJob(ctx, "WARN", "Skipped trips which couldn't be exported", skippedTripsSummary(exportErrors))
+	}
+	if err != nil {
+		logJob(ctx, "ERROR", "Failed to build GTFS feed", models.JobLogFields{"Error": err.Error()})
+
+		return nil, fmt.Errorf("Failed to build GTFS feed: %v", err)
+	}
+
+	logJob(ctx, "INFO", "Exported GTFS feed", models.JobLogFields{"Trips": len(timetable.Trips) - len(exportErrors), "Bytes": len(feed)})
+
+	jobDatasets.UpdateProgress(models.BackgroundJobProgress{
+		Phase: "build",
+		Processed: uint(len(timetable.Trips)),
+		Total: uint(len(timetable.Trips)),
+	})
+	jobDatasets.UpdateProgress(models.BackgroundJobProgress{Phase: "write"})
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := models.SaveGTFSExport(ctx, jobID, feed); err != nil {
+		return nil, fmt.Errorf("Failed to save GTFS feed: %v", err)
+	}
+
+	return models.BackgroundJobResult{
+		"Trips": uint(len(timetable.Trips) - len(exportErrors)),
+		"SkippedTrips": uint(len(exportErrors)),
+	}, nil
+}
+
+// skippedTripsSummary counts the trips left out of a feed for each reason, with
+// the first few of them as SampleTrips, rather than logging every trip
+func skippedTripsSummary(exportErrors []gtfsExportError) models.JobLogFields {
+	reasons := make(map[string]int)
+	sampleTrips := make([]models.JobLogFields, 0)
+	for _, exportError := range exportErrors {
+		reasons[exportError.Reason]++
+
+		if len(sampleTrips) < gtfsSampleSkippedTrips {
+			sampleTrips = append(sampleTrips, models.JobLogFields{
+				"TripID": exportError.TripID,
+				"DatasetID": exportError.DatasetID,
+				"Reason": exportError.Reason,
+			})
+		}
+	}
+
+	return models.JobLogFields{
+		"SkippedTrips": len(exportErrors),
+		"Reasons": reasons,
+		"SampleTrips": sampleTrips,
+	}
+}
+
+// GetGTFSExport gets the most recently exported GTFS feed. found is false when
+// a feed hasn't been exported
+func GetGTFSExport() ([]byte, time.Time, bool, error) {
+	feed, createdAt, err := models.GetLatestGTFSExport()
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, time.Time{}, false, nil
+		}
+
+		return nil, time.Time{}, false, err
+	}
+
+	return feed, createdAt, true, nil
+}
+
+type gtfsFile struct {
+	name    string
+	records [][]string
+}
+
+// newGTFSFile gets a file with the header of its gtfsFields
+func newGTFSFile(name string) gtfsFile {
+	header := make([]string, 0, len(gtfsFields[name]))
+	for _, field := range gtfsFields[name] {
+		header = append(header, field.name)
+	}
+
+	return gtfsFile{name: name, records: [][]string{header}}
+}
+
+// gtfsExportError is a trip left out of a GTFS feed and the reason why
+type gtfsExportError struct {
+	TripID    uint
+	DatasetID uint
+	Reason    string
+}
+
+// buildGTFSFeed zips the timetable as agency.txt, stops.txt, routes.txt,
+// trips.txt, stop_times.txt and calendar.txt. Trips which would make an invalid
+// feed are left out and returned as export errors, along with the routes,
+// agencies and stops only they use. Every record is checked against gtfsFields
+// and an error is returned if the feed is still invalid
+func buildGTFSFeed(timetable models.GTFSTimetable, exportedAt time.Time) ([]byte, []gtfsExportError, error) {
+	exportErrors := make([]gtfsExportError, 0)
+
+	lines := make(map[string]models.Line)
+	for _, line := range timetable.Lines {
+		lines[line.ID] = line
+	}
+
+	operators := make(map[string]models.Operator)
+	for _, operator := range timetable.Operators {
+		operators[operator.ID] = operator
+	}
+
+	busStops := make(map[string]models.BusStop)
+	for _, busStop := range timetable.BusStops {
+		busStops[busStop.ID] = busStop
+	}
+
+	exportedDate := time.Date(exportedAt.Year(), exportedAt.Month(), exportedAt.Day(), 0, 0, 0, 0, time.UTC)
+
+	trips := newGTFSFile("trips.txt")
+	stopTimes := newGTFSFile("stop_times.txt")
+	services := make(map[string][]string)
+	usedLines := make(map[string]bool)
+	usedStops := make(map[string]bool)
+
+	for _, trip := range timetable.Trips {
+		tripRecords, err := gtfsTripRecords(trip, lines, operators, busStops, exportedDate)
+		if err != nil {
+			exportErrors = append(exportErrors, gtfsExportError{
+				TripID: trip.ID,
+				DatasetID: trip.DatasetID,
+				Reason: err.Error(),
+			})
+
+			continue
+		}
+
+		trips.records = append(trips.records, tripRecords["trips.txt"]...)
+		stopTimes.records = append(stopTimes.records, tripRecords["stop_times.txt"]...)
+
+		calendar := tripRecords["calendar.txt"][0]
+		services[calendar[0]] = calendar
+
+		for _, stop := range trip.Stops {
+			usedStops[stop.BusStopID] = true
+		}
+
+		usedLines[trip.LineID] = true
+	}
+
+	if len(usedLines) == 0 {
+		return nil, exportErrors, errors.New("No valid trips to export")
+	}
+
+	agency := newGTFSFile("agency.txt")
+	routes := newGTFSFile("routes.txt")
+	usedOperators := make(map[string]bool)
+
+	for _, line := range timetable.Lines {
+		if !usedLines[line.ID] {
+			continue
+		}
+
+		routes.records = append(routes.records, gtfsRouteRecord(line))
+		usedOperators[line.OperatorID] = true
+	}
+
+	for _, operator := range timetable.Operators {
+		if !usedOperators[operator.ID] {
+			continue
+		}
+
+		agency.records = append(agency.records, gtfsAgencyRecord(operator))
+	}
+
+	stops := newGTFSFile("stops.txt")
+	for _, busStop := range timetable.BusStops {
+		if !usedStops[busStop.ID] {
+			continue
+		}
+
+		stops.records = append(stops.records, gtfsStopRecord(busStop))
+	}
+
+	calendar := newGTFSFile("calendar.txt")
+	serviceIDs := make([]string, 0)
+	for serviceID := range services {
+		serviceIDs = append(serviceIDs, serviceID)
+	}
+	sort.Strings(serviceIDs)
+	for _, serviceID := range serviceIDs {
+		calendar.records = append(calendar.records, services[serviceID])
+	}
+
+	files := []gtfsFile{agency, stops, routes, trips, stopTimes, calendar}
+	for _, file := range files {
+		if err := validateGTFSFile(file); err != nil {
+			return nil, exportErrors, fmt.Errorf("Invalid GTFS feed: %v", err)
+		}
+	}
+
+	feed, err := zipGTFSFiles(files, exportedAt)
+
+	return feed, exportErrors, err
+}
+
+// gtfsTripRecords gets the records of every file a trip is written to, by the
+// name of the file, including the route, agency and stops it uses. An error is
+// returned when the trip can't be exported or any of its records are invalid
+func gtfsTripRecords(trip models.GTFSTrip, lines map[string]models.Line, operators map[string]models.Operator, busStops map[string]models.BusStop, exportedDate time.Time) (map[string][][]string, error) {
+	if reason := validateGTFSTrip(trip, lines, operators, busStops); reason != "" {
+		return nil, errors.New(reason)
+	}
+
+	calendar, err := gtfsCalendar(trip, exportedDate)
+	if err != nil {
+		return nil, err
+	}
+
+	directionID := "0"
+	if trip.Direction == "INBOUND" {
+		directionID = "1"
+	}
+
+	tripID := strconv.FormatUint(uint64(trip.ID), 10)
+	headsign := busStops[trip.Stops[len(trip.Stops) - 1].BusStopID].Name
+	line := lines[trip.LineID]
+
+	records := map[string][][]string{
+		"agency.txt": {gtfsAgencyRecord(operators[line.OperatorID])},
+		"routes.txt": {gtfsRouteRecord(line)},
+		"trips.txt": {{trip.LineID, calendar[0], tripID, headsign, directionID}},
+		"calendar.txt": {calendar},
+	}
+
+	for stopSequence, stop := range trip.Stops {
+		stopTime := gtfsTime(trip.DepartureTime + stop.Offset)
+
+		records["stop_times.txt"] = append(records["stop_times.txt"], []string{
+			tripID, stopTime, stopTime, stop.BusStopID, strconv.Itoa(stopSequence),
+		})
+		records["stops.txt"] = append(records["stops.txt"], gtfsStopRecord(busStops[stop.BusStopID]))
+	}
+
+	for _, file := range []string{"agency.txt", "stops.txt", "routes.txt", "trips.txt", "stop_times.txt", "calendar.txt"} {
+		for _, record := range records[file] {
+			if err := validateGTFSRecord(file, record); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return records, nil
+}
+
+func gtfsAgencyRecord(operator models.Operator) []string {
+	name := operator.Name
+	if name == "" {
+		name = operator.ShortName
+	}
+	if name == "" {
+		name = operator.ID
+	}
+
+	return []string{operator.ID, name, gtfsAgencyURL, timetableLocation.String()}
+}
+
+func gtfsRouteRecord(line models.Line) []string {
+	return []string{line.ID, line.OperatorID, line.Name, gtfsRouteTypeBus}
+}
+
+func gtfsStopRecord(busStop models.BusStop) []string {
+	return []string{
+		busStop.ID,
+		busStop.Name,
+		strconv.FormatFloat(float64(busStop.Latitude), 'f', -1, 32),
+		strconv.FormatFloat(float64(busStop.Longitude), 'f', -1, 32),
+	}
+}
+
+// validateGTFSTrip gets the reason a trip can't be exported, or an empty string
+// when it is valid
+func validateGTFSTrip(trip models.GTFSTrip, lines map[string]models.Line, operators map[string]models.Operator, busStops map[string]models.BusStop) string {
+	line, ok := lines[trip.LineID]
+	if !ok {
+		return "Trip line doesn't exist"
+	}
+
+	if _, ok := operators[line.OperatorID]; !ok {
+		return "Trip line operator doesn't exist"
+	}
+
+	if trip.Days == 0 {
+		return "Trip doesn't run on any day of the week"
+	}
+
+	if len(trip.Stops) < 2 {
+		return "Trip has fewer than two stops"
+	}
+
+	for stopIndex, stop := range trip.Stops {
+		if _, ok := busStops[stop.BusStopID]; !ok {
+			return "Trip stop doesn't exist"
+		}
+
+		if stopIndex > 0 && stop.Offset < trip.Stops[stopIndex - 1].Offset {
+			return "Trip stop times go back in time"
+		}
+	}
+
+	return ""
+}
+
+// gtfsCalendar gets the calendar.txt record of the days and dates a trip runs.
+// The service_id identifies the days and dates so trips running at the same
+// times share a service
+func gtfsCalendar(trip models.GTFSTrip, exportedDate time.Time) ([]string, error) {
+	startDate, err := time.Parse("2006-01-02", trip.StartDate)
+	if err != nil {
+		return nil, errors.New("Invalid trip start date")
+	}
+
+	var endDate time.Time
+	if trip.EndDate == "" {
+		endDate = exportedDate
+		if startDate.After(endDate) {
+			endDate = startDate
+		}
+		endDate = endDate.AddDate(0, 0, gtfsOpenEndedServiceDays)
+	} else {
+		endDate, err = time.Parse("2006-01-02", trip.EndDate)
+		if err != nil {
+			return nil, errors.New("Invalid trip end date")
+		}
+	}
+
+	if endDate.Before(startDate) {
+		return nil, errors.New("Trip ends before it starts")
+	}
+
+	start, end := startDate.Format("20060102"), endDate.Format("20060102")
+
+	calendar := []string{fmt.Sprintf("%03d-%s-%s", trip.Days, start, end)}
+	for _, day := range gtfsDays {
+		if trip.Days & day.bit != 0 {
+			calendar = append(calendar, "1")
+		} else {
+			calendar = append(calendar, "0")
+		}
+	}
+
+	return append(calendar, start, end), nil
+}
+
+// gtfsTime formats seconds after midnight as HH:MM:SS. Times after midnight of
+// the next day are over 24:00:00
+func gtfsTime(seconds uint) string {
+	return fmt.Sprintf("%02d:%02d:%02d", seconds / 3600, seconds / 60 % 60, seconds % 60)
+}
+
+func zipGTFSFiles(files []gtfsFile, modified time.Time) ([]byte, error) {
+	var feed bytes.Buffer
+	zipWriter := zip.NewWriter(&feed)
+
+	for _, file := range files {
+		fileWriter, err := zipWriter.CreateHeader(&zip.FileHeader{
+			Name: file.name,
+			Method: zip.Deflate,
+			Modified: modified,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		csvWriter := csv.NewWriter(fileWriter)
+		if err := csvWriter.WriteAll(file.records); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := zipWriter.Close(); err != nil {
+		return nil, err
+	}
+
+	return feed.Bytes(), nil
+}
+
+// gtfsFormat gets the reason a value of a GTFS field is invalid, or an empty
+// string when it is valid
+type gtfsFormat func(value string) string
+
+// gtfsField is a field of a GTFS file. A required field must have a value, and
+// the key fields of a file identify each of its records
+type gtfsField struct {
+	name     string
+	required bool
+	key      bool
+	format   gtfsFormat
+}
+
+// gtfsFields are the fields of each exported file in the order they are
+// written, with the formats of the GTFS reference
+var gtfsFields = map[string][]gtfsField{
+	"agency.txt": {
+		{"agency_id", true, true, gtfsText},
+		{"agency_name", true, false, gtfsText},
+		{"agency_url", true, false, gtfsURL},
+		{"agency_timezone", true, false, gtfsTimezone},
+	},
+	"stops.txt": {
+		{"stop_id", true, true, gtfsText},
+		{"stop_name", true, false, gtfsText},
+		{"stop_lat", true, false, gtfsCoordinate(90)},
+		{"stop_lon", true, false, gtfsCoordinate(180)},
+	},
+	// agency_id is required as there is more than one agency, and
+	// route_short_name as routes don't have a route_long_name
+	"routes.txt": {
+		{"route_id", true, true, gtfsText},
+		{"agency_id", true, false, gtfsText},
+		{"route_short_name", true, false, gtfsText},
+		{"route_type", true, false, gtfsEnum("0", "1", "2", "3", "4", "5", "6", "7", "11", "12")},
+	},
+	"trips.txt": {
+		{"route_id", true, false, gtfsText},
+		{"service_id", true, false, gtfsText},
+		{"trip_id", true, true, gtfsText},
+		{"trip_headsign", false, false, gtfsText},
+		{"direction_id", false, false, gtfsEnum("0", "1")},
+	},
+	"stop_times.txt": {
+		{"trip_id", true, true, gtfsText},
+		{"arrival_time", true, false, gtfsTimeFormat},
+		{"departure_time", true, false, gtfsTimeFormat},
+		{"stop_id", true, false, gtfsText},
+		{"stop_sequence", true, true, gtfsNonNegativeInteger},
+	},
+	"calendar.txt": {
+		{"service_id", true, true, gtfsText},
+		{"monday", true, false, gtfsEnum("0", "1")},
+		{"tuesday", true, false, gtfsEnum("0", "1")},
+		{"wednesday", true, false, gtfsEnum("0", "1")},
+		{"thursday", true, false, gtfsEnum("0", "1")},
+		{"friday", true, false, gtfsEnum("0", "1")},
+		{"saturday", true, false, gtfsEnum("0", "1")},
+		{"sunday", true, false, gtfsEnum("0", "1")},
+		{"start_date", true, false, gtfsDate},
+		{"end_date", true, false, gtfsDate},
+	},
+}
+
+var gtfsTimePattern = regexp.MustCompile(`^[0-9]{1,3}:[0-5][0-9]:[0-5][0-9]$`)
+
+func gtfsText(value string) string {
+	if strings.TrimSpace(value) == "" {
+		return "is blank"
+	}
+
+	return ""
+}
+
+func gtfsURL(value string) string {
+	parsed, err := url.Parse(value)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return "isn't a http or https URL"
+	}
+
+	return ""
+}
+
+func gtfsTimezone(value string) string {
+	if _, err := time.LoadLocation(value); err != nil || value == "" || value == "Local" {
+		return "isn't a TZ timezone"
+	}
+
+	return ""
+}
+
+// gtfsCoordinate gets the format of a latitude or longitude from -limit to
+// limit degrees
+func gtfsCoordinate(limit float64) gtfsFormat {
+	return func(value string) string {
+		degrees, err := strconv.ParseFloat(value, 64)
+		if err != nil || degrees < -limit || degrees > limit {
+			return fmt.Sprintf("isn't a number from -%v to %v", limit, limit)
+		}
+
+		return ""
+	}
+}
+
+// gtfsEnum gets the format of a field which can only have one of values
+func gtfsEnum(values ...string) gtfsFormat {
+	return func(value string) string {
+		for _, allowed := range values {
+			if value == allowed {
+				return ""
+			}
+		}
+
+		return fmt.Sprintf("isn't one of %v", strings.Join(values, ", "))
+	}
+}
+
+func gtfsTimeFormat(value string) string {
+	if !gtfsTimePattern.MatchString(value) {
+		return "isn't a HH:MM:SS time"
+	}
+
+	return ""
+}
+
+func gtfsDate(value string) string {
+	if _, err := time.Parse("20060102", value); err != nil || len(value) != 8 {
+		return "isn't a YYYYMMDD date"
+	}
+
+	return ""
+}
+
+func gtfsNonNegativeInteger(value string) string {
+	if _, err := strconv.ParseUint(value, 10, 32); err != nil {
+		return "isn't a non-negative integer"
+	}
+
+	return ""
+}
+
+// validateGTFSRecord checks a record of a file has a value for every required
+// field of gtfsFields and that each value is in the format of its field
+func validateGTFSRecord(fileName string, record []string) error {
+	fields := gtfsFields[fileName]
+	if len(record) != len(fields) {
+		return fmt.Errorf("%s record has %d fields, want %d", fileName, len(record), len(fields))
+	}
+
+	for fieldIndex, field := range fields {
+		value := record[fieldIndex]
+		if value == "" {
+			if field.required {
+				return fmt.Errorf("%s %s is required", fileName, field.name)
+			}
+
+			continue
+		}
+
+		if reason := field.format(value); reason != "" {
+			return fmt.Errorf("%s %s %s", fileName, field.name, reason)
+		}
+	}
+
+	return nil
+}
+
+// validateGTFSFile checks a file has the header of its gtfsFields, that every
+// record is valid and that no two records have the same key fields
+func validateGTFSFile(file gtfsFile) error {
+	fields := gtfsFields[file.name]
+	header := newGTFSFile(file.name).records[0]
+	if len(file.records) == 0 || !reflect.DeepEqual(file.records[0], header) {
+		return fmt.Errorf("%s doesn't have the header %v", file.name, strings.Join(header, ","))
+	}
+
+	keys := make(map[string]bool)
+	for _, record := range file.records[1:] {
+		if err := validateGTFSRecord(file.name, record); err != nil {
+			return err
+		}
+
+		key := make([]string, 0)
+		for fieldIndex, field := range fields {
+			if field.key {
+				key = append(key, record[fieldIndex])
+			}
+		}
+
+		if keys[strings.Join(key, "\x00")] {
+			return fmt.Errorf("%s has more than one record for %v", file.name, strings.Join(key, ", "))
+		}
+		keys[strings.Join(key, "\x00")] = true
+	}
+
+	return nil
+}
@@ -0,0 +1,373 @@
+package controllers
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"server/models"
+	"testing"
+	"time"
+)
+
+func Test_buildGTFSFeed(t *testing.T) {
+	busStops := []models.BusStop{
+		models.BusStop{ID: "240098906", Name: "Bus Station", Longitude: 1.0813389, Latitude: 51.276302},
+		models.BusStop{ID: "240095612", Name: "Darwin College", Longitude: 1.0713621, Latitude: 51.29914},
+		models.BusStop{ID: "240097777", Name: "Unused", Longitude: 1.07, Latitude: 51.28},
+		models.BusStop{ID: "240097778", Name: " ", Longitude: 1.07, Latitude: 51.28},
+	}
+	timetable := models.GTFSTimetable{
+		Operators: []models.Operator{
+			models.Operator{ID: "SCEK", Name: "Stagecoach in East Kent", ShortName: "Stagecoach"},
+			models.Operator{ID: "NADS", ShortName: "Nu-Venture"},
+		},
+		Lines: []models.Line{
+			models.Line{ID: "SCEK:Uni1", OperatorID: "SCEK", Name: "Uni1"},
+			models.Line{ID: "NADS:1", OperatorID: "NADS", Name: "1"},
+			models.Line{ID: "NADS:2", OperatorID: "NADS"},
+		},
+		BusStops: busStops,
+		Trips: []models.GTFSTrip{
+			models.GTFSTrip{
+				ID: 1,
+				LineID: "SCEK:Uni1",
+				RouteID: "RT197",
+				Direction: "OUTBOUND",
+				DepartureTime: 86100,
+				Days: models.Monday | models.Tuesday | models.Wednesday | models.Thursday | models.Friday,
+				StartDate: "2021-03-07",
+				EndDate: "2021-07-31",
+				DatasetID: 2022,
+				Stops: []models.TripStop{
+					models.TripStop{BusStopID: busStops[0].ID, Offset: 0},
+					models.TripStop{BusStopID: busStops[1].ID, Offset: 840},
+				},
+			},
+			models.GTFSTrip{
+				ID: 2,
+				LineID: "SCEK:Uni1",
+				RouteID: "RT198",
+				Direction: "INBOUND",
+				DepartureTime: 36000,
+				Days: models.Saturday,
+				StartDate: "2021-03-07",
+				DatasetID: 2022,
+				Stops: []models.TripStop{
+					models.TripStop{BusStopID: busStops[1].ID, Offset: 0},
+					models.TripStop{BusStopID: busStops[0].ID, Offset: 900},
+				},
+			},
+			models.GTFSTrip{
+				ID: 3,
+				LineID: "NADS:1",
+				RouteID: "RT1",
+				Direction: "OUTBOUND",
+				DepartureTime: 36000,
+				Days: models.EveryDay,
+				StartDate: "2021-03-07",
+				DatasetID: 3000,
+				Stops: []models.TripStop{
+					models.TripStop{BusStopID: busStops[0].ID, Offset: 0},
+					models.TripStop{BusStopID: busStops[2].ID, Offset: 600},
+					models.TripStop{BusStopID: busStops[1].ID, Offset: 300},
+				},
+			},
+			models.GTFSTrip{
+				ID: 4,
+				LineID: "SCEK:Uni1",
+				RouteID: "RT197",
+				Direction: "OUTBOUND",
+				DepartureTime: 36000,
+				Days: 0,
+				StartDate: "2021-03-07",
+				DatasetID: 2022,
+				Stops: []models.TripStop{
+					models.TripStop{BusStopID: busStops[0].ID, Offset: 0},
+					models.TripStop{BusStopID: busStops[1].ID, Offset: 840},
+				},
+			},
+			models.GTFSTrip{
+				ID: 5,
+				LineID: "SCEK:Uni1",
+				RouteID: "RT197",
+				Direction: "OUTBOUND",
+				DepartureTime: 36000,
+				Days: models.Sunday,
+				StartDate: "2021-03-07",
+				EndDate: "2021-03-06",
+				DatasetID: 2022,
+				Stops: []models.TripStop{
+					models.TripStop{BusStopID: busStops[0].ID, Offset: 0},
+					models.TripStop{BusStopID: busStops[1].ID, Offset: 840},
+				},
+			},
+			models.GTFSTrip{
+				ID: 6,
+				LineID: "NADS:2",
+				RouteID: "RT2",
+				Direction: "OUTBOUND",
+				DepartureTime: 36000,
+				Days: models.EveryDay,
+				StartDate: "2021-03-07",
+				DatasetID: 3000,
+				Stops: []models.TripStop{
+					models.TripStop{BusStopID: busStops[0].ID, Offset: 0},
+					models.TripStop{BusStopID: busStops[1].ID, Offset: 600},
+				},
+			},
+			models.GTFSTrip{
+				ID: 7,
+				LineID: "SCEK:Uni1",
+				RouteID: "RT197",
+				Direction: "OUTBOUND",
+				DepartureTime: 36000,
+				Days: models.Sunday,
+				StartDate: "2021-03-07",
+				DatasetID: 2022,
+				Stops: []models.TripStop{
+					models.TripStop{BusStopID: busStops[0].ID, Offset: 0},
+					models.TripStop{BusStopID: busStops[3].ID, Offset: 840},
+				},
+			},
+			models.GTFSTrip{
+				ID: 8,
+				LineID: "SCEK:Uni1",
+				RouteID: "RT197",
+				Direction: "OUTBOUND",
+				DepartureTime: 36000,
+				Days: models.Sunday,
+				StartDate: "2021-02-30",
+				DatasetID: 2022,
+				Stops: []models.TripStop{
+					models.TripStop{BusStopID: busStops[0].ID, Offset: 0},
+					models.TripStop{BusStopID: busStops[1].ID, Offset: 840},
+				},
+			},
+		},
+	}
+	exportedAt := time.Date(2021, 4, 6, 12, 0, 0, 0, timetableLocation)
+
+	wantFiles := map[string]string{
+		"agency.txt": "agency_id,agency_name,agency_url,agency_timezone\n" +
+			"SCEK,Stagecoach in East Kent,https://www.bus-data.dft.gov.uk/,Europe/London\n",
+		"stops.txt": "stop_id,stop_name,stop_lat,stop_lon\n" +
+			"240098906,Bus Station,51.276302,1.0813389\n" +
+			"240095612,Darwin College,51.29914,1.0713621\n",
+		"routes.txt": "route_id,agency_id,route_short_name,route_type\n" +
+			"SCEK:Uni1,SCEK,Uni1,3\n",
+		"trips.txt": "route_id,service_id,trip_id,trip_headsign,direction_id\n" +
+			"SCEK:Uni1,031-20210307-20210731,1,Darwin College,0\n" +
+			"SCEK:Uni1,032-20210307-20220406,2,Bus Station,1\n",
+		"stop_times.txt": "trip_id,arrival_time,departure_time,stop_id,stop_sequence\n" +
+			"1,23:55:00,23:55:00,240098906,0\n" +
+			"1,24:09:00,24:09:00,240095612,1\n" +
+			"2,10:00:00,10:00:00,240095612,0\n" +
+			"2,10:15:00,10:15:00,240098906,1\n",
+		"calendar.txt": "service_id,monday,tuesday,wednesday,thursday,friday,saturday,sunday,start_date,end_date\n" +
+			"031-20210307-20210731,1,1,1,1,1,0,0,20210307,20210731\n" +
+			"032-20210307-20220406,0,0,0,0,0,1,0,20210307,20220406\n",
+	}
+	wantFileNames := []string{"agency.txt", "stops.txt", "routes.txt", "trips.txt", "stop_times.txt", "calendar.txt"}
+	wantExportErrors := []gtfsExportError{
+		gtfsExportError{TripID: 3, DatasetID: 3000, Reason: "Trip stop times go back in time"},
+		gtfsExportError{TripID: 4, DatasetID: 2022, Reason: "Trip doesn't run on any day of the week"},
+		gtfsExportError{TripID: 5, DatasetID: 2022, Reason: "Trip ends before it starts"},
+		gtfsExportError{TripID: 6, DatasetID: 3000, Reason: "routes.txt route_short_name is required"},
+		gtfsExportError{TripID: 7, DatasetID: 2022, Reason: "stops.txt stop_name is blank"},
+		gtfsExportError{TripID: 8, DatasetID: 2022, Reason: "Invalid trip start date"},
+	}
+
+	feed, exportErrors, err := buildGTFSFeed(timetable, exportedAt)
+	if err != nil {
+		t.Fatalf("buildGTFSFeed() error = %v", err)
+	}
+
+	if !reflect.DeepEqual(exportErrors, wantExportErrors) {
+		t.Errorf("buildGTFSFeed() exportErrors = %v, want %v", exportErrors, wantExportErrors)
+	}
+
+	zipReader, err := zip.NewReader(bytes.NewReader(feed), int64(len(feed)))
+	if err != nil {
+		t.Fatalf("buildGTFSFeed() feed isn't a zip file: %v", err)
+	}
+
+	fileNames := make([]string, 0)
+	for _, file := range zipReader.File {
+		fileNames = append(fileNames, file.Name)
+
+		reader, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		contents, err := ioutil.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(contents) != wantFiles[file.Name] {
+			t.Errorf("buildGTFSFeed() %v = %q, want %q", file.Name, contents, wantFiles[file.Name])
+		}
+	}
+
+	if !reflect.DeepEqual(fileNames, wantFileNames) {
+		t.Errorf("buildGTFSFeed() files = %v, want %v", fileNames, wantFileNames)
+	}
+}
+
+func Test_buildGTFSFeed_noTrips(t *testing.T) {
+	_, _, err := buildGTFSFeed(models.GTFSTimetable{}, time.Now())
+	if err == nil {
+		t.Errorf("buildGTFSFeed() error = nil, want an error when there are no trips")
+	}
+}
+
+func Test_validateGTFSRecord(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileName string
+		record   []string
+		wantErr  string
+	}{
+		{
+			name: "Allows a valid stop time after midnight of the next day",
+			fileName: "stop_times.txt",
+			record: []string{"1", "24:09:00", "24:09:00", "240095612", "1"},
+		},
+		{
+			name: "Allows an optional field to be empty",
+			fileName: "trips.txt",
+			record: []string{"SCEK:Uni1", "031-20210307-20210731", "1", "", ""},
+		},
+		{
+			name: "Rejects a missing required field",
+			fileName: "agency.txt",
+			record: []string{"SCEK", "", "https://www.bus-data.dft.gov.uk/", "Europe/London"},
+			wantErr: "agency.txt agency_name is required",
+		},
+		{
+			name: "Rejects an invalid URL",
+			fileName: "agency.txt",
+			record: []string{"SCEK", "Stagecoach", "www.bus-data.dft.gov.uk", "Europe/London"},
+			wantErr: "agency.txt agency_url isn't a http or https URL",
+		},
+		{
+			name: "Rejects an unknown timezone",
+			fileName: "agency.txt",
+			record: []string{"SCEK", "Stagecoach", "https://www.bus-data.dft.gov.uk/", "BST"},
+			wantErr: "agency.txt agency_timezone isn't a TZ timezone",
+		},
+		{
+			name: "Rejects a latitude out of range",
+			fileName: "stops.txt",
+			record: []string{"240098906", "Bus Station", "91", "1.0813389"},
+			wantErr: "stops.txt stop_lat isn't a number from -90 to 90",
+		},
+		{
+			name: "Rejects an unknown route type",
+			fileName: "routes.txt",
+			record: []string{"SCEK:Uni1", "SCEK", "Uni1", "8"},
+			wantErr: "routes.txt route_type isn't one of 0, 1, 2, 3, 4, 5, 6, 7, 11, 12",
+		},
+		{
+			name: "Rejects a time without seconds",
+			fileName: "stop_times.txt",
+			record: []string{"1", "8:15", "8:15", "240095612", "0"},
+			wantErr: "stop_times.txt arrival_time isn't a HH:MM:SS time",
+		},
+		{
+			name: "Rejects a negative stop sequence",
+			fileName: "stop_times.txt",
+			record: []string{"1", "08:15:00", "08:15:00", "240095612", "-1"},
+			wantErr: "stop_times.txt stop_sequence isn't a non-negative integer",
+		},
+		{
+			name: "Rejects a date which doesn't exist",
+			fileName: "calendar.txt",
+			record: []string{"001-20210230-20210731", "1", "0", "0", "0", "0", "0", "0", "20210230", "20210731"},
+			wantErr: "calendar.txt start_date isn't a YYYYMMDD date",
+		},
+		{
+			name: "Rejects a record with missing fields",
+			fileName: "trips.txt",
+			record: []string{"SCEK:Uni1", "031-20210307-20210731", "1"},
+			wantErr: "trips.txt record has 3 fields, want 5",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateGTFSRecord(tt.fileName, tt.record)
+			if (err == nil) != (tt.wantErr == "") || (err != nil && err.Error() != tt.wantErr) {
+				t.Errorf("validateGTFSRecord() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_validateGTFSFile(t *testing.T) {
+	file := newGTFSFile("stop_times.txt")
+	file.records = append(file.records,
+		[]string{"1", "08:00:00", "08:00:00", "240098906", "0"},
+		[]string{"1", "08:10:00", "08:10:00", "240095612", "1"},
+		[]string{"2", "08:00:00", "08:00:00", "240098906", "0"},
+	)
+
+	if err := validateGTFSFile(file); err != nil {
+		t.Errorf("validateGTFSFile() error = %v, want nil", err)
+	}
+
+	file.records = append(file.records, []string{"1", "08:20:00", "08:20:00", "240098906", "1"})
+
+	if err := validateGTFSFile(file); err == nil {
+		t.Errorf("validateGTFSFile() error = nil, want an error for a repeated trip_id and stop_sequence")
+	}
+}
+
+func Test_gtfsTime(t *testing.T) {
+	tests := []struct {
+		name    string
+		seconds uint
+		want    string
+	}{
+		{name: "Formats midnight", seconds: 0, want: "00:00:00"},
+		{name: "Formats a time of day", seconds: 29730, want: "08:15:30"},
+		{name: "Formats a time after midnight of the next day", seconds: 90000, want: "25:00:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := gtfsTime(tt.seconds); got != tt.want {
+				t.Errorf("gtfsTime() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_skippedTripsSummary(t *testing.T) {
+	exportErrors := make([]gtfsExportError, 0)
+	for tripID := uint(1); tripID <= 8; tripID++ {
+		reason := "Trip doesn't run on any day"
+		if tripID % 2 == 0 {
+			reason = "Trip has fewer than two stops"
+		}
+
+		exportErrors = append(exportErrors, gtfsExportError{TripID: tripID, DatasetID: 2022, Reason: reason})
+	}
+
+	got := skippedTripsSummary(exportErrors)
+
+	if got["SkippedTrips"] != 8 {
+		t.Errorf("skippedTripsSummary() SkippedTrips = %v, want 8", got["SkippedTrips"])
+	}
+
+	wantReasons := map[string]int{"Trip doesn't run on any day": 4, "Trip has fewer than two stops": 4}
+	if !reflect.DeepEqual(got["Reasons"], wantReasons) {
+		t.Errorf("skippedTripsSummary() Reasons = %v, want %v", got["Reasons"], wantReasons)
+	}
+
+	sampleTrips, _ := got["SampleTrips"].([]models.JobLogFields)
+	if len(sampleTrips) != gtfsSampleSkippedTrips || sampleTrips[0]["TripID"] != uint(1) {
+		t.Errorf("skippedTripsSummary() SampleTrips = %v, want the first %v trips", sampleTrips, gtfsSampleSkippedTrips)
+	}
+}
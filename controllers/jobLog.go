@@ -0,0 +1,193 @@
+package controllers
+
+import (
+	"server/models"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"database/sql"
+	_ "github.com/lib/pq"
+)
+
+// maxJobLogEntries is how many entries a job can log each time it runs, so a
+// job logging a warning for every file can't fill the db
+const maxJobLogEntries = 10000
+
+// jobLogFlushInterval is how often the entries logged by a running job are
+// saved, so they can be followed while it runs
+const jobLogFlushInterval = time.Second
+
+// jobLogKey is the context key of the log of a running job
+type jobLogKey struct{}
+
+// jobLog buffers the entries logged by a running job until they are saved.
+// dropped counts the entries logged after maxJobLogEntries. flushing is held
+// while entries are saved so they are saved in the order they were logged
+type jobLog struct {
+	sync.Mutex
+	flushing sync.Mutex
+	jobID   uint
+	entries []models.JobLogEntry
+	logged  uint
+	dropped uint
+}
+
+// withJobLog gets a context which saves the entries logged with it to the log
+// of a job
+func withJobLog(ctx context.Context, jobID uint) (context.Context, *jobLog) {
+	jobLog := &jobLog{jobID: jobID}
+
+	return context.WithValue(ctx, jobLogKey{}, jobLog), jobLog
+}
+
+// logJob logs a message with its fields to stderr, and to the log of the job
+// running with ctx. WARN and ERROR messages are logged as errors
+func logJob(ctx context.Context, level string, message string, fields models.JobLogFields) {
+	jobLog, _ := ctx.Value(jobLogKey{}).(*jobLog)
+
+	line := message
+	if jobLog != nil {
+		line = fmt.Sprintf("Job %d: %s", jobLog.jobID, message)
+	}
+	if len(fields) > 0 {
+		line += " " + formatJobLogFields(fields)
+	}
+
+	if level == "WARN" || level == "ERROR" {
+		fmt.Fprintln(os.Stderr, line)
+	} else {
+		log.Println(line)
+	}
+
+	if jobLog != nil {
+		jobLog.add(models.JobLogEntry{Level: level, Message: message, Fields: fields, CreatedAt: time.Now()})
+	}
+}
+
+// formatJobLogFields formats fields as key=value pairs in key order
+func formatJobLogFields(fields models.JobLogFields) string {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = fmt.Sprintf("%s=%v", key, fields[key])
+	}
+
+	return strings.Join(pairs, " ")
+}
+
+func (jobLog *jobLog) add(entry models.JobLogEntry) {
+	jobLog.Lock()
+	defer jobLog.Unlock()
+
+	if jobLog.logged >= maxJobLogEntries {
+		jobLog.dropped++
+		return
+	}
+
+	jobLog.logged++
+	jobLog.entries = append(jobLog.entries, entry)
+}
+
+// flushEvery saves the buffered entries every jobLogFlushInterval until done
+// is closed
+func (jobLog *jobLog) flushEvery(done chan struct{}) {
+	ticker := time.NewTicker(jobLogFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+			case <-done:
+				return
+			case <-ticker.C:
+				jobLog.flush()
+		}
+	}
+}
+
+// flush saves the buffered entries, noting how many were dropped once the job
+// has logged maxJobLogEntries. Entries which couldn't be saved are kept to be
+// saved by the next flush
+func (jobLog *jobLog) flush() {
+	jobLog.flushing.Lock()
+	defer jobLog.flushing.Unlock()
+
+	jobLog.Lock()
+	entries := jobLog.entries
+	jobLog.entries = nil
+
+	if jobLog.dropped > 0 {
+		entries = append(entries, models.JobLogEntry{
+			Level: "WARN",
+			Message: "Job log is full, dropped entries",
+			Fields: models.JobLogFields{"Dropped": jobLog.dropped},
+			CreatedAt: time.Now(),
+		})
+		jobLog.dropped = 0
+	}
+	jobLog.Unlock()
+
+	if len(entries) == 0 {
+		return
+	}
+
+	db, err := sql.Open("postgres", os.Getenv("DATABASE_URL"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Couldn't connect to db", err)
+	} else {
+		err = models.InsertJobLogs(jobLog.jobID, entries, db)
+		db.Close()
+	}
+
+	if err != nil {
+		jobLog.Lock()
+		jobLog.entries = append(entries, jobLog.entries...)
+		jobLog.Unlock()
+	}
+}
+
+// GetBackgroundJobLogs gets up to limit log entries of a job at level or above,
+// logged after the entry with the id of afterID. When tail is true the last
+// limit entries are got instead. finished is true when the job had finished
+// before the entries were got, so it won't log any more. found is false when
+// there is no job with the id of jobID
+func GetBackgroundJobLogs(jobID uint, level string, afterID uint, limit uint, tail bool) ([]models.JobLogEntry, bool, bool, error) {
+	connectionString := os.Getenv("DATABASE_URL")
+
+	db, err := sql.Open("postgres", connectionString)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Couldn't connect to db", err)
+
+		return nil, false, false, err
+	}
+	defer db.Close()
+
+	// the status is got before the entries, so no entries are logged after a
+	// finished job's entries are got
+	status, err := models.GetJobStatus(jobID, db)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, false, false, nil
+		}
+
+		return nil, false, false, err
+	}
+
+	finished := status != "QUEUED" && status != "RUNNING"
+
+	entries, err := models.GetJobLogs(jobID, level, afterID, limit, tail, db)
+	if err != nil {
+		return nil, false, true, err
+	}
+
+	return entries, finished, true, nil
+}
@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"server/models"
+	"context"
+	"testing"
+)
+
+func Test_logJob(t *testing.T) {
+	tests := []struct {
+		name        string
+		logged      int
+		wantEntries int
+		wantDropped uint
+	}{
+		{
+			name: "Buffers each entry",
+			logged: 3,
+			wantEntries: 4,
+			wantDropped: 0,
+		},
+		{
+			name: "Drops the entries after the maximum",
+			logged: maxJobLogEntries + 5,
+			wantEntries: maxJobLogEntries,
+			wantDropped: 6,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, jobLog := withJobLog(context.Background(), 1)
+
+			for i := 0; i < tt.logged; i++ {
+				jobLog.add(models.JobLogEntry{Level: "DEBUG", Message: "Parsed file"})
+			}
+			logJob(ctx, "INFO", "Imported dataset", models.JobLogFields{"DatasetID": 1})
+
+			if len(jobLog.entries) != tt.wantEntries {
+				t.Errorf("logJob() entries = %v, want %v", len(jobLog.entries), tt.wantEntries)
+			}
+			if jobLog.dropped != tt.wantDropped {
+				t.Errorf("logJob() dropped = %v, want %v", jobLog.dropped, tt.wantDropped)
+			}
+		})
+	}
+}
+
+func Test_logJob_withoutJobLog(t *testing.T) {
+	// a context without a job log, such as in an importer test, only logs to
+	// stderr
+	logJob(context.Background(), "WARN", "Skipped TransXChange file", models.JobLogFields{"FileName": "a.xml"})
+}
+
+func Test_formatJobLogFields(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields models.JobLogFields
+		want   string
+	}{
+		{
+			name: "Formats no fields",
+			fields: models.JobLogFields{},
+			want: "",
+		},
+		{
+			name: "Formats fields in key order",
+			fields: models.JobLogFields{"FileName": "a.xml", "DatasetID": 12},
+			want: "DatasetID=12 FileName=a.xml",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatJobLogFields(tt.fields); got != tt.want {
+				t.Errorf("formatJobLogFields() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
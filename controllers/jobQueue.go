@@ -0,0 +1,207 @@
+package controllers
+
+import (
+	"server/models"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+	"database/sql"
+	"github.com/lib/pq"
+)
+
+// maxRetryDelay is the longest a job waits before being retried
+const maxRetryDelay = time.Hour
+
+// jobPollInterval is how often idle workers check for jobs which are due, such
+// as retries or jobs queued by another process
+const jobPollInterval = 5 * time.Second
+
+// defaultJobWorkers is how many jobs run at once when JOB_WORKERS isn't set
+const defaultJobWorkers = 2
+
+// queuedJobs wakes an idle worker when a job is queued
+var queuedJobs = make(chan struct{}, 1)
+
+// transientError is a failure which may not happen again if the job is
+// retried, such as a DfT or BODS request timing out or returning a server error
+type transientError struct {
+	err error
+}
+
+func (transient transientError) Error() string {
+	return transient.err.Error()
+}
+
+func (transient transientError) Unwrap() error {
+	return transient.err
+}
+
+func isTransientError(err error) bool {
+	var transient transientError
+
+	return errors.As(err, &transient)
+}
+
+// isDatabaseConflict is true when a transaction was rolled back because it
+// deadlocked or couldn't be serialised with another, such as two datasets with
+// the same operator importing in parallel, so can be run again
+func isDatabaseConflict(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "40P01" || pqErr.Code == "40001"
+	}
+
+	return false
+}
+
+// dftStatusError is the error of a non 200 DfT or BODS response, which is
+// transient when it is a server error or the rate limit was exceeded
+func dftStatusError(statusCode int) error {
+	err := fmt.Errorf("DFT returned non 200 status of: %d", statusCode)
+	if statusCode >= 500 || statusCode == http.StatusTooManyRequests {
+		return transientError{err: err}
+	}
+
+	return err
+}
+
+// retryDelay is how long a job waits before its next attempt after failing
+// attempt times, doubling with each attempt up to maxRetryDelay
+func retryDelay(baseDelay time.Duration, attempt uint) time.Duration {
+	delay := baseDelay
+	for retry := uint(1); retry < attempt && delay < maxRetryDelay; retry++ {
+		delay *= 2
+	}
+
+	if delay > maxRetryDelay {
+		return maxRetryDelay
+	}
+
+	return delay
+}
+
+// parseJobWorkers parses how many jobs run at once, falling back to
+// defaultJobWorkers when it is empty or invalid
+func parseJobWorkers(value string) int {
+	if value == "" {
+		return defaultJobWorkers
+	}
+
+	workers, err := strconv.Atoi(value)
+	if err != nil || workers < 1 {
+		fmt.Fprintln(os.Stderr, "Invalid JOB_WORKERS, using", defaultJobWorkers, value)
+
+		return defaultJobWorkers
+	}
+
+	return workers
+}
+
+// enqueueBackgroundJob queues a job with its parameters to be run by a worker.
+// When its type's concurrency doesn't allow another job like it to be queued,
+// the job already queued or running is returned instead
+func enqueueBackgroundJob(name string, parameters interface{}) (models.BackgroundJob, error) {
+	jobType, ok := getBackgroundJobType(name)
+	if !ok {
+		return models.BackgroundJob{}, ErrUnknownJobType
+	}
+
+	// recover jobs left running by a stopped process first so they don't block
+	// the new job, sending webhooks the jobs which are failed
+	expireBackgroundJobs()
+
+	connectionString := os.Getenv("DATABASE_URL")
+
+	db, err := sql.Open("postgres", connectionString)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Couldn't connect to db", err)
+		return models.BackgroundJob{}, err
+	}
+	defer db.Close()
+
+	job, created, err := models.CreateBackgroundJob(name, parameters, jobUniqueKey(jobType, parameters), jobType.maxAttempts, db)
+	if err != nil {
+		return models.BackgroundJob{}, err
+	}
+
+	if !created {
+		log.Println("Background job already queued or running", job.ID, job.Type, job.UniqueKey)
+
+		return job, nil
+	}
+
+	select {
+		case queuedJobs <- struct{}{}:
+		default:
+	}
+
+	return job, nil
+}
+
+// StartBackgroundJobWorkers starts JOB_WORKERS workers which run queued jobs,
+// and a worker which sends queued webhook deliveries. Jobs and deliveries are
+// claimed from the database so they are shared with the workers of other
+// processes
+func StartBackgroundJobWorkers() {
+	workers := parseJobWorkers(os.Getenv("JOB_WORKERS"))
+
+	for worker := 0; worker < workers; worker++ {
+		go runBackgroundJobWorker()
+	}
+
+	go runWebhookWorker()
+}
+
+func runBackgroundJobWorker() {
+	for {
+		job, found := claimBackgroundJob()
+		if !found {
+			select {
+				case <-queuedJobs:
+				case <-time.After(jobPollInterval):
+			}
+
+			continue
+		}
+
+		runBackgroundJob(job)
+	}
+}
+
+// claimBackgroundJob claims the next job due to run. found is false when there
+// is no job due or it couldn't be claimed
+func claimBackgroundJob() (models.BackgroundJob, bool) {
+	db, err := sql.Open("postgres", os.Getenv("DATABASE_URL"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Couldn't connect to db", err)
+		return models.BackgroundJob{}, false
+	}
+	defer db.Close()
+
+	job, err := models.ClaimBackgroundJob(db)
+	if err != nil {
+		return models.BackgroundJob{}, false
+	}
+
+	return job, true
+}
+
+func runBackgroundJob(job models.BackgroundJob) {
+	jobType, ok := getBackgroundJobType(job.Type)
+
+	ctx := startBackgroundJob(job, jobTimeout(jobType))
+	logJob(ctx, "INFO", "Running job", models.JobLogFields{"Type": job.Type, "Attempt": job.Attempts})
+
+	if !ok {
+		finishBackgroundJob(ctx, job, nil, fmt.Errorf("Unknown job type %v", job.Type))
+		return
+	}
+
+	result, err := jobType.run(ctx, job, &models.JobDatasets{JobID: job.ID, Attempt: job.Attempts})
+
+	finishBackgroundJob(ctx, job, result, err)
+}
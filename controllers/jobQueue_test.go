@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+	"github.com/lib/pq"
+)
+
+func Test_retryDelay(t *testing.T) {
+	tests := []struct {
+		name      string
+		baseDelay time.Duration
+		attempt   uint
+		want      time.Duration
+	}{
+		{
+			name: "Waits the base delay after the first attempt",
+			baseDelay: time.Minute,
+			attempt: 1,
+			want: time.Minute,
+		},
+		{
+			name: "Doubles the delay after each attempt",
+			baseDelay: time.Minute,
+			attempt: 3,
+			want: 4 * time.Minute,
+		},
+		{
+			name: "Waits at most maxRetryDelay",
+			baseDelay: 5 * time.Minute,
+			attempt: 10,
+			want: maxRetryDelay,
+		},
+		{
+			name: "Limits a base delay longer than maxRetryDelay",
+			baseDelay: 2 * time.Hour,
+			attempt: 1,
+			want: maxRetryDelay,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retryDelay(tt.baseDelay, tt.attempt); got != tt.want {
+				t.Errorf("retryDelay() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_isTransientError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "A server error is transient",
+			err: dftStatusError(503),
+			want: true,
+		},
+		{
+			name: "Exceeding the rate limit is transient",
+			err: dftStatusError(429),
+			want: true,
+		},
+		{
+			name: "A client error isn't transient",
+			err: dftStatusError(404),
+			want: false,
+		},
+		{
+			name: "A wrapped transient error is transient",
+			err: fmt.Errorf("Dataset 2022 failed to import: %w", transientError{err: errors.New("timeout")}),
+			want: true,
+		},
+		{
+			name: "Other errors aren't transient",
+			err: errors.New("Unmarshal failed"),
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isTransientError(tt.err); got != tt.want {
+				t.Errorf("isTransientError() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_isDatabaseConflict(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "A deadlock is a conflict",
+			err: &pq.Error{Code: "40P01"},
+			want: true,
+		},
+		{
+			name: "A serialisation failure is a conflict",
+			err: fmt.Errorf("Failed to replace dataset: %w", &pq.Error{Code: "40001"}),
+			want: true,
+		},
+		{
+			name: "A constraint violation isn't a conflict",
+			err: &pq.Error{Code: "23505"},
+			want: false,
+		},
+		{
+			name: "Other errors aren't conflicts",
+			err: errors.New("Failed to read file"),
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isDatabaseConflict(tt.err); got != tt.want {
+				t.Errorf("isDatabaseConflict() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseJobWorkers(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  int
+	}{
+		{
+			name: "Defaults when JOB_WORKERS isn't set",
+			value: "",
+			want: defaultJobWorkers,
+		},
+		{
+			name: "Parses the number of workers",
+			value: "4",
+			want: 4,
+		},
+		{
+			name: "Defaults when the number is invalid",
+			value: "four",
+			want: defaultJobWorkers,
+		},
+		{
+			name: "Defaults when there are no workers",
+			value: "0",
+			want: defaultJobWorkers,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseJobWorkers(tt.value); got != tt.want {
+				t.Errorf("parseJobWorkers() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
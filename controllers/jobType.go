@@ -0,0 +1,264 @@
+package controllers
+
+import (
+	"server/models"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+	"database/sql"
+)
+
+// The names of the types of background job
+const (
+	updateBusStopsJobType = "UPDATE NATIONAL PUBLIC TRANSPORT ACCESS NODES"
+	updateRouteJobType    = "UPDATE ROUTES BY DATASET ID"
+	updateRoutesJobType   = "UPDATE ALL PUBLISHED ROUTES"
+	exportGTFSJobType     = "EXPORT GTFS"
+)
+
+// jobRunner runs a claimed job, recording its progress on jobDatasets, and
+// returns a summary of the rows it wrote
+type jobRunner func(ctx context.Context, job models.BackgroundJob, jobDatasets models.JobDataset) (models.BackgroundJobResult, error)
+
+// jobParameters are the JSON parameters a job is queued with. validate checks
+// them before the job is queued
+type jobParameters interface {
+	validate() error
+}
+
+// uniqueJobParameters are parameters which identify what a job works on, such
+// as the dataset it imports
+type uniqueJobParameters interface {
+	uniqueKey() string
+}
+
+// jobConcurrency is how many jobs of a type can be queued or running at once.
+// Queuing a job while it can't be queued gets the job already queued or
+// running instead
+type jobConcurrency string
+
+const (
+	// jobConcurrencyOne queues one job of a type at a time
+	jobConcurrencyOne jobConcurrency = "ONE"
+	// jobConcurrencyOnePerKey queues one job of a type at a time for each
+	// uniqueKey of its parameters, so jobs working on different things run in
+	// parallel
+	jobConcurrencyOnePerKey jobConcurrency = "ONE PER KEY"
+	// jobConcurrencyUnlimited queues every job of its type
+	jobConcurrencyUnlimited jobConcurrency = "UNLIMITED"
+)
+
+// backgroundJobType is a type of job which can be queued. parameters gets the
+// parameters the job is queued with to be decoded into, and is nil when the job
+// has none. The job is stopped after timeout, or MAX_JOB_DURATION if timeout is
+// 0. A job which fails with a transientError is retried
+// up to maxAttempts times, waiting retryDelay before the first retry and twice
+// as long before each retry after that
+type backgroundJobType struct {
+	name        string
+	parameters  func() jobParameters
+	run         jobRunner
+	concurrency jobConcurrency
+	timeout     time.Duration
+	maxAttempts uint
+	retryDelay  time.Duration
+}
+
+// backgroundJobTypes are the jobs which can be queued
+var backgroundJobTypes = []backgroundJobType{
+	{
+		name: updateBusStopsJobType,
+		run: runUpdateBusStops,
+		concurrency: jobConcurrencyOne,
+		timeout: time.Hour,
+		maxAttempts: 3,
+		retryDelay: time.Minute,
+	},
+	{
+		name: updateRouteJobType,
+		parameters: func() jobParameters { return &updateRouteParameters{} },
+		run: runUpdateRoute,
+		concurrency: jobConcurrencyOnePerKey,
+		timeout: time.Hour,
+		maxAttempts: 3,
+		retryDelay: time.Minute,
+	},
+	{
+		name: updateRoutesJobType,
+		parameters: func() jobParameters { return &updateRoutesParameters{} },
+		run: runUpdateRoutes,
+		concurrency: jobConcurrencyOne,
+		// a national sync downloads thousands of datasets, 2 seconds apart
+		timeout: 24 * time.Hour,
+		maxAttempts: 2,
+		retryDelay: 5 * time.Minute,
+	},
+	{
+		name: exportGTFSJobType,
+		run: runGTFSExport,
+		concurrency: jobConcurrencyOne,
+		timeout: time.Hour,
+		maxAttempts: 1,
+	},
+}
+
+// ErrUnknownJobType is returned when queuing a job of a type which isn't in
+// backgroundJobTypes
+var ErrUnknownJobType = errors.New("Unknown job type")
+
+// JobParametersError is returned when queuing a job with parameters which
+// don't match its type
+type JobParametersError struct {
+	err error
+}
+
+func (parametersError JobParametersError) Error() string {
+	return parametersError.err.Error()
+}
+
+// getBackgroundJobType gets a type of job by its name
+func getBackgroundJobType(name string) (backgroundJobType, bool) {
+	for _, jobType := range backgroundJobTypes {
+		if jobType.name == name {
+			return jobType, true
+		}
+	}
+
+	return backgroundJobType{}, false
+}
+
+// BackgroundJobTypeNames are the names of the jobs which can be queued
+func BackgroundJobTypeNames() []string {
+	names := make([]string, 0, len(backgroundJobTypes))
+	for _, jobType := range backgroundJobTypes {
+		names = append(names, jobType.name)
+	}
+
+	return names
+}
+
+// GetBackgroundJobTypes describes each type of job which can be queued with the
+// parameters it is queued with
+func GetBackgroundJobTypes() []models.BackgroundJobTypeSchema {
+	schemas := make([]models.BackgroundJobTypeSchema, 0, len(backgroundJobTypes))
+
+	for _, jobType := range backgroundJobTypes {
+		schema := models.BackgroundJobTypeSchema{
+			Name: jobType.name,
+			Parameters: map[string]string{},
+			Concurrency: string(jobType.concurrency),
+			Timeout: jobTimeout(jobType).String(),
+			MaxAttempts: jobType.maxAttempts,
+		}
+
+		if jobType.parameters != nil {
+			schema.Parameters = jobParametersSchema(jobType.parameters())
+		}
+
+		schemas = append(schemas, schema)
+	}
+
+	return schemas
+}
+
+// jobParametersSchema is the JSON type of each field of a job's parameters by
+// its name
+func jobParametersSchema(parameters jobParameters) map[string]string {
+	schema := make(map[string]string)
+
+	parametersType := reflect.TypeOf(parameters).Elem()
+	for fieldIndex := 0; fieldIndex < parametersType.NumField(); fieldIndex++ {
+		field := parametersType.Field(fieldIndex)
+
+		switch field.Type.Kind() {
+			case reflect.Bool:
+				schema[field.Name] = "boolean"
+			case reflect.Int, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+				schema[field.Name] = "integer"
+			case reflect.Float32, reflect.Float64:
+				schema[field.Name] = "number"
+			default:
+				schema[field.Name] = "string"
+		}
+	}
+
+	return schema
+}
+
+// jobUniqueKey is the key only one job of a type can be queued or running with
+// at once, which is not valid when the type has no limit. Parameters without
+// a uniqueKey have the same key
+func jobUniqueKey(jobType backgroundJobType, parameters interface{}) sql.NullString {
+	switch jobType.concurrency {
+		case jobConcurrencyUnlimited:
+			return sql.NullString{}
+		case jobConcurrencyOnePerKey:
+			if unique, ok := parameters.(uniqueJobParameters); ok {
+				return sql.NullString{String: unique.uniqueKey(), Valid: true}
+			}
+	}
+
+	return sql.NullString{String: "", Valid: true}
+}
+
+// jobTimeout is how long a job of a type can run for before it is stopped and
+// failed
+func jobTimeout(jobType backgroundJobType) time.Duration {
+	if jobType.timeout > 0 {
+		return jobType.timeout
+	}
+
+	return maxJobDuration
+}
+
+// parseJobParameters decodes and validates the JSON parameters of a job of a
+// type. Unknown parameters aren't allowed, and a type without parameters can
+// only be given null or an empty object
+func parseJobParameters(jobType backgroundJobType, parametersJSON json.RawMessage) (jobParameters, error) {
+	trimmed := bytes.TrimSpace(parametersJSON)
+	empty := len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null"))
+
+	if jobType.parameters == nil {
+		if !empty && !bytes.Equal(trimmed, []byte("{}")) {
+			return nil, JobParametersError{err: fmt.Errorf("%s has no parameters", jobType.name)}
+		}
+
+		return nil, nil
+	}
+
+	parameters := jobType.parameters()
+	if !empty {
+		decoder := json.NewDecoder(bytes.NewReader(trimmed))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(parameters); err != nil {
+			return nil, JobParametersError{err: fmt.Errorf("Invalid parameters for %s: %v", jobType.name, err)}
+		}
+	}
+
+	if err := parameters.validate(); err != nil {
+		return nil, JobParametersError{err: err}
+	}
+
+	return parameters, nil
+}
+
+// QueueBackgroundJob queues a job of any type with its JSON parameters.
+// ErrUnknownJobType is returned when the type can't be queued, and a
+// JobParametersError when the parameters don't match the type
+func QueueBackgroundJob(name string, parametersJSON json.RawMessage) (models.BackgroundJob, error) {
+	jobType, ok := getBackgroundJobType(name)
+	if !ok {
+		return models.BackgroundJob{}, ErrUnknownJobType
+	}
+
+	parameters, err := parseJobParameters(jobType, parametersJSON)
+	if err != nil {
+		return models.BackgroundJob{}, err
+	}
+
+	return enqueueBackgroundJob(name, parameters)
+}
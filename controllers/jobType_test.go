@@ -0,0 +1,177 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_parseJobParameters(t *testing.T) {
+	tests := []struct {
+		name       string
+		jobType    string
+		parameters string
+		want       jobParameters
+		wantErr    bool
+	}{
+		{
+			name: "Parses the parameters of a job",
+			jobType: updateRouteJobType,
+			parameters: `{"DatasetID": 2022, "Force": true}`,
+			want: &updateRouteParameters{DatasetID: 2022, Force: true},
+		},
+		{
+			name: "Allows a job without parameters to have none",
+			jobType: exportGTFSJobType,
+			parameters: "",
+			want: nil,
+		},
+		{
+			name: "Allows a job without parameters to have an empty object",
+			jobType: updateBusStopsJobType,
+			parameters: "{}",
+			want: nil,
+		},
+		{
+			name: "Allows optional parameters to be left out",
+			jobType: updateRoutesJobType,
+			parameters: "null",
+			want: &updateRoutesParameters{},
+		},
+		{
+			name: "Rejects parameters on a job without any",
+			jobType: exportGTFSJobType,
+			parameters: `{"Force": true}`,
+			wantErr: true,
+		},
+		{
+			name: "Rejects unknown parameters",
+			jobType: updateRoutesJobType,
+			parameters: `{"DatasetID": 2022}`,
+			wantErr: true,
+		},
+		{
+			name: "Rejects parameters of the wrong JSON type",
+			jobType: updateRouteJobType,
+			parameters: `{"DatasetID": "2022"}`,
+			wantErr: true,
+		},
+		{
+			name: "Rejects parameters which aren't valid",
+			jobType: updateRouteJobType,
+			parameters: `{"Force": true}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jobType, ok := getBackgroundJobType(tt.jobType)
+			if !ok {
+				t.Fatalf("getBackgroundJobType() didn't find %v", tt.jobType)
+			}
+
+			got, err := parseJobParameters(jobType, json.RawMessage(tt.parameters))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseJobParameters() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if _, ok := err.(JobParametersError); tt.wantErr && !ok {
+				t.Errorf("parseJobParameters() error = %T, want JobParametersError", err)
+			}
+
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseJobParameters() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_jobTimeout(t *testing.T) {
+	tests := []struct {
+		name           string
+		timeout        time.Duration
+		maxJobDuration time.Duration
+		want           time.Duration
+	}{
+		{
+			name: "Uses the timeout of the job type",
+			timeout: time.Hour,
+			maxJobDuration: 6 * time.Hour,
+			want: time.Hour,
+		},
+		{
+			name: "Uses MAX_JOB_DURATION when the job type has no timeout",
+			timeout: 0,
+			maxJobDuration: 6 * time.Hour,
+			want: 6 * time.Hour,
+		},
+		{
+			name: "Uses the timeout of the job type when it is longer than MAX_JOB_DURATION",
+			timeout: 24 * time.Hour,
+			maxJobDuration: 6 * time.Hour,
+			want: 24 * time.Hour,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func(duration time.Duration) { maxJobDuration = duration }(maxJobDuration)
+			maxJobDuration = tt.maxJobDuration
+
+			if got := jobTimeout(backgroundJobType{timeout: tt.timeout}); got != tt.want {
+				t.Errorf("jobTimeout() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_jobParametersSchema(t *testing.T) {
+	want := map[string]string{"NOC": "string", "AdminArea": "string", "Force": "boolean"}
+
+	if got := jobParametersSchema(&updateRoutesParameters{}); !reflect.DeepEqual(got, want) {
+		t.Errorf("jobParametersSchema() = %v, want %v", got, want)
+	}
+}
+
+func Test_jobUniqueKey(t *testing.T) {
+	tests := []struct {
+		name       string
+		jobType    backgroundJobType
+		parameters interface{}
+		want       sql.NullString
+	}{
+		{
+			name: "Keys every job of a type which runs one at a time the same",
+			jobType: backgroundJobType{concurrency: jobConcurrencyOne},
+			parameters: &updateRoutesParameters{NOC: "SCEK"},
+			want: sql.NullString{String: "", Valid: true},
+		},
+		{
+			name: "Keys jobs by their parameters",
+			jobType: backgroundJobType{concurrency: jobConcurrencyOnePerKey},
+			parameters: &updateRouteParameters{DatasetID: 2022, Force: true},
+			want: sql.NullString{String: "DatasetID=2022", Valid: true},
+		},
+		{
+			name: "Keys jobs without a key the same",
+			jobType: backgroundJobType{concurrency: jobConcurrencyOnePerKey},
+			parameters: nil,
+			want: sql.NullString{String: "", Valid: true},
+		},
+		{
+			name: "Doesn't key jobs of a type without a limit",
+			jobType: backgroundJobType{concurrency: jobConcurrencyUnlimited},
+			parameters: &updateRouteParameters{DatasetID: 2022},
+			want: sql.NullString{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := jobUniqueKey(tt.jobType, tt.parameters); got != tt.want {
+				t.Errorf("jobUniqueKey() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
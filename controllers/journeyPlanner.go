@@ -0,0 +1,422 @@
+package controllers
+
+import (
+	"server/models"
+	"sort"
+	"sync"
+	"time"
+	"os"
+	"fmt"
+	_ "time/tzdata"
+)
+
+// minimumChangeTime is the time in seconds allowed to change between buses at a
+// stop
+const minimumChangeTime = 60
+
+// maximumItineraries is the most itineraries returned by a journey plan
+const maximumItineraries = 5
+
+// timetableNetworkTTL is how long the trips of a day are held in memory before
+// they are reloaded
+const timetableNetworkTTL = 15 * time.Minute
+
+// previousServiceDayOverrun is how many seconds after midnight the trips of the
+// previous service day are also searched. Trips running after midnight belong to
+// the service day they started on, with times over 24 hours
+const previousServiceDayOverrun = 6 * 3600
+
+// secondsPerDay is the offset of the times of the next day from the times of a
+// service day
+const secondsPerDay = 24 * 3600
+
+// timetableLocation is the time zone TransXChange departure times are in
+var timetableLocation = loadTimetableLocation()
+
+func loadTimetableLocation() *time.Location {
+	location, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to load Europe/London time zone", err)
+
+		return time.UTC
+	}
+
+	return location
+}
+
+// timetableNetwork is every trip running on a service date indexed by the stops
+// they call at, and the bus stops they call at keyed by ID
+type timetableNetwork struct {
+	serviceDate time.Time
+	trips       []models.TimetableTrip
+	busStops    map[string]models.BusStop
+	stopTrips   map[string][]tripStopIndex
+
+	// connections and stopGrid are only built when reachability is requested
+	connectionsOnce sync.Once
+	connections     []connection
+	stopGridOnce    sync.Once
+	stopGrid        map[stopGridCell][]models.BusStop
+
+	// lineTrips is only built when vehicle positions are matched to trips
+	lineTripsOnce sync.Once
+	lineTrips     map[string][]int
+}
+
+// tripStopIndex is a stop of a trip in the network
+type tripStopIndex struct {
+	trip int
+	stop int
+}
+
+// tripLeg is a trip boarded at one stop index and alighted at a later one
+type tripLeg struct {
+	trip   int
+	board  int
+	alight int
+}
+
+// getTripsOnDate gets the trips of a service date with the bus stops they call
+// at
+var getTripsOnDate = models.GetTripsOnDate
+
+// timetableNetworkLoad loads the network of a service date once, however many
+// requests are waiting for it
+type timetableNetworkLoad struct {
+	once      sync.Once
+	network   *timetableNetwork
+	err       error
+	startedAt time.Time
+}
+
+// timetableNetworks are the loads of recently used service dates keyed by date.
+// The lock is only held to find a load, not while it is loading, so a date being
+// loaded doesn't block requests for the dates already held in memory
+var timetableNetworks = struct {
+	sync.Mutex
+	loads map[string]*timetableNetworkLoad
+}{loads: make(map[string]*timetableNetworkLoad)}
+
+// PlanJourneys plans up to maximumItineraries journeys from one bus stop to
+// another departing after departAfter with at most one change. found is false
+// when either bus stop doesn't exist
+func PlanJourneys(from string, to string, departAfter time.Time) ([]models.Itinerary, bool, error) {
+	for _, atcoCode := range []string{from, to} {
+		if _, found, err := GetBusStop(atcoCode); !found || err != nil {
+			return nil, found, err
+		}
+	}
+
+	departAfter = departAfter.In(timetableLocation)
+	year, month, day := departAfter.Date()
+	serviceDate := time.Date(year, month, day, 0, 0, 0, 0, timetableLocation)
+
+	network, err := getTimetableNetwork(serviceDate)
+	if err != nil {
+		return nil, false, err
+	}
+
+	hour, minute, second := departAfter.Clock()
+	earliestDeparture := uint(hour * 3600 + minute * 60 + second)
+
+	itineraries := planJourneys(network, from, to, earliestDeparture)
+
+	// trips which started before midnight are planned 24 hours later on the
+	// previous service day
+	if earliestDeparture < previousServiceDayOverrun {
+		previousNetwork, err := getTimetableNetwork(serviceDate.AddDate(0, 0, -1))
+		if err != nil {
+			return nil, false, err
+		}
+
+		previousItineraries := planJourneys(previousNetwork, from, to, earliestDeparture + secondsPerDay)
+		itineraries = mergeItineraries(previousItineraries, itineraries)
+	}
+
+	return itineraries, true, nil
+}
+
+// getTimetableNetwork gets the network of a service date, loading it from the
+// database when it isn't held in memory or has expired. Requests for a date
+// which is being loaded wait for that load rather than starting another, and a
+// load which fails is tried again by the next request
+func getTimetableNetwork(serviceDate time.Time) (*timetableNetwork, error) {
+	key := serviceDate.Format("2006-01-02")
+
+	timetableNetworks.Lock()
+	// remove expired networks so only recently planned days are held in memory
+	for loadKey, load := range timetableNetworks.loads {
+		if time.Since(load.startedAt) >= timetableNetworkTTL {
+			delete(timetableNetworks.loads, loadKey)
+		}
+	}
+
+	load, ok := timetableNetworks.loads[key]
+	if !ok {
+		load = &timetableNetworkLoad{startedAt: time.Now()}
+		timetableNetworks.loads[key] = load
+	}
+	timetableNetworks.Unlock()
+
+	load.once.Do(func() {
+		trips, busStops, err := getTripsOnDate(serviceDate)
+		if err != nil {
+			load.err = err
+			return
+		}
+
+		load.network = newTimetableNetwork(serviceDate, trips, busStops)
+	})
+
+	if load.err != nil {
+		timetableNetworks.Lock()
+		if timetableNetworks.loads[key] == load {
+			delete(timetableNetworks.loads, key)
+		}
+		timetableNetworks.Unlock()
+
+		return nil, load.err
+	}
+
+	return load.network, nil
+}
+
+func newTimetableNetwork(serviceDate time.Time, trips []models.TimetableTrip, busStops map[string]models.BusStop) *timetableNetwork {
+	stopTrips := make(map[string][]tripStopIndex)
+	for tripIndex, trip := range trips {
+		for stopIndex, stop := range trip.Stops {
+			stopTrips[stop.BusStopID] = append(stopTrips[stop.BusStopID], tripStopIndex{
+				trip: tripIndex,
+				stop: stopIndex,
+			})
+		}
+	}
+
+	return &timetableNetwork{
+		serviceDate: serviceDate,
+		trips: trips,
+		busStops: busStops,
+		stopTrips: stopTrips,
+	}
+}
+
+// mergeItineraries merges the itineraries planned on two service days in
+// departure order, leaving out any which arrive no earlier than an itinerary
+// departing at the same time or later, up to maximumItineraries
+func mergeItineraries(itineraries []models.Itinerary, otherItineraries []models.Itinerary) []models.Itinerary {
+	candidates := append(append([]models.Itinerary{}, itineraries...), otherItineraries...)
+	sort.SliceStable(candidates, func(i int, j int) bool {
+		return candidates[i].DepartureTime.Before(candidates[j].DepartureTime)
+	})
+
+	merged := make([]models.Itinerary, 0)
+	for candidateIndex, candidate := range candidates {
+		dominated := false
+		for otherIndex, other := range candidates {
+			if otherIndex == candidateIndex || other.DepartureTime.Before(candidate.DepartureTime) || other.ArrivalTime.After(candidate.ArrivalTime) {
+				continue
+			}
+
+			// of two identical itineraries only the first is kept
+			if other.DepartureTime.Equal(candidate.DepartureTime) && other.ArrivalTime.Equal(candidate.ArrivalTime) && otherIndex > candidateIndex {
+				continue
+			}
+
+			dominated = true
+			break
+		}
+
+		if !dominated {
+			merged = append(merged, candidate)
+		}
+	}
+
+	if len(merged) > maximumItineraries {
+		merged = merged[:maximumItineraries]
+	}
+
+	return merged
+}
+
+// planJourneys repeatedly plans the earliest arriving journey, each time
+// departing after the previous journey, until maximumItineraries are found or
+// there are no more journeys. A journey arriving at the same time as a later
+// departing journey is replaced by it.
+func planJourneys(network *timetableNetwork, from string, to string, earliestDeparture uint) []models.Itinerary {
+	itineraries := make([]models.Itinerary, 0)
+	lastArrival := uint(0)
+
+	for len(itineraries) < maximumItineraries {
+		legs, ok := network.earliestArrival(from, to, earliestDeparture)
+		if !ok {
+			break
+		}
+
+		itinerary := network.itinerary(legs)
+		departure := network.departure(legs[0])
+		arrival := network.arrival(legs[len(legs) - 1])
+
+		if len(itineraries) > 0 && arrival == lastArrival {
+			itineraries[len(itineraries) - 1] = itinerary
+		} else {
+			itineraries = append(itineraries, itinerary)
+		}
+
+		lastArrival = arrival
+		earliestDeparture = departure + 1
+	}
+
+	return itineraries
+}
+
+// earliestArrival finds the journey from one stop to another departing at or
+// after earliestDeparture which arrives first. It scans in two rounds: the
+// first reaches every stop with a single trip from the origin and the second
+// changes to another trip at each of those stops. Direct journeys are
+// preferred to a change arriving at the same time.
+func (network *timetableNetwork) earliestArrival(from string, to string, earliestDeparture uint) ([]tripLeg, bool) {
+	// round 1: the earliest arrival at every stop reachable from the origin
+	firstLegs := make(map[string]tripLeg)
+	for _, origin := range network.stopTrips[from] {
+		trip := network.trips[origin.trip]
+		if network.stopTime(origin.trip, origin.stop) < earliestDeparture {
+			continue
+		}
+
+		for stopIndex := origin.stop + 1; stopIndex < len(trip.Stops); stopIndex++ {
+			leg := tripLeg{trip: origin.trip, board: origin.stop, alight: stopIndex}
+			stopID := trip.Stops[stopIndex].BusStopID
+
+			if best, ok := firstLegs[stopID]; !ok || network.isBetterLeg(leg, best) {
+				firstLegs[stopID] = leg
+			}
+		}
+	}
+
+	var bestLegs []tripLeg
+	if direct, ok := firstLegs[to]; ok {
+		bestLegs = []tripLeg{direct}
+	}
+
+	// round 2: change to another trip at each stop reached by round 1
+	for changeStopID, firstLeg := range firstLegs {
+		if changeStopID == to || changeStopID == from {
+			continue
+		}
+
+		readyAt := network.arrival(firstLeg) + minimumChangeTime
+
+		for _, change := range network.stopTrips[changeStopID] {
+			if change.trip == firstLeg.trip || network.stopTime(change.trip, change.stop) < readyAt {
+				continue
+			}
+
+			trip := network.trips[change.trip]
+			for stopIndex := change.stop + 1; stopIndex < len(trip.Stops); stopIndex++ {
+				if trip.Stops[stopIndex].BusStopID != to {
+					continue
+				}
+
+				secondLeg := tripLeg{trip: change.trip, board: change.stop, alight: stopIndex}
+				if bestLegs == nil || network.isBetterJourney([]tripLeg{firstLeg, secondLeg}, bestLegs) {
+					bestLegs = []tripLeg{firstLeg, secondLeg}
+				}
+
+				break
+			}
+		}
+	}
+
+	return bestLegs, bestLegs != nil
+}
+
+// isBetterLeg is true when leg arrives before best, or at the same time but
+// departs later
+func (network *timetableNetwork) isBetterLeg(leg tripLeg, best tripLeg) bool {
+	return network.isBetterJourney([]tripLeg{leg}, []tripLeg{best})
+}
+
+// isBetterJourney is true when legs arrive before best, or at the same time
+// with fewer changes, or the same changes departing later. Remaining ties are
+// broken by trip order and the earliest change so plans are repeatable
+func (network *timetableNetwork) isBetterJourney(legs []tripLeg, best []tripLeg) bool {
+	arrival, bestArrival := network.arrival(legs[len(legs) - 1]), network.arrival(best[len(best) - 1])
+	if arrival != bestArrival {
+		return arrival < bestArrival
+	}
+
+	if len(legs) != len(best) {
+		return len(legs) < len(best)
+	}
+
+	departure, bestDeparture := network.departure(legs[0]), network.departure(best[0])
+	if departure != bestDeparture {
+		return departure > bestDeparture
+	}
+
+	for legIndex := range legs {
+		if legs[legIndex].trip != best[legIndex].trip {
+			return legs[legIndex].trip < best[legIndex].trip
+		}
+
+		if legs[legIndex].alight != best[legIndex].alight {
+			return legs[legIndex].alight < best[legIndex].alight
+		}
+	}
+
+	return false
+}
+
+// stopTime is the seconds after midnight a trip departs a stop
+func (network *timetableNetwork) stopTime(tripIndex int, stopIndex int) uint {
+	trip := network.trips[tripIndex]
+
+	return trip.DepartureTime + trip.Stops[stopIndex].Offset
+}
+
+func (network *timetableNetwork) departure(leg tripLeg) uint {
+	return network.stopTime(leg.trip, leg.board)
+}
+
+func (network *timetableNetwork) arrival(leg tripLeg) uint {
+	return network.stopTime(leg.trip, leg.alight)
+}
+
+// time converts seconds after midnight on the service date to a time
+func (network *timetableNetwork) time(seconds uint) time.Time {
+	year, month, day := network.serviceDate.Date()
+
+	return time.Date(year, month, day, 0, 0, int(seconds), 0, timetableLocation)
+}
+
+func (network *timetableNetwork) itinerary(legs []tripLeg) models.Itinerary {
+	journeyLegs := make([]models.JourneyLeg, 0)
+	for _, leg := range legs {
+		trip := network.trips[leg.trip]
+
+		stops := make([]models.BusStop, 0)
+		for stopIndex := leg.board; stopIndex <= leg.alight; stopIndex++ {
+			stops = append(stops, network.busStops[trip.Stops[stopIndex].BusStopID])
+		}
+
+		journeyLegs = append(journeyLegs, models.JourneyLeg{
+			TripID: trip.ID,
+			LineID: trip.LineID,
+			RouteID: trip.RouteID,
+			LineName: trip.LineName,
+			Direction: trip.Direction,
+			OperatorID: trip.OperatorID,
+			OperatorName: trip.OperatorName,
+			DepartureTime: network.time(network.departure(leg)),
+			ArrivalTime: network.time(network.arrival(leg)),
+			Stops: stops,
+		})
+	}
+
+	return models.Itinerary{
+		DepartureTime: journeyLegs[0].DepartureTime,
+		ArrivalTime: journeyLegs[len(journeyLegs) - 1].ArrivalTime,
+		Changes: uint(len(journeyLegs) - 1),
+		Legs: journeyLegs,
+	}
+}
@@ -0,0 +1,289 @@
+package controllers
+
+import (
+	"errors"
+	"reflect"
+	"server/models"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testTrip(id uint, lineName string, departureTime uint, stops map[string]uint, stopOrder []string) models.TimetableTrip {
+	tripStops := make([]models.TripStop, 0)
+	for _, stopID := range stopOrder {
+		tripStops = append(tripStops, models.TripStop{
+			BusStopID: stopID,
+			Offset: stops[stopID],
+		})
+	}
+
+	return models.TimetableTrip{
+		ID: id,
+		LineID: lineName,
+		RouteID: "RT1",
+		LineName: lineName,
+		Direction: "OUTBOUND",
+		OperatorID: "SCEK",
+		OperatorName: "Stagecoach in East Kent",
+		DepartureTime: departureTime,
+		Stops: tripStops,
+	}
+}
+
+// testBusStops gets the bus stops called at by trips, named by their IDs
+func testBusStops(trips []models.TimetableTrip) map[string]models.BusStop {
+	busStops := make(map[string]models.BusStop)
+	for _, trip := range trips {
+		for _, stop := range trip.Stops {
+			busStops[stop.BusStopID] = models.BusStop{ID: stop.BusStopID, Name: stop.BusStopID}
+		}
+	}
+
+	return busStops
+}
+
+func Test_planJourneys(t *testing.T) {
+	// A: S1 08:00, S2 08:05, S3 08:10
+	tripA := testTrip(1, "A", 28800, map[string]uint{"S1": 0, "S2": 300, "S3": 600}, []string{"S1", "S2", "S3"})
+	// B: S3 08:12, S4 08:17
+	tripB := testTrip(2, "B", 29520, map[string]uint{"S3": 0, "S4": 300}, []string{"S3", "S4"})
+	// C: S1 08:05, S4 08:35
+	tripC := testTrip(3, "C", 29100, map[string]uint{"S1": 0, "S4": 1800}, []string{"S1", "S4"})
+	// D: S1 08:07, S4 08:17
+	tripD := testTrip(4, "D", 29220, map[string]uint{"S1": 0, "S4": 600}, []string{"S1", "S4"})
+	// E: S3 08:10:30, S4 08:15 which is too soon to change to from A
+	tripE := testTrip(5, "E", 29430, map[string]uint{"S3": 0, "S4": 270}, []string{"S3", "S4"})
+
+	type itinerarySummary struct {
+		Lines         []string
+		DepartureTime string
+		ArrivalTime   string
+	}
+	type args struct {
+		trips             []models.TimetableTrip
+		from              string
+		to                string
+		earliestDeparture uint
+	}
+	tests := []struct {
+		name string
+		args args
+		want []itinerarySummary
+	}{
+		{
+			name: "Plans a change before a slower direct journey",
+			args: args{
+				trips: []models.TimetableTrip{tripA, tripB, tripC},
+				from: "S1",
+				to: "S4",
+				earliestDeparture: 28200,
+			},
+			want: []itinerarySummary{
+				itinerarySummary{
+					Lines: []string{"A", "B"},
+					DepartureTime: "08:00:00",
+					ArrivalTime: "08:17:00",
+				},
+				itinerarySummary{
+					Lines: []string{"C"},
+					DepartureTime: "08:05:00",
+					ArrivalTime: "08:35:00",
+				},
+			},
+		},
+		{
+			name: "Prefers a later direct journey arriving at the same time as a change",
+			args: args{
+				trips: []models.TimetableTrip{tripA, tripB, tripC, tripD},
+				from: "S1",
+				to: "S4",
+				earliestDeparture: 28200,
+			},
+			want: []itinerarySummary{
+				itinerarySummary{
+					Lines: []string{"D"},
+					DepartureTime: "08:07:00",
+					ArrivalTime: "08:17:00",
+				},
+			},
+		},
+		{
+			name: "Doesn't change between buses without the minimum change time",
+			args: args{
+				trips: []models.TimetableTrip{tripA, tripE, tripC},
+				from: "S1",
+				to: "S4",
+				earliestDeparture: 28200,
+			},
+			want: []itinerarySummary{
+				itinerarySummary{
+					Lines: []string{"C"},
+					DepartureTime: "08:05:00",
+					ArrivalTime: "08:35:00",
+				},
+			},
+		},
+		{
+			name: "Doesn't plan journeys departing before the earliest departure",
+			args: args{
+				trips: []models.TimetableTrip{tripA, tripB, tripC},
+				from: "S1",
+				to: "S4",
+				earliestDeparture: 29101,
+			},
+			want: []itinerarySummary{},
+		},
+		{
+			name: "Doesn't plan journeys in the wrong direction",
+			args: args{
+				trips: []models.TimetableTrip{tripA, tripB, tripC},
+				from: "S4",
+				to: "S1",
+				earliestDeparture: 0,
+			},
+			want: []itinerarySummary{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serviceDate := time.Date(2021, 4, 6, 0, 0, 0, 0, timetableLocation)
+			network := newTimetableNetwork(serviceDate, tt.args.trips, testBusStops(tt.args.trips))
+
+			itineraries := planJourneys(network, tt.args.from, tt.args.to, tt.args.earliestDeparture)
+
+			got := make([]itinerarySummary, 0)
+			for _, itinerary := range itineraries {
+				lines := make([]string, 0)
+				for _, leg := range itinerary.Legs {
+					lines = append(lines, leg.LineName)
+				}
+
+				if itinerary.Changes != uint(len(itinerary.Legs) - 1) {
+					t.Errorf("planJourneys() changes = %v, want %v", itinerary.Changes, len(itinerary.Legs) - 1)
+				}
+
+				got = append(got, itinerarySummary{
+					Lines: lines,
+					DepartureTime: itinerary.DepartureTime.Format("15:04:05"),
+					ArrivalTime: itinerary.ArrivalTime.Format("15:04:05"),
+				})
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("planJourneys() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_mergeItineraries(t *testing.T) {
+	// the previous day's A: S1 23:50, S2 00:10, S3 00:20
+	tripA := testTrip(1, "A", 85800, map[string]uint{"S1": 0, "S2": 1200, "S3": 1800}, []string{"S1", "S2", "S3"})
+	// B: S2 00:15, S3 00:40
+	tripB := testTrip(2, "B", 900, map[string]uint{"S2": 0, "S3": 1500}, []string{"S2", "S3"})
+	// C: S2 00:30, S3 00:35
+	tripC := testTrip(3, "C", 1800, map[string]uint{"S2": 0, "S3": 300}, []string{"S2", "S3"})
+
+	serviceDate := time.Date(2021, 4, 6, 0, 0, 0, 0, timetableLocation)
+	previousNetwork := newTimetableNetwork(serviceDate.AddDate(0, 0, -1), []models.TimetableTrip{tripA}, testBusStops([]models.TimetableTrip{tripA}))
+	network := newTimetableNetwork(serviceDate, []models.TimetableTrip{tripB, tripC}, testBusStops([]models.TimetableTrip{tripB, tripC}))
+
+	// 00:05 is 24:05 on the previous service day
+	previousItineraries := planJourneys(previousNetwork, "S2", "S3", 300 + secondsPerDay)
+	itineraries := planJourneys(network, "S2", "S3", 300)
+
+	got := make([]string, 0)
+	for _, itinerary := range mergeItineraries(previousItineraries, itineraries) {
+		got = append(got, itinerary.Legs[0].LineName + " " + itinerary.DepartureTime.Format(time.RFC3339) + " " + itinerary.ArrivalTime.Format(time.RFC3339))
+	}
+
+	// B departs after A but arrives later, so is left out
+	want := []string{
+		"A 2021-04-06T00:10:00+01:00 2021-04-06T00:20:00+01:00",
+		"C 2021-04-06T00:30:00+01:00 2021-04-06T00:35:00+01:00",
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mergeItineraries() = %v, want %v", got, want)
+	}
+}
+
+func Test_getTimetableNetwork(t *testing.T) {
+	defer func() {
+		getTripsOnDate = models.GetTripsOnDate
+		timetableNetworks.Lock()
+		timetableNetworks.loads = make(map[string]*timetableNetworkLoad)
+		timetableNetworks.Unlock()
+	}()
+
+	loaded := time.Date(2021, 4, 5, 0, 0, 0, 0, timetableLocation)
+	loading := time.Date(2021, 4, 6, 0, 0, 0, 0, timetableLocation)
+	failing := time.Date(2021, 4, 7, 0, 0, 0, 0, timetableLocation)
+
+	release := make(chan struct{})
+	var loadsMutex sync.Mutex
+	loads := make(map[string]int)
+	getTripsOnDate = func(date time.Time) ([]models.TimetableTrip, map[string]models.BusStop, error) {
+		key := date.Format("2006-01-02")
+
+		loadsMutex.Lock()
+		loads[key]++
+		loadsMutex.Unlock()
+
+		if date.Equal(loading) {
+			<-release
+		}
+
+		if date.Equal(failing) {
+			return nil, nil, errors.New("Failed to load trips")
+		}
+
+		return []models.TimetableTrip{}, map[string]models.BusStop{}, nil
+	}
+
+	if _, err := getTimetableNetwork(loaded); err != nil {
+		t.Fatal(err)
+	}
+
+	// two requests wait for the same load of a date
+	var waiting sync.WaitGroup
+	for request := 0; request < 2; request++ {
+		waiting.Add(1)
+		go func() {
+			defer waiting.Done()
+
+			if _, err := getTimetableNetwork(loading); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+
+	// a date held in memory isn't blocked by a date being loaded
+	done := make(chan struct{})
+	go func() {
+		getTimetableNetwork(loaded)
+		close(done)
+	}()
+
+	select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Error("getTimetableNetwork() blocked by a date being loaded")
+	}
+
+	close(release)
+	waiting.Wait()
+
+	// a failed load is tried again
+	for request := 0; request < 2; request++ {
+		if _, err := getTimetableNetwork(failing); err == nil {
+			t.Error("getTimetableNetwork() error = nil, want an error")
+		}
+	}
+
+	want := map[string]int{"2021-04-05": 1, "2021-04-06": 1, "2021-04-07": 2}
+	if !reflect.DeepEqual(loads, want) {
+		t.Errorf("getTimetableNetwork() loads = %v, want %v", loads, want)
+	}
+}
@@ -0,0 +1,332 @@
+package controllers
+
+import (
+	"server/models"
+	"server/types"
+	"server/utils"
+	"math"
+	"sort"
+	"time"
+)
+
+// walkingSpeed is the walking speed in metres per second
+const walkingSpeed = 1.25
+
+// maximumWalkingDistance is the furthest in metres walked between bus stops
+const maximumWalkingDistance = 400
+
+// MaximumReachabilityMinutes is the largest time budget of a reachability
+// request
+const MaximumReachabilityMinutes = 180
+
+// reachabilityCellSize is the size in metres of the square cells a reachable
+// area is made of
+const reachabilityCellSize = 50
+
+// stopGridSize is the size in degrees of the cells bus stops are grouped into
+// to find stops within walking distance. It is larger than the walking distance
+// at UK latitudes so only neighbouring cells need to be searched
+const stopGridSize = 0.008
+
+// connection is a trip departing one stop and arriving at the next
+type connection struct {
+	trip      int
+	stop      int
+	departure uint
+	arrival   uint
+}
+
+type stopGridCell struct {
+	longitude int
+	latitude  int
+}
+
+// Reach gets every bus stop reachable from a bus stop by bus and walking within
+// maxMinutes of departAt, and optionally a GeoJSON feature approximating the
+// area reachable by walking from them. found is false when the bus stop doesn't
+// exist
+func Reach(from string, departAt time.Time, maxMinutes uint, includeArea bool) ([]models.ReachableStop, *types.GeoJSONFeature, bool, error) {
+	origin, found, err := GetBusStop(from)
+	if !found || err != nil {
+		return nil, nil, found, err
+	}
+
+	departAt = departAt.In(timetableLocation)
+	year, month, day := departAt.Date()
+	serviceDate := time.Date(year, month, day, 0, 0, 0, 0, timetableLocation)
+
+	network, err := getTimetableNetwork(serviceDate)
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	hour, minute, second := departAt.Clock()
+	departure := uint(hour * 3600 + minute * 60 + second)
+
+	reachableStops := network.reach(origin, departure, maxMinutes * 60)
+
+	// trips which started before midnight are reached 24 hours later on the
+	// previous service day
+	if departure < previousServiceDayOverrun {
+		previousNetwork, err := getTimetableNetwork(serviceDate.AddDate(0, 0, -1))
+		if err != nil {
+			return nil, nil, false, err
+		}
+
+		previousStops := previousNetwork.reach(origin, departure + secondsPerDay, maxMinutes * 60)
+		reachableStops = mergeReachableStops(reachableStops, previousStops)
+	}
+
+	if !includeArea {
+		return reachableStops, nil, true, nil
+	}
+
+	deadline := departAt.Add(time.Duration(maxMinutes) * time.Minute)
+	area := reachableArea(reachableStops, deadline)
+
+	return reachableStops, &area, true, nil
+}
+
+// reach scans every connection in departure order from the departure time,
+// keeping the earliest arrival at each stop. Stops within walking distance of
+// a reached stop are reached by walking, including from stops reached by
+// walking. A minimum change time is allowed at
+// stops reached by bus before boarding another bus.
+func (network *timetableNetwork) reach(origin models.BusStop, departure uint, budget uint) []models.ReachableStop {
+	deadline := departure + budget
+	busStops := map[string]models.BusStop{origin.ID: origin}
+	arrivals := map[string]uint{origin.ID: departure}
+	readyAt := map[string]uint{origin.ID: departure}
+
+	setReady := func(stopID string, ready uint) {
+		if current, ok := readyAt[stopID]; !ok || ready < current {
+			readyAt[stopID] = ready
+		}
+	}
+
+	// walking continues from stops reached by walking, so stops are reached by
+	// walking between several stops in turn
+	walk := func(from models.BusStop) {
+		walkFrom := []string{from.ID}
+		for len(walkFrom) > 0 {
+			stop := busStops[walkFrom[len(walkFrom) - 1]]
+			walkFrom = walkFrom[:len(walkFrom) - 1]
+
+			for _, busStop := range network.nearbyStops(stop) {
+				walkingTime := uint(math.Ceil(utils.Distance(busStopCoordinate(stop), busStopCoordinate(busStop)) / walkingSpeed))
+				walkingArrival := arrivals[stop.ID] + walkingTime
+
+				if current, ok := arrivals[busStop.ID]; walkingArrival <= deadline && (!ok || walkingArrival < current) {
+					busStops[busStop.ID] = busStop
+					arrivals[busStop.ID] = walkingArrival
+					setReady(busStop.ID, walkingArrival)
+
+					walkFrom = append(walkFrom, busStop.ID)
+				}
+			}
+		}
+	}
+
+	walk(origin)
+
+	connections := network.departureOrderedConnections()
+	boarded := make([]bool, len(network.trips))
+
+	firstConnection := sort.Search(len(connections), func(connectionIndex int) bool {
+		return connections[connectionIndex].departure >= departure
+	})
+
+	for _, connection := range connections[firstConnection:] {
+		if connection.departure > deadline {
+			break
+		}
+
+		trip := network.trips[connection.trip]
+
+		if !boarded[connection.trip] {
+			ready, ok := readyAt[trip.Stops[connection.stop].BusStopID]
+			if !ok || ready > connection.departure {
+				continue
+			}
+
+			boarded[connection.trip] = true
+		}
+
+		if connection.arrival > deadline {
+			continue
+		}
+
+		busStop := network.busStops[trip.Stops[connection.stop + 1].BusStopID]
+		if current, ok := arrivals[busStop.ID]; ok && current <= connection.arrival {
+			continue
+		}
+
+		busStops[busStop.ID] = busStop
+		arrivals[busStop.ID] = connection.arrival
+		setReady(busStop.ID, connection.arrival + minimumChangeTime)
+
+		walk(busStop)
+	}
+
+	reachableStops := make([]models.ReachableStop, 0)
+	for stopID, arrival := range arrivals {
+		reachableStops = append(reachableStops, models.ReachableStop{
+			BusStop: busStops[stopID],
+			ArrivalTime: network.time(arrival),
+			Minutes: (arrival - departure) / 60,
+		})
+	}
+
+	sortReachableStops(reachableStops)
+
+	return reachableStops
+}
+
+// sortReachableStops sorts reachable stops by arrival time, then bus stop ID
+func sortReachableStops(reachableStops []models.ReachableStop) {
+	sort.Slice(reachableStops, func(i int, j int) bool {
+		if !reachableStops[i].ArrivalTime.Equal(reachableStops[j].ArrivalTime) {
+			return reachableStops[i].ArrivalTime.Before(reachableStops[j].ArrivalTime)
+		}
+
+		return reachableStops[i].BusStop.ID < reachableStops[j].BusStop.ID
+	})
+}
+
+// mergeReachableStops merges the stops reached on two service days, keeping
+// the earliest arrival at each stop
+func mergeReachableStops(reachableStops []models.ReachableStop, otherStops []models.ReachableStop) []models.ReachableStop {
+	earliest := make(map[string]models.ReachableStop)
+	for _, reachableStop := range append(append([]models.ReachableStop{}, reachableStops...), otherStops...) {
+		if current, ok := earliest[reachableStop.BusStop.ID]; !ok || reachableStop.ArrivalTime.Before(current.ArrivalTime) {
+			earliest[reachableStop.BusStop.ID] = reachableStop
+		}
+	}
+
+	merged := make([]models.ReachableStop, 0)
+	for _, reachableStop := range earliest {
+		merged = append(merged, reachableStop)
+	}
+
+	sortReachableStops(merged)
+
+	return merged
+}
+
+// departureOrderedConnections gets every connection of the network ordered by
+// departure
+func (network *timetableNetwork) departureOrderedConnections() []connection {
+	network.connectionsOnce.Do(func() {
+		connections := make([]connection, 0)
+		for tripIndex, trip := range network.trips {
+			for stopIndex := 0; stopIndex < len(trip.Stops) - 1; stopIndex++ {
+				connections = append(connections, connection{
+					trip: tripIndex,
+					stop: stopIndex,
+					departure: network.stopTime(tripIndex, stopIndex),
+					arrival: network.stopTime(tripIndex, stopIndex + 1),
+				})
+			}
+		}
+
+		sort.SliceStable(connections, func(i int, j int) bool {
+			return connections[i].departure < connections[j].departure
+		})
+
+		network.connections = connections
+	})
+
+	return network.connections
+}
+
+func getStopGridCell(busStop models.BusStop) stopGridCell {
+	return stopGridCell{
+		longitude: int(math.Floor(float64(busStop.Longitude) / stopGridSize)),
+		latitude: int(math.Floor(float64(busStop.Latitude) / stopGridSize)),
+	}
+}
+
+// nearbyStops gets the stops of the network within walking distance of a bus
+// stop, excluding the bus stop
+func (network *timetableNetwork) nearbyStops(busStop models.BusStop) []models.BusStop {
+	network.stopGridOnce.Do(func() {
+		stopGrid := make(map[stopGridCell][]models.BusStop)
+		for stopID := range network.stopTrips {
+			stop := network.busStops[stopID]
+			cell := getStopGridCell(stop)
+
+			stopGrid[cell] = append(stopGrid[cell], stop)
+		}
+
+		network.stopGrid = stopGrid
+	})
+
+	nearbyStops := make([]models.BusStop, 0)
+	cell := getStopGridCell(busStop)
+	for longitude := cell.longitude - 1; longitude <= cell.longitude + 1; longitude++ {
+		for latitude := cell.latitude - 1; latitude <= cell.latitude + 1; latitude++ {
+			for _, stop := range network.stopGrid[stopGridCell{longitude, latitude}] {
+				if stop.ID == busStop.ID {
+					continue
+				}
+
+				if utils.Distance(busStopCoordinate(busStop), busStopCoordinate(stop)) <= maximumWalkingDistance {
+					nearbyStops = append(nearbyStops, stop)
+				}
+			}
+		}
+	}
+
+	return nearbyStops
+}
+
+func busStopCoordinate(busStop models.BusStop) types.Coordinate {
+	return types.Coordinate{
+		Longitude: busStop.Longitude,
+		Latitude: busStop.Latitude,
+	}
+}
+
+// reachableArea approximates the reachable area as the union of a circle around
+// each reachable stop of the distance which can be walked in the remaining
+// time, up to the maximum walking distance. The union is a single MultiPolygon
+// feature made of square cells
+func reachableArea(reachableStops []models.ReachableStop, deadline time.Time) types.GeoJSONFeature {
+	circles := make([]utils.CircleArea, 0)
+	for _, reachableStop := range reachableStops {
+		remaining := deadline.Sub(reachableStop.ArrivalTime).Seconds()
+		radius := math.Min(remaining * walkingSpeed, maximumWalkingDistance)
+		if radius <= 0 {
+			continue
+		}
+
+		circles = append(circles, utils.CircleArea{
+			Centre: busStopCoordinate(reachableStop.BusStop),
+			Radius: radius,
+		})
+	}
+
+	polygons := make([][][][2]float32, 0)
+	for _, polygon := range utils.UnionCircles(circles, reachabilityCellSize) {
+		rings := make([][][2]float32, 0)
+		for _, ring := range polygon {
+			points := make([][2]float32, 0)
+			for _, point := range ring {
+				points = append(points, [2]float32{point.Longitude, point.Latitude})
+			}
+
+			rings = append(rings, points)
+		}
+
+		polygons = append(polygons, rings)
+	}
+
+	return types.GeoJSONFeature{
+		Type: "Feature",
+		Geometry: types.GeoJSONGeometry{
+			Type: "MultiPolygon",
+			Coordinates: polygons,
+		},
+		Properties: map[string]interface{}{},
+	}
+}
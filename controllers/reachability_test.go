@@ -0,0 +1,201 @@
+package controllers
+
+import (
+	"reflect"
+	"server/models"
+	"testing"
+	"time"
+)
+
+func Test_reach(t *testing.T) {
+	// S2 and S3 are about 700m apart, S4 is about 220m from S3 and S6 is about
+	// 330m from S4, which is too far to walk to from S3
+	coordinates := map[string][2]float32{
+		"S1": [2]float32{1.00, 51.000},
+		"S2": [2]float32{1.01, 51.000},
+		"S3": [2]float32{1.02, 51.000},
+		"S4": [2]float32{1.02, 51.002},
+		"S5": [2]float32{1.10, 51.000},
+		"S6": [2]float32{1.02, 51.005},
+	}
+	busStops := make(map[string]models.BusStop)
+	for stopID, coordinate := range coordinates {
+		busStops[stopID] = models.BusStop{ID: stopID, Name: stopID, Longitude: coordinate[0], Latitude: coordinate[1]}
+	}
+
+	// A: S1 08:00, S2 08:05, S3 08:10
+	tripA := testTrip(1, "A", 28800, map[string]uint{"S1": 0, "S2": 300, "S3": 600}, []string{"S1", "S2", "S3"})
+	// B: S3 08:10:30, S5 08:20 which is too soon to change to from A
+	tripB := testTrip(2, "B", 29430, map[string]uint{"S3": 0, "S5": 570}, []string{"S3", "S5"})
+	// C: S3 08:12, S5 08:30
+	tripC := testTrip(3, "C", 29520, map[string]uint{"S3": 0, "S5": 1080}, []string{"S3", "S5"})
+	// D: S4 08:20, S1 08:40
+	tripD := testTrip(4, "D", 30000, map[string]uint{"S4": 0, "S1": 1200}, []string{"S4", "S1"})
+
+	// E: S6 09:30, S5 09:45
+	tripE := testTrip(5, "E", 34200, map[string]uint{"S6": 0, "S5": 900}, []string{"S6", "S5"})
+
+	trips := []models.TimetableTrip{tripA, tripB, tripC, tripD, tripE}
+
+	type reachableStopSummary struct {
+		ID          string
+		ArrivalTime string
+		Minutes     uint
+	}
+	type args struct {
+		from      string
+		departure uint
+		budget    uint
+	}
+	tests := []struct {
+		name string
+		args args
+		want []reachableStopSummary
+	}{
+		{
+			name: "Reaches stops by bus and by walking on from stops reached by walking",
+			args: args{
+				from: "S1",
+				departure: 28500,
+				budget: 1800,
+			},
+			want: []reachableStopSummary{
+				reachableStopSummary{"S1", "07:55:00", 0},
+				reachableStopSummary{"S2", "08:05:00", 10},
+				reachableStopSummary{"S3", "08:10:00", 15},
+				reachableStopSummary{"S4", "08:12:58", 17},
+				reachableStopSummary{"S6", "08:17:26", 22},
+			},
+		},
+		{
+			name: "Changes buses with the minimum change time",
+			args: args{
+				from: "S1",
+				departure: 28500,
+				budget: 2400,
+			},
+			want: []reachableStopSummary{
+				reachableStopSummary{"S1", "07:55:00", 0},
+				reachableStopSummary{"S2", "08:05:00", 10},
+				reachableStopSummary{"S3", "08:10:00", 15},
+				reachableStopSummary{"S4", "08:12:58", 17},
+				reachableStopSummary{"S6", "08:17:26", 22},
+				reachableStopSummary{"S5", "08:30:00", 35},
+			},
+		},
+		{
+			name: "Walks from the origin to board a bus",
+			args: args{
+				from: "S4",
+				departure: 28800,
+				budget: 1800,
+			},
+			want: []reachableStopSummary{
+				reachableStopSummary{"S4", "08:00:00", 0},
+				reachableStopSummary{"S3", "08:02:58", 2},
+				reachableStopSummary{"S6", "08:04:28", 4},
+				reachableStopSummary{"S5", "08:20:00", 20},
+			},
+		},
+		{
+			name: "Only reaches the origin after the last departure",
+			args: args{
+				from: "S1",
+				departure: 32400,
+				budget: 1800,
+			},
+			want: []reachableStopSummary{
+				reachableStopSummary{"S1", "09:00:00", 0},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serviceDate := time.Date(2021, 4, 6, 0, 0, 0, 0, timetableLocation)
+			network := newTimetableNetwork(serviceDate, trips, busStops)
+
+			origin := models.BusStop{
+				ID: tt.args.from,
+				Longitude: coordinates[tt.args.from][0],
+				Latitude: coordinates[tt.args.from][1],
+			}
+
+			got := make([]reachableStopSummary, 0)
+			for _, reachableStop := range network.reach(origin, tt.args.departure, tt.args.budget) {
+				got = append(got, reachableStopSummary{
+					ID: reachableStop.BusStop.ID,
+					ArrivalTime: reachableStop.ArrivalTime.Format("15:04:05"),
+					Minutes: reachableStop.Minutes,
+				})
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("reach() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_reachableArea(t *testing.T) {
+	deadline := time.Date(2021, 4, 6, 8, 30, 0, 0, timetableLocation)
+	reachableStop := func(stopID string, longitude float32, latitude float32, arrival time.Time) models.ReachableStop {
+		return models.ReachableStop{
+			BusStop: models.BusStop{ID: stopID, Longitude: longitude, Latitude: latitude},
+			ArrivalTime: arrival,
+		}
+	}
+
+	type args struct {
+		reachableStops []models.ReachableStop
+	}
+	tests := []struct {
+		name         string
+		args         args
+		wantPolygons int
+	}{
+		{
+			name: "Merges the circles of nearby stops into one polygon",
+			args: args{
+				reachableStops: []models.ReachableStop{
+					reachableStop("S3", 1.02, 51.000, deadline.Add(-20 * time.Minute)),
+					reachableStop("S4", 1.02, 51.002, deadline.Add(-15 * time.Minute)),
+				},
+			},
+			wantPolygons: 1,
+		},
+		{
+			name: "Gets a polygon for each group of stops",
+			args: args{
+				reachableStops: []models.ReachableStop{
+					reachableStop("S3", 1.02, 51.000, deadline.Add(-20 * time.Minute)),
+					reachableStop("S4", 1.02, 51.002, deadline.Add(-15 * time.Minute)),
+					reachableStop("S5", 1.10, 51.000, deadline.Add(-time.Minute)),
+				},
+			},
+			wantPolygons: 2,
+		},
+		{
+			name: "Leaves out stops reached at the deadline",
+			args: args{
+				reachableStops: []models.ReachableStop{
+					reachableStop("S3", 1.02, 51.000, deadline.Add(-20 * time.Minute)),
+					reachableStop("S5", 1.10, 51.000, deadline),
+				},
+			},
+			wantPolygons: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := reachableArea(tt.args.reachableStops, deadline)
+
+			if got.Type != "Feature" || got.Geometry.Type != "MultiPolygon" {
+				t.Fatalf("reachableArea() = %v %v, want a MultiPolygon Feature", got.Type, got.Geometry.Type)
+			}
+
+			if polygons := got.Geometry.Coordinates.([][][][2]float32); len(polygons) != tt.wantPolygons {
+				t.Errorf("reachableArea() = %v polygons, want %v", len(polygons), tt.wantPolygons)
+			}
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package controllers
+
+import (
+	"server/models"
+	"server/utils"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// defaultSchedules are run when SCHEDULE_FILE isn't set
+var defaultSchedules = []models.Schedule{
+	{
+		Name: "Nightly NaPTAN refresh",
+		Cron: "0 2 * * *",
+		Type: updateBusStopsJobType,
+	},
+}
+
+// scheduledJob is a schedule with its parsed cron expression
+type scheduledJob struct {
+	schedule models.Schedule
+	cron     utils.CronSchedule
+}
+
+var scheduledJobs = struct {
+	sync.Mutex
+	jobs []scheduledJob
+}{}
+
+// StartScheduler loads the schedules from the JSON file SCHEDULE_FILE, or the
+// defaultSchedules when it isn't set, and queues each schedule's job when it
+// is due. Schedules are in UK time
+func StartScheduler() error {
+	schedules, err := loadSchedules(os.Getenv("SCHEDULE_FILE"))
+	if err != nil {
+		return err
+	}
+
+	jobs, err := parseSchedules(schedules, time.Now())
+	if err != nil {
+		return err
+	}
+
+	scheduledJobs.Lock()
+	scheduledJobs.jobs = jobs
+	scheduledJobs.Unlock()
+
+	for _, job := range jobs {
+		log.Println("Scheduled", job.schedule.Name, "next running at", job.schedule.NextRun)
+	}
+
+	go runScheduler()
+
+	return nil
+}
+
+// GetSchedules gets every schedule with when it next runs
+func GetSchedules() []models.Schedule {
+	scheduledJobs.Lock()
+	defer scheduledJobs.Unlock()
+
+	schedules := make([]models.Schedule, 0)
+	for _, job := range scheduledJobs.jobs {
+		schedules = append(schedules, job.schedule)
+	}
+
+	return schedules
+}
+
+// loadSchedules reads the schedules of a JSON file, or the defaultSchedules
+// when path is empty
+func loadSchedules(path string) ([]models.Schedule, error) {
+	if path == "" {
+		return defaultSchedules, nil
+	}
+
+	file, err := ioutil.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to read SCHEDULE_FILE", err)
+		return nil, err
+	}
+
+	schedules := make([]models.Schedule, 0)
+	if err := json.Unmarshal(file, &schedules); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to parse SCHEDULE_FILE", err)
+		return nil, err
+	}
+
+	return schedules, nil
+}
+
+// parseSchedules checks each schedule queues a job which can be run and
+// parses its cron expression, setting when it next runs after now
+func parseSchedules(schedules []models.Schedule, now time.Time) ([]scheduledJob, error) {
+	jobs := make([]scheduledJob, 0)
+	names := make(map[string]bool)
+
+	for _, schedule := range schedules {
+		if schedule.Name == "" {
+			return nil, fmt.Errorf("Schedule %q has no name", schedule.Cron)
+		}
+
+		if names[schedule.Name] {
+			return nil, fmt.Errorf("Schedule %q is defined more than once", schedule.Name)
+		}
+		names[schedule.Name] = true
+
+		jobType, ok := getBackgroundJobType(schedule.Type)
+		if !ok {
+			return nil, fmt.Errorf("Schedule %q has an unknown job type %q", schedule.Name, schedule.Type)
+		}
+
+		if _, err := parseJobParameters(jobType, scheduleParameters(schedule)); err != nil {
+			return nil, fmt.Errorf("Schedule %q has invalid job parameters: %v", schedule.Name, err)
+		}
+
+		cron, err := utils.ParseCronSchedule(schedule.Cron, timetableLocation)
+		if err != nil {
+			return nil, fmt.Errorf("Schedule %q has an invalid cron expression: %v", schedule.Name, err)
+		}
+
+		schedule.NextRun = cron.Next(now)
+		schedule.LastRun = nil
+		schedule.LastJob = ""
+		schedule.LastError = ""
+
+		jobs = append(jobs, scheduledJob{schedule: schedule, cron: cron})
+	}
+
+	return jobs, nil
+}
+
+// scheduleParameters are the JSON parameters of the job a schedule queues,
+// from the job parameters set on the schedule
+func scheduleParameters(schedule models.Schedule) json.RawMessage {
+	parameters := make(map[string]interface{})
+	if schedule.DatasetID != 0 {
+		parameters["DatasetID"] = schedule.DatasetID
+	}
+	if schedule.NOC != "" {
+		parameters["NOC"] = schedule.NOC
+	}
+	if schedule.AdminArea != "" {
+		parameters["AdminArea"] = schedule.AdminArea
+	}
+	if schedule.Force {
+		parameters["Force"] = true
+	}
+
+	parametersJSON, _ := json.Marshal(parameters)
+
+	return parametersJSON
+}
+
+// runScheduler waits until the next schedule is due and queues the jobs of
+// every schedule which is due, until no schedule will be due again
+func runScheduler() {
+	for {
+		nextRun, ok := nextScheduledRun()
+		if !ok {
+			return
+		}
+
+		time.Sleep(time.Until(nextRun))
+
+		now := time.Now().In(timetableLocation)
+
+		scheduledJobs.Lock()
+		due := make([]int, 0)
+		for jobIndex, job := range scheduledJobs.jobs {
+			if !job.schedule.NextRun.IsZero() && !job.schedule.NextRun.After(now) {
+				due = append(due, jobIndex)
+			}
+		}
+		scheduledJobs.Unlock()
+
+		for _, jobIndex := range due {
+			runScheduledJob(jobIndex, now)
+		}
+	}
+}
+
+// nextScheduledRun is when the next schedule is due. ok is false when no
+// schedule will be due again
+func nextScheduledRun() (time.Time, bool) {
+	scheduledJobs.Lock()
+	defer scheduledJobs.Unlock()
+
+	var nextRun time.Time
+	for _, job := range scheduledJobs.jobs {
+		if job.schedule.NextRun.IsZero() {
+			continue
+		}
+
+		if nextRun.IsZero() || job.schedule.NextRun.Before(nextRun) {
+			nextRun = job.schedule.NextRun
+		}
+	}
+
+	return nextRun, !nextRun.IsZero()
+}
+
+// runScheduledJob queues the job of a due schedule, recording the job or why it
+// couldn't be queued, and sets when the schedule next runs. The job already
+// queued or running is recorded when another job like it can't be queued
+func runScheduledJob(jobIndex int, now time.Time) {
+	scheduledJobs.Lock()
+	schedule := scheduledJobs.jobs[jobIndex].schedule
+	scheduledJobs.Unlock()
+
+	job, err := QueueBackgroundJob(schedule.Type, scheduleParameters(schedule))
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to queue scheduled job", schedule.Name, err)
+	} else {
+		log.Println("Queued scheduled job", schedule.Name, job.ID)
+	}
+
+	scheduledJobs.Lock()
+	defer scheduledJobs.Unlock()
+
+	scheduled := &scheduledJobs.jobs[jobIndex]
+	scheduled.schedule.LastRun = &now
+	scheduled.schedule.LastJob = ""
+	scheduled.schedule.LastError = ""
+	if err != nil {
+		scheduled.schedule.LastError = err.Error()
+	} else {
+		scheduled.schedule.LastJob = job.URI
+	}
+	scheduled.schedule.NextRun = scheduled.cron.Next(now)
+}
@@ -0,0 +1,152 @@
+package controllers
+
+import (
+	"reflect"
+	"server/models"
+	"testing"
+	"time"
+)
+
+func Test_loadSchedules(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		want    []models.Schedule
+		wantErr bool
+	}{
+		{
+			name: "Uses the default schedules when SCHEDULE_FILE isn't set",
+			path: "",
+			want: defaultSchedules,
+		},
+		{
+			name: "Loads the schedules of a file",
+			path: "./testdata/schedules.json",
+			want: []models.Schedule{
+				{
+					Name: "Nightly NaPTAN refresh",
+					Cron: "0 2 * * *",
+					Type: "UPDATE NATIONAL PUBLIC TRANSPORT ACCESS NODES",
+				},
+				{
+					Name: "Stagecoach East Kent timetables",
+					Cron: "30 3 * * *",
+					Type: "UPDATE ALL PUBLISHED ROUTES",
+					NOC: "SCEK",
+				},
+				{
+					Name: "Unibus timetable",
+					Cron: "0 4 * * MON",
+					Type: "UPDATE ROUTES BY DATASET ID",
+					DatasetID: 2022,
+				},
+			},
+		},
+		{
+			name: "Fails when the file doesn't exist",
+			path: "./testdata/missing.json",
+			wantErr: true,
+		},
+		{
+			name: "Fails when the file isn't a list of schedules",
+			path: "./testdata/dft-timetable-query.json",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := loadSchedules(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("loadSchedules() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("loadSchedules() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseSchedules(t *testing.T) {
+	now := time.Date(2021, 4, 6, 21, 33, 48, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		schedules   []models.Schedule
+		wantNextRun []time.Time
+		wantErr     bool
+	}{
+		{
+			name: "Sets when each schedule next runs in UK time",
+			schedules: []models.Schedule{
+				{Name: "NaPTAN", Cron: "0 2 * * *", Type: "UPDATE NATIONAL PUBLIC TRANSPORT ACCESS NODES"},
+				{Name: "Unibus", Cron: "0 4 * * MON", Type: "UPDATE ROUTES BY DATASET ID", DatasetID: 2022},
+				{Name: "Never", Cron: "0 0 30 2 *", Type: "EXPORT GTFS"},
+			},
+			wantNextRun: []time.Time{
+				time.Date(2021, 4, 7, 1, 0, 0, 0, time.UTC),
+				time.Date(2021, 4, 12, 3, 0, 0, 0, time.UTC),
+				{},
+			},
+		},
+		{
+			name: "Rejects a schedule without a name",
+			schedules: []models.Schedule{
+				{Cron: "0 2 * * *", Type: "UPDATE NATIONAL PUBLIC TRANSPORT ACCESS NODES"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Rejects schedules with the same name",
+			schedules: []models.Schedule{
+				{Name: "NaPTAN", Cron: "0 2 * * *", Type: "UPDATE NATIONAL PUBLIC TRANSPORT ACCESS NODES"},
+				{Name: "NaPTAN", Cron: "0 3 * * *", Type: "UPDATE NATIONAL PUBLIC TRANSPORT ACCESS NODES"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Rejects an unknown job type",
+			schedules: []models.Schedule{
+				{Name: "Stops", Cron: "0 2 * * *", Type: "UPDATE BUS STOPS"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Rejects updating routes by dataset ID without a dataset",
+			schedules: []models.Schedule{
+				{Name: "Unibus", Cron: "0 4 * * MON", Type: "UPDATE ROUTES BY DATASET ID"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Rejects filtering by operator when not updating all published routes",
+			schedules: []models.Schedule{
+				{Name: "NaPTAN", Cron: "0 2 * * *", Type: "UPDATE NATIONAL PUBLIC TRANSPORT ACCESS NODES", NOC: "SCEK"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Rejects an invalid cron expression",
+			schedules: []models.Schedule{
+				{Name: "NaPTAN", Cron: "nightly", Type: "UPDATE NATIONAL PUBLIC TRANSPORT ACCESS NODES"},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSchedules(tt.schedules, now)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseSchedules() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			for jobIndex, wantNextRun := range tt.wantNextRun {
+				if gotNextRun := got[jobIndex].schedule.NextRun; !gotNextRun.Equal(wantNextRun) {
+					t.Errorf("parseSchedules() %v NextRun = %v, want %v", got[jobIndex].schedule.Name, gotNextRun, wantNextRun)
+				}
+			}
+		})
+	}
+}
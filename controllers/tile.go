@@ -0,0 +1,340 @@
+package controllers
+
+import (
+	"server/models"
+	"server/types"
+	"server/utils"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// minimumStopsZoom is the lowest zoom level with a stops layer
+const minimumStopsZoom = 12
+
+// minimumRoutesZoom is the lowest zoom level with a feature for each route in
+// the routes layer. Tiles below it have an overview of the whole network
+const minimumRoutesZoom = 8
+
+// tileBuffer is the distance in tile coordinates features are drawn outside a
+// tile so lines and points on the edge aren't cut off
+const tileBuffer = 64
+
+// tileCacheTTL is how long a tile is held in memory before it is redrawn
+const tileCacheTTL = time.Hour
+
+// maximumCachedTiles is the most tiles held in memory
+const maximumCachedTiles = 4096
+
+type cachedTile struct {
+	tile      []byte
+	createdAt time.Time
+}
+
+var tileCache = struct {
+	sync.Mutex
+	tiles map[string]cachedTile
+}{tiles: make(map[string]cachedTile)}
+
+// overviewRoute is the path of a route simplified for the tiles below
+// minimumRoutesZoom, with the bounds of the path
+type overviewRoute struct {
+	path   []types.Coordinate
+	bounds types.BoundingBox
+}
+
+// routeOverview is every route of the network, loaded once for every tile
+// below minimumRoutesZoom and reloaded after tileCacheTTL
+var routeOverview = struct {
+	sync.Mutex
+	routes   []overviewRoute
+	loadedAt time.Time
+}{}
+
+// GetTile gets a Mapbox Vector Tile of the web mercator tile x, y at a zoom
+// level, with a stops layer of bus stops and a routes layer of route paths.
+// Tiles are held in memory for tileCacheTTL
+func GetTile(zoom uint, x uint, y uint) ([]byte, error) {
+	key := fmt.Sprintf("%d/%d/%d", zoom, x, y)
+
+	tileCache.Lock()
+	cached, ok := tileCache.tiles[key]
+	tileCache.Unlock()
+
+	if ok && time.Since(cached.createdAt) < tileCacheTTL {
+		return cached.tile, nil
+	}
+
+	tile, err := drawTile(zoom, x, y)
+	if err != nil {
+		return nil, err
+	}
+
+	tileCache.Lock()
+	defer tileCache.Unlock()
+
+	if len(tileCache.tiles) >= maximumCachedTiles {
+		for cachedKey, cachedTile := range tileCache.tiles {
+			if time.Since(cachedTile.createdAt) >= tileCacheTTL {
+				delete(tileCache.tiles, cachedKey)
+			}
+		}
+
+		// remove any tile when none have expired
+		for cachedKey := range tileCache.tiles {
+			if len(tileCache.tiles) < maximumCachedTiles {
+				break
+			}
+
+			delete(tileCache.tiles, cachedKey)
+		}
+	}
+
+	tileCache.tiles[key] = cachedTile{tile: tile, createdAt: time.Now()}
+
+	return tile, nil
+}
+
+func drawTile(zoom uint, x uint, y uint) ([]byte, error) {
+	bounds := bufferedTileBounds(zoom, x, y)
+	layers := make([]utils.VectorTileLayer, 0)
+
+	if zoom >= minimumStopsZoom {
+		busStops, err := models.GetEveryBusStopWithinBounds(
+			bounds.Min.Longitude, bounds.Min.Latitude, bounds.Max.Longitude, bounds.Max.Latitude,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		layers = append(layers, stopsLayer(busStops, zoom, x, y))
+	}
+
+	if zoom >= minimumRoutesZoom {
+		routes, err := models.GetRoutePathsWithinBounds(
+			bounds.Min.Longitude, bounds.Min.Latitude, bounds.Max.Longitude, bounds.Max.Latitude,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		layers = append(layers, routesLayer(routes, zoom, x, y))
+	} else {
+		routes, err := getRouteOverview()
+		if err != nil {
+			return nil, err
+		}
+
+		layers = append(layers, routeOverviewLayer(routes, bounds, zoom, x, y))
+	}
+
+	return utils.EncodeVectorTile(layers), nil
+}
+
+// bufferedTileBounds gets the bounds of a tile extended by the tile buffer
+func bufferedTileBounds(zoom uint, x uint, y uint) types.BoundingBox {
+	bounds := utils.TileBounds(zoom, x, y)
+	longitudeBuffer := (bounds.Max.Longitude - bounds.Min.Longitude) * tileBuffer / utils.VectorTileExtent
+	latitudeBuffer := (bounds.Max.Latitude - bounds.Min.Latitude) * tileBuffer / utils.VectorTileExtent
+
+	return types.BoundingBox{
+		Min: types.Coordinate{
+			Longitude: bounds.Min.Longitude - longitudeBuffer,
+			Latitude: bounds.Min.Latitude - latitudeBuffer,
+		},
+		Max: types.Coordinate{
+			Longitude: bounds.Max.Longitude + longitudeBuffer,
+			Latitude: bounds.Max.Latitude + latitudeBuffer,
+		},
+	}
+}
+
+func stopsLayer(busStops []models.BusStop, zoom uint, x uint, y uint) utils.VectorTileLayer {
+	features := make([]utils.VectorTileFeature, 0)
+	for _, busStop := range busStops {
+		position := utils.TilePosition(busStopCoordinate(busStop), zoom, x, y)
+
+		features = append(features, utils.VectorTileFeature{
+			Type: utils.VectorTilePoint,
+			Geometry: [][]utils.TilePoint{{position}},
+			Properties: map[string]interface{}{
+				"id": busStop.ID,
+				"name": busStop.Name,
+				"bearing": float64(busStop.Bearing),
+			},
+		})
+	}
+
+	return utils.VectorTileLayer{Name: "stops", Features: features}
+}
+
+// routesLayer draws the path of each route simplified to the zoom level. Only
+// the sections of a path which cross the buffered tile are drawn
+func routesLayer(routes []models.Route, zoom uint, x uint, y uint) utils.VectorTileLayer {
+	features := make([]utils.VectorTileFeature, 0)
+	for _, route := range routes {
+		if len(route.Path) < 2 {
+			continue
+		}
+
+		path := utils.Simplify(route.Path, utils.ZoomTolerance(zoom, route.Path[0].Latitude))
+
+		positions := make([]utils.TilePoint, 0)
+		for _, coordinate := range path {
+			positions = append(positions, utils.TilePosition(coordinate, zoom, x, y))
+		}
+
+		parts := clipLine(positions)
+		if len(parts) == 0 {
+			continue
+		}
+
+		features = append(features, utils.VectorTileFeature{
+			Type: utils.VectorTileLineString,
+			Geometry: parts,
+			Properties: map[string]interface{}{
+				"lineId": route.LineID,
+				"routeId": route.RouteID,
+				"name": route.Name,
+				"direction": route.Direction,
+				"operatorId": route.OperatorID,
+			},
+		})
+	}
+
+	return utils.VectorTileLayer{Name: "routes", Features: features}
+}
+
+// getRouteOverview gets the path of every route simplified to the most detailed
+// zoom level below minimumRoutesZoom
+func getRouteOverview() ([]overviewRoute, error) {
+	routeOverview.Lock()
+	defer routeOverview.Unlock()
+
+	if routeOverview.routes != nil && time.Since(routeOverview.loadedAt) < tileCacheTTL {
+		return routeOverview.routes, nil
+	}
+
+	routes, err := models.GetRoutePathsWithinBounds(-180, -90, 180, 90)
+	if err != nil {
+		return nil, err
+	}
+
+	overview := make([]overviewRoute, 0, len(routes))
+	for _, route := range routes {
+		if len(route.Path) < 2 {
+			continue
+		}
+
+		path := utils.Simplify(route.Path, utils.ZoomTolerance(minimumRoutesZoom - 1, route.Path[0].Latitude))
+		overview = append(overview, overviewRoute{path: path, bounds: pathBounds(path)})
+	}
+
+	routeOverview.routes = overview
+	routeOverview.loadedAt = time.Now()
+
+	return overview, nil
+}
+
+func pathBounds(path []types.Coordinate) types.BoundingBox {
+	bounds := types.BoundingBox{Min: path[0], Max: path[0]}
+	for _, coordinate := range path[1:] {
+		bounds.Min.Longitude = float32(math.Min(float64(bounds.Min.Longitude), float64(coordinate.Longitude)))
+		bounds.Min.Latitude = float32(math.Min(float64(bounds.Min.Latitude), float64(coordinate.Latitude)))
+		bounds.Max.Longitude = float32(math.Max(float64(bounds.Max.Longitude), float64(coordinate.Longitude)))
+		bounds.Max.Latitude = float32(math.Max(float64(bounds.Max.Latitude), float64(coordinate.Latitude)))
+	}
+
+	return bounds
+}
+
+// routeOverviewLayer draws the routes crossing a tile below minimumRoutesZoom,
+// simplified to the zoom level. Routes drawn the same at the zoom level, such as
+// the outbound and inbound routes of a line or routes sharing a road, are
+// drawn once with the number of routes they are
+func routeOverviewLayer(routes []overviewRoute, bounds types.BoundingBox, zoom uint, x uint, y uint) utils.VectorTileLayer {
+	features := make([]utils.VectorTileFeature, 0)
+	featureIndexes := make(map[string]int)
+
+	for _, route := range routes {
+		if route.bounds.Max.Longitude < bounds.Min.Longitude || route.bounds.Min.Longitude > bounds.Max.Longitude ||
+			route.bounds.Max.Latitude < bounds.Min.Latitude || route.bounds.Min.Latitude > bounds.Max.Latitude {
+			continue
+		}
+
+		path := utils.Simplify(route.path, utils.ZoomTolerance(zoom, route.path[0].Latitude))
+
+		positions := make([]utils.TilePoint, 0)
+		for _, coordinate := range path {
+			position := utils.TilePosition(coordinate, zoom, x, y)
+
+			// points in the same tile unit are drawn as one
+			if len(positions) == 0 || positions[len(positions) - 1] != position {
+				positions = append(positions, position)
+			}
+		}
+
+		parts := clipLine(positions)
+		if len(parts) == 0 {
+			continue
+		}
+
+		key := fmt.Sprint(parts)
+		if featureIndex, ok := featureIndexes[key]; ok {
+			features[featureIndex].Properties["routes"] = features[featureIndex].Properties["routes"].(uint) + 1
+			continue
+		}
+
+		featureIndexes[key] = len(features)
+		features = append(features, utils.VectorTileFeature{
+			Type: utils.VectorTileLineString,
+			Geometry: parts,
+			Properties: map[string]interface{}{
+				"routes": uint(1),
+			},
+		})
+	}
+
+	return utils.VectorTileLayer{Name: "routes", Features: features}
+}
+
+// clipLine splits a line into the parts with segments which may cross the
+// buffered tile. A segment is kept when its bounding box overlaps the tile
+func clipLine(positions []utils.TilePoint) [][]utils.TilePoint {
+	parts := make([][]utils.TilePoint, 0)
+	var part []utils.TilePoint
+
+	for positionIndex := 1; positionIndex < len(positions); positionIndex++ {
+		start, end := positions[positionIndex - 1], positions[positionIndex]
+
+		if !segmentOverlapsTile(start, end) {
+			if len(part) > 0 {
+				parts = append(parts, part)
+				part = nil
+			}
+
+			continue
+		}
+
+		if len(part) == 0 {
+			part = []utils.TilePoint{start}
+		}
+		part = append(part, end)
+	}
+
+	if len(part) > 0 {
+		parts = append(parts, part)
+	}
+
+	return parts
+}
+
+func segmentOverlapsTile(start utils.TilePoint, end utils.TilePoint) bool {
+	minimum, maximum := -tileBuffer, utils.VectorTileExtent + tileBuffer
+
+	return !(start.X < minimum && end.X < minimum) &&
+		!(start.X > maximum && end.X > maximum) &&
+		!(start.Y < minimum && end.Y < minimum) &&
+		!(start.Y > maximum && end.Y > maximum)
+}
@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"reflect"
+	"server/types"
+	"server/utils"
+	"testing"
+)
+
+func Test_clipLine(t *testing.T) {
+	type args struct {
+		positions []utils.TilePoint
+	}
+	tests := []struct {
+		name string
+		args args
+		want [][]utils.TilePoint
+	}{
+		{
+			name: "Keeps a line within the tile",
+			args: args{
+				positions: []utils.TilePoint{{X: 10, Y: 10}, {X: 100, Y: 100}, {X: 200, Y: 50}},
+			},
+			want: [][]utils.TilePoint{{{X: 10, Y: 10}, {X: 100, Y: 100}, {X: 200, Y: 50}}},
+		},
+		{
+			name: "Keeps a segment crossing the tile with both ends outside",
+			args: args{
+				positions: []utils.TilePoint{{X: -1000, Y: 2000}, {X: 5000, Y: 2000}},
+			},
+			want: [][]utils.TilePoint{{{X: -1000, Y: 2000}, {X: 5000, Y: 2000}}},
+		},
+		{
+			name: "Splits a line leaving and re-entering the tile",
+			args: args{
+				positions: []utils.TilePoint{
+					{X: 10, Y: 10},
+					{X: -500, Y: 10},
+					{X: -600, Y: 500},
+					{X: -500, Y: 1000},
+					{X: 10, Y: 1000},
+				},
+			},
+			want: [][]utils.TilePoint{
+				{{X: 10, Y: 10}, {X: -500, Y: 10}},
+				{{X: -500, Y: 1000}, {X: 10, Y: 1000}},
+			},
+		},
+		{
+			name: "Drops a line outside the tile",
+			args: args{
+				positions: []utils.TilePoint{{X: 5000, Y: 10}, {X: 6000, Y: 100}},
+			},
+			want: [][]utils.TilePoint{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := clipLine(tt.args.positions); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("clipLine() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_routeOverviewLayer(t *testing.T) {
+	canterbury := []types.Coordinate{
+		{Longitude: 1.0813389, Latitude: 51.276302},
+		{Longitude: 1.0713621, Latitude: 51.29914},
+		{Longitude: 1.3874, Latitude: 51.3591},
+	}
+	reversed := []types.Coordinate{canterbury[2], canterbury[1], canterbury[0]}
+	edinburgh := []types.Coordinate{
+		{Longitude: -3.1883, Latitude: 55.9533},
+		{Longitude: -3.2, Latitude: 55.96},
+	}
+
+	routes := []overviewRoute{
+		{path: canterbury, bounds: pathBounds(canterbury)},
+		{path: canterbury, bounds: pathBounds(canterbury)},
+		{path: reversed, bounds: pathBounds(reversed)},
+		{path: edinburgh, bounds: pathBounds(edinburgh)},
+	}
+
+	// the zoom 6 tile covering Kent
+	layer := routeOverviewLayer(routes, bufferedTileBounds(6, 32, 21), 6, 32, 21)
+
+	if layer.Name != "routes" || len(layer.Features) != 2 {
+		t.Fatalf("routeOverviewLayer() = %v, want the 2 directions of the Kent route", layer)
+	}
+
+	if routeCount := layer.Features[0].Properties["routes"]; routeCount != uint(2) {
+		t.Errorf("routeOverviewLayer() routes = %v, want 2", routeCount)
+	}
+
+	if routeCount := layer.Features[1].Properties["routes"]; routeCount != uint(1) {
+		t.Errorf("routeOverviewLayer() routes = %v, want 1", routeCount)
+	}
+}
@@ -0,0 +1,665 @@
+package controllers
+
+import (
+	"server/models"
+	"strings"
+	"strconv"
+	"regexp"
+	"time"
+	"os"
+	"fmt"
+	"encoding/xml"
+	"errors"
+)
+
+const transXChangeNamespace = "http://www.transxchange.org.uk/"
+
+// supportedTransXChangeVersions are the TransXChange schema versions which can
+// be decoded. 2.5 keeps the elements of 2.4 which are decoded here, so both are
+// decoded the same way
+var supportedTransXChangeVersions = []string{"2.1", "2.4", "2.5"}
+
+type parsedTransXChange struct {
+	schemaVersion string
+	revision      uint
+	createdAt     string
+	modifiedAt    string
+	operators     []models.Operator
+	lines         []models.Line
+	journeys      []models.Journey
+	journeyStops  []models.JourneyStop
+	trips         []models.Trip
+	importErrors  []models.ImportError
+}
+
+// parseTransXChange decodes a TransXChange document of any supported schema
+// version into operators, lines, journeys, journey stops and trips. Services,
+// route sections and vehicle journeys which can't be decoded are skipped and
+// returned as import errors.
+func parseTransXChange(rawXML []byte) (parsedTransXChange, error) {
+	var importErrors []models.ImportError
+
+	var transXChange transXChange
+	err := xml.Unmarshal(rawXML, &transXChange)
+	if err != nil {
+		return parsedTransXChange{}, err
+	}
+
+	// Files are either in the TransXChange namespace or have no namespace at all
+	if namespace := transXChange.XMLName.Space; namespace != "" && namespace != transXChangeNamespace {
+		fmt.Fprintln(os.Stderr, "Unknown transXChange namespace", namespace, transXChange.FileName)
+
+		return parsedTransXChange{}, errors.New("Unknown transXChange namespace")
+	}
+
+	version := strings.TrimSpace(transXChange.Version)
+	if !isSupportedTransXChangeVersion(version) {
+		fmt.Fprintln(os.Stderr, "Unsupported transXChange version", version, transXChange.FileName)
+
+		return parsedTransXChange{}, fmt.Errorf(
+			"Unsupported transXChange version %v. Supports: %v",
+			version, strings.Join(supportedTransXChangeVersions, ", "),
+		)
+	}
+
+	// operators
+	transXChangeOperators := append(transXChange.Operators, transXChange.LicensedOperators...)
+
+	operators := make([]models.Operator, 0)
+	for _, operator := range transXChangeOperators {
+		operators = append(operators, models.Operator{
+			ID: operator.nationalOperatorCode(),
+			Name: operator.name(),
+			ShortName: operator.ShortName,
+		})
+	}
+
+	// services and lines
+	lines := make([]models.Line, 0)
+	journeys := make([]models.Journey, 0)
+	servicePatterns := make(map[string]serviceJourneyPattern)
+	for _, service := range transXChange.Services {
+		// Use the LocalOperatorID to get the operatorID
+		operatorID := ""
+		operatorIndex := 0
+		for operatorID == "" && operatorIndex < len(transXChangeOperators) {
+			if transXChangeOperators[operatorIndex].LocalID == service.LocalOperatorID {
+				operatorID = transXChangeOperators[operatorIndex].nationalOperatorCode()
+			}
+
+			operatorIndex++
+		}
+
+		if operatorID == "" {
+			fmt.Fprintln(os.Stderr, "Couldn't find a operatorID", transXChange.FileName)
+
+			importErrors = append(importErrors, models.ImportError{
+				ElementID: service.ServiceCode,
+				Reason: "Couldn't find a operatorID",
+			})
+
+			continue
+		}
+
+		lineID := service.Line.ID
+		if version == "2.1" {
+			// 2.1 line IDs are only unique within the file
+			lineID = service.ServiceCode + ":" + service.Line.ID
+		}
+
+		lines = append(lines, models.Line{
+			ID:         lineID,
+			OperatorID: operatorID,
+			Name:       service.Line.Name,
+		})
+
+		for _, journeyPattern := range service.JourneyPattern {
+			journeys = append(journeys, models.Journey{
+				LineID: lineID,
+				RouteID: journeyPattern.RouteID,
+				Direction: normaliseDirection(journeyPattern.Direction),
+				Description: journeyPattern.Description,
+			})
+
+			servicePatterns[journeyPattern.ID] = serviceJourneyPattern{
+				lineID: lineID,
+				service: service,
+				journeyPattern: journeyPattern,
+			}
+		}
+	}
+
+	routeSections := make(map[string]transXChangeRouteSection)
+	for _, routeSection := range transXChange.RouteSections {
+		routeSections[routeSection.ID] = routeSection
+	}
+
+	journeyStops := make([]models.JourneyStop, 0)
+	routeStopCounts := make(map[string]int)
+	routedSections := make(map[string]bool)
+	for _, route := range transXChange.Routes {
+		// a route runs along each of its route sections in order
+		var sections []transXChangeRouteSection
+		var missingSectionID string
+		for _, routeSectionID := range route.RouteSectionIDs {
+			routeSection, ok := routeSections[routeSectionID]
+			if !ok {
+				missingSectionID = routeSectionID
+				break
+			}
+
+			routedSections[routeSectionID] = true
+			sections = append(sections, routeSection)
+		}
+
+		if missingSectionID != "" || len(sections) == 0 {
+			fmt.Fprintln(os.Stderr, "Couldn't find a RouteSection", missingSectionID, route.ID, transXChange.FileName)
+
+			importErrors = append(importErrors, models.ImportError{
+				ElementID: route.ID,
+				Reason: "Couldn't find a RouteSection",
+			})
+
+			continue
+		}
+
+		// find the LineID from the Journey with the same RouteID
+		var lineID string
+		journeyIndex := 0
+		for lineID == "" && journeyIndex < len(journeys) {
+			if journeys[journeyIndex].RouteID == route.ID {
+				lineID = journeys[journeyIndex].LineID
+			}
+
+			journeyIndex++
+		}
+
+		if lineID == "" {
+			fmt.Fprintln(os.Stderr, "Couldn't find a LineID", route.ID, transXChange.FileName)
+
+			importErrors = append(importErrors, models.ImportError{
+				ElementID: route.ID,
+				Reason: "Couldn't find a LineID",
+			})
+
+			continue
+		}
+
+		routeStops, brokenSectionID, err := parseRouteSections(sections, lineID, route.ID)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err, brokenSectionID, transXChange.FileName)
+
+			importErrors = append(importErrors, models.ImportError{
+				ElementID: brokenSectionID,
+				Reason: err.Error(),
+			})
+
+			continue
+		}
+
+		journeyStops = append(journeyStops, routeStops...)
+		routeStopCounts[route.ID] = len(routeStops)
+	}
+
+	for _, routeSection := range transXChange.RouteSections {
+		if routedSections[routeSection.ID] {
+			continue
+		}
+
+		fmt.Fprintln(os.Stderr, "Couldn't find a routeID", routeSection.ID, transXChange.FileName)
+
+		importErrors = append(importErrors, models.ImportError{
+			ElementID: routeSection.ID,
+			Reason: "Couldn't find a routeID",
+		})
+	}
+
+	trips := parseVehicleJourneys(transXChange, servicePatterns, routeStopCounts, &importErrors)
+
+	return parsedTransXChange{
+		schemaVersion: version,
+		revision:      transXChange.Revision,
+		createdAt:     strings.TrimSpace(transXChange.CreatedAt),
+		modifiedAt:    strings.TrimSpace(transXChange.UpdatedAt),
+		operators:     operators,
+		lines:         lines,
+		journeys:      journeys,
+		journeyStops:  journeyStops,
+		trips:         trips,
+		importErrors:  importErrors,
+	}, nil
+}
+
+// parseRouteSections gets the ordered stops of a route along the route links of
+// each of its sections in turn. When the links don't join up, the ID of the
+// route section with the link which doesn't follow on is returned
+func parseRouteSections(routeSections []transXChangeRouteSection, lineID string, routeID string) ([]models.JourneyStop, string, error) {
+	journeyStops := make([]models.JourneyStop, 0)
+
+	previousStopDestination := ""
+	for _, routeSection := range routeSections {
+		for _, routeLink := range routeSection.RouteLinks {
+			if len(journeyStops) > 0 && routeLink.From != previousStopDestination {
+				return nil, routeSection.ID, errors.New("Previous stop destination does not match current stop")
+			}
+
+			if len(journeyStops) == 0 {
+				journeyStops = append(journeyStops, models.JourneyStop{
+					LineID: lineID,
+					RouteID: routeID,
+					StopNumber: 0,
+					BusStopID: routeLink.From,
+				})
+			}
+
+			journeyStops = append(journeyStops, models.JourneyStop{
+				LineID: lineID,
+				RouteID: routeID,
+				StopNumber: uint(len(journeyStops)),
+				BusStopID: routeLink.To,
+			})
+			previousStopDestination = routeLink.To
+		}
+	}
+
+	return journeyStops, "", nil
+}
+
+// serviceJourneyPattern is a journey pattern with the service and line it
+// belongs to
+type serviceJourneyPattern struct {
+	lineID         string
+	service        transXChangeService
+	journeyPattern transXChangeJourneyPattern
+}
+
+// parseVehicleJourneys gets the scheduled trips of each vehicle journey. A
+// journey whose timing links don't match the stops of its route is skipped and
+// added to the import errors
+func parseVehicleJourneys(transXChange transXChange, servicePatterns map[string]serviceJourneyPattern, routeStopCounts map[string]int, importErrors *[]models.ImportError) []models.Trip {
+	journeyPatternSections := make(map[string]transXChangeJourneyPatternSection)
+	for _, journeyPatternSection := range transXChange.JourneyPatternSections {
+		journeyPatternSections[journeyPatternSection.ID] = journeyPatternSection
+	}
+
+	vehicleJourneys := make(map[string]transXChangeVehicleJourney)
+	for _, vehicleJourney := range transXChange.VehicleJourneys {
+		vehicleJourneys[vehicleJourney.Code] = vehicleJourney
+	}
+
+	trips := make([]models.Trip, 0)
+	for _, vehicleJourney := range transXChange.VehicleJourneys {
+		trip, err := parseVehicleJourney(vehicleJourney, vehicleJourneys, servicePatterns, journeyPatternSections, routeStopCounts)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err, vehicleJourney.Code, transXChange.FileName)
+
+			*importErrors = append(*importErrors, models.ImportError{
+				ElementID: vehicleJourney.Code,
+				Reason: err.Error(),
+			})
+
+			continue
+		}
+
+		trips = append(trips, trip)
+	}
+
+	return trips
+}
+
+func parseVehicleJourney(
+	vehicleJourney transXChangeVehicleJourney,
+	vehicleJourneys map[string]transXChangeVehicleJourney,
+	servicePatterns map[string]serviceJourneyPattern,
+	journeyPatternSections map[string]transXChangeJourneyPatternSection,
+	routeStopCounts map[string]int,
+) (models.Trip, error) {
+	// a vehicle journey can use the journey pattern of another vehicle journey
+	journeyPatternID := vehicleJourney.JourneyPatternID
+	if journeyPatternID == "" {
+		journeyPatternID = vehicleJourneys[vehicleJourney.VehicleJourneyRef].JourneyPatternID
+	}
+
+	servicePattern, ok := servicePatterns[journeyPatternID]
+	if !ok {
+		return models.Trip{}, errors.New("Couldn't find a JourneyPattern")
+	}
+
+	startDate := strings.TrimSpace(servicePattern.service.StartDate)
+	if _, err := time.Parse("2006-01-02", startDate); err != nil {
+		return models.Trip{}, errors.New("Invalid OperatingPeriod StartDate")
+	}
+
+	endDate := strings.TrimSpace(servicePattern.service.EndDate)
+	if _, err := time.Parse("2006-01-02", endDate); endDate != "" && err != nil {
+		return models.Trip{}, errors.New("Invalid OperatingPeriod EndDate")
+	}
+
+	departureTime, err := parseTimeOfDay(vehicleJourney.DepartureTime)
+	if err != nil {
+		return models.Trip{}, err
+	}
+
+	// Each stop is departed after the run time of the previous timing links and
+	// any time waiting at the stops
+	stopOffsets := make([]uint, 0)
+	var offset uint
+	for _, journeyPatternSectionID := range servicePattern.journeyPattern.JourneyPatternSectionIDs {
+		journeyPatternSection, ok := journeyPatternSections[journeyPatternSectionID]
+		if !ok {
+			return models.Trip{}, errors.New("Couldn't find a JourneyPatternSection")
+		}
+
+		for _, timingLink := range journeyPatternSection.TimingLinks {
+			durations := []string{timingLink.From.WaitTime, timingLink.RunTime, timingLink.To.WaitTime}
+			seconds := make([]uint, len(durations))
+			for durationIndex, duration := range durations {
+				if seconds[durationIndex], err = parseDuration(duration); err != nil {
+					return models.Trip{}, err
+				}
+			}
+
+			if len(stopOffsets) == 0 {
+				stopOffsets = append(stopOffsets, 0)
+			}
+
+			offset += seconds[0]
+			stopOffsets[len(stopOffsets) - 1] = offset
+
+			offset += seconds[1]
+			stopOffsets = append(stopOffsets, offset)
+
+			offset += seconds[2]
+		}
+	}
+
+	routeID := servicePattern.journeyPattern.RouteID
+	if len(stopOffsets) != routeStopCounts[routeID] {
+		return models.Trip{}, errors.New("JourneyPatternTimingLinks do not match the route stops")
+	}
+
+	operatingProfile := vehicleJourney.OperatingProfile
+	if operatingProfile == nil {
+		operatingProfile = servicePattern.service.OperatingProfile
+	}
+
+	return models.Trip{
+		LineID: servicePattern.lineID,
+		RouteID: routeID,
+		VehicleJourneyCode: vehicleJourney.Code,
+		DepartureTime: departureTime,
+		Days: operatingProfile.days(),
+		StartDate: startDate,
+		EndDate: endDate,
+		StopOffsets: stopOffsets,
+	}, nil
+}
+
+var durationPattern = regexp.MustCompile(`^PT(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?$`)
+
+// parseDuration parses an ISO 8601 duration such as PT1H5M30S into seconds. An
+// empty duration is 0 seconds
+func parseDuration(duration string) (uint, error) {
+	duration = strings.TrimSpace(duration)
+	if duration == "" {
+		return 0, nil
+	}
+
+	matches := durationPattern.FindStringSubmatch(duration)
+	if matches == nil {
+		return 0, fmt.Errorf("Invalid duration %v", duration)
+	}
+
+	var seconds uint
+	for unitIndex, unitSeconds := range []uint{3600, 60, 1} {
+		if matches[unitIndex + 1] == "" {
+			continue
+		}
+
+		value, err := strconv.ParseUint(matches[unitIndex + 1], 10, 32)
+		if err != nil {
+			return 0, fmt.Errorf("Invalid duration %v", duration)
+		}
+
+		seconds += uint(value) * unitSeconds
+	}
+
+	return seconds, nil
+}
+
+// parseTimeOfDay parses a time of day such as 08:15:00 into seconds after
+// midnight
+func parseTimeOfDay(timeOfDay string) (uint, error) {
+	parts := strings.Split(strings.TrimSpace(timeOfDay), ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("Invalid departure time %v", timeOfDay)
+	}
+
+	var seconds uint
+	for partIndex, unitSeconds := range []uint{3600, 60, 1} {
+		value, err := strconv.ParseUint(parts[partIndex], 10, 32)
+		if err != nil || (partIndex > 0 && value >= 60) {
+			return 0, fmt.Errorf("Invalid departure time %v", timeOfDay)
+		}
+
+		seconds += uint(value) * unitSeconds
+	}
+
+	return seconds, nil
+}
+
+func isSupportedTransXChangeVersion(version string) bool {
+	for _, supportedVersion := range supportedTransXChangeVersions {
+		if version == supportedVersion {
+			return true
+		}
+	}
+
+	return false
+}
+
+// normaliseDirection maps a TransXChange journey pattern direction on to
+// INBOUND or OUTBOUND
+func normaliseDirection(direction string) string {
+	switch strings.ToLower(strings.TrimSpace(direction)) {
+		case "inbound", "anticlockwise": return "INBOUND"
+		default: return "OUTBOUND"
+	}
+}
+
+// TransXChange
+type transXChange struct {
+	XMLName           xml.Name                   `xml:"TransXChange"`
+	FileName          string                     `xml:"FileName,attr"` // used for debugging
+	CreatedAt         string                     `xml:"CreationDateTime,attr"`
+	UpdatedAt         string                     `xml:"ModificationDateTime,attr"`
+	Version           string                     `xml:"SchemaVersion,attr"`
+	Revision          uint                       `xml:"RevisionNumber,attr"`
+	RouteSections     []transXChangeRouteSection `xml:"RouteSections>RouteSection"`
+	Routes            []transXChangeRoute        `xml:"Routes>Route"`
+	Operators         []transXChangeOperator     `xml:"Operators>Operator"`
+	LicensedOperators []transXChangeOperator     `xml:"Operators>LicensedOperator"`
+	Services          []transXChangeService      `xml:"Services>Service"`
+	JourneyPatternSections []transXChangeJourneyPatternSection `xml:"JourneyPatternSections>JourneyPatternSection"`
+	VehicleJourneys   []transXChangeVehicleJourney `xml:"VehicleJourneys>VehicleJourney"`
+}
+
+type transXChangeRoute struct {
+	XML             xml.Name `xml:"Route"`
+	ID              string   `xml:"id,attr"`
+	CreatedAt       string   `xml:"CreationDateTime,attr"`
+	UpdatedAt       string   `xml:"ModificationDateTime,attr"`
+	Revision        uint     `xml:"RevisionNumber,attr"`
+	RouteSectionIDs []string `xml:"RouteSectionRef"`
+	Description     string   `xml:"Description"`
+}
+
+type transXChangeRouteSection struct {
+	XML        xml.Name                `xml:"RouteSection"`
+	ID         string                  `xml:"id,attr"`
+	RouteLinks []transXChangeRouteLink `xml:"RouteLink"`
+}
+
+type transXChangeRouteLink struct {
+	XML  xml.Name `xml:"RouteLink"`
+	From string   `xml:"From>StopPointRef"`
+	To   string   `xml:"To>StopPointRef"`
+}
+
+// transXChangeOperator is either an Operator or a LicensedOperator
+type transXChangeOperator struct {
+	OperatorID     string `xml:"NationalOperatorCode"`
+	OperatorCode   string `xml:"OperatorCode"`
+	LocalID        string `xml:"id,attr"` // local ID to this file
+	ShortName      string `xml:"OperatorShortName"`
+	Name           string `xml:"TradingName"`
+	NameOnLicence  string `xml:"OperatorNameOnLicence"`
+}
+
+// nationalOperatorCode falls back to the OperatorCode as the national operator
+// code is optional in 2.1
+func (operator transXChangeOperator) nationalOperatorCode() string {
+	if operator.OperatorID != "" {
+		return operator.OperatorID
+	}
+
+	return operator.OperatorCode
+}
+
+// name falls back to the name on licence when the operator has no trading name
+func (operator transXChangeOperator) name() string {
+	if operator.Name != "" {
+		return operator.Name
+	}
+
+	return operator.NameOnLicence
+}
+
+type transXChangeService struct {
+	XML             xml.Name                     `xml:"Service"`
+	ServiceCode     string                       `xml:"ServiceCode"`
+	LocalOperatorID string                       `xml:"RegisteredOperatorRef"`
+	Line            transXChangeServiceLine    	 `xml:"Lines>Line"`
+	Origin          string                       `xml:"StandardService>Origin"`
+	Destination     string                       `xml:"StandardService>Destination"`
+	JourneyPattern  []transXChangeJourneyPattern `xml:"StandardService>JourneyPattern"`
+	StartDate       string                       `xml:"OperatingPeriod>StartDate"`
+	EndDate         string                       `xml:"OperatingPeriod>EndDate"`
+	OperatingProfile *transXChangeOperatingProfile `xml:"OperatingProfile"`
+}
+
+type transXChangeServiceLine struct {
+	XML      xml.Name	                       `xml:"Line"`
+	ID       string                          `xml:"id,attr"`
+	Name     string                          `xml:"LineName"`
+	OutBound transXChangeServiceLineOutBound `xml:"OutboundDescription"`
+	InBound  transXChangeServiceLineInBound  `xml:"InboundDescription"`
+}
+
+type transXChangeServiceLineOutBound struct {
+	XML         xml.Name `xml:"OutboundDescription"`
+	Origin      string   `xml:"Origin"`
+	Destination string   `xml:"Destination"`
+	Description string   `xml:"Description"`
+}
+
+type transXChangeServiceLineInBound struct {
+	XML         xml.Name `xml:"InboundDescription"`
+	Origin      string   `xml:"Origin"`
+	Destination string   `xml:"Destination"`
+	Description string   `xml:"Description"`
+}
+
+type transXChangeJourneyPattern struct {
+	XML                xml.Name `xml:"JourneyPattern"`
+	ID                 string   `xml:"id,attr"`
+	DestinationDisplay string   `xml:"DestinationDisplay"`
+	Direction          string   `xml:"Direction"`
+	Description        string   `xml:"Description"`
+	RouteID            string   `xml:"RouteRef"`
+	JourneyPatternSectionIDs []string `xml:"JourneyPatternSectionRefs"`
+}
+
+type transXChangeJourneyPatternSection struct {
+	XML         xml.Name                           `xml:"JourneyPatternSection"`
+	ID          string                             `xml:"id,attr"`
+	TimingLinks []transXChangeJourneyPatternTimingLink `xml:"JourneyPatternTimingLink"`
+}
+
+type transXChangeJourneyPatternTimingLink struct {
+	XML     xml.Name                         `xml:"JourneyPatternTimingLink"`
+	From    transXChangeJourneyPatternStopUsage `xml:"From"`
+	To      transXChangeJourneyPatternStopUsage `xml:"To"`
+	RunTime string                           `xml:"RunTime"`
+}
+
+type transXChangeJourneyPatternStopUsage struct {
+	StopPointRef string `xml:"StopPointRef"`
+	WaitTime     string `xml:"WaitTime"`
+}
+
+type transXChangeVehicleJourney struct {
+	XML               xml.Name                      `xml:"VehicleJourney"`
+	Code              string                        `xml:"VehicleJourneyCode"`
+	JourneyPatternID  string                        `xml:"JourneyPatternRef"`
+	VehicleJourneyRef string                        `xml:"VehicleJourneyRef"`
+	DepartureTime     string                        `xml:"DepartureTime"`
+	OperatingProfile  *transXChangeOperatingProfile `xml:"OperatingProfile"`
+}
+
+// transXChangeOperatingProfile is the days a vehicle journey runs on. Bank
+// holidays and serviced organisation (school) days aren't decoded
+type transXChangeOperatingProfile struct {
+	DaysOfWeek   *transXChangeDaysOfWeek `xml:"RegularDayType>DaysOfWeek"`
+	HolidaysOnly *struct{}              `xml:"RegularDayType>HolidaysOnly"`
+}
+
+type transXChangeDaysOfWeek struct {
+	Days []xml.Name `xml:",any"`
+}
+
+// transXChangeDays are the days of the week of each TransXChange day type as a
+// bitmask where Monday is bit 0 and Sunday is bit 6
+var transXChangeDays = map[string]uint8{
+	"Monday": models.Monday,
+	"Tuesday": models.Tuesday,
+	"Wednesday": models.Wednesday,
+	"Thursday": models.Thursday,
+	"Friday": models.Friday,
+	"Saturday": models.Saturday,
+	"Sunday": models.Sunday,
+	"MondayToFriday": models.Monday | models.Tuesday | models.Wednesday | models.Thursday | models.Friday,
+	"MondayToSaturday": models.Monday | models.Tuesday | models.Wednesday | models.Thursday | models.Friday | models.Saturday,
+	"MondayToSunday": models.EveryDay,
+	"NotMonday": models.EveryDay &^ models.Monday,
+	"NotTuesday": models.EveryDay &^ models.Tuesday,
+	"NotWednesday": models.EveryDay &^ models.Wednesday,
+	"NotThursday": models.EveryDay &^ models.Thursday,
+	"NotFriday": models.EveryDay &^ models.Friday,
+	"NotSaturday": models.EveryDay &^ models.Saturday,
+	"NotSunday": models.EveryDay &^ models.Sunday,
+	"Weekend": models.Saturday | models.Sunday,
+}
+
+// days gets the days of the week the profile runs on. A journey without an
+// operating profile is treated as running every day
+func (operatingProfile *transXChangeOperatingProfile) days() uint8 {
+	if operatingProfile == nil {
+		return models.EveryDay
+	}
+
+	if operatingProfile.HolidaysOnly != nil {
+		return 0
+	}
+
+	if operatingProfile.DaysOfWeek == nil {
+		return models.EveryDay
+	}
+
+	var days uint8
+	for _, day := range operatingProfile.DaysOfWeek.Days {
+		days |= transXChangeDays[day.Local]
+	}
+
+	return days
+}
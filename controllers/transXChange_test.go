@@ -0,0 +1,1550 @@
+package controllers
+
+import (
+	"io/ioutil"
+	"reflect"
+	"server/models"
+	"testing"
+)
+
+func Test_parseTransXChange(t *testing.T) {
+	type args struct {
+		xmlFile string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    parsedTransXChange
+		wantErr bool
+	}{
+		{
+			name: "Gets two routes from SCEK-953",
+			args: args{
+				xmlFile: "./testdata/dft-timetable.xml",
+			},
+			want: parsedTransXChange{
+				schemaVersion: "2.4",
+				revision: 67,
+				createdAt: "2020-11-22T11:00:00",
+				modifiedAt: "2021-03-03T11:06:57",
+				operators: []models.Operator{
+					models.Operator{
+						ID:        "SCEK",
+						Name:      "Stagecoach in East Kent",
+						ShortName: "Stagecoach",
+					},
+				},
+				lines: []models.Line{
+					models.Line{
+						ID:         "SCEK:PK0000098:84_953_953:953:",
+						OperatorID: "SCEK",
+						Name:       "953",
+					},
+				},
+				journeys: []models.Journey{
+					models.Journey{
+						LineID:      "SCEK:PK0000098:84_953_953:953:",
+						RouteID:     "RT131",
+						Direction:   "OUTBOUND",
+						Description: "Bus Station - High School",
+					},
+					models.Journey{
+						LineID:      "SCEK:PK0000098:84_953_953:953:",
+						RouteID:     "RT132",
+						Direction:   "INBOUND",
+						Description: "Bus Station - High School",
+					},
+				},
+				journeyStops: []models.JourneyStop{
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:84_953_953:953:",
+						RouteID:    "RT131",
+						StopNumber: 0,
+						BusStopID:  "240098892",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:84_953_953:953:",
+						RouteID:    "RT131",
+						StopNumber: 1,
+						BusStopID:  "2400A049530A",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:84_953_953:953:",
+						RouteID:    "RT131",
+						StopNumber: 2,
+						BusStopID:  "240096713",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:84_953_953:953:",
+						RouteID:    "RT131",
+						StopNumber: 3,
+						BusStopID:  "2400A050490A",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:84_953_953:953:",
+						RouteID:    "RT131",
+						StopNumber: 4,
+						BusStopID:  "2400A050530A",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:84_953_953:953:",
+						RouteID:    "RT132",
+						StopNumber: 0,
+						BusStopID:  "2400A050510A",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:84_953_953:953:",
+						RouteID:    "RT132",
+						StopNumber: 1,
+						BusStopID:  "2400A050500A",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:84_953_953:953:",
+						RouteID:    "RT132",
+						StopNumber: 2,
+						BusStopID:  "240096711",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:84_953_953:953:",
+						RouteID:    "RT132",
+						StopNumber: 3,
+						BusStopID:  "2400A049550A",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:84_953_953:953:",
+						RouteID:    "RT132",
+						StopNumber: 4,
+						BusStopID:  "2400A039640A",
+					},
+				},
+				trips: []models.Trip{
+					models.Trip{
+						LineID: "SCEK:PK0000098:84_953_953:953:",
+						RouteID: "RT131",
+						VehicleJourneyCode: "VJ964",
+						DepartureTime: 29700,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 120, 420, 480, 600},
+					},
+					models.Trip{
+						LineID: "SCEK:PK0000098:84_953_953:953:",
+						RouteID: "RT131",
+						VehicleJourneyCode: "VJ965",
+						DepartureTime: 30000,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 120, 420, 480, 600},
+					},
+					models.Trip{
+						LineID: "SCEK:PK0000098:84_953_953:953:",
+						RouteID: "RT131",
+						VehicleJourneyCode: "VJ966",
+						DepartureTime: 30720,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 120, 420, 480, 600},
+					},
+					models.Trip{
+						LineID: "SCEK:PK0000098:84_953_953:953:",
+						RouteID: "RT132",
+						VehicleJourneyCode: "VJ967",
+						DepartureTime: 54600,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 0, 120, 420, 600},
+					},
+					models.Trip{
+						LineID: "SCEK:PK0000098:84_953_953:953:",
+						RouteID: "RT132",
+						VehicleJourneyCode: "VJ968",
+						DepartureTime: 54600,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 0, 120, 420, 600},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Skips a route section with a broken route link",
+			args: args{
+				xmlFile: "./testdata/dft-timetable-broken.xml",
+			},
+			want: parsedTransXChange{
+				schemaVersion: "2.4",
+				revision: 67,
+				createdAt: "2020-11-22T11:00:00",
+				modifiedAt: "2021-03-03T11:06:57",
+				operators: []models.Operator{
+					models.Operator{
+						ID:        "SCEK",
+						Name:      "Stagecoach in East Kent",
+						ShortName: "Stagecoach",
+					},
+				},
+				lines: []models.Line{
+					models.Line{
+						ID:         "SCEK:PK0000098:84_953_953:953:",
+						OperatorID: "SCEK",
+						Name:       "953",
+					},
+				},
+				journeys: []models.Journey{
+					models.Journey{
+						LineID:      "SCEK:PK0000098:84_953_953:953:",
+						RouteID:     "RT131",
+						Direction:   "OUTBOUND",
+						Description: "Bus Station - High School",
+					},
+					models.Journey{
+						LineID:      "SCEK:PK0000098:84_953_953:953:",
+						RouteID:     "RT132",
+						Direction:   "INBOUND",
+						Description: "Bus Station - High School",
+					},
+				},
+				journeyStops: []models.JourneyStop{
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:84_953_953:953:",
+						RouteID:    "RT132",
+						StopNumber: 0,
+						BusStopID:  "2400A050510A",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:84_953_953:953:",
+						RouteID:    "RT132",
+						StopNumber: 1,
+						BusStopID:  "2400A050500A",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:84_953_953:953:",
+						RouteID:    "RT132",
+						StopNumber: 2,
+						BusStopID:  "240096711",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:84_953_953:953:",
+						RouteID:    "RT132",
+						StopNumber: 3,
+						BusStopID:  "2400A049550A",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:84_953_953:953:",
+						RouteID:    "RT132",
+						StopNumber: 4,
+						BusStopID:  "2400A039640A",
+					},
+				},
+				trips: []models.Trip{
+					models.Trip{
+						LineID: "SCEK:PK0000098:84_953_953:953:",
+						RouteID: "RT132",
+						VehicleJourneyCode: "VJ967",
+						DepartureTime: 54600,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 0, 120, 420, 600},
+					},
+					models.Trip{
+						LineID: "SCEK:PK0000098:84_953_953:953:",
+						RouteID: "RT132",
+						VehicleJourneyCode: "VJ968",
+						DepartureTime: 54600,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 0, 120, 420, 600},
+					},
+				},
+				importErrors: []models.ImportError{
+					models.ImportError{
+						ElementID: "RS1",
+						Reason:    "Previous stop destination does not match current stop",
+					},
+					models.ImportError{
+						ElementID: "VJ964",
+						Reason:    "JourneyPatternTimingLinks do not match the route stops",
+					},
+					models.ImportError{
+						ElementID: "VJ965",
+						Reason:    "JourneyPatternTimingLinks do not match the route stops",
+					},
+					models.ImportError{
+						ElementID: "VJ966",
+						Reason:    "JourneyPatternTimingLinks do not match the route stops",
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Gets two routes from uni1",
+			args: args{
+				xmlFile: "./testdata/dft-timetable-uni1.xml",
+			},
+			want: parsedTransXChange{
+				schemaVersion: "2.4",
+				revision: 67,
+				createdAt: "2020-11-22T11:00:00",
+				modifiedAt: "2021-03-03T11:07:03",
+				operators: []models.Operator{
+					models.Operator{
+						ID:        "SCEK",
+						Name:      "Stagecoach in East Kent",
+						ShortName: "Stagecoach",
+					},
+				},
+				lines: []models.Line{
+					models.Line{
+						ID:         "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						OperatorID: "SCEK",
+						Name:       "Uni1",
+					},
+				},
+				journeys: []models.Journey{
+					models.Journey{
+						LineID:      "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID:     "RT197",
+						Direction:   "OUTBOUND",
+						Description: "City Centre - University",
+					},
+					models.Journey{
+						LineID:      "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID:     "RT199",
+						Direction:   "INBOUND",
+						Description: "City Centre - University",
+					},
+					models.Journey{
+						LineID:      "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID:     "RT198",
+						Direction:   "INBOUND",
+						Description: "City Centre - University",
+					},
+					models.Journey{
+						LineID:      "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID:     "RT199",
+						Direction:   "INBOUND",
+						Description: "City Centre - University",
+					},
+					models.Journey{
+						LineID:      "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID:     "RT199",
+						Direction:   "INBOUND",
+						Description: "City Centre - University",
+					},
+					models.Journey{
+						LineID:      "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID:     "RT199",
+						Direction:   "INBOUND",
+						Description: "City Centre - University",
+					},
+				},
+				journeyStops: []models.JourneyStop{
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID:    "RT197",
+						StopNumber: 0,
+						BusStopID:  "240098906",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID:    "RT197",
+						StopNumber: 1,
+						BusStopID:  "2400A049530A",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID:    "RT197",
+						StopNumber: 2,
+						BusStopID:  "2400A048110A",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID:    "RT197",
+						StopNumber: 3,
+						BusStopID:  "2400A048140A",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID:    "RT197",
+						StopNumber: 4,
+						BusStopID:  "2400A048160A",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID:    "RT197",
+						StopNumber: 5,
+						BusStopID:  "2400A048170A",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID:    "RT197",
+						StopNumber: 6,
+						BusStopID:  "2400A050260A",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID:    "RT197",
+						StopNumber: 7,
+						BusStopID:  "2400A050270A",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID:    "RT197",
+						StopNumber: 8,
+						BusStopID:  "2400A050290A",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID:    "RT197",
+						StopNumber: 9,
+						BusStopID:  "2400100704",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID:    "RT197",
+						StopNumber: 10,
+						BusStopID:  "2400A040360A",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID:    "RT197",
+						StopNumber: 11,
+						BusStopID:  "240095612",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID:    "RT198",
+						StopNumber: 0,
+						BusStopID:  "240095612",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID:    "RT198",
+						StopNumber: 1,
+						BusStopID:  "2400A040370A",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID:    "RT198",
+						StopNumber: 2,
+						BusStopID:  "240075428",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID:    "RT198",
+						StopNumber: 3,
+						BusStopID:  "240097602",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID:    "RT198",
+						StopNumber: 4,
+						BusStopID:  "240097597",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID:    "RT198",
+						StopNumber: 5,
+						BusStopID:  "2400100702",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID:    "RT198",
+						StopNumber: 6,
+						BusStopID:  "2400A050300A",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID:    "RT198",
+						StopNumber: 7,
+						BusStopID:  "2400A050280A",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID:    "RT198",
+						StopNumber: 8,
+						BusStopID:  "2400A048180A",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID:    "RT198",
+						StopNumber: 9,
+						BusStopID:  "2400A048150A",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID:    "RT198",
+						StopNumber: 10,
+						BusStopID:  "2400105752",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID:    "RT198",
+						StopNumber: 11,
+						BusStopID:  "2400A040380A",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID:    "RT198",
+						StopNumber: 12,
+						BusStopID:  "240096711",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID:    "RT198",
+						StopNumber: 13,
+						BusStopID:  "2400A049550A",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID:    "RT198",
+						StopNumber: 14,
+						BusStopID:  "2400A039640A",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID:    "RT199",
+						StopNumber: 0,
+						BusStopID:  "240095612",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID:    "RT199",
+						StopNumber: 1,
+						BusStopID:  "2400A040370A",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID:    "RT199",
+						StopNumber: 2,
+						BusStopID:  "2400100702",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID:    "RT199",
+						StopNumber: 3,
+						BusStopID:  "2400A050300A",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID:    "RT199",
+						StopNumber: 4,
+						BusStopID:  "2400A050280A",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID:    "RT199",
+						StopNumber: 5,
+						BusStopID:  "2400A048180A",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID:    "RT199",
+						StopNumber: 6,
+						BusStopID:  "2400A048150A",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID:    "RT199",
+						StopNumber: 7,
+						BusStopID:  "2400105752",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID:    "RT199",
+						StopNumber: 8,
+						BusStopID:  "2400A040380A",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID:    "RT199",
+						StopNumber: 9,
+						BusStopID:  "240096711",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID:    "RT199",
+						StopNumber: 10,
+						BusStopID:  "2400A049550A",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID:    "RT199",
+						StopNumber: 11,
+						BusStopID:  "2400A039640A",
+					},
+				},
+				trips: []models.Trip{
+					models.Trip{
+						LineID: "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID: "RT197",
+						VehicleJourneyCode: "VJ1341",
+						DepartureTime: 29400,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 120, 360, 420, 480, 600, 660, 720, 840, 900, 960, 1020},
+					},
+					models.Trip{
+						LineID: "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID: "RT197",
+						VehicleJourneyCode: "VJ1342",
+						DepartureTime: 30300,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 120, 360, 420, 480, 600, 660, 720, 840, 900, 960, 1020},
+					},
+					models.Trip{
+						LineID: "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID: "RT199",
+						VehicleJourneyCode: "VJ1343",
+						DepartureTime: 30420,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 120, 240, 480, 540, 660, 720, 840, 900, 1020, 1200, 1380},
+					},
+					models.Trip{
+						LineID: "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID: "RT197",
+						VehicleJourneyCode: "VJ1344",
+						DepartureTime: 31200,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 120, 360, 420, 480, 600, 660, 720, 840, 900, 960, 1020},
+					},
+					models.Trip{
+						LineID: "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID: "RT199",
+						VehicleJourneyCode: "VJ1345",
+						DepartureTime: 31320,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 120, 240, 480, 540, 660, 720, 840, 900, 1020, 1200, 1380},
+					},
+					models.Trip{
+						LineID: "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID: "RT198",
+						VehicleJourneyCode: "VJ1346",
+						DepartureTime: 32220,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 60, 300, 360, 420, 600, 780, 840, 960, 1020, 1140, 1200, 1320, 1500, 1680},
+					},
+					models.Trip{
+						LineID: "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID: "RT197",
+						VehicleJourneyCode: "VJ1347",
+						DepartureTime: 33000,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 120, 360, 420, 480, 600, 660, 720, 840, 900, 960, 1020},
+					},
+					models.Trip{
+						LineID: "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID: "RT199",
+						VehicleJourneyCode: "VJ1348",
+						DepartureTime: 34200,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 120, 300, 600, 660, 780, 840, 960, 1020, 1140, 1320, 1500},
+					},
+					models.Trip{
+						LineID: "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID: "RT197",
+						VehicleJourneyCode: "VJ1349",
+						DepartureTime: 34800,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 120, 360, 420, 480, 600, 660, 720, 840, 900, 960, 1020},
+					},
+					models.Trip{
+						LineID: "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID: "RT199",
+						VehicleJourneyCode: "VJ1350",
+						DepartureTime: 36000,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 120, 300, 600, 660, 780, 840, 960, 1020, 1140, 1320, 1500},
+					},
+					models.Trip{
+						LineID: "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID: "RT197",
+						VehicleJourneyCode: "VJ1351",
+						DepartureTime: 36600,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 120, 360, 420, 480, 600, 660, 720, 840, 900, 960, 1020},
+					},
+					models.Trip{
+						LineID: "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID: "RT199",
+						VehicleJourneyCode: "VJ1352",
+						DepartureTime: 37800,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 120, 300, 600, 660, 780, 840, 960, 1020, 1140, 1320, 1500},
+					},
+					models.Trip{
+						LineID: "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID: "RT197",
+						VehicleJourneyCode: "VJ1353",
+						DepartureTime: 38400,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 120, 360, 420, 480, 600, 660, 720, 840, 900, 960, 1020},
+					},
+					models.Trip{
+						LineID: "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID: "RT199",
+						VehicleJourneyCode: "VJ1354",
+						DepartureTime: 39600,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 120, 300, 600, 660, 780, 840, 960, 1020, 1140, 1320, 1500},
+					},
+					models.Trip{
+						LineID: "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID: "RT197",
+						VehicleJourneyCode: "VJ1355",
+						DepartureTime: 40200,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 120, 360, 420, 480, 600, 660, 720, 840, 900, 960, 1020},
+					},
+					models.Trip{
+						LineID: "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID: "RT199",
+						VehicleJourneyCode: "VJ1356",
+						DepartureTime: 41400,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 120, 300, 600, 660, 780, 840, 960, 1020, 1140, 1320, 1500},
+					},
+					models.Trip{
+						LineID: "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID: "RT197",
+						VehicleJourneyCode: "VJ1357",
+						DepartureTime: 42000,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 120, 360, 420, 480, 600, 660, 720, 840, 900, 960, 1020},
+					},
+					models.Trip{
+						LineID: "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID: "RT199",
+						VehicleJourneyCode: "VJ1358",
+						DepartureTime: 43200,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 120, 300, 600, 660, 780, 840, 960, 1020, 1140, 1320, 1500},
+					},
+					models.Trip{
+						LineID: "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID: "RT197",
+						VehicleJourneyCode: "VJ1359",
+						DepartureTime: 43800,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 120, 360, 420, 480, 600, 660, 720, 840, 900, 960, 1020},
+					},
+					models.Trip{
+						LineID: "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID: "RT199",
+						VehicleJourneyCode: "VJ1360",
+						DepartureTime: 45000,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 120, 300, 600, 660, 780, 840, 960, 1020, 1140, 1320, 1500},
+					},
+					models.Trip{
+						LineID: "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID: "RT197",
+						VehicleJourneyCode: "VJ1361",
+						DepartureTime: 45600,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 120, 360, 420, 480, 600, 660, 720, 840, 900, 960, 1020},
+					},
+					models.Trip{
+						LineID: "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID: "RT199",
+						VehicleJourneyCode: "VJ1362",
+						DepartureTime: 46800,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 120, 300, 600, 660, 780, 840, 960, 1020, 1140, 1320, 1500},
+					},
+					models.Trip{
+						LineID: "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID: "RT197",
+						VehicleJourneyCode: "VJ1363",
+						DepartureTime: 47400,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 120, 360, 420, 480, 600, 660, 720, 840, 900, 960, 1020},
+					},
+					models.Trip{
+						LineID: "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID: "RT199",
+						VehicleJourneyCode: "VJ1364",
+						DepartureTime: 48600,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 120, 300, 600, 660, 780, 840, 960, 1020, 1140, 1320, 1500},
+					},
+					models.Trip{
+						LineID: "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID: "RT197",
+						VehicleJourneyCode: "VJ1365",
+						DepartureTime: 49200,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 120, 360, 420, 480, 600, 660, 720, 840, 900, 960, 1020},
+					},
+					models.Trip{
+						LineID: "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID: "RT199",
+						VehicleJourneyCode: "VJ1366",
+						DepartureTime: 50400,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 120, 300, 600, 660, 780, 840, 960, 1020, 1140, 1320, 1500},
+					},
+					models.Trip{
+						LineID: "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID: "RT197",
+						VehicleJourneyCode: "VJ1367",
+						DepartureTime: 51000,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 120, 360, 420, 480, 600, 660, 720, 840, 900, 960, 1020},
+					},
+					models.Trip{
+						LineID: "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID: "RT199",
+						VehicleJourneyCode: "VJ1368",
+						DepartureTime: 52200,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 120, 300, 600, 660, 780, 840, 960, 1020, 1140, 1320, 1500},
+					},
+					models.Trip{
+						LineID: "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID: "RT197",
+						VehicleJourneyCode: "VJ1369",
+						DepartureTime: 52800,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 120, 360, 420, 480, 600, 660, 720, 840, 900, 960, 1020},
+					},
+					models.Trip{
+						LineID: "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID: "RT199",
+						VehicleJourneyCode: "VJ1370",
+						DepartureTime: 54000,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 120, 300, 600, 660, 780, 840, 960, 1020, 1140, 1320, 1500},
+					},
+					models.Trip{
+						LineID: "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID: "RT197",
+						VehicleJourneyCode: "VJ1371",
+						DepartureTime: 54600,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 120, 360, 420, 480, 600, 660, 720, 840, 900, 960, 1020},
+					},
+					models.Trip{
+						LineID: "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID: "RT199",
+						VehicleJourneyCode: "VJ1372",
+						DepartureTime: 55800,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 120, 300, 600, 660, 780, 840, 960, 1020, 1140, 1320, 1500},
+					},
+					models.Trip{
+						LineID: "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID: "RT197",
+						VehicleJourneyCode: "VJ1373",
+						DepartureTime: 56700,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 120, 360, 420, 480, 600, 660, 720, 840, 900, 960, 1020},
+					},
+					models.Trip{
+						LineID: "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID: "RT199",
+						VehicleJourneyCode: "VJ1374",
+						DepartureTime: 57840,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 60, 180, 360, 420, 540, 600, 720, 780, 900, 1080, 1260},
+					},
+					models.Trip{
+						LineID: "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID: "RT197",
+						VehicleJourneyCode: "VJ1375",
+						DepartureTime: 58500,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 120, 360, 420, 480, 600, 660, 720, 840, 900, 960, 1020},
+					},
+					models.Trip{
+						LineID: "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID: "RT199",
+						VehicleJourneyCode: "VJ1376",
+						DepartureTime: 59640,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 60, 180, 360, 420, 540, 600, 720, 780, 900, 1080, 1260},
+					},
+					models.Trip{
+						LineID: "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID: "RT197",
+						VehicleJourneyCode: "VJ1377",
+						DepartureTime: 60600,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 120, 360, 420, 480, 600, 660, 720, 840, 900, 960, 1020},
+					},
+					models.Trip{
+						LineID: "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID: "RT199",
+						VehicleJourneyCode: "VJ1378",
+						DepartureTime: 61680,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 60, 180, 420, 480, 600, 660, 780, 840, 960, 1140, 1320},
+					},
+					models.Trip{
+						LineID: "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID: "RT197",
+						VehicleJourneyCode: "VJ1379",
+						DepartureTime: 62400,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 120, 360, 420, 480, 600, 660, 720, 840, 900, 960, 1020},
+					},
+					models.Trip{
+						LineID: "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID: "RT199",
+						VehicleJourneyCode: "VJ1380",
+						DepartureTime: 63480,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 60, 180, 420, 480, 600, 660, 780, 840, 960, 1140, 1320},
+					},
+					models.Trip{
+						LineID: "SCEK:PK0000098:314_Uni1_Uni1V:Uni1:",
+						RouteID: "RT197",
+						VehicleJourneyCode: "VJ1381",
+						DepartureTime: 64500,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 120, 360, 420, 480, 600, 660, 720, 840, 900, 960, 1020},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Gets two routes from a 2.1 SCEK-953",
+			args: args{
+				xmlFile: "./testdata/dft-timetable-2.1.xml",
+			},
+			want: parsedTransXChange{
+				schemaVersion: "2.1",
+				revision: 67,
+				createdAt: "2020-11-22T11:00:00",
+				modifiedAt: "2021-03-03T11:06:57",
+				operators: []models.Operator{
+					models.Operator{
+						ID:        "EK",
+						Name:      "East Kent Road Car Co Ltd",
+						ShortName: "Stagecoach",
+					},
+				},
+				lines: []models.Line{
+					models.Line{
+						ID:         "PK0000098:84_953_953:1",
+						OperatorID: "EK",
+						Name:       "953",
+					},
+				},
+				journeys: []models.Journey{
+					models.Journey{
+						LineID:      "PK0000098:84_953_953:1",
+						RouteID:     "RT131",
+						Direction:   "OUTBOUND",
+						Description: "Bus Station - High School",
+					},
+					models.Journey{
+						LineID:      "PK0000098:84_953_953:1",
+						RouteID:     "RT132",
+						Direction:   "INBOUND",
+						Description: "Bus Station - High School",
+					},
+				},
+				journeyStops: []models.JourneyStop{
+					models.JourneyStop{
+						LineID:     "PK0000098:84_953_953:1",
+						RouteID:    "RT131",
+						StopNumber: 0,
+						BusStopID:  "240098892",
+					},
+					models.JourneyStop{
+						LineID:     "PK0000098:84_953_953:1",
+						RouteID:    "RT131",
+						StopNumber: 1,
+						BusStopID:  "2400A049530A",
+					},
+					models.JourneyStop{
+						LineID:     "PK0000098:84_953_953:1",
+						RouteID:    "RT131",
+						StopNumber: 2,
+						BusStopID:  "240096713",
+					},
+					models.JourneyStop{
+						LineID:     "PK0000098:84_953_953:1",
+						RouteID:    "RT131",
+						StopNumber: 3,
+						BusStopID:  "2400A050490A",
+					},
+					models.JourneyStop{
+						LineID:     "PK0000098:84_953_953:1",
+						RouteID:    "RT131",
+						StopNumber: 4,
+						BusStopID:  "2400A050530A",
+					},
+					models.JourneyStop{
+						LineID:     "PK0000098:84_953_953:1",
+						RouteID:    "RT132",
+						StopNumber: 0,
+						BusStopID:  "2400A050510A",
+					},
+					models.JourneyStop{
+						LineID:     "PK0000098:84_953_953:1",
+						RouteID:    "RT132",
+						StopNumber: 1,
+						BusStopID:  "2400A050500A",
+					},
+					models.JourneyStop{
+						LineID:     "PK0000098:84_953_953:1",
+						RouteID:    "RT132",
+						StopNumber: 2,
+						BusStopID:  "240096711",
+					},
+					models.JourneyStop{
+						LineID:     "PK0000098:84_953_953:1",
+						RouteID:    "RT132",
+						StopNumber: 3,
+						BusStopID:  "2400A049550A",
+					},
+					models.JourneyStop{
+						LineID:     "PK0000098:84_953_953:1",
+						RouteID:    "RT132",
+						StopNumber: 4,
+						BusStopID:  "2400A039640A",
+					},
+				},
+				trips: []models.Trip{
+					models.Trip{
+						LineID: "PK0000098:84_953_953:1",
+						RouteID: "RT131",
+						VehicleJourneyCode: "VJ964",
+						DepartureTime: 29700,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 120, 420, 480, 600},
+					},
+					models.Trip{
+						LineID: "PK0000098:84_953_953:1",
+						RouteID: "RT131",
+						VehicleJourneyCode: "VJ965",
+						DepartureTime: 30000,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 120, 420, 480, 600},
+					},
+					models.Trip{
+						LineID: "PK0000098:84_953_953:1",
+						RouteID: "RT131",
+						VehicleJourneyCode: "VJ966",
+						DepartureTime: 30720,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 120, 420, 480, 600},
+					},
+					models.Trip{
+						LineID: "PK0000098:84_953_953:1",
+						RouteID: "RT132",
+						VehicleJourneyCode: "VJ967",
+						DepartureTime: 54600,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 0, 120, 420, 600},
+					},
+					models.Trip{
+						LineID: "PK0000098:84_953_953:1",
+						RouteID: "RT132",
+						VehicleJourneyCode: "VJ968",
+						DepartureTime: 54600,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 0, 120, 420, 600},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Gets two routes from a 2.5 SCEK-953",
+			args: args{
+				xmlFile: "./testdata/dft-timetable-2.5.xml",
+			},
+			want: parsedTransXChange{
+				schemaVersion: "2.5",
+				revision: 67,
+				createdAt: "2020-11-22T11:00:00",
+				modifiedAt: "2021-03-03T11:06:57",
+				operators: []models.Operator{
+					models.Operator{
+						ID:        "SCEK",
+						Name:      "Stagecoach in East Kent",
+						ShortName: "Stagecoach",
+					},
+				},
+				lines: []models.Line{
+					models.Line{
+						ID:         "SCEK:PK0000098:84_953_953:953:",
+						OperatorID: "SCEK",
+						Name:       "953",
+					},
+				},
+				journeys: []models.Journey{
+					models.Journey{
+						LineID:      "SCEK:PK0000098:84_953_953:953:",
+						RouteID:     "RT131",
+						Direction:   "OUTBOUND",
+						Description: "Bus Station - High School",
+					},
+					models.Journey{
+						LineID:      "SCEK:PK0000098:84_953_953:953:",
+						RouteID:     "RT132",
+						Direction:   "INBOUND",
+						Description: "Bus Station - High School",
+					},
+				},
+				journeyStops: []models.JourneyStop{
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:84_953_953:953:",
+						RouteID:    "RT131",
+						StopNumber: 0,
+						BusStopID:  "240098892",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:84_953_953:953:",
+						RouteID:    "RT131",
+						StopNumber: 1,
+						BusStopID:  "2400A049530A",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:84_953_953:953:",
+						RouteID:    "RT131",
+						StopNumber: 2,
+						BusStopID:  "240096713",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:84_953_953:953:",
+						RouteID:    "RT131",
+						StopNumber: 3,
+						BusStopID:  "2400A050490A",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:84_953_953:953:",
+						RouteID:    "RT131",
+						StopNumber: 4,
+						BusStopID:  "2400A050530A",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:84_953_953:953:",
+						RouteID:    "RT132",
+						StopNumber: 0,
+						BusStopID:  "2400A050510A",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:84_953_953:953:",
+						RouteID:    "RT132",
+						StopNumber: 1,
+						BusStopID:  "2400A050500A",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:84_953_953:953:",
+						RouteID:    "RT132",
+						StopNumber: 2,
+						BusStopID:  "240096711",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:84_953_953:953:",
+						RouteID:    "RT132",
+						StopNumber: 3,
+						BusStopID:  "2400A049550A",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:84_953_953:953:",
+						RouteID:    "RT132",
+						StopNumber: 4,
+						BusStopID:  "2400A039640A",
+					},
+				},
+				trips: []models.Trip{
+					models.Trip{
+						LineID: "SCEK:PK0000098:84_953_953:953:",
+						RouteID: "RT131",
+						VehicleJourneyCode: "VJ964",
+						DepartureTime: 29700,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 120, 420, 480, 600},
+					},
+					models.Trip{
+						LineID: "SCEK:PK0000098:84_953_953:953:",
+						RouteID: "RT131",
+						VehicleJourneyCode: "VJ965",
+						DepartureTime: 30000,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 120, 420, 480, 600},
+					},
+					models.Trip{
+						LineID: "SCEK:PK0000098:84_953_953:953:",
+						RouteID: "RT131",
+						VehicleJourneyCode: "VJ966",
+						DepartureTime: 30720,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 120, 420, 480, 600},
+					},
+					models.Trip{
+						LineID: "SCEK:PK0000098:84_953_953:953:",
+						RouteID: "RT132",
+						VehicleJourneyCode: "VJ967",
+						DepartureTime: 54600,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 0, 120, 420, 600},
+					},
+					models.Trip{
+						LineID: "SCEK:PK0000098:84_953_953:953:",
+						RouteID: "RT132",
+						VehicleJourneyCode: "VJ968",
+						DepartureTime: 54600,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 0, 120, 420, 600},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Joins the route sections of a 2.5 route in order",
+			args: args{
+				xmlFile: "./testdata/dft-timetable-2.5-sections.xml",
+			},
+			want: parsedTransXChange{
+				schemaVersion: "2.5",
+				revision: 68,
+				createdAt: "2020-11-22T11:00:00",
+				modifiedAt: "2021-03-03T11:06:57",
+				operators: []models.Operator{
+					models.Operator{
+						ID:        "SCEK",
+						Name:      "Stagecoach in East Kent",
+						ShortName: "Stagecoach",
+					},
+				},
+				lines: []models.Line{
+					models.Line{
+						ID:         "SCEK:PK0000098:84_953_953:953:",
+						OperatorID: "SCEK",
+						Name:       "953",
+					},
+				},
+				journeys: []models.Journey{
+					models.Journey{
+						LineID:      "SCEK:PK0000098:84_953_953:953:",
+						RouteID:     "RT131",
+						Direction:   "OUTBOUND",
+						Description: "Bus Station - High School",
+					},
+				},
+				journeyStops: []models.JourneyStop{
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:84_953_953:953:",
+						RouteID:    "RT131",
+						StopNumber: 0,
+						BusStopID:  "240098892",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:84_953_953:953:",
+						RouteID:    "RT131",
+						StopNumber: 1,
+						BusStopID:  "2400A049530A",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:84_953_953:953:",
+						RouteID:    "RT131",
+						StopNumber: 2,
+						BusStopID:  "240096713",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:84_953_953:953:",
+						RouteID:    "RT131",
+						StopNumber: 3,
+						BusStopID:  "2400A050490A",
+					},
+					models.JourneyStop{
+						LineID:     "SCEK:PK0000098:84_953_953:953:",
+						RouteID:    "RT131",
+						StopNumber: 4,
+						BusStopID:  "2400A050530A",
+					},
+				},
+				trips: []models.Trip{
+					models.Trip{
+						LineID: "SCEK:PK0000098:84_953_953:953:",
+						RouteID: "RT131",
+						VehicleJourneyCode: "VJ964",
+						DepartureTime: 29700,
+						Days: 31,
+						StartDate: "2021-03-07",
+						EndDate: "",
+						StopOffsets: []uint{0, 120, 420, 480, 600},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Fails to parse an unsupported 2.0 file",
+			args: args{
+				xmlFile: "./testdata/dft-timetable-2.0.xml",
+			},
+			want: parsedTransXChange{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			xml, err := ioutil.ReadFile(tt.args.xmlFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := parseTransXChange(xml)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseTransXChange() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if got.schemaVersion != tt.want.schemaVersion {
+				t.Errorf("parseTransXChange() = SchemaVersion{ %v }, want SchemaVersion{ %v }", got.schemaVersion, tt.want.schemaVersion)
+			}
+
+			if !reflect.DeepEqual(got.operators, tt.want.operators) {
+				t.Errorf("parseTransXChange() = Operators{ %v }, want Operators{ %v }", got.operators, tt.want.operators)
+			}
+
+			if !reflect.DeepEqual(got.lines, tt.want.lines) {
+				t.Errorf("parseTransXChange() = Lines{ %v }, want Lines{ %v }", got.lines, tt.want.lines)
+			}
+
+			if !reflect.DeepEqual(got.journeys, tt.want.journeys) {
+				t.Errorf("parseTransXChange() = Journeys{ %v }, want Journeys{ %v }", got.journeys, tt.want.journeys)
+			}
+
+			if !reflect.DeepEqual(got.journeyStops, tt.want.journeyStops) {
+				t.Errorf("parseTransXChange() = JourneyStops{ %v }, want JourneyStops{ %v }", got.journeyStops, tt.want.journeyStops)
+			}
+
+			if !reflect.DeepEqual(got.trips, tt.want.trips) {
+				t.Errorf("parseTransXChange() = Trips{ %v }, want Trips{ %v }", got.trips, tt.want.trips)
+			}
+
+			if !reflect.DeepEqual(got.importErrors, tt.want.importErrors) {
+				t.Errorf("parseTransXChange() = ImportErrors{ %v }, want ImportErrors{ %v }", got.importErrors, tt.want.importErrors)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseTransXChange() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+func Test_parseDuration(t *testing.T) {
+	type args struct {
+		duration string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    uint
+		wantErr bool
+	}{
+		{
+			name: "Parses minutes \"PT5M\"",
+			args: args{
+				duration: "PT5M",
+			},
+			want: 300,
+			wantErr: false,
+		},
+		{
+			name: "Parses hours, minutes and seconds \"PT1H5M30S\"",
+			args: args{
+				duration: "PT1H5M30S",
+			},
+			want: 3930,
+			wantErr: false,
+		},
+		{
+			name: "Parses an empty duration as 0 seconds",
+			args: args{
+				duration: "",
+			},
+			want: 0,
+			wantErr: false,
+		},
+		{
+			name: "Fails to parse a duration in days \"P1D\"",
+			args: args{
+				duration: "P1D",
+			},
+			want: 0,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseDuration(tt.args.duration)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseDuration() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("parseDuration() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseTimeOfDay(t *testing.T) {
+	type args struct {
+		timeOfDay string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    uint
+		wantErr bool
+	}{
+		{
+			name: "Parses \"08:15:00\"",
+			args: args{
+				timeOfDay: "08:15:00",
+			},
+			want: 29700,
+			wantErr: false,
+		},
+		{
+			name: "Fails to parse \"08:15\"",
+			args: args{
+				timeOfDay: "08:15",
+			},
+			want: 0,
+			wantErr: true,
+		},
+		{
+			name: "Fails to parse \"08:75:00\"",
+			args: args{
+				timeOfDay: "08:75:00",
+			},
+			want: 0,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTimeOfDay(tt.args.timeOfDay)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseTimeOfDay() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("parseTimeOfDay() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
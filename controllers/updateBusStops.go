@@ -3,10 +3,12 @@ package controllers
 import (
 	"server/utils"
 	"server/models"
+	"context"
 	"io"
 	"log"
 	"os"
 	"fmt"
+	"errors"
 	"strings"
 	"net/http"
 	"io/ioutil"
@@ -17,106 +19,127 @@ import (
 
 const naptanURL = "https://naptan.app.dft.gov.uk/Datarequest/naptan.ashx"
 
-// UpdateBusStops updates all bus stops using the NaPTAN database and returns
-// a background job
+// UpdateBusStops queues a job updating all bus stops using the NaPTAN database
 func UpdateBusStops() (models.BackgroundJob, error) {
-	// Set job in db
-	connectionString := os.Getenv("DATABASE_URL")
-
-	db, err := sql.Open("postgres", connectionString)
-	if err != nil {
-		log.Println("Couldn't connect to db", err)
-
-		return models.BackgroundJob{}, err
-	}
-	defer db.Close()
-
-	job, err := models.CreateBackgroundJob("UPDATE NATIONAL PUBLIC TRANSPORT ACCESS NODES", db)
-	if err != nil {
-		return models.BackgroundJob{}, err
-	}
+	return enqueueBackgroundJob(updateBusStopsJobType, nil)
+}
 
-	go runUpdate(job.ID)
+func runUpdateBusStops(ctx context.Context, job models.BackgroundJob, jobDatasets models.JobDataset) (models.BackgroundJobResult, error) {
+	client := contextHTTPClient{ctx: ctx, client: &http.Client{}}
 
-	return job, nil
+	return updateBusStops(ctx, client, jobDatasets)
 }
 
-func runUpdate(jobID uint) {
+// updateBusStops downloads, unzips and parses NaPTAN and writes its active bus
+// stops, recording each phase on the job. Nothing is written if ctx is
+// cancelled
+func updateBusStops(ctx context.Context, client httpClient, jobDatasets models.JobDataset) (models.BackgroundJobResult, error) {
 	connectionString, _ := os.LookupEnv("DATABASE_URL")
 	db, err := sql.Open("postgres", connectionString)
 	if err != nil {
 		log.Println("Couldn't connect to db", err)
-		models.UpdateBackgroundJob(jobID, "FAILED", db)
-		return
+		return nil, fmt.Errorf("Couldn't connect to db: %v", err)
 	}
 	defer db.Close()
 
-	log.Println("Connected to DB")
-
 	// Get NaPTAN from naptanURL
-	zippedFolder, err := getBusStopsFromDFT(&http.Client{})
+	jobDatasets.UpdateProgress(models.BackgroundJobProgress{Phase: "download"})
+	logJob(ctx, "INFO", "Downloading NaPTAN", models.JobLogFields{"URL": naptanURL})
+
+	zippedFolder, err := getBusStopsFromDFT(client)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Failed to get NaPTAN from DFT")
-		models.UpdateBackgroundJob(jobID, "FAILED", db)
-		return
+		logJob(ctx, "ERROR", "Failed to get NaPTAN from DFT", models.JobLogFields{"Error": err.Error()})
+		return nil, fmt.Errorf("Failed to get NaPTAN from DFT: %w", err)
 	}
 
 	// UnZip folder
+	jobDatasets.UpdateProgress(models.BackgroundJobProgress{Phase: "unzip"})
+
 	rawFile, err := utils.UnZipFile(zippedFolder)
 	if err != nil {
-		log.Println("Failed to unzip folder")
-		models.UpdateBackgroundJob(jobID, "FAILED", db)
-		return
+		logJob(ctx, "ERROR", "Failed to unzip folder", models.JobLogFields{"Error": err.Error()})
+		return nil, fmt.Errorf("Failed to unzip NaPTAN: %v", err)
+	}
+
+	if len(rawFile) == 0 {
+		logJob(ctx, "ERROR", "NaPTAN folder is empty", nil)
+		return nil, errors.New("NaPTAN folder is empty")
 	}
 
 	file, err := rawFile[0].Open()
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Failed to unzip folder")
-		models.UpdateBackgroundJob(jobID, "FAILED", db)
-		return
+		logJob(ctx, "ERROR", "Failed to open NaPTAN file", models.JobLogFields{"FileName": rawFile[0].Name, "Error": err.Error()})
+		return nil, fmt.Errorf("Failed to open %v: %v", rawFile[0].Name, err)
 	}
 	defer file.Close()
 
 	// Parse xml
-	busStops, err := parseXML(file)
+	jobDatasets.UpdateProgress(models.BackgroundJobProgress{Phase: "parse"})
+
+	logJob(ctx, "INFO", "Parsing NaPTAN", models.JobLogFields{"FileName": rawFile[0].Name})
+
+	busStops, err := parseXML(ctx, file)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Failed to parse xml")
-		models.UpdateBackgroundJob(jobID, "FAILED", db)
-		return
+		logJob(ctx, "ERROR", "Failed to parse xml", models.JobLogFields{"FileName": rawFile[0].Name, "Error": err.Error()})
+		return nil, fmt.Errorf("Failed to parse NaPTAN: %v", err)
 	}
 
 	// Insert using model
-	if err := models.UpdateBusStops(busStops, db); err != nil {
-		fmt.Fprintln(os.Stderr, "Failed to rebuild bus stops")
-		models.UpdateBackgroundJob(jobID, "FAILED", db)
-		return
+	jobDatasets.UpdateProgress(models.BackgroundJobProgress{Phase: "write", Total: uint(len(busStops))})
+
+	if err := models.UpdateBusStops(ctx, busStops, db); err != nil {
+		logJob(ctx, "ERROR", "Failed to rebuild bus stops", models.JobLogFields{"Error": err.Error()})
+		return nil, fmt.Errorf("Failed to write bus stops: %v", err)
 	}
 
-	// Complete background job
-	models.UpdateBackgroundJob(jobID, "COMPLETE", db)
+	jobDatasets.UpdateProgress(models.BackgroundJobProgress{
+		Phase: "write",
+		Processed: uint(len(busStops)),
+		Total: uint(len(busStops)),
+	})
+
+	return models.BackgroundJobResult{"BusStops": uint(len(busStops))}, nil
 }
 
 type httpClient interface {
 	Get(url string) (*http.Response, error)
 }
 
+// contextHTTPClient makes every request with the context of a background job,
+// so downloads stop when the job is cancelled
+type contextHTTPClient struct {
+	ctx    context.Context
+	client *http.Client
+}
+
+func (client contextHTTPClient) Get(url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(client.ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.client.Do(req)
+}
+
 func getBusStopsFromDFT(client httpClient) ([]byte, error) {
 	resp, err := client.Get(naptanURL)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "Failed to make request to DFT", err)
-		return nil, err
+		return nil, transientError{err: err}
 	}
 
 	if resp.StatusCode != 200 {
-		fmt.Fprintf(os.Stderr, "DFT returned non 200 status of: %d/n", resp.StatusCode)
-		return nil, err
+		resp.Body.Close()
+
+		fmt.Fprintf(os.Stderr, "DFT returned non 200 status of: %d\n", resp.StatusCode)
+		return nil, dftStatusError(resp.StatusCode)
 	}
 
 	defer resp.Body.Close()
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "Failed to read DFT response", err)
-		return nil, err
+		return nil, transientError{err: err}
 	}
 
 	return body, nil
@@ -132,17 +155,23 @@ type stopPoint struct {
 	Bearing    float32  `xml:"StopClassification>OnStreet>Bus>MarkedPoint>Bearing>Degrees"`
 }
 
-func parseXML(xmlFile io.ReadCloser) ([]models.BusStop, error) {
+// parseXML decodes the active stop points of NaPTAN, stopping when ctx is
+// cancelled
+func parseXML(ctx context.Context, xmlFile io.ReadCloser) ([]models.BusStop, error) {
 	decoder := xml.NewDecoder(xmlFile)
 	stopPoints := make([]stopPoint, 0)
 
 	reachedEndOfFile := false
 
 	for !reachedEndOfFile {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
 		token, err := decoder.Token()
 		if err != nil {
 			if err != io.EOF {
-				fmt.Fprintln(os.Stderr, "Failed to decode xml token", err)
+				logJob(ctx, "ERROR", "Failed to decode xml token", models.JobLogFields{"Error": err.Error()})
 				return nil, err
 			}
 			reachedEndOfFile = true
@@ -154,7 +183,7 @@ func parseXML(xmlFile io.ReadCloser) ([]models.BusStop, error) {
 					var stopPoint stopPoint
 
 					if err := decoder.DecodeElement(&stopPoint, &currentElement); err != nil {
-						fmt.Fprintln(os.Stderr, "Failed to decode StopPoint", err)
+						logJob(ctx, "ERROR", "Failed to decode StopPoint", models.JobLogFields{"Error": err.Error()})
 						return nil, err
 					}
 
@@ -166,7 +195,7 @@ func parseXML(xmlFile io.ReadCloser) ([]models.BusStop, error) {
 		}
 	}
 
-	log.Println("Stop Points: ", len(stopPoints))
+	logJob(ctx, "INFO", "Parsed active stop points", models.JobLogFields{"StopPoints": len(stopPoints)})
 
 	busStops := make([]models.BusStop, 0)
 
@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"context"
 	"strings"
 	"io/ioutil"
 	"os"
@@ -14,6 +15,7 @@ import (
 func Test_parseXML(t *testing.T) {
 	type args struct {
 		xmlFilePath string
+		cancelled   bool
 	}
 	tests := []struct {
 		name    string
@@ -44,6 +46,15 @@ func Test_parseXML(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "Stops parsing when the job is cancelled",
+			args: args{
+				xmlFilePath: "testdata/simpleNaPTAN.xml",
+				cancelled: true,
+			},
+			want: nil,
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -53,7 +64,13 @@ func Test_parseXML(t *testing.T) {
 			}
 			defer file.Close()
 
-			got, err := parseXML(file)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.args.cancelled {
+				cancel()
+			}
+
+			got, err := parseXML(ctx, file)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("parseXML() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -0,0 +1,203 @@
+package controllers
+
+import (
+	"server/models"
+	"server/types"
+	"server/transformers"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// gtfsRealtimeVersion is the GTFS Realtime specification version of the feed
+const gtfsRealtimeVersion = "2.0"
+
+// GetVehiclePositions gets a GTFS Realtime feed of the position of every bus
+// within a bounding box. Each bus is matched to the GTFS route and trip of the
+// exported GTFS feed where possible
+func GetVehiclePositions(bounds types.BoundingBox) (types.GTFSRealtimeFeedMessage, error) {
+	siri, err := models.GetBusLocation(
+		types.Coordinate{Longitude: bounds.Min.Longitude, Latitude: bounds.Max.Latitude},
+		types.Coordinate{Longitude: bounds.Max.Longitude, Latitude: bounds.Min.Latitude},
+	)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+
+		return types.GTFSRealtimeFeedMessage{}, err
+	}
+
+	networks := make(map[string]*timetableNetwork)
+	getNetwork := func(serviceDate time.Time) *timetableNetwork {
+		key := serviceDate.Format("2006-01-02")
+		if network, ok := networks[key]; ok {
+			return network
+		}
+
+		network, err := getTimetableNetwork(serviceDate)
+		if err != nil {
+			// positions are still useful without trips
+			log.Println("Failed to load timetable to match vehicle positions", err)
+		}
+
+		networks[key] = network
+
+		return network
+	}
+
+	entities := make([]types.GTFSRealtimeFeedEntity, 0)
+	for _, vehicleActivity := range siri.ServiceDelivery.VehicleMonitoringDelivery.VehicleActivity {
+		entity, err := transformers.VehiclePosition(vehicleActivity)
+		if err != nil {
+			log.Printf("Failed to parse bus last update time, error: %s", err.Error())
+
+			continue
+		}
+
+		journey := vehicleActivity.MonitoredVehicleJourney
+
+		// the service date is the date of the origin departure, or when the
+		// position was recorded without one
+		originDeparture, err := time.Parse(time.RFC3339, journey.OriginAimedDepartureTime)
+		hasOriginDeparture := err == nil
+		if !hasOriginDeparture {
+			originDeparture = time.Unix(int64(entity.Vehicle.Timestamp), 0)
+		}
+
+		originDeparture = originDeparture.In(timetableLocation)
+		year, month, day := originDeparture.Date()
+		serviceDate := time.Date(year, month, day, 0, 0, 0, 0, timetableLocation)
+
+		if network := getNetwork(serviceDate); network != nil {
+			var departure *uint
+			if hasOriginDeparture {
+				hour, minute, second := originDeparture.Clock()
+				seconds := uint(hour * 3600 + minute * 60 + second)
+				departure = &seconds
+			}
+
+			entity.Vehicle.Trip = network.vehicleTrip(journey, departure)
+		}
+
+		entities = append(entities, entity)
+	}
+
+	return types.GTFSRealtimeFeedMessage{
+		Header: types.GTFSRealtimeFeedHeader{
+			GTFSRealtimeVersion: gtfsRealtimeVersion,
+			Incrementality: "FULL_DATASET",
+			Timestamp: uint64(time.Now().Unix()),
+		},
+		Entity: entities,
+	}, nil
+}
+
+// vehicleTrip gets the GTFS trip descriptor of a monitored vehicle journey, or
+// nil when its line can't be matched. The trip is left out when only the line
+// can be matched
+func (network *timetableNetwork) vehicleTrip(journey types.MonitoredVehicleJourney, originDeparture *uint) *types.GTFSRealtimeTripDescriptor {
+	lineID, tripIndex := network.matchVehicleJourney(journey, originDeparture)
+	if lineID == "" {
+		return nil
+	}
+
+	direction := strings.ToUpper(journey.DirectionRef)
+	if tripIndex != -1 {
+		direction = network.trips[tripIndex].Direction
+	}
+
+	descriptor := types.GTFSRealtimeTripDescriptor{RouteID: lineID}
+
+	// direction IDs match the exported trips.txt
+	if direction == "OUTBOUND" || direction == "INBOUND" {
+		directionID := uint32(0)
+		if direction == "INBOUND" {
+			directionID = 1
+		}
+
+		descriptor.DirectionID = &directionID
+	}
+
+	if tripIndex != -1 {
+		trip := network.trips[tripIndex]
+
+		descriptor.TripID = strconv.FormatUint(uint64(trip.ID), 10)
+		descriptor.StartTime = gtfsTime(trip.DepartureTime)
+		descriptor.StartDate = network.serviceDate.Format("20060102")
+	}
+
+	return &descriptor
+}
+
+// matchVehicleJourney matches a monitored vehicle journey to a line by its
+// operator and line name, and to a trip of the line by its vehicle journey
+// code, or by its direction, origin and origin departure time. lineID is empty
+// when there isn't exactly one matching line and tripIndex is -1 when there
+// isn't exactly one matching trip
+func (network *timetableNetwork) matchVehicleJourney(journey types.MonitoredVehicleJourney, originDeparture *uint) (string, int) {
+	network.lineTripsOnce.Do(func() {
+		lineTrips := make(map[string][]int)
+		for tripIndex, trip := range network.trips {
+			key := trip.OperatorID + "\x00" + trip.LineName
+			lineTrips[key] = append(lineTrips[key], tripIndex)
+		}
+
+		network.lineTrips = lineTrips
+	})
+
+	lineName := journey.PublishedLineName
+	if lineName == "" {
+		lineName = journey.LineRef
+	}
+
+	tripIndexes := network.lineTrips[journey.OperatorRef + "\x00" + lineName]
+
+	lineID := ""
+	for _, tripIndex := range tripIndexes {
+		if lineID != "" && network.trips[tripIndex].LineID != lineID {
+			return "", -1
+		}
+
+		lineID = network.trips[tripIndex].LineID
+	}
+
+	if lineID == "" {
+		return "", -1
+	}
+
+	direction := strings.ToUpper(journey.DirectionRef)
+
+	codeMatches := make([]int, 0)
+	departureMatches := make([]int, 0)
+	for _, tripIndex := range tripIndexes {
+		trip := network.trips[tripIndex]
+
+		if (direction == "OUTBOUND" || direction == "INBOUND") && trip.Direction != direction {
+			continue
+		}
+
+		if journey.VehicleJourneyRef != "" && trip.VehicleJourneyCode == journey.VehicleJourneyRef {
+			codeMatches = append(codeMatches, tripIndex)
+		}
+
+		if originDeparture == nil || len(trip.Stops) == 0 || network.stopTime(tripIndex, 0) != *originDeparture {
+			continue
+		}
+
+		if journey.OriginRef == "" || trip.Stops[0].BusStopID == journey.OriginRef {
+			departureMatches = append(departureMatches, tripIndex)
+		}
+	}
+
+	if len(codeMatches) == 1 {
+		return lineID, codeMatches[0]
+	}
+
+	if len(departureMatches) == 1 {
+		return lineID, departureMatches[0]
+	}
+
+	return lineID, -1
+}
@@ -0,0 +1,127 @@
+package controllers
+
+import (
+	"reflect"
+	"server/models"
+	"server/types"
+	"testing"
+	"time"
+)
+
+func Test_vehicleTrip(t *testing.T) {
+	// Uni1: S1 08:00 and 08:30 outbound, S3 08:00 inbound
+	tripA := testTrip(1, "Uni1", 28800, map[string]uint{"S1": 0, "S2": 300}, []string{"S1", "S2"})
+	tripA.VehicleJourneyCode = "VJ1"
+	tripB := testTrip(2, "Uni1", 30600, map[string]uint{"S1": 0, "S2": 300}, []string{"S1", "S2"})
+	tripB.VehicleJourneyCode = "VJ2"
+	tripC := testTrip(3, "Uni1", 28800, map[string]uint{"S3": 0, "S1": 300}, []string{"S3", "S1"})
+	tripC.Direction = "INBOUND"
+	// 2 is run by two lines of the same operator so can't be matched
+	tripD := testTrip(4, "2", 28800, map[string]uint{"S1": 0, "S2": 300}, []string{"S1", "S2"})
+	tripE := testTrip(5, "2", 28800, map[string]uint{"S1": 0, "S2": 300}, []string{"S1", "S2"})
+	tripE.LineID = "2 Variant"
+
+	trips := []models.TimetableTrip{tripA, tripB, tripC, tripD, tripE}
+
+	departure := func(seconds uint) *uint {
+		return &seconds
+	}
+	direction := func(directionID uint32) *uint32 {
+		return &directionID
+	}
+
+	type args struct {
+		journey         types.MonitoredVehicleJourney
+		originDeparture *uint
+	}
+	tests := []struct {
+		name string
+		args args
+		want *types.GTFSRealtimeTripDescriptor
+	}{
+		{
+			name: "Matches a trip by vehicle journey code",
+			args: args{
+				journey: types.MonitoredVehicleJourney{
+					OperatorRef: "SCEK",
+					PublishedLineName: "Uni1",
+					DirectionRef: "outbound",
+					VehicleJourneyRef: "VJ2",
+				},
+			},
+			want: &types.GTFSRealtimeTripDescriptor{
+				TripID: "2",
+				RouteID: "Uni1",
+				DirectionID: direction(0),
+				StartTime: "08:30:00",
+				StartDate: "20210406",
+			},
+		},
+		{
+			name: "Matches a trip by direction, origin and departure time",
+			args: args{
+				journey: types.MonitoredVehicleJourney{
+					OperatorRef: "SCEK",
+					LineRef: "Uni1",
+					DirectionRef: "inbound",
+					OriginRef: "S3",
+					VehicleJourneyRef: "1234",
+				},
+				originDeparture: departure(28800),
+			},
+			want: &types.GTFSRealtimeTripDescriptor{
+				TripID: "3",
+				RouteID: "Uni1",
+				DirectionID: direction(1),
+				StartTime: "08:00:00",
+				StartDate: "20210406",
+			},
+		},
+		{
+			name: "Matches only the line without a matching trip",
+			args: args{
+				journey: types.MonitoredVehicleJourney{
+					OperatorRef: "SCEK",
+					PublishedLineName: "Uni1",
+					DirectionRef: "outbound",
+				},
+				originDeparture: departure(29000),
+			},
+			want: &types.GTFSRealtimeTripDescriptor{
+				RouteID: "Uni1",
+				DirectionID: direction(0),
+			},
+		},
+		{
+			name: "Doesn't match a line name used by two lines",
+			args: args{
+				journey: types.MonitoredVehicleJourney{
+					OperatorRef: "SCEK",
+					PublishedLineName: "2",
+				},
+				originDeparture: departure(28800),
+			},
+			want: nil,
+		},
+		{
+			name: "Doesn't match a line of another operator",
+			args: args{
+				journey: types.MonitoredVehicleJourney{
+					OperatorRef: "NADS",
+					PublishedLineName: "Uni1",
+				},
+			},
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serviceDate := time.Date(2021, 4, 6, 0, 0, 0, 0, timetableLocation)
+			network := newTimetableNetwork(serviceDate, trips, testBusStops(trips))
+
+			if got := network.vehicleTrip(tt.args.journey, tt.args.originDeparture); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("vehicleTrip() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,274 @@
+package controllers
+
+import (
+	"server/models"
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+	"database/sql"
+	_ "github.com/lib/pq"
+)
+
+// webhookMaxAttempts is how many times a webhook is sent a job before giving up
+const webhookMaxAttempts = 5
+
+// webhookRetryDelay is how long is waited before sending a webhook again after
+// its first attempt fails, doubling with each attempt after that
+const webhookRetryDelay = 30 * time.Second
+
+// webhookTimeout is how long a webhook has to respond
+const webhookTimeout = 10 * time.Second
+
+// webhookDeliveryLease is how long a claimed delivery has to be sent before it
+// is claimed again, in case the process sending it stopped
+const webhookDeliveryLease = 2 * time.Minute
+
+// queuedWebhookDeliveries wakes the webhook worker when deliveries are queued
+var queuedWebhookDeliveries = make(chan struct{}, 1)
+
+// webhookDeliveriesLimit is how many of the latest deliveries of a webhook are
+// listed
+const webhookDeliveriesLimit = 100
+
+// webhookPayload is the JSON body sent to a webhook. SentAt is signed with the
+// job so receivers can reject old payloads being replayed
+type webhookPayload struct {
+	Event  string
+	SentAt time.Time
+	Job    models.BackgroundJob
+}
+
+// CreateWebhook registers a webhook sent the jobs of types which finish with
+// one of statuses, generating the secret its payloads are signed with
+func CreateWebhook(url string, types []string, statuses []string) (models.Webhook, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to generate webhook secret", err)
+		return models.Webhook{}, err
+	}
+
+	// lib/pq sends a nil array as NULL
+	if types == nil {
+		types = make([]string, 0)
+	}
+	if statuses == nil {
+		statuses = make([]string, 0)
+	}
+
+	db, err := sql.Open("postgres", os.Getenv("DATABASE_URL"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Couldn't connect to db", err)
+		return models.Webhook{}, err
+	}
+	defer db.Close()
+
+	return models.CreateWebhook(url, types, statuses, hex.EncodeToString(secret), db)
+}
+
+// GetWebhooks gets every webhook without its secret
+func GetWebhooks() ([]models.Webhook, error) {
+	db, err := sql.Open("postgres", os.Getenv("DATABASE_URL"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Couldn't connect to db", err)
+		return nil, err
+	}
+	defer db.Close()
+
+	return models.GetWebhooks(db)
+}
+
+// DeleteWebhook removes a webhook and its delivery log. found is false when
+// there is no webhook with the id
+func DeleteWebhook(id uint) (bool, error) {
+	db, err := sql.Open("postgres", os.Getenv("DATABASE_URL"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Couldn't connect to db", err)
+		return false, err
+	}
+	defer db.Close()
+
+	return models.DeleteWebhook(id, db)
+}
+
+// GetWebhookDeliveries gets the latest deliveries of a webhook, newest first.
+// found is false when there is no webhook with the id
+func GetWebhookDeliveries(id uint) ([]models.WebhookDelivery, bool, error) {
+	db, err := sql.Open("postgres", os.Getenv("DATABASE_URL"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Couldn't connect to db", err)
+		return nil, false, err
+	}
+	defer db.Close()
+
+	if _, err := models.GetWebhook(id, db); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, false, nil
+		}
+
+		return nil, false, err
+	}
+
+	deliveries, err := models.GetWebhookDeliveries(id, webhookDeliveriesLimit, db)
+	if err != nil {
+		return nil, true, err
+	}
+
+	return deliveries, true, nil
+}
+
+// webhookEvent is the event of a job finishing with a status, such as
+// job.complete
+func webhookEvent(status string) string {
+	return "job." + strings.ToLower(status)
+}
+
+// signWebhookPayload is the hex HMAC-SHA256 of a payload with a webhook's secret
+func signWebhookPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// notifyWebhooks queues sending the final state of a job to every webhook of
+// its type and status, which the webhook worker sends in the background
+func notifyWebhooks(jobID uint) {
+	db, err := sql.Open("postgres", os.Getenv("DATABASE_URL"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Couldn't connect to db", err)
+		return
+	}
+	defer db.Close()
+
+	job, err := models.GetBackgroundJob(jobID, db)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to get finished background job", jobID, err)
+		return
+	}
+
+	webhooks, err := models.GetJobWebhooks(job.Type, job.Status, db)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to get webhooks of background job", jobID, err)
+		return
+	}
+
+	for _, webhook := range webhooks {
+		delivery := models.WebhookDelivery{
+			WebhookID: webhook.ID,
+			JobID: job.ID,
+			Event: webhookEvent(job.Status),
+			Attempt: 1,
+		}
+
+		if err := models.QueueWebhookDelivery(delivery, 0, db); err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to queue webhook delivery", webhook.ID, jobID, err)
+		}
+	}
+
+	select {
+		case queuedWebhookDeliveries <- struct{}{}:
+		default:
+	}
+}
+
+// runWebhookWorker sends queued webhook deliveries as they become due, checking
+// for them every jobPollInterval. Deliveries are claimed from the database so
+// ones queued by other processes, or left unsent when a process stopped, are
+// sent too
+func runWebhookWorker() {
+	client := &http.Client{Timeout: webhookTimeout}
+
+	for {
+		if !deliverWebhook(client) {
+			select {
+				case <-queuedWebhookDeliveries:
+				case <-time.After(jobPollInterval):
+			}
+		}
+	}
+}
+
+// deliverWebhook sends the next delivery which is due, without the job's import
+// errors, which can be got from its URI. A delivery which isn't responded to
+// with a 2xx status is queued again up to webhookMaxAttempts times, waiting
+// longer after each attempt. Returns false when no delivery is due
+func deliverWebhook(client *http.Client) bool {
+	db, err := sql.Open("postgres", os.Getenv("DATABASE_URL"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Couldn't connect to db", err)
+		return false
+	}
+	defer db.Close()
+
+	delivery, webhook, err := models.ClaimWebhookDelivery(webhookDeliveryLease, db)
+	if err != nil {
+		return false
+	}
+
+	var statusCode int
+	job, err := models.GetBackgroundJob(delivery.JobID, db)
+	if err == nil {
+		job.Errors = nil
+
+		var body []byte
+		body, err = json.Marshal(webhookPayload{Event: delivery.Event, SentAt: time.Now().UTC(), Job: job})
+		if err == nil {
+			statusCode, err = sendWebhook(client, webhook, delivery.Event, body)
+		}
+	}
+
+	delivery.StatusCode = statusCode
+	delivery.Delivered = err == nil
+	if err != nil {
+		delivery.Error = err.Error()
+
+		log.Println("Failed to send webhook", webhook.ID, "job", delivery.JobID, "attempt", delivery.Attempt, err)
+	}
+
+	retry := delivery.Attempt < webhookMaxAttempts
+	if err := models.FinishWebhookDelivery(delivery, retry, retryDelay(webhookRetryDelay, delivery.Attempt), db); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to update webhook delivery", delivery.ID, err)
+	}
+
+	return true
+}
+
+// sendWebhook posts a signed payload to a webhook. An error is returned when
+// the webhook couldn't be reached or didn't respond with a 2xx status
+func sendWebhook(client *http.Client, webhook models.Webhook, event string, body []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("User-Agent", "bus-api-server-webhook")
+	req.Header.Set("X-Webhook-Event", event)
+	req.Header.Set("X-Webhook-Signature", "sha256=" + signWebhookPayload(webhook.Secret, body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// read a little of the body so the connection can be reused
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("Webhook responded with status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
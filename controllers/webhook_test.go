@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"server/models"
+	"testing"
+)
+
+func Test_signWebhookPayload(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret string
+		body   string
+		want   string
+	}{
+		{
+			name: "Signs a payload with HMAC-SHA256",
+			secret: "key",
+			body: "The quick brown fox jumps over the lazy dog",
+			want: "f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8",
+		},
+		{
+			name: "Signs an empty payload",
+			secret: "",
+			body: "",
+			want: "b613679a0814d9ec772f95d778c35fc5ff1697c493715653c6c712144292c5ad",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := signWebhookPayload(tt.secret, []byte(tt.body)); got != tt.want {
+				t.Errorf("signWebhookPayload() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_sendWebhook(t *testing.T) {
+	tests := []struct {
+		name           string
+		responseStatus int
+		wantStatusCode int
+		wantErr        bool
+	}{
+		{
+			name: "Delivers a payload",
+			responseStatus: http.StatusOK,
+			wantStatusCode: http.StatusOK,
+			wantErr: false,
+		},
+		{
+			name: "Delivers a payload accepted without content",
+			responseStatus: http.StatusNoContent,
+			wantStatusCode: http.StatusNoContent,
+			wantErr: false,
+		},
+		{
+			name: "Fails when the webhook responds with an error",
+			responseStatus: http.StatusInternalServerError,
+			wantStatusCode: http.StatusInternalServerError,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := []byte(`{"Event":"job.complete"}`)
+			wantSignature := "sha256=" + signWebhookPayload("secret", body)
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if signature := r.Header.Get("X-Webhook-Signature"); signature != wantSignature {
+					t.Errorf("X-Webhook-Signature = %v, want %v", signature, wantSignature)
+				}
+
+				if event := r.Header.Get("X-Webhook-Event"); event != "job.complete" {
+					t.Errorf("X-Webhook-Event = %v, want job.complete", event)
+				}
+
+				received, _ := ioutil.ReadAll(r.Body)
+				if string(received) != string(body) {
+					t.Errorf("body = %s, want %s", received, body)
+				}
+
+				w.WriteHeader(tt.responseStatus)
+			}))
+			defer server.Close()
+
+			webhook := models.Webhook{ID: 1, URL: server.URL, Secret: "secret"}
+
+			statusCode, err := sendWebhook(server.Client(), webhook, "job.complete", body)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("sendWebhook() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if statusCode != tt.wantStatusCode {
+				t.Errorf("sendWebhook() = %v, want %v", statusCode, tt.wantStatusCode)
+			}
+		})
+	}
+}
@@ -18,14 +18,46 @@ psql -v ON_ERROR_STOP=1 --username "$POSTGRES_USER" --dbname "$POSTGRES_DB" <<-E
 			bearing DOUBLE PRECISION NOT NULL
 		);
 
-		CREATE TYPE job_type AS ENUM ('UPDATE NATIONAL PUBLIC TRANSPORT ACCESS NODES', 'UPDATE ROUTES BY DATASET ID');
-		CREATE TYPE status AS ENUM ('RUNNING', 'COMPLETE', 'FAILED');
+		CREATE TYPE status AS ENUM ('QUEUED', 'RUNNING', 'COMPLETE', 'FAILED', 'CANCELLED');
 		CREATE TABLE IF NOT EXISTS background_job (
 			id SERIAL NOT NULL PRIMARY KEY,
-			type job_type NOT NULL,
-			status status NOT NULL DEFAULT 'RUNNING',
+			type VARCHAR(64) NOT NULL,
+			status status NOT NULL DEFAULT 'QUEUED',
+			parameters JSONB,
+			unique_key VARCHAR(128),
+			attempts INTEGER NOT NULL DEFAULT 0,
+			max_attempts INTEGER NOT NULL DEFAULT 1,
+			run_at TIMESTAMP NOT NULL DEFAULT NOW(),
+			phase VARCHAR(32) NOT NULL DEFAULT '',
+			processed INTEGER NOT NULL DEFAULT 0,
+			total INTEGER NOT NULL DEFAULT 0,
+			error TEXT NOT NULL DEFAULT '',
+			result JSONB,
 			created_at TIMESTAMP NOT NULL DEFAULT NOW(),
-			updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+			updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+			heartbeat_at TIMESTAMP NOT NULL DEFAULT NOW(),
+			cancel_requested BOOLEAN NOT NULL DEFAULT FALSE
+		);
+		CREATE INDEX IF NOT EXISTS background_job_queue ON background_job(run_at, id) WHERE status = 'QUEUED';
+		CREATE UNIQUE INDEX IF NOT EXISTS background_job_active_key ON background_job(type, unique_key) WHERE status IN ('QUEUED', 'RUNNING');
+
+		CREATE TYPE dataset_status AS ENUM ('PENDING', 'RUNNING', 'COMPLETE', 'FAILED', 'SKIPPED', 'CANCELLED');
+		CREATE TABLE IF NOT EXISTS background_job_dataset (
+			job_id INTEGER NOT NULL,
+			dataset_id INTEGER NOT NULL,
+			status dataset_status NOT NULL DEFAULT 'PENDING',
+			updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+			CONSTRAINT background_job_dataset_id PRIMARY KEY (job_id, dataset_id),
+			FOREIGN KEY (job_id) REFERENCES background_job(id)
+		);
+
+		CREATE TABLE IF NOT EXISTS dataset (
+			id INTEGER NOT NULL PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			operator_name VARCHAR(255) NOT NULL,
+			modified TIMESTAMPTZ NOT NULL,
+			revision INTEGER NOT NULL,
+			imported_at TIMESTAMP NOT NULL DEFAULT NOW()
 		);
 
 		CREATE TABLE IF NOT EXISTS operator (
@@ -38,7 +70,10 @@ psql -v ON_ERROR_STOP=1 --username "$POSTGRES_USER" --dbname "$POSTGRES_DB" <<-E
 			id VARCHAR(255) NOT NULL PRIMARY KEY,
 			name VARCHAR(255) NOT NULL,
 			operator_id VARCHAR(255) NOT NULL,
-			FOREIGN KEY (operator_id) REFERENCES operator(id)
+			dataset_id INTEGER NOT NULL,
+			file_name VARCHAR(255) NOT NULL,
+			FOREIGN KEY (operator_id) REFERENCES operator(id),
+			FOREIGN KEY (dataset_id) REFERENCES dataset(id)
 		);
 
 		CREATE TYPE direction_type AS ENUM ('OUTBOUND', 'INBOUND');
@@ -47,8 +82,11 @@ psql -v ON_ERROR_STOP=1 --username "$POSTGRES_USER" --dbname "$POSTGRES_DB" <<-E
 			route_id VARCHAR(255) NOT NULL,
 			direction direction_type NOT NULL,
 			description VARCHAR(255) NOT NULL,
+			dataset_id INTEGER NOT NULL,
+			file_name VARCHAR(255) NOT NULL,
 			CONSTRAINT journey_id PRIMARY KEY (line_id, route_id),
-			FOREIGN KEY (line_id) REFERENCES line(id)
+			FOREIGN KEY (line_id) REFERENCES line(id),
+			FOREIGN KEY (dataset_id) REFERENCES dataset(id)
 		);
 
 		CREATE TABLE IF NOT EXISTS journey_stop (
@@ -56,10 +94,102 @@ psql -v ON_ERROR_STOP=1 --username "$POSTGRES_USER" --dbname "$POSTGRES_DB" <<-E
 			route_id VARCHAR(255) NOT NULL,
 			stop_number smallint NOT NULL,
 			bus_stop_id CHAR(12) NOT NULL,
+			dataset_id INTEGER NOT NULL,
 			CONSTRAINT journey_stop_id PRIMARY KEY (line_id, route_id, stop_number),
 			FOREIGN KEY (line_id, route_id) REFERENCES journey(line_id, route_id),
-			FOREIGN KEY (bus_stop_id) REFERENCES bus_stop(id)
+			FOREIGN KEY (bus_stop_id) REFERENCES bus_stop(id),
+			FOREIGN KEY (dataset_id) REFERENCES dataset(id)
+		);
+
+		CREATE TABLE IF NOT EXISTS trip (
+			id SERIAL NOT NULL PRIMARY KEY,
+			line_id VARCHAR(255) NOT NULL,
+			route_id VARCHAR(255) NOT NULL,
+			vehicle_journey_code VARCHAR(255) NOT NULL,
+			departure_time INTEGER NOT NULL,
+			days SMALLINT NOT NULL,
+			start_date DATE NOT NULL,
+			end_date DATE,
+			stop_offsets INTEGER[] NOT NULL,
+			dataset_id INTEGER NOT NULL,
+			file_name VARCHAR(255) NOT NULL,
+			FOREIGN KEY (line_id, route_id) REFERENCES journey(line_id, route_id),
+			FOREIGN KEY (dataset_id) REFERENCES dataset(id)
+		);
+
+		CREATE INDEX IF NOT EXISTS line_dataset_id ON line(dataset_id);
+		CREATE INDEX IF NOT EXISTS journey_dataset_id ON journey(dataset_id);
+		CREATE INDEX IF NOT EXISTS journey_stop_dataset_id ON journey_stop(dataset_id);
+		CREATE INDEX IF NOT EXISTS trip_dataset_id ON trip(dataset_id);
+		CREATE INDEX IF NOT EXISTS bus_stop_location ON bus_stop(longitude, latitude);
+		CREATE INDEX IF NOT EXISTS journey_stop_bus_stop_id ON journey_stop(bus_stop_id);
+
+		CREATE TABLE IF NOT EXISTS timetable_file (
+			dataset_id INTEGER NOT NULL,
+			file_name VARCHAR(255) NOT NULL,
+			schema_version VARCHAR(8) NOT NULL,
+			revision INTEGER NOT NULL,
+			created_at TIMESTAMP,
+			modified_at TIMESTAMP,
+			imported_at TIMESTAMP NOT NULL DEFAULT NOW(),
+			CONSTRAINT timetable_file_id PRIMARY KEY (dataset_id, file_name),
+			FOREIGN KEY (dataset_id) REFERENCES dataset(id)
+		);
+
+		CREATE TABLE IF NOT EXISTS timetable_import_error (
+			id SERIAL NOT NULL PRIMARY KEY,
+			job_id INTEGER NOT NULL,
+			dataset_id INTEGER NOT NULL,
+			file_name VARCHAR(255) NOT NULL,
+			element_id VARCHAR(255) NOT NULL,
+			reason VARCHAR(255) NOT NULL,
+			FOREIGN KEY (job_id) REFERENCES background_job(id)
+		);
+
+		CREATE TABLE IF NOT EXISTS gtfs_export (
+			job_id INTEGER NOT NULL PRIMARY KEY,
+			feed BYTEA NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+			FOREIGN KEY (job_id) REFERENCES background_job(id)
 		);
+
+		CREATE TYPE log_level AS ENUM ('DEBUG', 'INFO', 'WARN', 'ERROR');
+		CREATE TABLE IF NOT EXISTS job_log (
+			id SERIAL NOT NULL PRIMARY KEY,
+			job_id INTEGER NOT NULL,
+			level log_level NOT NULL,
+			message TEXT NOT NULL,
+			fields JSONB,
+			created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+			FOREIGN KEY (job_id) REFERENCES background_job(id)
+		);
+		CREATE INDEX IF NOT EXISTS job_log_job_id ON job_log(job_id, id);
+
+		CREATE TABLE IF NOT EXISTS webhook (
+			id SERIAL NOT NULL PRIMARY KEY,
+			url TEXT NOT NULL,
+			job_types VARCHAR(64)[] NOT NULL DEFAULT '{}',
+			statuses status[] NOT NULL DEFAULT '{}',
+			secret VARCHAR(64) NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT NOW()
+		);
+
+		CREATE TABLE IF NOT EXISTS webhook_delivery (
+			id SERIAL NOT NULL PRIMARY KEY,
+			webhook_id INTEGER NOT NULL,
+			job_id INTEGER NOT NULL,
+			event VARCHAR(32) NOT NULL,
+			attempt INTEGER NOT NULL,
+			status_code INTEGER NOT NULL DEFAULT 0,
+			error TEXT NOT NULL DEFAULT '',
+			delivered BOOLEAN NOT NULL,
+			next_attempt_at TIMESTAMP,
+			created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+			FOREIGN KEY (webhook_id) REFERENCES webhook(id) ON DELETE CASCADE,
+			FOREIGN KEY (job_id) REFERENCES background_job(id)
+		);
+		CREATE INDEX IF NOT EXISTS webhook_delivery_webhook_id ON webhook_delivery(webhook_id, id);
+		CREATE INDEX IF NOT EXISTS webhook_delivery_due ON webhook_delivery(next_attempt_at, id) WHERE next_attempt_at IS NOT NULL;
   COMMIT;
 
 	GRANT SELECT ON TABLE bus_stop TO $APP_DB_USER;
@@ -70,15 +200,63 @@ psql -v ON_ERROR_STOP=1 --username "$POSTGRES_USER" --dbname "$POSTGRES_DB" <<-E
 	GRANT INSERT ON TABLE background_job TO $APP_DB_USER;
 	GRANT UPDATE ON TABLE background_job TO $APP_DB_USER;
 
+	GRANT SELECT ON TABLE background_job_dataset TO $APP_DB_USER;
+	GRANT INSERT ON TABLE background_job_dataset TO $APP_DB_USER;
+	GRANT UPDATE ON TABLE background_job_dataset TO $APP_DB_USER;
+
+	GRANT SELECT ON TABLE dataset TO $APP_DB_USER;
+	GRANT INSERT ON TABLE dataset TO $APP_DB_USER;
+	GRANT UPDATE ON TABLE dataset TO $APP_DB_USER;
+
 	GRANT SELECT ON TABLE operator TO $APP_DB_USER;
 	GRANT INSERT ON TABLE operator TO $APP_DB_USER;
+	GRANT UPDATE ON TABLE operator TO $APP_DB_USER;
 
 	GRANT SELECT ON TABLE line TO $APP_DB_USER;
 	GRANT INSERT ON TABLE line TO $APP_DB_USER;
+	GRANT UPDATE ON TABLE line TO $APP_DB_USER;
+	GRANT DELETE ON TABLE line TO $APP_DB_USER;
 
 	GRANT SELECT ON TABLE journey TO $APP_DB_USER;
 	GRANT INSERT ON TABLE journey TO $APP_DB_USER;
+	GRANT UPDATE ON TABLE journey TO $APP_DB_USER;
+	GRANT DELETE ON TABLE journey TO $APP_DB_USER;
 
 	GRANT SELECT ON TABLE journey_stop TO $APP_DB_USER;
 	GRANT INSERT ON TABLE journey_stop TO $APP_DB_USER;
+	GRANT UPDATE ON TABLE journey_stop TO $APP_DB_USER;
+	GRANT DELETE ON TABLE journey_stop TO $APP_DB_USER;
+
+	GRANT SELECT ON TABLE trip TO $APP_DB_USER;
+	GRANT INSERT ON TABLE trip TO $APP_DB_USER;
+	GRANT DELETE ON TABLE trip TO $APP_DB_USER;
+	GRANT USAGE ON SEQUENCE trip_id_seq TO $APP_DB_USER;
+
+	GRANT SELECT ON TABLE timetable_file TO $APP_DB_USER;
+	GRANT INSERT ON TABLE timetable_file TO $APP_DB_USER;
+	GRANT DELETE ON TABLE timetable_file TO $APP_DB_USER;
+
+	GRANT SELECT ON TABLE timetable_import_error TO $APP_DB_USER;
+	GRANT INSERT ON TABLE timetable_import_error TO $APP_DB_USER;
+	GRANT DELETE ON TABLE timetable_import_error TO $APP_DB_USER;
+	GRANT USAGE ON SEQUENCE timetable_import_error_id_seq TO $APP_DB_USER;
+
+	GRANT SELECT ON TABLE gtfs_export TO $APP_DB_USER;
+	GRANT INSERT ON TABLE gtfs_export TO $APP_DB_USER;
+	GRANT DELETE ON TABLE gtfs_export TO $APP_DB_USER;
+
+	GRANT SELECT ON TABLE job_log TO $APP_DB_USER;
+	GRANT INSERT ON TABLE job_log TO $APP_DB_USER;
+	GRANT USAGE ON SEQUENCE job_log_id_seq TO $APP_DB_USER;
+
+	GRANT SELECT ON TABLE webhook TO $APP_DB_USER;
+	GRANT INSERT ON TABLE webhook TO $APP_DB_USER;
+	GRANT DELETE ON TABLE webhook TO $APP_DB_USER;
+	GRANT USAGE ON SEQUENCE webhook_id_seq TO $APP_DB_USER;
+
+	GRANT SELECT ON TABLE webhook_delivery TO $APP_DB_USER;
+	GRANT INSERT ON TABLE webhook_delivery TO $APP_DB_USER;
+	GRANT UPDATE ON TABLE webhook_delivery TO $APP_DB_USER;
+	GRANT DELETE ON TABLE webhook_delivery TO $APP_DB_USER;
+	GRANT USAGE ON SEQUENCE webhook_delivery_id_seq TO $APP_DB_USER;
 EOSQL
\ No newline at end of file
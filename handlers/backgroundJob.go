@@ -3,6 +3,8 @@ package handlers
 import (
 	"server/controllers"
 	"server/utils"
+	"encoding/json"
+	"errors"
 	"strconv"
 	"fmt"
 	"server/models"
@@ -10,17 +12,18 @@ import (
 	"net/http"
 	"log"
 	"os"
+	"time"
 )
 
 type backgroundJob struct {}
 
-// BackgroundJob takes all bus background job requests (GET, OPTIONS). It is
-// protected by an admin auth token
+// BackgroundJob takes all bus background job requests (GET, POST, DELETE,
+// OPTIONS). It is protected by an admin auth token
 func BackgroundJob(w http.ResponseWriter, r *http.Request) {
 	authorizationHeader := r.Header.Get("Authorization")
 	adminToken := os.Getenv("ADMIN_TOKEN")
 	if authorizationHeader != "Bearer " + adminToken {
-		log.Println("Unauthorized request to GET /api/job")
+		log.Println("Unauthorized request to", r.Method, "/api/job")
 
 		w.WriteHeader(http.StatusUnauthorized)
 		fmt.Fprint(w, http.StatusText(http.StatusUnauthorized))
@@ -31,6 +34,8 @@ func BackgroundJob(w http.ResponseWriter, r *http.Request) {
 	backgroundJob := backgroundJob{}
 	acceptedMethods := []string{
 		http.MethodGet,
+		http.MethodPost,
+		http.MethodDelete,
 		http.MethodOptions,
 	}
 
@@ -53,6 +58,8 @@ func BackgroundJob(w http.ResponseWriter, r *http.Request) {
 
 	switch method := r.Method; method {
 		case http.MethodGet: backgroundJob.get(w, r)
+		case http.MethodPost: backgroundJob.post(w, r)
+		case http.MethodDelete: backgroundJob.delete(w, r)
 		default:
 			w.Header().Set("Allow", strings.Join(acceptedMethods, ", "))
 			w.WriteHeader(http.StatusMethodNotAllowed)
@@ -65,9 +72,71 @@ type getBackgroundJobBody struct {
 	Job  models.BackgroundJob
 }
 
-// get is a GET route for getting a background job by an ID
-func (*backgroundJob) get(w http.ResponseWriter, r *http.Request) {
+type getBackgroundJobsBody struct {
+	Jobs []models.BackgroundJob
+	Next string `json:",omitempty"`
+}
+
+// defaultJobsLimit is how many jobs are listed when no limit is given
+const defaultJobsLimit = 25
+
+// maximumJobsLimit is the most jobs listed in one page
+const maximumJobsLimit = 100
+
+type getBackgroundJobTypesBody struct {
+	Types []models.BackgroundJobTypeSchema
+}
+
+type postBackgroundJobRequest struct {
+	Type       string
+	Parameters json.RawMessage
+}
+
+// maximumJobBodySize is the largest job accepted in bytes
+const maximumJobBodySize = 64 * 1024
+
+type getBackgroundJobLogsBody struct {
+	Logs []models.JobLogEntry
+	Next string `json:",omitempty"`
+}
+
+// defaultJobLogsLimit is how many log entries are got when no limit is given
+const defaultJobLogsLimit = 1000
+
+// maximumJobLogsLimit is the most log entries got in one page
+const maximumJobLogsLimit = 10000
+
+// jobLogsFollowInterval is how often the log of a job is checked for new
+// entries while it is followed
+var jobLogsFollowInterval = time.Second
+
+// get is a GET route for getting a background job by an ID, its log when the
+// path is /api/job/:jobID/logs, the types of job when the path is
+// /api/job/types, or listing jobs when no ID is given
+func (backgroundJob *backgroundJob) get(w http.ResponseWriter, r *http.Request) {
 	urlPath := strings.Split(r.URL.EscapedPath(), "/")
+	if len(urlPath) < 4 || urlPath[3] == "" {
+		backgroundJob.list(w, r)
+
+		return
+	}
+
+	if len(urlPath) == 4 && urlPath[3] == "types" {
+		response := getBackgroundJobTypesBody{Types: controllers.GetBackgroundJobTypes()}
+		compress := strings.Contains(r.Header.Get("Accept-Encoding"), "gzip")
+
+		utils.SendJSONResponse(w, http.StatusOK, compress, response)
+
+		return
+	}
+
+	if len(urlPath) > 5 || (len(urlPath) == 5 && urlPath[4] != "logs") {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, http.StatusText(http.StatusNotFound))
+
+		return
+	}
+
 	jobID, err := strconv.ParseUint(urlPath[3], 10, 32)
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
@@ -76,6 +145,12 @@ func (*backgroundJob) get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if len(urlPath) == 5 {
+		backgroundJob.logs(w, r, uint(jobID))
+
+		return
+	}
+
 	job, found, err := controllers.GetBackgroundJobStatus(uint(jobID))
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -98,4 +173,360 @@ func (*backgroundJob) get(w http.ResponseWriter, r *http.Request) {
 	compress := strings.Contains(r.Header.Get("Accept-Encoding"), "gzip")
 
 	utils.SendJSONResponse(w, http.StatusOK, compress, response)
-}
\ No newline at end of file
+}
+
+// logs is a GET route for getting the log of a background job oldest first,
+// optionally only the entries at or above a level, after an entry or the last
+// tail entries. When follow is true the entries are streamed as newline
+// delimited JSON until the job finishes
+func (*backgroundJob) logs(w http.ResponseWriter, r *http.Request, jobID uint) {
+	query := r.URL.Query()
+
+	level := "DEBUG"
+	if levelParam := query.Get("level"); levelParam != "" {
+		if !containsString(models.JobLogLevels, levelParam) {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, "Level must be one of " + strings.Join(models.JobLogLevels, ", "))
+
+			return
+		}
+
+		level = levelParam
+	}
+
+	afterID := uint64(0)
+	if afterParam := query.Get("after"); afterParam != "" {
+		parsedAfter, err := strconv.ParseUint(afterParam, 10, 32)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, "After must be a positive integer")
+
+			return
+		}
+
+		afterID = parsedAfter
+	}
+
+	limit := uint64(defaultJobLogsLimit)
+	tail := false
+	if limitParam := query.Get("limit"); limitParam != "" {
+		parsedLimit, err := strconv.ParseUint(limitParam, 10, 32)
+		if err != nil || parsedLimit == 0 || parsedLimit > maximumJobLogsLimit {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "Limit must be an integer between 1 and %d", maximumJobLogsLimit)
+
+			return
+		}
+
+		limit = parsedLimit
+	}
+
+	if tailParam := query.Get("tail"); tailParam != "" {
+		parsedTail, err := strconv.ParseUint(tailParam, 10, 32)
+		if err != nil || parsedTail == 0 || parsedTail > maximumJobLogsLimit {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "Tail must be an integer between 1 and %d", maximumJobLogsLimit)
+
+			return
+		}
+
+		limit = parsedTail
+		tail = true
+	}
+
+	follow := false
+	if followParam := query.Get("follow"); followParam != "" {
+		parsedFollow, err := strconv.ParseBool(followParam)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, "Follow must be true or false")
+
+			return
+		}
+
+		follow = parsedFollow
+	}
+
+	logs, finished, found, err := controllers.GetBackgroundJobLogs(jobID, level, uint(afterID), uint(limit), tail)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+
+		fmt.Fprint(w, http.StatusText(http.StatusInternalServerError))
+
+		return
+	}
+
+	if !found {
+		w.WriteHeader(http.StatusNotFound)
+
+		fmt.Fprint(w, "No job found")
+
+		return
+	}
+
+	if follow {
+		followJobLogs(w, r, jobID, level, uint(afterID), uint(limit), logs, finished)
+
+		return
+	}
+
+	// Request ok
+	response := getBackgroundJobLogsBody{Logs: logs}
+	if !tail && uint64(len(logs)) == limit {
+		query.Set("after", strconv.FormatUint(uint64(logs[len(logs) - 1].ID), 10))
+		response.Next = fmt.Sprintf("/api/job/%d/logs?%s", jobID, query.Encode())
+	}
+
+	compress := strings.Contains(r.Header.Get("Accept-Encoding"), "gzip")
+
+	utils.SendJSONResponse(w, http.StatusOK, compress, response)
+}
+
+// followJobLogs streams the log entries of a job as newline delimited JSON,
+// starting with logs got after the entry with the id of afterID and then checking for new entries every
+// jobLogsFollowInterval, until the job has finished and every entry has been
+// sent or the client disconnects
+func followJobLogs(w http.ResponseWriter, r *http.Request, jobID uint, level string, afterID uint, limit uint, logs []models.JobLogEntry, finished bool) {
+	flusher, canFlush := w.(http.Flusher)
+
+	w.Header().Set("Content-Type", "application/x-ndjson; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	encoder := json.NewEncoder(w)
+
+	for {
+		for _, entry := range logs {
+			if err := encoder.Encode(entry); err != nil {
+				return
+			}
+
+			afterID = entry.ID
+		}
+
+		if canFlush {
+			flusher.Flush()
+		}
+
+		// a full page may have more entries waiting, so isn't waited for
+		if uint(len(logs)) < limit {
+			if finished {
+				return
+			}
+
+			select {
+				case <-r.Context().Done():
+					return
+				case <-time.After(jobLogsFollowInterval):
+			}
+		}
+
+		var err error
+		var found bool
+		logs, finished, found, err = controllers.GetBackgroundJobLogs(jobID, level, afterID, limit, false)
+		if err != nil || !found {
+			return
+		}
+	}
+}
+
+// post is a POST route for queuing a job of any type with its JSON parameters.
+// The job is returned with 202 Accepted, or the job already queued or running
+// when its type's concurrency doesn't allow another like it
+func (*backgroundJob) post(w http.ResponseWriter, r *http.Request) {
+	urlPath := strings.Split(r.URL.EscapedPath(), "/")
+	if len(urlPath) > 3 && urlPath[3] != "" {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, http.StatusText(http.StatusNotFound))
+
+		return
+	}
+
+	var request postBackgroundJobRequest
+
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maximumJobBodySize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, "Body must be a JSON job")
+
+		return
+	}
+
+	job, err := controllers.QueueBackgroundJob(request.Type, request.Parameters)
+	var parametersError controllers.JobParametersError
+	if err == controllers.ErrUnknownJobType {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, "Type must be one of " + strings.Join(controllers.BackgroundJobTypeNames(), ", "))
+
+		return
+	}
+	if errors.As(err, &parametersError) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, parametersError.Error())
+
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+
+		fmt.Fprint(w, http.StatusText(http.StatusInternalServerError))
+
+		return
+	}
+
+	// Request accepted
+	w.Header().Set("Location", job.URI)
+
+	response := getBackgroundJobBody{Job: job}
+	compress := strings.Contains(r.Header.Get("Accept-Encoding"), "gzip")
+
+	utils.SendJSONResponse(w, http.StatusAccepted, compress, response)
+}
+
+// delete is a DELETE route for cancelling a running background job. The job is
+// returned once it has stopped, or with 202 Accepted if it is still stopping
+func (*backgroundJob) delete(w http.ResponseWriter, r *http.Request) {
+	urlPath := strings.Split(r.URL.EscapedPath(), "/")
+	if len(urlPath) < 4 {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, "Job ID must be a positive integer")
+
+		return
+	}
+
+	jobID, err := strconv.ParseUint(urlPath[3], 10, 32)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, "Job ID must be a positive integer")
+
+		return
+	}
+
+	job, found, err := controllers.CancelBackgroundJob(uint(jobID))
+	if err == controllers.ErrJobNotRunning {
+		w.WriteHeader(http.StatusConflict)
+
+		fmt.Fprint(w, "Job is not running")
+
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+
+		fmt.Fprint(w, http.StatusText(http.StatusInternalServerError))
+
+		return
+	}
+
+	if !found {
+		w.WriteHeader(http.StatusNotFound)
+
+		fmt.Fprint(w, "No job found")
+
+		return
+	}
+
+	status := http.StatusOK
+	if job.Status == "RUNNING" {
+		status = http.StatusAccepted
+	}
+
+	response := getBackgroundJobBody{Job: job}
+	compress := strings.Contains(r.Header.Get("Accept-Encoding"), "gzip")
+
+	utils.SendJSONResponse(w, status, compress, response)
+}
+
+// list is a GET route for listing background jobs newest first, optionally
+// filtered by type, status and the time they were created since
+func (*backgroundJob) list(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := models.BackgroundJobFilter{
+		Type: query.Get("type"),
+		Status: query.Get("status"),
+	}
+
+	if filter.Type != "" && !containsString(controllers.BackgroundJobTypeNames(), filter.Type) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, "Type must be one of " + strings.Join(controllers.BackgroundJobTypeNames(), ", "))
+
+		return
+	}
+
+	if filter.Status != "" && !containsString(models.BackgroundJobStatuses, filter.Status) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, "Status must be one of " + strings.Join(models.BackgroundJobStatuses, ", "))
+
+		return
+	}
+
+	if since := query.Get("since"); since != "" {
+		sinceTime, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, "Since must be an RFC 3339 time")
+
+			return
+		}
+
+		filter.Since = sinceTime
+	}
+
+	limit := uint64(defaultJobsLimit)
+	if limitParam := query.Get("limit"); limitParam != "" {
+		parsedLimit, err := strconv.ParseUint(limitParam, 10, 32)
+		if err != nil || parsedLimit == 0 || parsedLimit > maximumJobsLimit {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "Limit must be an integer between 1 and %d", maximumJobsLimit)
+
+			return
+		}
+
+		limit = parsedLimit
+	}
+
+	offset := uint64(0)
+	if offsetParam := query.Get("offset"); offsetParam != "" {
+		parsedOffset, err := strconv.ParseUint(offsetParam, 10, 32)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, "Offset must be a positive integer")
+
+			return
+		}
+
+		offset = parsedOffset
+	}
+
+	jobs, hasMore, err := controllers.GetBackgroundJobs(filter, uint(limit), uint(offset))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+
+		fmt.Fprint(w, http.StatusText(http.StatusInternalServerError))
+
+		return
+	}
+
+	// Request ok
+	response := getBackgroundJobsBody{Jobs: jobs}
+	if hasMore {
+		query.Set("limit", strconv.FormatUint(limit, 10))
+		query.Set("offset", strconv.FormatUint(offset + limit, 10))
+		response.Next = "/api/job?" + query.Encode()
+	}
+
+	compress := strings.Contains(r.Header.Get("Accept-Encoding"), "gzip")
+
+	utils.SendJSONResponse(w, http.StatusOK, compress, response)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
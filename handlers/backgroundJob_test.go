@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestBackgroundJobHandler(t *testing.T) {
+	os.Setenv("ADMIN_TOKEN", "test")
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		token      string
+		wantStatus int
+	}{
+		{
+			name: "Rejects a request without the admin token",
+			method: "GET",
+			path: "/api/job",
+			token: "wrong",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "Rejects an unknown type",
+			method: "GET",
+			path: "/api/job?type=UNKNOWN",
+			token: "test",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Rejects an unknown status",
+			method: "GET",
+			path: "/api/job?status=PAUSED",
+			token: "test",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Rejects a since which isn't RFC 3339",
+			method: "GET",
+			path: "/api/job/?since=2021-04-06",
+			token: "test",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Rejects a limit above the maximum",
+			method: "GET",
+			path: "/api/job?limit=101",
+			token: "test",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Rejects a negative offset",
+			method: "GET",
+			path: "/api/job?offset=-1",
+			token: "test",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Rejects a job ID which isn't a number",
+			method: "GET",
+			path: "/api/job/abc",
+			token: "test",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Rejects an unknown path below a job",
+			method: "GET",
+			path: "/api/job/1/events",
+			token: "test",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "Rejects the logs of a job ID which isn't a number",
+			method: "GET",
+			path: "/api/job/abc/logs",
+			token: "test",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Rejects an unknown log level",
+			method: "GET",
+			path: "/api/job/1/logs?level=TRACE",
+			token: "test",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Rejects a log tail of 0",
+			method: "GET",
+			path: "/api/job/1/logs?tail=0",
+			token: "test",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Rejects a log limit above the maximum",
+			method: "GET",
+			path: "/api/job/1/logs?limit=10001",
+			token: "test",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Rejects a log after which isn't a number",
+			method: "GET",
+			path: "/api/job/1/logs?after=last",
+			token: "test",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Rejects a follow which isn't a boolean",
+			method: "GET",
+			path: "/api/job/1/logs?follow=always",
+			token: "test",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Rejects cancelling without a job ID",
+			method: "DELETE",
+			path: "/api/job/",
+			token: "test",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Rejects cancelling without the admin token",
+			method: "DELETE",
+			path: "/api/job/1",
+			token: "wrong",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "Lists the types of job",
+			method: "GET",
+			path: "/api/job/types",
+			token: "test",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "Rejects queuing a job without the admin token",
+			method: "POST",
+			path: "/api/job",
+			body: `{"Type": "EXPORT GTFS"}`,
+			token: "wrong",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "Rejects queuing a job which isn't JSON",
+			method: "POST",
+			path: "/api/job",
+			body: "EXPORT GTFS",
+			token: "test",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Rejects queuing a job with an unknown field",
+			method: "POST",
+			path: "/api/job",
+			body: `{"Type": "EXPORT GTFS", "Priority": 1}`,
+			token: "test",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Rejects queuing an unknown type",
+			method: "POST",
+			path: "/api/job",
+			body: `{"Type": "UPDATE BUS STOPS"}`,
+			token: "test",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Rejects queuing a job with invalid parameters",
+			method: "POST",
+			path: "/api/job",
+			body: `{"Type": "UPDATE ROUTES BY DATASET ID", "Parameters": {"DatasetID": "2022"}}`,
+			token: "test",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Rejects queuing a job with missing parameters",
+			method: "POST",
+			path: "/api/job",
+			body: `{"Type": "UPDATE ROUTES BY DATASET ID", "Parameters": {"Force": true}}`,
+			token: "test",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Rejects queuing a job to a job ID",
+			method: "POST",
+			path: "/api/job/1",
+			body: `{"Type": "EXPORT GTFS"}`,
+			token: "test",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Accept", "*/*")
+			req.Header.Set("Authorization", "Bearer " + tt.token)
+
+			responseRecorder := httptest.NewRecorder()
+			handler := http.HandlerFunc(BackgroundJob)
+
+			handler.ServeHTTP(responseRecorder, req)
+
+			if status := responseRecorder.Code; status != tt.wantStatus {
+				t.Errorf("handler returned wrong status code: got %v want %v", status, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"server/controllers"
 	"strconv"
 	"strings"
+	"math"
 	"net/http"
 	"fmt"
 	"os"
@@ -51,10 +52,11 @@ func BusRoutes(w http.ResponseWriter, r *http.Request) {
 }
 
 type getBusRouteBody struct {
-	Route  models.Route
+	Routes  []models.Route
 }
 
-// get is a GET route for getting a bus route by 
+// get is a GET route for getting every route variant of a line by line name,
+// direction and operator
 func (*busRouteHandler) get(w http.ResponseWriter, r *http.Request) {
 	urlQuery := r.URL.Query()
 
@@ -83,7 +85,50 @@ func (*busRouteHandler) get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	route, err := controllers.GetRoute(lineName, direction, operatorID)
+	tolerance := float64(0)
+	if urlQuery.Get("tolerance") != "" {
+		var err error
+
+		tolerance, err = strconv.ParseFloat(urlQuery.Get("tolerance"), 64)
+		if err != nil || tolerance < 0 || math.IsNaN(tolerance) || math.IsInf(tolerance, 0) {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, "tolerance must be a positive number of metres")
+
+			return
+		}
+	}
+
+	var zoom *uint
+	if urlQuery.Get("zoom") != "" {
+		if urlQuery.Get("tolerance") != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, "You can't provide both a tolerance and zoom")
+
+			return
+		}
+
+		zoomLevel, err := strconv.ParseUint(urlQuery.Get("zoom"), 10, 32)
+		if err != nil || zoomLevel > utils.MaximumZoom {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "zoom must be a whole number from 0 to %d", utils.MaximumZoom)
+
+			return
+		}
+
+		zoomValue := uint(zoomLevel)
+		zoom = &zoomValue
+	}
+
+	encodePolyline := urlQuery.Get("polyline") == "true"
+
+	var routes []models.Route
+	var err error
+
+	if urlQuery.Get("tolerance") != "" || zoom != nil || encodePolyline {
+		routes, err = controllers.GetSimplifiedRoute(lineName, direction, operatorID, tolerance, zoom, encodePolyline)
+	} else {
+		routes, err = controllers.GetRoute(lineName, direction, operatorID)
+	}
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 
@@ -92,7 +137,7 @@ func (*busRouteHandler) get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if route.LineID == "" {
+	if len(routes) == 0 {
 		w.WriteHeader(http.StatusNotFound)
 
 		fmt.Fprint(w, http.StatusText(http.StatusNotFound))
@@ -101,7 +146,7 @@ func (*busRouteHandler) get(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Response ok
-	response := getBusRouteBody{ Route: route }
+	response := getBusRouteBody{ Routes: routes }
 	compress := strings.Contains(r.Header.Get("Accept-Encoding"), "gzip")
 
 	// Set caching header to 6 hours
@@ -111,13 +156,14 @@ func (*busRouteHandler) get(w http.ResponseWriter, r *http.Request) {
 	utils.SendJSONResponse(w, http.StatusOK, compress, response)
 }
 
-// update is a UPDATE route for updating routes with a datasetID. The route is
-// protected by an admin token
+// update is a UPDATE route for updating routes with a datasetID, or every
+// published dataset when no datasetID is given. The route is protected by an
+// admin token
 func (*busRouteHandler) update(w http.ResponseWriter, r *http.Request) {
 	authorizationHeader := r.Header.Get("Authorization")
 	adminToken := os.Getenv("ADMIN_TOKEN")
 	if authorizationHeader != "Bearer " + adminToken {
-		fmt.Fprintln(os.Stderr, "Unauthorized request to GET /api/bus-routes")
+		fmt.Fprintln(os.Stderr, "Unauthorized request to PUT /api/bus-routes")
 
 		w.WriteHeader(http.StatusUnauthorized)
 		fmt.Fprint(w, http.StatusText(http.StatusUnauthorized))
@@ -127,15 +173,26 @@ func (*busRouteHandler) update(w http.ResponseWriter, r *http.Request) {
 
 	urlPath := strings.Split(r.URL.EscapedPath(), "/")
 
-	datasetID, err := strconv.ParseUint(urlPath[3], 10, 32)
-	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprint(w, "Dataset ID must be a positive integer")
+	urlQuery := r.URL.Query()
+	force := urlQuery.Get("force") == "true"
 
-		return
-	}
+	var job models.BackgroundJob
+	var err error
+
+	if len(urlPath) < 4 || urlPath[3] == "" {
+		// No dataset ID so update every published dataset
+		job, err = controllers.UpdateRoutes(urlQuery.Get("noc"), urlQuery.Get("adminArea"), force)
+	} else {
+		datasetID, parseErr := strconv.ParseUint(urlPath[3], 10, 32)
+		if parseErr != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, "Dataset ID must be a positive integer")
 
-	job, err := controllers.UpdateRoute(uint(datasetID), &http.Client{}, &models.BusRoutes{})
+			return
+		}
+
+		job, err = controllers.UpdateRoute(uint(datasetID), force)
+	}
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 
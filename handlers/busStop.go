@@ -55,8 +55,88 @@ type getBusStopBody struct {
 	BusStops  []models.BusStop
 }
 
-// get is a GET route for getting bus stops within a bounds
-func (*busStopHandler) get(w http.ResponseWriter, r *http.Request) {
+type getBusStopLinesBody struct {
+	Lines  []models.BusStopLine
+}
+
+// get is a GET route for getting bus stops within a bounds, a single bus stop
+// (/api/bus-stops/:atcoCode) or the lines calling at a bus stop
+// (/api/bus-stops/:atcoCode/lines)
+func (busStopHandler *busStopHandler) get(w http.ResponseWriter, r *http.Request) {
+	urlPath := strings.Split(strings.TrimSuffix(r.URL.EscapedPath(), "/"), "/")
+
+	switch {
+		case len(urlPath) == 3: busStopHandler.getWithinBounds(w, r)
+		case len(urlPath) == 4 && urlPath[3] != "": busStopHandler.getByID(w, r, urlPath[3])
+		case len(urlPath) == 5 && urlPath[3] != "" && urlPath[4] == "lines":
+			busStopHandler.getLines(w, r, urlPath[3])
+		default:
+			w.WriteHeader(http.StatusNotFound)
+
+			fmt.Fprint(w, http.StatusText(http.StatusNotFound))
+	}
+}
+
+type getBusStopByIDBody struct {
+	BusStop  models.BusStop
+}
+
+// getByID responds with the bus stop atcoCode
+func (*busStopHandler) getByID(w http.ResponseWriter, r *http.Request, atcoCode string) {
+	busStop, found, err := controllers.GetBusStop(atcoCode)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, http.StatusText(http.StatusInternalServerError))
+
+		return
+	}
+
+	if !found {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, "No bus stop found")
+
+		return
+	}
+
+	// Response ok
+	response := getBusStopByIDBody{BusStop: busStop}
+	compress := strings.Contains(r.Header.Get("Accept-Encoding"), "gzip")
+
+	// Set caching header to 6 hours
+	w.Header().Set("Cache-Control", "public, max-age=21600")
+
+	utils.SendJSONResponse(w, http.StatusOK, compress, response)
+}
+
+// getLines responds with every route which calls at the bus stop atcoCode
+func (*busStopHandler) getLines(w http.ResponseWriter, r *http.Request, atcoCode string) {
+	lines, found, err := controllers.GetBusStopLines(atcoCode)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, http.StatusText(http.StatusInternalServerError))
+
+		return
+	}
+
+	if !found {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, "No bus stop found")
+
+		return
+	}
+
+	// Response ok
+	response := getBusStopLinesBody{Lines: lines}
+	compress := strings.Contains(r.Header.Get("Accept-Encoding"), "gzip")
+
+	// Set caching header to 6 hours
+	w.Header().Set("Cache-Control", "public, max-age=21600")
+
+	utils.SendJSONResponse(w, http.StatusOK, compress, response)
+}
+
+// getWithinBounds responds with the bus stops within a bounds
+func (*busStopHandler) getWithinBounds(w http.ResponseWriter, r *http.Request) {
 	urlQuery := r.URL.Query()
 
 	minLongitude, err := strconv.ParseFloat(urlQuery.Get("minLongitude"), 32)
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"server/models"
+	"server/utils"
+	"server/controllers"
+	"strings"
+	"net/http"
+	"fmt"
+)
+
+type datasetHandler struct {}
+
+// Datasets handles all dataset requests (GET, OPTIONS)
+func Datasets(w http.ResponseWriter, r *http.Request) {
+	datasetHandler := datasetHandler{}
+	acceptedMethods := []string{
+		http.MethodGet,
+		http.MethodOptions,
+	}
+
+	if r.Method == http.MethodOptions {
+		utils.OptionsResponse(w, acceptedMethods, contentTypeJson)
+
+		return
+	}
+
+	// Check content type of JSON is accepted by client
+	acceptHeader := r.Header.Get("Accept")
+	if !(strings.Contains(acceptHeader, "*/*") ||
+		strings.Contains(acceptHeader, "application/json")) {
+		w.WriteHeader(http.StatusNotAcceptable)
+
+		fmt.Fprint(w, contentTypeJson)
+
+		return
+	}
+
+	switch method := r.Method; method {
+		case http.MethodGet: datasetHandler.get(w, r)
+		default:
+			w.Header().Set("Allow", strings.Join(acceptedMethods, ", "))
+			w.WriteHeader(http.StatusMethodNotAllowed)
+
+			fmt.Fprint(w, http.StatusText(http.StatusMethodNotAllowed))
+	}
+}
+
+type getDatasetsBody struct {
+	Datasets  []models.Dataset
+}
+
+// get is a GET route for listing every imported timetable dataset
+func (*datasetHandler) get(w http.ResponseWriter, r *http.Request) {
+	datasets, err := controllers.GetDatasets()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+
+		fmt.Fprint(w, http.StatusText(http.StatusInternalServerError))
+
+		return
+	}
+
+	// Response ok
+	response := getDatasetsBody{Datasets: datasets}
+	compress := strings.Contains(r.Header.Get("Accept-Encoding"), "gzip")
+
+	utils.SendJSONResponse(w, http.StatusOK, compress, response)
+}
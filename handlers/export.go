@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"server/utils"
+	"server/controllers"
+	"server/models"
+	"strings"
+	"net/http"
+	"log"
+	"os"
+	"fmt"
+	"time"
+)
+
+type exportHandler struct {}
+
+const contentTypeZip = "application/zip"
+
+// gtfsExportMaxAge is how long after it was exported a GTFS feed can be cached
+const gtfsExportMaxAge = 6 * time.Hour
+
+// Export handles all timetable export requests (GET, PUT, OPTIONS)
+func Export(w http.ResponseWriter, r *http.Request) {
+	if r.URL.EscapedPath() != "/api/export/gtfs.zip" {
+		http.NotFound(w, r)
+
+		return
+	}
+
+	exportHandler := exportHandler{}
+	acceptedMethods := []string{
+		http.MethodGet,
+		http.MethodPut,
+		http.MethodOptions,
+	}
+
+	if r.Method == http.MethodOptions {
+		utils.OptionsResponse(w, acceptedMethods, contentTypeZip)
+
+		return
+	}
+
+	switch method := r.Method; method {
+		case http.MethodGet: exportHandler.get(w, r)
+		case http.MethodPut: exportHandler.put(w, r)
+		default:
+			w.Header().Set("Allow", strings.Join(acceptedMethods, ", "))
+			w.WriteHeader(http.StatusMethodNotAllowed)
+
+			fmt.Fprint(w, http.StatusText(http.StatusMethodNotAllowed))
+	}
+}
+
+// get is a GET route for downloading the most recently exported GTFS feed
+func (*exportHandler) get(w http.ResponseWriter, r *http.Request) {
+	// Check content type of zip is accepted by client
+	acceptHeader := r.Header.Get("Accept")
+	if !(strings.Contains(acceptHeader, "*/*") ||
+		strings.Contains(acceptHeader, contentTypeZip)) {
+		w.WriteHeader(http.StatusNotAcceptable)
+
+		fmt.Fprint(w, contentTypeZip)
+
+		return
+	}
+
+	feed, createdAt, found, err := controllers.GetGTFSExport()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, http.StatusText(http.StatusInternalServerError))
+
+		return
+	}
+
+	if !found {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, "No GTFS feed has been exported")
+
+		return
+	}
+
+	if notModified := cacheGTFSExport(w, r, createdAt, time.Now()); notModified {
+		w.WriteHeader(http.StatusNotModified)
+
+		return
+	}
+
+	// Response ok
+	w.Header().Set("Content-Disposition", "attachment; filename=\"gtfs.zip\"")
+
+	// the feed is already compressed so isn't gzipped again
+	utils.SendBinaryResponse(w, http.StatusOK, false, contentTypeZip, feed)
+}
+
+// cacheGTFSExport sets the caching headers of a feed exported at createdAt. The
+// feed can be cached until gtfsExportMaxAge after it was exported, and is then
+// revalidated with its ETag or Last-Modified. notModified is true when the
+// request's conditional headers match the feed
+func cacheGTFSExport(w http.ResponseWriter, r *http.Request, createdAt time.Time, now time.Time) bool {
+	etag := fmt.Sprintf("\"%x\"", createdAt.UnixNano())
+
+	maxAge := gtfsExportMaxAge - now.Sub(createdAt)
+	if maxAge < 0 {
+		maxAge = 0
+	}
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", createdAt.UTC().Format(http.TimeFormat))
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())))
+
+	// If-Modified-Since is ignored when If-None-Match is sent
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		for _, match := range strings.Split(ifNoneMatch, ",") {
+			if match = strings.TrimSpace(match); match == etag || match == "*" {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	ifModifiedSince, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+
+	return err == nil && !createdAt.Truncate(time.Second).After(ifModifiedSince)
+}
+
+type putExportBody struct {
+	Job  models.BackgroundJob
+}
+
+// put is a PUT route for exporting every imported timetable as a GTFS feed. The
+// route is protected by an admin token
+func (*exportHandler) put(w http.ResponseWriter, r *http.Request) {
+	authorizationHeader := r.Header.Get("Authorization")
+	adminToken := os.Getenv("ADMIN_TOKEN")
+	if authorizationHeader != "Bearer " + adminToken {
+		log.Println("Unauthorized request to PUT /api/export/gtfs.zip")
+
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, http.StatusText(http.StatusUnauthorized))
+
+		return
+	}
+
+	// Check content type of JSON is accepted by client
+	acceptHeader := r.Header.Get("Accept")
+	if !(strings.Contains(acceptHeader, "*/*") ||
+		strings.Contains(acceptHeader, "application/json")) {
+		w.WriteHeader(http.StatusNotAcceptable)
+
+		fmt.Fprint(w, contentTypeJson)
+
+		return
+	}
+
+	job, err := controllers.ExportGTFS()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, http.StatusText(http.StatusInternalServerError))
+
+		return
+	}
+
+	// Request accepted
+	response := putExportBody{ Job: job }
+	compress := strings.Contains(r.Header.Get("Accept-Encoding"), "gzip")
+
+	utils.SendJSONResponse(w, http.StatusAccepted, compress, response)
+}
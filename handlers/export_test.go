@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_cacheGTFSExport(t *testing.T) {
+	createdAt := time.Date(2021, 4, 7, 2, 0, 0, 361187000, time.UTC)
+	etag := "\"167370e06d1486b8\""
+
+	type args struct {
+		headers map[string]string
+		now     time.Time
+	}
+	tests := []struct {
+		name             string
+		args             args
+		wantCacheControl string
+		wantNotModified  bool
+	}{
+		{
+			name: "Caches a new feed until 6 hours after it was exported",
+			args: args{
+				headers: map[string]string{},
+				now: createdAt.Add(time.Hour),
+			},
+			wantCacheControl: "public, max-age=18000",
+			wantNotModified: false,
+		},
+		{
+			name: "Revalidates a feed exported more than 6 hours ago",
+			args: args{
+				headers: map[string]string{},
+				now: createdAt.Add(7 * time.Hour),
+			},
+			wantCacheControl: "public, max-age=0",
+			wantNotModified: false,
+		},
+		{
+			name: "Isn't modified when the ETag matches",
+			args: args{
+				headers: map[string]string{"If-None-Match": "\"1\", " + etag},
+				now: createdAt.Add(7 * time.Hour),
+			},
+			wantCacheControl: "public, max-age=0",
+			wantNotModified: true,
+		},
+		{
+			name: "Is modified when the ETag is of an older feed, even if it wasn't modified since",
+			args: args{
+				headers: map[string]string{
+					"If-None-Match": "\"1\"",
+					"If-Modified-Since": "Wed, 07 Apr 2021 02:00:00 GMT",
+				},
+				now: createdAt.Add(time.Hour),
+			},
+			wantCacheControl: "public, max-age=18000",
+			wantNotModified: false,
+		},
+		{
+			name: "Isn't modified since it was exported",
+			args: args{
+				headers: map[string]string{"If-Modified-Since": "Wed, 07 Apr 2021 02:00:00 GMT"},
+				now: createdAt.Add(time.Hour),
+			},
+			wantCacheControl: "public, max-age=18000",
+			wantNotModified: true,
+		},
+		{
+			name: "Is modified since before it was exported",
+			args: args{
+				headers: map[string]string{"If-Modified-Since": "Tue, 06 Apr 2021 02:00:00 GMT"},
+				now: createdAt.Add(time.Hour),
+			},
+			wantCacheControl: "public, max-age=18000",
+			wantNotModified: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", "/api/export/gtfs.zip", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for key, value := range tt.args.headers {
+				req.Header.Set(key, value)
+			}
+
+			responseRecorder := httptest.NewRecorder()
+
+			if got := cacheGTFSExport(responseRecorder, req, createdAt, tt.args.now); got != tt.wantNotModified {
+				t.Errorf("cacheGTFSExport() = %v, want %v", got, tt.wantNotModified)
+			}
+
+			if got := responseRecorder.Header().Get("Cache-Control"); got != tt.wantCacheControl {
+				t.Errorf("Cache-Control = %v, want %v", got, tt.wantCacheControl)
+			}
+
+			if got := responseRecorder.Header().Get("ETag"); got != etag {
+				t.Errorf("ETag = %v, want %v", got, etag)
+			}
+
+			if got := responseRecorder.Header().Get("Last-Modified"); got != "Wed, 07 Apr 2021 02:00:00 GMT" {
+				t.Errorf("Last-Modified = %v, want Wed, 07 Apr 2021 02:00:00 GMT", got)
+			}
+		})
+	}
+}
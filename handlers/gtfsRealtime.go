@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"server/utils"
+	"server/controllers"
+	"server/types"
+	"strings"
+	"net/http"
+	"fmt"
+)
+
+type gtfsRealtimeHandler struct {}
+
+const contentTypeProtobuf = "application/x-protobuf"
+
+// greatBritain is the bounding box of vehicle positions without a bbox
+var greatBritain = types.BoundingBox{
+	Min: types.Coordinate{Longitude: -8.65, Latitude: 49.86},
+	Max: types.Coordinate{Longitude: 1.77, Latitude: 60.86},
+}
+
+// GTFSRealtime handles all GTFS Realtime feed requests (GET, OPTIONS)
+func GTFSRealtime(w http.ResponseWriter, r *http.Request) {
+	if r.URL.EscapedPath() != "/api/gtfs-rt/vehicle-positions" {
+		http.NotFound(w, r)
+
+		return
+	}
+
+	gtfsRealtimeHandler := gtfsRealtimeHandler{}
+	acceptedMethods := []string{
+		http.MethodGet,
+		http.MethodOptions,
+	}
+
+	if r.Method == http.MethodOptions {
+		utils.OptionsResponse(w, acceptedMethods, contentTypeProtobuf)
+
+		return
+	}
+
+	switch method := r.Method; method {
+		case http.MethodGet: gtfsRealtimeHandler.getVehiclePositions(w, r)
+		default:
+			w.Header().Set("Allow", strings.Join(acceptedMethods, ", "))
+			w.WriteHeader(http.StatusMethodNotAllowed)
+
+			fmt.Fprint(w, http.StatusText(http.StatusMethodNotAllowed))
+	}
+}
+
+// getVehiclePositions is a GET route for a GTFS Realtime VehiclePositions feed
+// of the buses within a bounding box, as a protocol buffer or as JSON for
+// debugging
+func (*gtfsRealtimeHandler) getVehiclePositions(w http.ResponseWriter, r *http.Request) {
+	urlQuery := r.URL.Query()
+
+	debugJSON := urlQuery.Get("format") == "json"
+	if urlQuery.Get("format") != "" && !debugJSON {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, "format must be \"json\" or left out")
+
+		return
+	}
+
+	// Check content type of the feed is accepted by client
+	acceptHeader := r.Header.Get("Accept")
+	contentType := contentTypeProtobuf
+	if debugJSON {
+		contentType = contentTypeJson
+	}
+	if !(strings.Contains(acceptHeader, "*/*") ||
+		(debugJSON && strings.Contains(acceptHeader, "application/json")) ||
+		(!debugJSON && (strings.Contains(acceptHeader, contentTypeProtobuf) ||
+		strings.Contains(acceptHeader, "application/octet-stream")))) {
+		w.WriteHeader(http.StatusNotAcceptable)
+
+		fmt.Fprint(w, contentType)
+
+		return
+	}
+
+	bounds := greatBritain
+	if urlQuery.Get("bbox") != "" {
+		var err error
+
+		bounds, err = parseBoundingBox(urlQuery.Get("bbox"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, err)
+
+			return
+		}
+	}
+
+	feed, err := controllers.GetVehiclePositions(bounds)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, http.StatusText(http.StatusInternalServerError))
+
+		return
+	}
+
+	// Response ok
+	compress := strings.Contains(r.Header.Get("Accept-Encoding"), "gzip")
+
+	if debugJSON {
+		utils.SendJSONResponse(w, http.StatusOK, compress, feed)
+
+		return
+	}
+
+	utils.SendBinaryResponse(w, http.StatusOK, compress, contentTypeProtobuf, utils.EncodeGTFSRealtimeFeed(feed))
+}
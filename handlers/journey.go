@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"server/models"
+	"server/utils"
+	"server/controllers"
+	"strings"
+	"net/http"
+	"time"
+	"fmt"
+)
+
+type journeyHandler struct {}
+
+// Journeys handles all journey planner requests (GET, OPTIONS)
+func Journeys(w http.ResponseWriter, r *http.Request) {
+	journeyHandler := journeyHandler{}
+	acceptedMethods := []string{
+		http.MethodGet,
+		http.MethodOptions,
+	}
+
+	if r.Method == http.MethodOptions {
+		utils.OptionsResponse(w, acceptedMethods, contentTypeJson)
+
+		return
+	}
+
+	// Check content type of JSON is accepted by client
+	acceptHeader := r.Header.Get("Accept")
+	if !(strings.Contains(acceptHeader, "*/*") ||
+		strings.Contains(acceptHeader, "application/json")) {
+		w.WriteHeader(http.StatusNotAcceptable)
+
+		fmt.Fprint(w, contentTypeJson)
+
+		return
+	}
+
+	switch method := r.Method; method {
+		case http.MethodGet: journeyHandler.get(w, r)
+		default:
+			w.Header().Set("Allow", strings.Join(acceptedMethods, ", "))
+			w.WriteHeader(http.StatusMethodNotAllowed)
+
+			fmt.Fprint(w, http.StatusText(http.StatusMethodNotAllowed))
+	}
+}
+
+type getJourneysBody struct {
+	Itineraries  []models.Itinerary
+}
+
+// get is a GET route for planning journeys between two bus stops
+func (*journeyHandler) get(w http.ResponseWriter, r *http.Request) {
+	urlQuery := r.URL.Query()
+
+	from := strings.TrimSpace(urlQuery.Get("from"))
+	to := strings.TrimSpace(urlQuery.Get("to"))
+	if from == "" || to == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, "You must provide a from and to bus stop")
+
+		return
+	}
+
+	if from == to {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, "The from and to bus stops must be different")
+
+		return
+	}
+
+	departAfter := time.Now()
+	if urlQuery.Get("departAfter") != "" {
+		var err error
+
+		departAfter, err = time.Parse(time.RFC3339, urlQuery.Get("departAfter"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, "departAfter must be a RFC 3339 time")
+
+			return
+		}
+	}
+
+	itineraries, found, err := controllers.PlanJourneys(from, to, departAfter)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, http.StatusText(http.StatusInternalServerError))
+
+		return
+	}
+
+	if !found {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, "No bus stop found")
+
+		return
+	}
+
+	// Response ok
+	response := getJourneysBody{Itineraries: itineraries}
+	compress := strings.Contains(r.Header.Get("Accept-Encoding"), "gzip")
+
+	utils.SendJSONResponse(w, http.StatusOK, compress, response)
+}
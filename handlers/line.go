@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"server/models"
+	"server/types"
+	"server/utils"
+	"server/controllers"
+	"strconv"
+	"strings"
+	"net/http"
+	"errors"
+	"fmt"
+)
+
+type lineHandler struct {}
+
+// Lines handles all line requests (GET, OPTIONS)
+func Lines(w http.ResponseWriter, r *http.Request) {
+	lineHandler := lineHandler{}
+	acceptedMethods := []string{
+		http.MethodGet,
+		http.MethodOptions,
+	}
+
+	if r.Method == http.MethodOptions {
+		utils.OptionsResponse(w, acceptedMethods, contentTypeJson)
+
+		return
+	}
+
+	// Check content type of JSON is accepted by client
+	acceptHeader := r.Header.Get("Accept")
+	if !(strings.Contains(acceptHeader, "*/*") ||
+		strings.Contains(acceptHeader, "application/json")) {
+		w.WriteHeader(http.StatusNotAcceptable)
+
+		fmt.Fprint(w, contentTypeJson)
+
+		return
+	}
+
+	switch method := r.Method; method {
+		case http.MethodGet: lineHandler.get(w, r)
+		default:
+			w.Header().Set("Allow", strings.Join(acceptedMethods, ", "))
+			w.WriteHeader(http.StatusMethodNotAllowed)
+
+			fmt.Fprint(w, http.StatusText(http.StatusMethodNotAllowed))
+	}
+}
+
+type getLinesBody struct {
+	Lines  []models.LineSummary
+}
+
+// get is a GET route for getting the lines serving bus stops within a bounding
+// box
+func (*lineHandler) get(w http.ResponseWriter, r *http.Request) {
+	bounds, err := parseBoundingBox(r.URL.Query().Get("bbox"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, err.Error())
+
+		return
+	}
+
+	lines, err := controllers.GetLinesWithinBounds(
+		bounds.Min.Longitude, bounds.Min.Latitude,
+		bounds.Max.Longitude, bounds.Max.Latitude,
+	)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+
+		fmt.Fprint(w, http.StatusText(http.StatusInternalServerError))
+
+		return
+	}
+
+	// Response ok
+	response := getLinesBody{Lines: lines}
+	compress := strings.Contains(r.Header.Get("Accept-Encoding"), "gzip")
+
+	// Set caching header to 6 hours
+	w.Header().Set("Cache-Control", "public, max-age=21600")
+
+	utils.SendJSONResponse(w, http.StatusOK, compress, response)
+}
+
+// parseBoundingBox parses a bounding box of the form
+// "minLongitude,minLatitude,maxLongitude,maxLatitude"
+func parseBoundingBox(bbox string) (types.BoundingBox, error) {
+	splitBounds := strings.Split(bbox, ",")
+
+	if len(splitBounds) != 4 {
+		return types.BoundingBox{}, errors.New("bbox must be minLongitude,minLatitude,maxLongitude,maxLatitude")
+	}
+
+	bounds := make([]float32, 0)
+	for _, bound := range splitBounds {
+		value, err := strconv.ParseFloat(strings.TrimSpace(bound), 32)
+		if err != nil {
+			return types.BoundingBox{}, errors.New("bbox must be of type BoundingBox(float32, float32, float32, float32)")
+		}
+
+		bounds = append(bounds, float32(value))
+	}
+
+	if bounds[0] > bounds[2] || bounds[1] > bounds[3] {
+		return types.BoundingBox{}, errors.New("bbox minimum must be less than the maximum")
+	}
+
+	return types.BoundingBox{
+			Min: types.Coordinate{Longitude: bounds[0], Latitude: bounds[1]},
+			Max: types.Coordinate{Longitude: bounds[2], Latitude: bounds[3]},
+		},
+		nil
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"reflect"
+	"server/types"
+	"testing"
+)
+
+func Test_parseBoundingBox(t *testing.T) {
+	type args struct {
+		bbox string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    types.BoundingBox
+		wantErr bool
+	}{
+		{
+			name: "Parses the bounding box \"1.05,51.26,1.1,51.3\"",
+			args: args{
+				bbox: "1.05,51.26,1.1,51.3",
+			},
+			want: types.BoundingBox{
+				Min: types.Coordinate{
+					Longitude: 1.05,
+					Latitude: 51.26,
+				},
+				Max: types.Coordinate{
+					Longitude: 1.1,
+					Latitude: 51.3,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Fails to parse when too few arguments are passed \"1.05,51.26,1.1\"",
+			args: args{
+				bbox: "1.05,51.26,1.1",
+			},
+			want: types.BoundingBox{},
+			wantErr: true,
+		},
+		{
+			name: "Fails to parse a incorrectly formated bound \"1.05,fails,1.1,51.3\"",
+			args: args{
+				bbox: "1.05,fails,1.1,51.3",
+			},
+			want: types.BoundingBox{},
+			wantErr: true,
+		},
+		{
+			name: "Fails to parse when the minimum is greater than the maximum \"1.1,51.3,1.05,51.26\"",
+			args: args{
+				bbox: "1.1,51.3,1.05,51.26",
+			},
+			want: types.BoundingBox{},
+			wantErr: true,
+		},
+		{
+			name: "Fails to parse when no arguments are passed \"\"",
+			args: args{
+				bbox: "",
+			},
+			want: types.BoundingBox{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseBoundingBox(tt.args.bbox)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseBoundingBox() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseBoundingBox() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"server/models"
+	"server/utils"
+	"server/controllers"
+	"strings"
+	"net/http"
+	"fmt"
+)
+
+type operatorHandler struct {}
+
+// Operators handles all operator requests (GET, OPTIONS)
+func Operators(w http.ResponseWriter, r *http.Request) {
+	operatorHandler := operatorHandler{}
+	acceptedMethods := []string{
+		http.MethodGet,
+		http.MethodOptions,
+	}
+
+	if r.Method == http.MethodOptions {
+		utils.OptionsResponse(w, acceptedMethods, contentTypeJson)
+
+		return
+	}
+
+	// Check content type of JSON is accepted by client
+	acceptHeader := r.Header.Get("Accept")
+	if !(strings.Contains(acceptHeader, "*/*") ||
+		strings.Contains(acceptHeader, "application/json")) {
+		w.WriteHeader(http.StatusNotAcceptable)
+
+		fmt.Fprint(w, contentTypeJson)
+
+		return
+	}
+
+	switch method := r.Method; method {
+		case http.MethodGet: operatorHandler.get(w, r)
+		default:
+			w.Header().Set("Allow", strings.Join(acceptedMethods, ", "))
+			w.WriteHeader(http.StatusMethodNotAllowed)
+
+			fmt.Fprint(w, http.StatusText(http.StatusMethodNotAllowed))
+	}
+}
+
+type getOperatorsBody struct {
+	Operators  []models.Operator
+}
+
+type getOperatorLinesBody struct {
+	Lines  []models.LineSummary
+}
+
+// get is a GET route for listing every operator, or the lines of an operator
+// when a national operator code is given (/api/operators/:noc/lines)
+func (operatorHandler *operatorHandler) get(w http.ResponseWriter, r *http.Request) {
+	urlPath := strings.Split(strings.TrimSuffix(r.URL.EscapedPath(), "/"), "/")
+
+	if len(urlPath) == 3 {
+		operatorHandler.list(w, r)
+
+		return
+	}
+
+	if len(urlPath) != 5 || urlPath[3] == "" || urlPath[4] != "lines" {
+		w.WriteHeader(http.StatusNotFound)
+
+		fmt.Fprint(w, http.StatusText(http.StatusNotFound))
+
+		return
+	}
+
+	lines, found, err := controllers.GetOperatorLines(urlPath[3])
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+
+		fmt.Fprint(w, http.StatusText(http.StatusInternalServerError))
+
+		return
+	}
+
+	if !found {
+		w.WriteHeader(http.StatusNotFound)
+
+		fmt.Fprint(w, "No operator found")
+
+		return
+	}
+
+	// Response ok
+	response := getOperatorLinesBody{Lines: lines}
+	compress := strings.Contains(r.Header.Get("Accept-Encoding"), "gzip")
+
+	// Set caching header to 6 hours
+	w.Header().Set("Cache-Control", "public, max-age=21600")
+
+	utils.SendJSONResponse(w, http.StatusOK, compress, response)
+}
+
+// list responds with every operator
+func (*operatorHandler) list(w http.ResponseWriter, r *http.Request) {
+	operators, err := controllers.GetOperators()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+
+		fmt.Fprint(w, http.StatusText(http.StatusInternalServerError))
+
+		return
+	}
+
+	// Response ok
+	response := getOperatorsBody{Operators: operators}
+	compress := strings.Contains(r.Header.Get("Accept-Encoding"), "gzip")
+
+	// Set caching header to 6 hours
+	w.Header().Set("Cache-Control", "public, max-age=21600")
+
+	utils.SendJSONResponse(w, http.StatusOK, compress, response)
+}
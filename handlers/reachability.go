@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"server/models"
+	"server/types"
+	"server/utils"
+	"server/controllers"
+	"strings"
+	"strconv"
+	"net/http"
+	"time"
+	"fmt"
+)
+
+type reachabilityHandler struct {}
+
+// defaultReachabilityMinutes is the time budget of a reachability request
+// without maxMinutes
+const defaultReachabilityMinutes = 30
+
+// Reachability handles all reachability requests (GET, OPTIONS)
+func Reachability(w http.ResponseWriter, r *http.Request) {
+	reachabilityHandler := reachabilityHandler{}
+	acceptedMethods := []string{
+		http.MethodGet,
+		http.MethodOptions,
+	}
+
+	if r.Method == http.MethodOptions {
+		utils.OptionsResponse(w, acceptedMethods, contentTypeJson)
+
+		return
+	}
+
+	// Check content type of JSON is accepted by client
+	acceptHeader := r.Header.Get("Accept")
+	if !(strings.Contains(acceptHeader, "*/*") ||
+		strings.Contains(acceptHeader, "application/json")) {
+		w.WriteHeader(http.StatusNotAcceptable)
+
+		fmt.Fprint(w, contentTypeJson)
+
+		return
+	}
+
+	switch method := r.Method; method {
+		case http.MethodGet: reachabilityHandler.get(w, r)
+		default:
+			w.Header().Set("Allow", strings.Join(acceptedMethods, ", "))
+			w.WriteHeader(http.StatusMethodNotAllowed)
+
+			fmt.Fprint(w, http.StatusText(http.StatusMethodNotAllowed))
+	}
+}
+
+type getReachabilityBody struct {
+	Stops  []models.ReachableStop
+	Area   *types.GeoJSONFeature `json:",omitempty"`
+}
+
+// get is a GET route for the bus stops reachable from a bus stop within a time
+// budget
+func (*reachabilityHandler) get(w http.ResponseWriter, r *http.Request) {
+	urlQuery := r.URL.Query()
+
+	from := strings.TrimSpace(urlQuery.Get("from"))
+	if from == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, "You must provide a from bus stop")
+
+		return
+	}
+
+	maxMinutes := uint64(defaultReachabilityMinutes)
+	if urlQuery.Get("maxMinutes") != "" {
+		var err error
+
+		maxMinutes, err = strconv.ParseUint(urlQuery.Get("maxMinutes"), 10, 32)
+		if err != nil || maxMinutes == 0 || maxMinutes > controllers.MaximumReachabilityMinutes {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "maxMinutes must be a whole number from 1 to %d", controllers.MaximumReachabilityMinutes)
+
+			return
+		}
+	}
+
+	departAt := time.Now()
+	if urlQuery.Get("departAt") != "" {
+		var err error
+
+		departAt, err = time.Parse(time.RFC3339, urlQuery.Get("departAt"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, "departAt must be a RFC 3339 time")
+
+			return
+		}
+	}
+
+	includeArea := urlQuery.Get("geojson") == "true"
+
+	stops, area, found, err := controllers.Reach(from, departAt, uint(maxMinutes), includeArea)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, http.StatusText(http.StatusInternalServerError))
+
+		return
+	}
+
+	if !found {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, "No bus stop found")
+
+		return
+	}
+
+	// Response ok
+	response := getReachabilityBody{Stops: stops, Area: area}
+	compress := strings.Contains(r.Header.Get("Accept-Encoding"), "gzip")
+
+	utils.SendJSONResponse(w, http.StatusOK, compress, response)
+}
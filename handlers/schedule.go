@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"server/controllers"
+	"server/models"
+	"server/utils"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+)
+
+type scheduleHandler struct {}
+
+// Schedules handles all schedule requests (GET, OPTIONS). It is protected by
+// an admin auth token
+func Schedules(w http.ResponseWriter, r *http.Request) {
+	authorizationHeader := r.Header.Get("Authorization")
+	adminToken := os.Getenv("ADMIN_TOKEN")
+	if authorizationHeader != "Bearer " + adminToken {
+		log.Println("Unauthorized request to", r.Method, "/api/schedules")
+
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, http.StatusText(http.StatusUnauthorized))
+
+		return
+	}
+
+	scheduleHandler := scheduleHandler{}
+	acceptedMethods := []string{
+		http.MethodGet,
+		http.MethodOptions,
+	}
+
+	if r.Method == http.MethodOptions {
+		utils.OptionsResponse(w, acceptedMethods, contentTypeJson)
+
+		return
+	}
+
+	// Check content type of JSON is accepted by client
+	acceptHeader := r.Header.Get("Accept")
+	if !(strings.Contains(acceptHeader, "*/*") ||
+		strings.Contains(acceptHeader, "application/json")) {
+		w.WriteHeader(http.StatusNotAcceptable)
+
+		fmt.Fprint(w, contentTypeJson)
+
+		return
+	}
+
+	switch method := r.Method; method {
+		case http.MethodGet: scheduleHandler.get(w, r)
+		default:
+			w.Header().Set("Allow", strings.Join(acceptedMethods, ", "))
+			w.WriteHeader(http.StatusMethodNotAllowed)
+
+			fmt.Fprint(w, http.StatusText(http.StatusMethodNotAllowed))
+	}
+}
+
+type getSchedulesBody struct {
+	Schedules []models.Schedule
+}
+
+// get is a GET route for listing every schedule with when it next runs
+func (*scheduleHandler) get(w http.ResponseWriter, r *http.Request) {
+	response := getSchedulesBody{Schedules: controllers.GetSchedules()}
+	compress := strings.Contains(r.Header.Get("Accept-Encoding"), "gzip")
+
+	utils.SendJSONResponse(w, http.StatusOK, compress, response)
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestSchedulesHandler(t *testing.T) {
+	os.Setenv("ADMIN_TOKEN", "test")
+
+	tests := []struct {
+		name       string
+		method     string
+		token      string
+		wantStatus int
+	}{
+		{
+			name: "Rejects a request without the admin token",
+			method: "GET",
+			token: "wrong",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "Lists the schedules",
+			method: "GET",
+			token: "test",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "Rejects changing the schedules",
+			method: "PUT",
+			token: "test",
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, "/api/schedules", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Accept", "*/*")
+			req.Header.Set("Authorization", "Bearer " + tt.token)
+
+			responseRecorder := httptest.NewRecorder()
+			handler := http.HandlerFunc(Schedules)
+
+			handler.ServeHTTP(responseRecorder, req)
+
+			if status := responseRecorder.Code; status != tt.wantStatus {
+				t.Errorf("handler returned wrong status code: got %v want %v", status, tt.wantStatus)
+			}
+		})
+	}
+}
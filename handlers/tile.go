@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"server/utils"
+	"server/controllers"
+	"strconv"
+	"strings"
+	"net/http"
+	"fmt"
+)
+
+type tileHandler struct {}
+
+const contentTypeVectorTile = "application/vnd.mapbox-vector-tile"
+
+// Tiles handles all vector tile requests (GET, OPTIONS)
+func Tiles(w http.ResponseWriter, r *http.Request) {
+	tileHandler := tileHandler{}
+	acceptedMethods := []string{
+		http.MethodGet,
+		http.MethodOptions,
+	}
+
+	if r.Method == http.MethodOptions {
+		utils.OptionsResponse(w, acceptedMethods, contentTypeVectorTile)
+
+		return
+	}
+
+	// Check content type of vector tiles is accepted by client
+	acceptHeader := r.Header.Get("Accept")
+	if !(strings.Contains(acceptHeader, "*/*") ||
+		strings.Contains(acceptHeader, contentTypeVectorTile) ||
+		strings.Contains(acceptHeader, "application/x-protobuf")) {
+		w.WriteHeader(http.StatusNotAcceptable)
+
+		fmt.Fprint(w, contentTypeVectorTile)
+
+		return
+	}
+
+	switch method := r.Method; method {
+		case http.MethodGet: tileHandler.get(w, r)
+		default:
+			w.Header().Set("Allow", strings.Join(acceptedMethods, ", "))
+			w.WriteHeader(http.StatusMethodNotAllowed)
+
+			fmt.Fprint(w, http.StatusText(http.StatusMethodNotAllowed))
+	}
+}
+
+// get is a GET route for a Mapbox Vector Tile of bus stops and routes by zoom
+// level and tile x, y
+func (*tileHandler) get(w http.ResponseWriter, r *http.Request) {
+	urlPath := strings.Split(r.URL.EscapedPath(), "/")
+	if len(urlPath) != 5 || !strings.HasSuffix(urlPath[4], ".mvt") {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, http.StatusText(http.StatusNotFound))
+
+		return
+	}
+
+	zoom, err := strconv.ParseUint(urlPath[2], 10, 32)
+	if err != nil || zoom > utils.MaximumZoom {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "Zoom must be a whole number from 0 to %d", utils.MaximumZoom)
+
+		return
+	}
+
+	tiles := uint64(1) << zoom
+
+	x, err := strconv.ParseUint(urlPath[3], 10, 32)
+	if err != nil || x >= tiles {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, "x must be a whole number less than 2 to the power of zoom")
+
+		return
+	}
+
+	y, err := strconv.ParseUint(strings.TrimSuffix(urlPath[4], ".mvt"), 10, 32)
+	if err != nil || y >= tiles {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, "y must be a whole number less than 2 to the power of zoom")
+
+		return
+	}
+
+	tile, err := controllers.GetTile(uint(zoom), uint(x), uint(y))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, http.StatusText(http.StatusInternalServerError))
+
+		return
+	}
+
+	// Response ok
+	compress := strings.Contains(r.Header.Get("Accept-Encoding"), "gzip")
+
+	// Set caching header to 6 hours
+	w.Header().Set("Cache-Control", "public, max-age=21600")
+
+	utils.SendBinaryResponse(w, http.StatusOK, compress, contentTypeVectorTile, tile)
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTilesHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+	}{
+		{
+			name: "Rejects a path without a tile",
+			path: "/tiles/14/8241",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "Rejects a tile without the mvt extension",
+			path: "/tiles/14/8241/5464.png",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "Rejects a zoom above the maximum zoom",
+			path: "/tiles/23/0/0.mvt",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Rejects an x outside of the zoom level",
+			path: "/tiles/1/2/0.mvt",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Rejects a y which isn't a number",
+			path: "/tiles/1/0/a.mvt",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", tt.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Accept", "*/*")
+
+			responseRecorder := httptest.NewRecorder()
+			handler := http.HandlerFunc(Tiles)
+
+			handler.ServeHTTP(responseRecorder, req)
+
+			if status := responseRecorder.Code; status != tt.wantStatus {
+				t.Errorf("handler returned wrong status code: got %v want %v", status, tt.wantStatus)
+			}
+		})
+	}
+}
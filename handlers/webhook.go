@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"server/controllers"
+	"server/models"
+	"server/utils"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type webhookHandler struct {}
+
+// maximumWebhookBodySize is the largest webhook registration accepted in bytes
+const maximumWebhookBodySize = 64 * 1024
+
+// Webhooks handles all webhook requests (GET, POST, DELETE, OPTIONS). It is
+// protected by an admin auth token
+func Webhooks(w http.ResponseWriter, r *http.Request) {
+	authorizationHeader := r.Header.Get("Authorization")
+	adminToken := os.Getenv("ADMIN_TOKEN")
+	if authorizationHeader != "Bearer " + adminToken {
+		log.Println("Unauthorized request to", r.Method, "/api/webhooks")
+
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, http.StatusText(http.StatusUnauthorized))
+
+		return
+	}
+
+	webhookHandler := webhookHandler{}
+	acceptedMethods := []string{
+		http.MethodGet,
+		http.MethodPost,
+		http.MethodDelete,
+		http.MethodOptions,
+	}
+
+	if r.Method == http.MethodOptions {
+		utils.OptionsResponse(w, acceptedMethods, contentTypeJson)
+
+		return
+	}
+
+	// Check content type of JSON is accepted by client
+	acceptHeader := r.Header.Get("Accept")
+	if !(strings.Contains(acceptHeader, "*/*") ||
+		strings.Contains(acceptHeader, "application/json")) {
+		w.WriteHeader(http.StatusNotAcceptable)
+
+		fmt.Fprint(w, contentTypeJson)
+
+		return
+	}
+
+	switch method := r.Method; method {
+		case http.MethodGet: webhookHandler.get(w, r)
+		case http.MethodPost: webhookHandler.post(w, r)
+		case http.MethodDelete: webhookHandler.delete(w, r)
+		default:
+			w.Header().Set("Allow", strings.Join(acceptedMethods, ", "))
+			w.WriteHeader(http.StatusMethodNotAllowed)
+
+			fmt.Fprint(w, http.StatusText(http.StatusMethodNotAllowed))
+	}
+}
+
+type getWebhooksBody struct {
+	Webhooks []models.Webhook
+}
+
+type getWebhookBody struct {
+	Webhook models.Webhook
+}
+
+type getWebhookDeliveriesBody struct {
+	Deliveries []models.WebhookDelivery
+}
+
+type postWebhookRequest struct {
+	URL      string
+	Types    []string
+	Statuses []string
+}
+
+// get is a GET route for listing webhooks, or the deliveries of a webhook when
+// the path is /api/webhooks/:webhookID/deliveries
+func (webhookHandler *webhookHandler) get(w http.ResponseWriter, r *http.Request) {
+	urlPath := strings.Split(r.URL.EscapedPath(), "/")
+	if len(urlPath) < 4 || urlPath[3] == "" {
+		webhookHandler.list(w, r)
+
+		return
+	}
+
+	if len(urlPath) != 5 || urlPath[4] != "deliveries" {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, http.StatusText(http.StatusNotFound))
+
+		return
+	}
+
+	webhookID, err := strconv.ParseUint(urlPath[3], 10, 32)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, "Webhook ID must be a positive integer")
+
+		return
+	}
+
+	deliveries, found, err := controllers.GetWebhookDeliveries(uint(webhookID))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+
+		fmt.Fprint(w, http.StatusText(http.StatusInternalServerError))
+
+		return
+	}
+
+	if !found {
+		w.WriteHeader(http.StatusNotFound)
+
+		fmt.Fprint(w, "No webhook found")
+
+		return
+	}
+
+	// Request ok
+	response := getWebhookDeliveriesBody{Deliveries: deliveries}
+	compress := strings.Contains(r.Header.Get("Accept-Encoding"), "gzip")
+
+	utils.SendJSONResponse(w, http.StatusOK, compress, response)
+}
+
+// list is a GET route for listing every webhook without its secret
+func (*webhookHandler) list(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := controllers.GetWebhooks()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+
+		fmt.Fprint(w, http.StatusText(http.StatusInternalServerError))
+
+		return
+	}
+
+	// Request ok
+	response := getWebhooksBody{Webhooks: webhooks}
+	compress := strings.Contains(r.Header.Get("Accept-Encoding"), "gzip")
+
+	utils.SendJSONResponse(w, http.StatusOK, compress, response)
+}
+
+// post is a POST route for registering a webhook. The webhook is returned with
+// the secret its payloads are signed with, which isn't returned again
+func (*webhookHandler) post(w http.ResponseWriter, r *http.Request) {
+	var request postWebhookRequest
+
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maximumWebhookBodySize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, "Body must be a JSON webhook")
+
+		return
+	}
+
+	webhookURL, err := url.Parse(request.URL)
+	if err != nil || (webhookURL.Scheme != "http" && webhookURL.Scheme != "https") || webhookURL.Host == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, "URL must be an absolute http or https URL")
+
+		return
+	}
+
+	for _, jobType := range request.Types {
+		if !containsString(controllers.BackgroundJobTypeNames(), jobType) {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, "Types must be of " + strings.Join(controllers.BackgroundJobTypeNames(), ", "))
+
+			return
+		}
+	}
+
+	for _, status := range request.Statuses {
+		if !containsString(models.WebhookStatuses, status) {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, "Statuses must be of " + strings.Join(models.WebhookStatuses, ", "))
+
+			return
+		}
+	}
+
+	webhook, err := controllers.CreateWebhook(request.URL, request.Types, request.Statuses)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+
+		fmt.Fprint(w, http.StatusText(http.StatusInternalServerError))
+
+		return
+	}
+
+	// Webhook created
+	w.Header().Set("Location", webhook.URI)
+
+	response := getWebhookBody{Webhook: webhook}
+	compress := strings.Contains(r.Header.Get("Accept-Encoding"), "gzip")
+
+	utils.SendJSONResponse(w, http.StatusCreated, compress, response)
+}
+
+// delete is a DELETE route for removing a webhook and its deliveries
+func (*webhookHandler) delete(w http.ResponseWriter, r *http.Request) {
+	urlPath := strings.Split(r.URL.EscapedPath(), "/")
+	if len(urlPath) != 4 {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, "Webhook ID must be a positive integer")
+
+		return
+	}
+
+	webhookID, err := strconv.ParseUint(urlPath[3], 10, 32)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, "Webhook ID must be a positive integer")
+
+		return
+	}
+
+	found, err := controllers.DeleteWebhook(uint(webhookID))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+
+		fmt.Fprint(w, http.StatusText(http.StatusInternalServerError))
+
+		return
+	}
+
+	if !found {
+		w.WriteHeader(http.StatusNotFound)
+
+		fmt.Fprint(w, "No webhook found")
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
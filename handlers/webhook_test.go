@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestWebhooksHandler(t *testing.T) {
+	os.Setenv("ADMIN_TOKEN", "test")
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		token      string
+		body       string
+		wantStatus int
+	}{
+		{
+			name: "Rejects a request without the admin token",
+			method: "GET",
+			path: "/api/webhooks",
+			token: "wrong",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "Rejects a body which isn't JSON",
+			method: "POST",
+			path: "/api/webhooks",
+			token: "test",
+			body: "https://ci.example.com/hooks/bus",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Rejects an unknown field",
+			method: "POST",
+			path: "/api/webhooks",
+			token: "test",
+			body: `{"URL": "https://ci.example.com/hooks/bus", "Secret": "abc"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Rejects a relative URL",
+			method: "POST",
+			path: "/api/webhooks",
+			token: "test",
+			body: `{"URL": "/hooks/bus"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Rejects a URL which isn't http",
+			method: "POST",
+			path: "/api/webhooks",
+			token: "test",
+			body: `{"URL": "ftp://ci.example.com/hooks/bus"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Rejects an unknown type",
+			method: "POST",
+			path: "/api/webhooks",
+			token: "test",
+			body: `{"URL": "https://ci.example.com/hooks/bus", "Types": ["UPDATE BUS STOPS"]}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Rejects a status which isn't final",
+			method: "POST",
+			path: "/api/webhooks",
+			token: "test",
+			body: `{"URL": "https://ci.example.com/hooks/bus", "Statuses": ["RUNNING"]}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Rejects a webhook ID which isn't a number",
+			method: "GET",
+			path: "/api/webhooks/abc/deliveries",
+			token: "test",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Rejects an unknown path",
+			method: "GET",
+			path: "/api/webhooks/1/attempts",
+			token: "test",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "Rejects deleting without a webhook ID",
+			method: "DELETE",
+			path: "/api/webhooks",
+			token: "test",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Accept", "*/*")
+			req.Header.Set("Authorization", "Bearer " + tt.token)
+
+			responseRecorder := httptest.NewRecorder()
+			handler := http.HandlerFunc(Webhooks)
+
+			handler.ServeHTTP(responseRecorder, req)
+
+			if status := responseRecorder.Code; status != tt.wantStatus {
+				t.Errorf("handler returned wrong status code: got %v want %v", status, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -5,97 +5,387 @@ import (
 	"time"
 	"fmt"
 	"errors"
+	"encoding/json"
 	"log"
+	"os"
+	"strings"
 	"database/sql"
 	_ "github.com/lib/pq"
 )
 
+// BackgroundJob is a long running import or export. A QUEUED job runs at RunAt
+// and is retried until it has run MaxAttempts times. Error is the reason a
+// FAILED job failed, or the last attempt of a retried job failed, and Result
+// summarises the rows it wrote
 type BackgroundJob struct {
+	ID          uint
+	URI         string
+	Type        string
+	Status      string
+	Parameters  json.RawMessage `json:",omitempty"`
+	UniqueKey   string          `json:",omitempty"`
+	Attempts    uint
+	MaxAttempts uint
+	RunAt       time.Time
+	Progress    BackgroundJobProgress
+	Error       string                 `json:",omitempty"`
+	Result      BackgroundJobResult    `json:",omitempty"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Datasets    []BackgroundJobDataset `json:",omitempty"`
+	Errors      []ImportError          `json:",omitempty"`
+}
+
+// BackgroundJobProgress is how far through a job is. Phase is what the job is
+// currently doing, such as download, unzip, parse or write, and Processed is how
+// many of the Total items of the phase are done. Total is 0 when the items of a
+// phase aren't counted
+type BackgroundJobProgress struct {
+	Phase     string
+	Processed uint
+	Total     uint
+}
+
+// BackgroundJobResult is the number of each kind of row written by a job, such
+// as BusStops or Trips
+type BackgroundJobResult map[string]uint
+
+// BackgroundJobDataset is the progress of a single timetable dataset imported
+// by a background job
+type BackgroundJobDataset struct {
 	ID        uint
-	URI       string
-	Type      string
 	Status    string
-	CreatedAt time.Time
 	UpdatedAt time.Time
 }
 
-const selectRunningJob string = "SELECT id FROM background_job WHERE type = $1 AND status = 'RUNNING'"
-const insertNewJob string = "INSERT INTO background_job(type) VALUES($1) RETURNING id, created_at"
-const selectJob string = "SELECT type, status, created_at, updated_at FROM background_job WHERE id = $1"
-const updateJob string = "UPDATE background_job SET status = $1, updated_at = NOW() WHERE id = $2"
+// BackgroundJobTypeSchema describes a type of background job which can be
+// queued. Parameters are the JSON types of the parameters it is queued with by
+// name, Concurrency is ONE when only one job of the type can be queued or
+// running at once, and Timeout is how long it can run for
+type BackgroundJobTypeSchema struct {
+	Name        string
+	Parameters  map[string]string
+	Concurrency string
+	Timeout     string
+	MaxAttempts uint
+}
+
+// BackgroundJobStatuses are the statuses of a background job
+var BackgroundJobStatuses = []string{"QUEUED", "RUNNING", "COMPLETE", "FAILED", "CANCELLED"}
+
+// BackgroundJobFilter filters listed background jobs. Empty fields aren't
+// filtered on
+type BackgroundJobFilter struct {
+	Type   string
+	Status string
+	Since  time.Time
+}
+
+// JobLease is how long a RUNNING job can go without a heartbeat before the
+// process running it is assumed to have stopped
+const JobLease = 2 * time.Minute
+
+// createJobAttempts is how many times a job is inserted while the active job
+// which stopped it being inserted keeps finishing before it can be selected
+const createJobAttempts = 5
+
+// expiredJobReason is the error of a job failed or requeued because its lease
+// expired
+const expiredJobReason = "Job stopped sending heartbeats, so the process running it is assumed to have stopped"
+
+const jobColumns string = "id, type, status, parameters, unique_key, attempts, max_attempts, run_at, phase, processed, total, error, result, created_at, updated_at"
+const selectActiveJob string = "SELECT " + jobColumns + " FROM background_job WHERE type = $1 AND unique_key = $2 AND status IN ('QUEUED', 'RUNNING')"
+const insertNewJob string = `INSERT INTO background_job(type, parameters, unique_key, max_attempts) VALUES($1, $2, $3, $4)
+ON CONFLICT (type, unique_key) WHERE status IN ('QUEUED', 'RUNNING') DO NOTHING RETURNING ` + jobColumns
+const selectJob string = "SELECT " + jobColumns + " FROM background_job WHERE id = $1"
+const claimJob string = `UPDATE background_job SET (status, attempts, heartbeat_at, updated_at) = ('RUNNING', attempts + 1, NOW(), NOW())
+WHERE id = (
+	SELECT id FROM background_job WHERE status = 'QUEUED' AND NOT cancel_requested AND run_at <= NOW()
+	ORDER BY run_at, id LIMIT 1 FOR UPDATE SKIP LOCKED
+) RETURNING ` + jobColumns
+const resetJobAttempt string = `WITH import_errors AS (DELETE FROM timetable_import_error WHERE job_id = $1)
+UPDATE background_job_dataset SET (status, updated_at) = ('PENDING', NOW()) WHERE job_id = $1`
+const updateJobProgress string = "UPDATE background_job SET (phase, processed, total, updated_at) = ($1, $2, $3, NOW()) WHERE id = $4 AND status = 'RUNNING' AND attempts = $5"
+const heartbeatJob string = "UPDATE background_job SET heartbeat_at = NOW() WHERE id = $1 AND status = 'RUNNING' AND attempts = $2"
+const expireJobs string = `UPDATE background_job
+SET (status, error, run_at, updated_at) = (CASE WHEN cancel_requested THEN 'CANCELLED' WHEN attempts < max_attempts THEN 'QUEUED' ELSE 'FAILED' END::status, $1, NOW(), NOW())
+WHERE status = 'RUNNING' AND heartbeat_at < NOW() - make_interval(secs => $2) RETURNING ` + jobColumns
+const retryJob string = "UPDATE background_job SET (status, error, run_at, updated_at) = ('QUEUED', $1, NOW() + make_interval(secs => $2), NOW()) WHERE id = $3 AND status = 'RUNNING' AND attempts = $4 AND NOT cancel_requested"
+const finishJob string = "UPDATE background_job SET (status, error, result, updated_at) = ($1, $2, $3, NOW()) WHERE id = $4 AND status = 'RUNNING' AND attempts = $5"
+const cancelJob string = "UPDATE background_job SET (status, cancel_requested, updated_at) = ('CANCELLED', TRUE, NOW()) WHERE id = $1 AND status IN ('QUEUED', 'RUNNING')"
+const requestJobCancel string = "UPDATE background_job SET cancel_requested = TRUE WHERE id = $1 AND status IN ('QUEUED', 'RUNNING')"
+const selectJobDatasets string = "SELECT dataset_id, status, updated_at FROM background_job_dataset WHERE job_id = $1 ORDER BY dataset_id"
+const insertJobDataset string = "INSERT INTO background_job_dataset(job_id, dataset_id) VALUES($1, $2) ON CONFLICT DO NOTHING"
+const selectJobImportErrors string = "SELECT dataset_id, file_name, element_id, reason FROM timetable_import_error WHERE job_id = $1 ORDER BY id"
+const insertJobImportError string = "INSERT INTO timetable_import_error(job_id, dataset_id, file_name, element_id, reason) VALUES($1, $2, $3, $4, $5)"
+const updateJobDataset string = "UPDATE background_job_dataset SET status = $1, updated_at = NOW() WHERE job_id = $2 AND dataset_id = $3"
+
+// CreateBackgroundJob queues a job with its parameters, which is run up to
+// maxAttempts times. Only one job of a type with the same uniqueKey can be
+// QUEUED or RUNNING at once, so when there already is one it is returned
+// instead and created is false. A job without a uniqueKey is always queued
+func CreateBackgroundJob(jobType string, parameters interface{}, uniqueKey sql.NullString, maxAttempts uint, db *sql.DB) (BackgroundJob, bool, error) {
+	// lib/pq sends []byte as bytea, so the parameters are sent as a string
+	var parametersJSON sql.NullString
+	if parameters != nil {
+		encoded, err := json.Marshal(parameters)
+		if err != nil {
+			log.Println("Failed to marshal background job parameters", err)
+			return BackgroundJob{}, false, err
+		}
+
+		parametersJSON = sql.NullString{String: string(encoded), Valid: true}
+	}
 
-func CreateBackgroundJob(jobType string, db *sql.DB) (BackgroundJob, error) {
 	ctx := context.Background()
 	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
 		log.Println("Couldn't create database transaction")
-		return BackgroundJob{}, err
+		return BackgroundJob{}, false, err
 	}
 
-	var runningJobID uint
-	jobTypeRunning := true
-
-	if err := tx.QueryRow(selectRunningJob, jobType).Scan(&runningJobID); err != nil {
-		if err != sql.ErrNoRows {
-			log.Println("Couldn't select running jobs", err)
+	// the unique index on active jobs makes the insert do nothing when there
+	// already is an active job with the key, even if it is being queued by
+	// another request at the same time. The active job can finish before it is
+	// selected, so the insert is tried again when there no longer is one
+	for attempt := 1; ; attempt++ {
+		job, err := scanBackgroundJob(tx.QueryRow(insertNewJob, jobType, parametersJSON, uniqueKey, maxAttempts))
+		if err == nil {
+			return commitCreatedJob(tx, job, true)
+		} else if err != sql.ErrNoRows {
+			log.Println("Error inserting background job to db", err)
 			tx.Rollback()
-			return BackgroundJob{}, errors.New("Couldn't select running jobs")
+			return BackgroundJob{}, false, errors.New("Error inserting into background_job")
 		}
 
-		jobTypeRunning = false
+		job, err = scanBackgroundJob(tx.QueryRow(selectActiveJob, jobType, uniqueKey))
+		if err == nil {
+			return commitCreatedJob(tx, job, false)
+		} else if err != sql.ErrNoRows || attempt == createJobAttempts {
+			log.Println("Couldn't select active job", err)
+			tx.Rollback()
+			return BackgroundJob{}, false, errors.New("Couldn't select active job")
+		}
 	}
+}
 
-	if jobTypeRunning {
-		log.Println("Job type running")
+// commitCreatedJob commits the transaction a job was created or selected in
+func commitCreatedJob(tx *sql.Tx, job BackgroundJob, created bool) (BackgroundJob, bool, error) {
+	if err := tx.Commit(); err != nil {
+		log.Println("Transaction failed", err)
 		tx.Rollback()
-		return BackgroundJob{}, errors.New("Job type running")
+		return BackgroundJob{}, false, errors.New("Transaction failed")
+	}
+
+	return job, created, nil
+}
+
+// ClaimBackgroundJob starts the next QUEUED job due to run, skipping jobs being
+// claimed by other workers. Import errors of a previous attempt are removed and
+// its datasets are PENDING again. Returns sql.ErrNoRows when no job is due
+func ClaimBackgroundJob(db *sql.DB) (BackgroundJob, error) {
+	tx, err := db.BeginTx(context.Background(), nil)
+	if err != nil {
+		log.Println("Couldn't create database transaction", err)
+		return BackgroundJob{}, err
 	}
 
-	var jobID uint
-	var createdAt time.Time
+	job, err := scanBackgroundJob(tx.QueryRow(claimJob))
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Println("Error claiming background job", err)
+		}
 
-	if err := tx.QueryRow(insertNewJob, jobType).Scan(&jobID, &createdAt); err != nil {
-		log.Println("Error inserting background job to db", err)
 		tx.Rollback()
-		return BackgroundJob{}, errors.New("Error inserting into background_job")
+		return BackgroundJob{}, err
+	}
+
+	if job.Attempts > 1 {
+		if _, err := tx.Exec(resetJobAttempt, job.ID); err != nil {
+			log.Println("Error resetting the previous attempt of background job", err)
+			tx.Rollback()
+			return BackgroundJob{}, err
+		}
 	}
 
 	if err := tx.Commit(); err != nil {
 		log.Println("Transaction failed", err)
-		tx.Rollback()
-		return BackgroundJob{}, errors.New("Transaction failed")
+		return BackgroundJob{}, err
 	}
 
-	return BackgroundJob{
-		ID: jobID,
-		URI: fmt.Sprintf("/api/job/%v", jobID),
-		Type: jobType,
-		Status: "RUNNING",
-		CreatedAt: createdAt,
-		UpdatedAt: createdAt,
-	}, nil
+	return job, nil
 }
 
-func UpdateBackgroundJob(id uint, status string, db *sql.DB) error {
-	if _, err := db.Query(updateJob, status, id); err != nil {
+// RetryBackgroundJob queues a running job to run again after delay, with the
+// reason its last attempt failed. Nothing is changed unless attempt is the
+// attempt the job is still running, so a worker which lost its lease can't
+// queue a job another worker has claimed since, or when the job has been asked
+// to cancel. queued is false when the job wasn't queued again
+func RetryBackgroundJob(id uint, attempt uint, reason string, delay time.Duration, db *sql.DB) (bool, error) {
+	updated, err := db.Exec(retryJob, reason, delay.Seconds(), id, attempt)
+	if err != nil {
+		log.Println("Error retrying background job in db", err)
+		return false, errors.New("Error updating background_job")
+	}
+
+	rowsAffected, err := updated.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected > 0, nil
+}
+
+// FinishBackgroundJob sets the final status of a running job, with the reason a
+// FAILED job failed, and saves its result. A job which didn't complete keeps the
+// result of the work it did before it stopped. finished is false when attempt
+// isn't the attempt the job is running, such as when it was cancelled by
+// another process or its lease expired and it was claimed again
+func FinishBackgroundJob(id uint, attempt uint, status string, reason string, result BackgroundJobResult, db *sql.DB) (bool, error) {
+	// lib/pq sends []byte as bytea, so the result is sent as a string
+	var resultJSON sql.NullString
+	if len(result) > 0 {
+		encoded, err := json.Marshal(result)
+		if err != nil {
+			log.Println("Failed to marshal background job result", err)
+			return false, err
+		}
+
+		resultJSON = sql.NullString{String: string(encoded), Valid: true}
+	}
+
+	updated, err := db.Exec(finishJob, status, reason, resultJSON, id, attempt)
+	if err != nil {
 		log.Println("Error updating background job in db", err)
+		return false, errors.New("Error updating background_job")
+	}
+
+	rowsAffected, err := updated.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected > 0, nil
+}
+
+// RequestBackgroundJobCancel records that a queued or running job has been asked
+// to cancel, so it is never claimed or queued again even if it isn't cancelled
+// straight away
+func RequestBackgroundJobCancel(id uint, db *sql.DB) error {
+	if _, err := db.Exec(requestJobCancel, id); err != nil {
+		log.Println("Error requesting background job cancel in db", err)
 		return errors.New("Error updating background_job")
 	}
 
 	return nil
 }
 
+// CancelBackgroundJob cancels a queued or running job, whichever attempt it is
+// on. cancelled is false when the job had already finished
+func CancelBackgroundJob(id uint, db *sql.DB) (bool, error) {
+	updated, err := db.Exec(cancelJob, id)
+	if err != nil {
+		log.Println("Error cancelling background job in db", err)
+		return false, errors.New("Error updating background_job")
+	}
+
+	rowsAffected, err := updated.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected > 0, nil
+}
+
+// HeartbeatBackgroundJob renews the lease of attempt of a running job. running
+// is false when the job isn't RUNNING that attempt, such as when it has been
+// cancelled by another process, or its lease expired and it was queued again
+func HeartbeatBackgroundJob(id uint, attempt uint, db *sql.DB) (bool, error) {
+	result, err := db.Exec(heartbeatJob, id, attempt)
+	if err != nil {
+		log.Println("Error updating background job heartbeat in db", err)
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected > 0, nil
+}
+
+// ExpireBackgroundJobs requeues every RUNNING job whose lease has expired, or
+// fails it when it has no attempts left and cancels it when it was asked to
+// cancel, and returns them
+func ExpireBackgroundJobs(db sqlDB) ([]BackgroundJob, error) {
+	rows, err := db.Query(expireJobs, expiredJobReason, JobLease.Seconds())
+	if err != nil {
+		log.Println("Error expiring background jobs in db", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := make([]BackgroundJob, 0)
+
+	for rows.Next() {
+		job, err := scanBackgroundJob(rows)
+		if err != nil {
+			log.Println("Error scanning expired background job", err)
+			return nil, err
+		}
+
+		jobs = append(jobs, job)
+	}
+
+	return jobs, rows.Err()
+}
+
 type sqlDB interface {
 	QueryRow(query string, args ...interface{}) *sql.Row
+	Query(query string, args ...interface{}) (*sql.Rows, error)
 }
 
-func GetBackgroundJob(jobID uint, db sqlDB) (BackgroundJob, error) {
-	var jobType string
-	var status string
-	var createdAt time.Time
-	var updatedAt time.Time
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
 
-	err := db.QueryRow(selectJob, jobID).Scan(&jobType, &status, &createdAt, &updatedAt)
+// scanBackgroundJob scans a row of jobColumns
+func scanBackgroundJob(row rowScanner) (BackgroundJob, error) {
+	var job BackgroundJob
+	var parametersJSON []byte
+	var uniqueKey sql.NullString
+	var resultJSON []byte
+
+	err := row.Scan(
+		&job.ID, &job.Type, &job.Status, &parametersJSON, &uniqueKey, &job.Attempts, &job.MaxAttempts,
+		&job.RunAt, &job.Progress.Phase, &job.Progress.Processed, &job.Progress.Total,
+		&job.Error, &resultJSON, &job.CreatedAt, &job.UpdatedAt,
+	)
+	if err != nil {
+		return BackgroundJob{}, err
+	}
+
+	if parametersJSON != nil {
+		job.Parameters = json.RawMessage(parametersJSON)
+	}
+
+	job.UniqueKey = uniqueKey.String
+
+	if resultJSON != nil {
+		if err := json.Unmarshal(resultJSON, &job.Result); err != nil {
+			log.Println("Error unmarshalling background job result", err)
+			return BackgroundJob{}, err
+		}
+	}
+
+	job.URI = fmt.Sprintf("/api/job/%v", job.ID)
+
+	return job, nil
+}
+
+func GetBackgroundJob(jobID uint, db sqlDB) (BackgroundJob, error) {
+	job, err := scanBackgroundJob(db.QueryRow(selectJob, jobID))
 	if err != nil {
 		if err != sql.ErrNoRows {
 			log.Println("Error getting background job from db", err)
@@ -104,14 +394,248 @@ func GetBackgroundJob(jobID uint, db sqlDB) (BackgroundJob, error) {
 		return BackgroundJob{}, err
 	}
 
-	job := BackgroundJob{
-		ID: jobID,
-		URI: fmt.Sprintf("/api/job/%v", jobID),
-		Type: jobType,
-		Status: status,
-		CreatedAt: createdAt,
-		UpdatedAt: updatedAt,
+	datasets, err := getBackgroundJobDatasets(jobID, db)
+	if err != nil {
+		return BackgroundJob{}, err
+	}
+
+	importErrors, err := getBackgroundJobImportErrors(jobID, db)
+	if err != nil {
+		return BackgroundJob{}, err
 	}
 
+	job.Datasets = datasets
+	job.Errors = importErrors
+
 	return job, nil
+}
+
+const selectJobs string = "SELECT " + jobColumns + " FROM background_job"
+
+// buildSelectJobsQuery builds the query of a page of jobs matching a filter,
+// newest first
+func buildSelectJobsQuery(filter BackgroundJobFilter, limit uint, offset uint) (string, []interface{}) {
+	conditions := make([]string, 0)
+	args := make([]interface{}, 0)
+
+	if filter.Type != "" {
+		args = append(args, filter.Type)
+		conditions = append(conditions, fmt.Sprintf("type = $%d", len(args)))
+	}
+
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+
+	if !filter.Since.IsZero() {
+		// created_at is stored in UTC without a time zone
+		args = append(args, filter.Since.UTC())
+		conditions = append(conditions, fmt.Sprintf("created_at >= $%d", len(args)))
+	}
+
+	query := selectJobs
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	args = append(args, limit, offset)
+	query += fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT $%d OFFSET $%d", len(args) - 1, len(args))
+
+	return query, args
+}
+
+// GetBackgroundJobs gets a page of the jobs matching a filter, newest first.
+// The jobs don't include their datasets or errors
+func GetBackgroundJobs(filter BackgroundJobFilter, limit uint, offset uint, db sqlDB) ([]BackgroundJob, error) {
+	query, args := buildSelectJobsQuery(filter, limit, offset)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		log.Println("Error getting background jobs from db", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := make([]BackgroundJob, 0)
+
+	for rows.Next() {
+		job, err := scanBackgroundJob(rows)
+		if err != nil {
+			log.Println("Error scanning background job", err)
+			return nil, err
+		}
+
+		jobs = append(jobs, job)
+	}
+
+	return jobs, rows.Err()
+}
+
+func getBackgroundJobDatasets(jobID uint, db sqlDB) ([]BackgroundJobDataset, error) {
+	rows, err := db.Query(selectJobDatasets, jobID)
+	if err != nil {
+		log.Println("Error getting background job datasets from db", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	datasets := make([]BackgroundJobDataset, 0)
+
+	for rows.Next() {
+		var dataset BackgroundJobDataset
+
+		if err := rows.Scan(&dataset.ID, &dataset.Status, &dataset.UpdatedAt); err != nil {
+			log.Println("Error scanning background job dataset", err)
+			return nil, err
+		}
+
+		datasets = append(datasets, dataset)
+	}
+
+	return datasets, rows.Err()
+}
+
+func getBackgroundJobImportErrors(jobID uint, db sqlDB) ([]ImportError, error) {
+	rows, err := db.Query(selectJobImportErrors, jobID)
+	if err != nil {
+		log.Println("Error getting background job import errors from db", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	importErrors := make([]ImportError, 0)
+
+	for rows.Next() {
+		var importError ImportError
+
+		if err := rows.Scan(&importError.DatasetID, &importError.FileName, &importError.ElementID, &importError.Reason); err != nil {
+			log.Println("Error scanning background job import error", err)
+			return nil, err
+		}
+
+		importErrors = append(importErrors, importError)
+	}
+
+	return importErrors, rows.Err()
+}
+
+// JobDatasets records the progress of the job JobID and each dataset it
+// imports. Progress is only recorded while the job is running Attempt
+type JobDatasets struct {
+	JobID   uint
+	Attempt uint
+}
+type JobDataset interface {
+	UpdateProgress(progress BackgroundJobProgress) error
+	AddDatasets(datasetIDs []uint) error
+	UpdateDataset(datasetID uint, status string) error
+	AddImportErrors(importErrors []ImportError) error
+}
+
+// UpdateProgress sets the current phase of the job and how much of it is done
+func (jobDatasets *JobDatasets) UpdateProgress(progress BackgroundJobProgress) error {
+	connectionString := os.Getenv("DATABASE_URL")
+
+	db, err := sql.Open("postgres", connectionString)
+	if err != nil {
+		log.Println("Failed to connect to db", err)
+
+		return err
+	}
+	defer db.Close()
+
+	_, err = db.Exec(updateJobProgress, progress.Phase, progress.Processed, progress.Total, jobDatasets.JobID, jobDatasets.Attempt)
+	if err != nil {
+		log.Println("Error updating background job progress in db", err)
+		return errors.New("Error updating background_job")
+	}
+
+	return nil
+}
+
+// AddDatasets adds the datasets to the job as PENDING
+func (jobDatasets *JobDatasets) AddDatasets(datasetIDs []uint) error {
+	connectionString := os.Getenv("DATABASE_URL")
+
+	db, err := sql.Open("postgres", connectionString)
+	if err != nil {
+		log.Println("Failed to connect to db", err)
+
+		return err
+	}
+	defer db.Close()
+
+	stmt, err := db.Prepare(insertJobDataset)
+	if err != nil {
+		log.Println("Failed to prepare insert job dataset statement", err)
+
+		return err
+	}
+	defer stmt.Close()
+
+	for _, datasetID := range datasetIDs {
+		if _, err := stmt.Exec(jobDatasets.JobID, datasetID); err != nil {
+			log.Println("Failed to execute insert job dataset statement", err)
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UpdateDataset sets the status of a dataset within the job
+func (jobDatasets *JobDatasets) UpdateDataset(datasetID uint, status string) error {
+	connectionString := os.Getenv("DATABASE_URL")
+
+	db, err := sql.Open("postgres", connectionString)
+	if err != nil {
+		log.Println("Failed to connect to db", err)
+
+		return err
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(updateJobDataset, status, jobDatasets.JobID, datasetID); err != nil {
+		log.Println("Error updating background job dataset in db", err)
+		return errors.New("Error updating background_job_dataset")
+	}
+
+	return nil
+}
+
+// AddImportErrors saves the files and records skipped by the job
+func (jobDatasets *JobDatasets) AddImportErrors(importErrors []ImportError) error {
+	connectionString := os.Getenv("DATABASE_URL")
+
+	db, err := sql.Open("postgres", connectionString)
+	if err != nil {
+		log.Println("Failed to connect to db", err)
+
+		return err
+	}
+	defer db.Close()
+
+	stmt, err := db.Prepare(insertJobImportError)
+	if err != nil {
+		log.Println("Failed to prepare insert import error statement", err)
+
+		return err
+	}
+	defer stmt.Close()
+
+	for _, importError := range importErrors {
+		_, err := stmt.Exec(
+			jobDatasets.JobID, importError.DatasetID, importError.FileName,
+			importError.ElementID, importError.Reason,
+		)
+		if err != nil {
+			log.Println("Failed to execute insert import error statement", err)
+
+			return err
+		}
+	}
+
+	return nil
 }
\ No newline at end of file
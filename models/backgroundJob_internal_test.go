@@ -0,0 +1,57 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_buildSelectJobsQuery(t *testing.T) {
+	since := time.Date(2021, 4, 6, 22, 0, 0, 0, time.FixedZone("BST", 3600))
+
+	type args struct {
+		filter BackgroundJobFilter
+		limit  uint
+		offset uint
+	}
+	tests := []struct {
+		name     string
+		args     args
+		want     string
+		wantArgs []interface{}
+	}{
+		{
+			name: "Lists every job without a filter",
+			args: args{limit: 26, offset: 0},
+			want: "SELECT id, type, status, parameters, unique_key, attempts, max_attempts, run_at, phase, processed, total, error, result, created_at, updated_at FROM background_job ORDER BY created_at DESC, id DESC LIMIT $1 OFFSET $2",
+			wantArgs: []interface{}{uint(26), uint(0)},
+		},
+		{
+			name: "Filters by status",
+			args: args{filter: BackgroundJobFilter{Status: "FAILED"}, limit: 11, offset: 10},
+			want: "SELECT id, type, status, parameters, unique_key, attempts, max_attempts, run_at, phase, processed, total, error, result, created_at, updated_at FROM background_job WHERE status = $1 ORDER BY created_at DESC, id DESC LIMIT $2 OFFSET $3",
+			wantArgs: []interface{}{"FAILED", uint(11), uint(10)},
+		},
+		{
+			name: "Filters by type, status and since in UTC",
+			args: args{
+				filter: BackgroundJobFilter{Type: "UPDATE ALL PUBLISHED ROUTES", Status: "COMPLETE", Since: since},
+				limit: 26,
+				offset: 0,
+			},
+			want: "SELECT id, type, status, parameters, unique_key, attempts, max_attempts, run_at, phase, processed, total, error, result, created_at, updated_at FROM background_job WHERE type = $1 AND status = $2 AND created_at >= $3 ORDER BY created_at DESC, id DESC LIMIT $4 OFFSET $5",
+			wantArgs: []interface{}{"UPDATE ALL PUBLISHED ROUTES", "COMPLETE", since.UTC(), uint(26), uint(0)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotArgs := buildSelectJobsQuery(tt.args.filter, tt.args.limit, tt.args.offset)
+			if got != tt.want {
+				t.Errorf("buildSelectJobsQuery() got = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(gotArgs, tt.wantArgs) {
+				t.Errorf("buildSelectJobsQuery() gotArgs = %v, want %v", gotArgs, tt.wantArgs)
+			}
+		})
+	}
+}
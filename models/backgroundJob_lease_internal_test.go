@@ -0,0 +1,202 @@
+package models
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// leaseDriver is a database with a single background_job row, which runs the
+// UPDATE statements of jobs by matching their WHERE conditions against the row
+// and setting the columns it has
+type leaseDriver struct {
+	sync.Mutex
+	row map[string]string
+}
+
+func (fake *leaseDriver) Open(name string) (driver.Conn, error) {
+	return leaseConn{fake}, nil
+}
+
+type leaseConn struct {
+	fake *leaseDriver
+}
+
+func (conn leaseConn) Prepare(query string) (driver.Stmt, error) {
+	return leaseStmt{fake: conn.fake, query: query}, nil
+}
+
+func (conn leaseConn) Close() error {
+	return nil
+}
+
+func (conn leaseConn) Begin() (driver.Tx, error) {
+	return nil, fmt.Errorf("Transactions aren't supported")
+}
+
+type leaseStmt struct {
+	fake  *leaseDriver
+	query string
+}
+
+func (stmt leaseStmt) Close() error {
+	return nil
+}
+
+func (stmt leaseStmt) NumInput() int {
+	return -1
+}
+
+func (stmt leaseStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, fmt.Errorf("Queries aren't supported")
+}
+
+// leaseValue is the value of a $n parameter, quoted string or boolean in a
+// statement
+func leaseValue(value string, args []driver.Value) (string, bool) {
+	if value == "TRUE" || value == "FALSE" {
+		return strings.ToLower(value), true
+	}
+
+	if strings.HasPrefix(value, "$") {
+		index, err := strconv.Atoi(value[1:])
+		if err != nil || index > len(args) {
+			return "", false
+		}
+
+		return fmt.Sprint(args[index-1]), true
+	}
+
+	if strings.HasPrefix(value, "'") {
+		return strings.Trim(value, "'"), true
+	}
+
+	return "", false
+}
+
+func (stmt leaseStmt) Exec(args []driver.Value) (driver.Result, error) {
+	stmt.fake.Lock()
+	defer stmt.fake.Unlock()
+
+	parts := strings.SplitN(stmt.query, " WHERE ", 2)
+	for _, condition := range strings.Split(parts[1], " AND ") {
+		if inIndex := strings.Index(condition, " IN ("); inIndex >= 0 {
+			column := condition[:inIndex]
+			values := strings.Split(strings.TrimSuffix(condition[inIndex+5:], ")"), ", ")
+
+			matches := false
+			for _, value := range values {
+				if expected, _ := leaseValue(value, args); expected == stmt.fake.row[column] {
+					matches = true
+				}
+			}
+
+			if !matches {
+				return driver.RowsAffected(0), nil
+			}
+
+			continue
+		}
+
+		if strings.HasPrefix(condition, "NOT ") {
+			if stmt.fake.row[strings.TrimPrefix(condition, "NOT ")] == "true" {
+				return driver.RowsAffected(0), nil
+			}
+
+			continue
+		}
+
+		comparison := strings.SplitN(condition, " = ", 2)
+		if expected, _ := leaseValue(comparison[1], args); expected != stmt.fake.row[comparison[0]] {
+			return driver.RowsAffected(0), nil
+		}
+	}
+
+	assignments := strings.SplitN(strings.TrimPrefix(parts[0], "UPDATE background_job SET "), " = ", 2)
+	columns := strings.Split(strings.Trim(assignments[0], "()"), ", ")
+	values := strings.Split(strings.Trim(assignments[1], "()"), ", ")
+
+	for index, column := range columns {
+		if value, ok := leaseValue(values[index], args); ok {
+			if _, ok := stmt.fake.row[column]; ok {
+				stmt.fake.row[column] = value
+			}
+		}
+	}
+
+	return driver.RowsAffected(1), nil
+}
+
+var leaseDatabase = &leaseDriver{}
+
+func init() {
+	sql.Register("backgroundJobLease", leaseDatabase)
+}
+
+func Test_backgroundJobLease(t *testing.T) {
+	db, err := sql.Open("backgroundJobLease", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// the first attempt stopped sending heartbeats, so its job was queued again
+	// and claimed by another worker as the second attempt
+	leaseDatabase.row = map[string]string{"id": "1", "status": "RUNNING", "attempts": "2", "error": ""}
+
+	if running, err := HeartbeatBackgroundJob(1, 1, db); err != nil || running {
+		t.Errorf("HeartbeatBackgroundJob() of the expired attempt = %v, %v, want false", running, err)
+	}
+
+	if finished, err := FinishBackgroundJob(1, 1, "CANCELLED", "", nil, db); err != nil || finished {
+		t.Errorf("FinishBackgroundJob() of the expired attempt = %v, %v, want false", finished, err)
+	}
+
+	if queued, err := RetryBackgroundJob(1, 1, "Timed out", time.Minute, db); err != nil || queued {
+		t.Errorf("RetryBackgroundJob() of the expired attempt = %v, %v, want false", queued, err)
+	}
+
+	if leaseDatabase.row["status"] != "RUNNING" || leaseDatabase.row["error"] != "" {
+		t.Errorf("Expired attempt changed the job to %v", leaseDatabase.row)
+	}
+
+	if running, err := HeartbeatBackgroundJob(1, 2, db); err != nil || !running {
+		t.Errorf("HeartbeatBackgroundJob() of the claimed attempt = %v, %v, want true", running, err)
+	}
+
+	if finished, err := FinishBackgroundJob(1, 2, "COMPLETE", "", nil, db); err != nil || !finished {
+		t.Errorf("FinishBackgroundJob() of the claimed attempt = %v, %v, want true", finished, err)
+	}
+
+	if leaseDatabase.row["status"] != "COMPLETE" {
+		t.Errorf("FinishBackgroundJob() status = %v, want COMPLETE", leaseDatabase.row["status"])
+	}
+
+	if cancelled, err := CancelBackgroundJob(1, db); err != nil || cancelled {
+		t.Errorf("CancelBackgroundJob() of a finished job = %v, %v, want false", cancelled, err)
+	}
+
+	// the job was asked to cancel while its third attempt was failing
+	leaseDatabase.row = map[string]string{"id": "1", "status": "RUNNING", "attempts": "3", "error": "", "cancel_requested": "false"}
+
+	if err := RequestBackgroundJobCancel(1, db); err != nil {
+		t.Errorf("RequestBackgroundJobCancel() error = %v", err)
+	}
+
+	if queued, err := RetryBackgroundJob(1, 3, "Timed out", time.Minute, db); err != nil || queued {
+		t.Errorf("RetryBackgroundJob() of a job asked to cancel = %v, %v, want false", queued, err)
+	}
+
+	if finished, err := FinishBackgroundJob(1, 3, "CANCELLED", "", nil, db); err != nil || !finished {
+		t.Errorf("FinishBackgroundJob() of a job asked to cancel = %v, %v, want true", finished, err)
+	}
+
+	if leaseDatabase.row["status"] != "CANCELLED" {
+		t.Errorf("FinishBackgroundJob() status = %v, want CANCELLED", leaseDatabase.row["status"])
+	}
+}
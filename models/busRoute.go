@@ -1,10 +1,12 @@
 package models
 
 import (
+	"context"
 	"os"
 	"fmt"
 	"database/sql"
-	_ "github.com/lib/pq"
+	"server/types"
+	"github.com/lib/pq"
 )
 
 // Operator
@@ -14,23 +16,31 @@ import (
 // | SCEK      | Stagecoach | Stagecoach in East Kent |
 
 // Line
-// | ID        | Name   | OperatorID    |
-// | --------- | ------ | ------------- |
-// | PK String | String | FK OperatorID |
-// | SCEK:PK...| 953    | SCEK          |
+// | ID        | Name   | OperatorID    | DatasetID    | FileName    |
+// | --------- | ------ | ------------- | ------------ | ----------- |
+// | PK String | String | FK OperatorID | FK DatasetID | String      |
+// | 2022:SC...| 953    | SCEK          | 2022         | SCEK-953... |
 
 // Journey
-// | LineID       | RouteID   | Direction        | Description |
-// | ------------ | --------- | ---------------- | ----------- |
-// | PK FK LineID | PK String | INBOUND/OUTBOUND | String      |
-// | SCEK:PK...   | RT132     | INBOUND          | Bus Stat... |
+// | LineID       | RouteID   | Direction        | Description | DatasetID    | FileName    |
+// | ------------ | --------- | ---------------- | ----------- | ------------ | ----------- |
+// | PK FK LineID | PK String | INBOUND/OUTBOUND | String      | FK DatasetID | String      |
+// | 2022:SCEK... | RT132     | INBOUND          | Bus Stat... | 2022         | SCEK-953... |
 
 // Journey Stop
-// | LineID              | RouteID               | StopNumber | BusStopID    |
-// | ------------------- | --------------------- | ---------- | ------------ |
-// | PK FK JourneyLineID | PK FK JourneyRouteID  | PK Uint    | FK BusStopID |
-// | SCEK:PK...          | RT132                 | 0          | 240098892    |
-
+// | LineID              | RouteID               | StopNumber | BusStopID    | DatasetID    |
+// | ------------------- | --------------------- | ---------- | ------------ | ------------ |
+// | PK FK JourneyLineID | PK FK JourneyRouteID  | PK Uint    | FK BusStopID | FK DatasetID |
+// | 2022:SCEK...        | RT132                 | 0          | 240098892    | 2022         |
+
+// Timetable File
+// | DatasetID    | FileName         | SchemaVersion | Revision | CreatedAt  | ModifiedAt | ImportedAt |
+// | ------------ | ---------------- | ------------- | -------- | ---------- | ---------- | ---------- |
+// | PK FK Uint   | PK String        | String        | Uint     | Timestamp  | Timestamp  | Timestamp  |
+// | 2022         | SCEK-953-953.xml | 2.4           | 67       | 2020-11... | 2021-03... | 2021-04... |
+
+// Route is a route variant of a line. A simplified route has a Path, or an
+// encoded Polyline, of its stop coordinates in place of its Stops
 type Route struct {
 	LineID       string
 	RouteID      string
@@ -41,15 +51,26 @@ type Route struct {
 	Description  string
 	Origin       string
 	Destination  string
-	Stops        []BusStop
+	Stops        []BusStop          `json:",omitempty"`
+	Path         []types.Coordinate `json:",omitempty"`
+	Polyline     string             `json:",omitempty"`
 }
 
 type BusRoutes struct {}
 type BusRoute interface {
-	InsertOperators(operators []Operator) error
-	InsertLines(lines []Line) error
-	InsertJourneys(journeys []Journey) error
-	InsertJourneyStops(journeyStops []JourneyStop) error
+	GetDataset(datasetID uint) (Dataset, bool, error)
+	ReplaceDataset(ctx context.Context, datasetID uint, timetable Timetable) ([]ImportError, error)
+}
+
+// Timetable is every operator, line, journey and journey stop within a dataset
+type Timetable struct {
+	Dataset      Dataset
+	Operators    []Operator
+	Lines        []Line
+	Journeys     []Journey
+	JourneyStops []JourneyStop
+	Trips        []Trip
+	Files        []TimetableFile
 }
 
 const getRouteByLineDirectionOperator = `SELECT
@@ -72,16 +93,18 @@ INNER JOIN journey ON journey_stop.line_id = journey.line_id AND journey_stop.ro
 INNER JOIN line ON journey_stop.line_id = line.id
 INNER JOIN operator ON line.operator_id = operator.id
 WHERE line.name=$1 AND journey.direction=$2 AND operator.id=$3
-ORDER BY journey_stop.route_id, journey_stop.stop_number`
+ORDER BY journey_stop.line_id, journey_stop.route_id, journey_stop.stop_number`
 
-func GetBusRoute(lineName string, direction string, operatorID string) (Route, error) {
+// GetBusRoute gets every route (journey pattern) of a line in a direction. Each
+// route has its own ordered stops, origin and destination
+func GetBusRoute(lineName string, direction string, operatorID string) ([]Route, error) {
 	connectionString := os.Getenv("DATABASE_URL")
 
 	db, err := sql.Open("postgres", connectionString)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "Failed to connect to db", err)
 
-		return Route{}, err
+		return nil, err
 	}
 	defer db.Close()
 
@@ -89,7 +112,7 @@ func GetBusRoute(lineName string, direction string, operatorID string) (Route, e
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "Failed to prepare select route statement", err)
 
-		return Route{}, err
+		return nil, err
 	}
 	defer stmt.Close()
 
@@ -97,46 +120,48 @@ func GetBusRoute(lineName string, direction string, operatorID string) (Route, e
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "Failed to execute select route statement", err)
 
-		return Route{}, err
+		return nil, err
 	}
 	defer rows.Close()
 
-	route := Route{}
-	busStops := make([]BusStop, 0)
+	routes := make([]Route, 0)
 
 	for rows.Next() {
 		var routeId, stopID, stopName, direction, description, lineName, lineID, operatorName, operatorShortName string
 		var longitude, latitude, bearing float32
 
-    err = rows.Scan(&routeId, &stopID, &stopName, &longitude, &latitude, &bearing, &direction, &description, &lineName, &lineID, &operatorName, &operatorShortName)
-    if err != nil {
+		err = rows.Scan(&routeId, &stopID, &stopName, &longitude, &latitude, &bearing, &direction, &description, &lineName, &lineID, &operatorName, &operatorShortName)
+		if err != nil {
 			fmt.Fprintln(os.Stderr, err)
 
-			return Route{}, err
+			return nil, err
 		}
 
-		route.LineID = lineID
-		route.RouteID = routeId
-		route.OperatorID = operatorID
-
-		if operatorName != "" {
-			route.OperatorName = operatorName
-		} else {
-			route.OperatorName = operatorShortName
-		}
-
-		route.Name = lineName
-		route.Direction = direction
-		route.Description = description
-
-		if route.Origin == "" {
-			route.Origin = stopName
+		// rows are ordered by route so a new route starts when the route changes
+		if len(routes) == 0 || routes[len(routes) - 1].LineID != lineID || routes[len(routes) - 1].RouteID != routeId {
+			route := Route{
+				LineID: lineID,
+				RouteID: routeId,
+				OperatorID: operatorID,
+				OperatorName: operatorName,
+				Name: lineName,
+				Direction: direction,
+				Description: description,
+				Origin: stopName,
+				Stops: make([]BusStop, 0),
+			}
+
+			if route.OperatorName == "" {
+				route.OperatorName = operatorShortName
+			}
+
+			routes = append(routes, route)
 		}
 
+		route := &routes[len(routes) - 1]
 		route.Destination = stopName
-
-		busStops = append(busStops, BusStop{
-			ID: stopID,
+		route.Stops = append(route.Stops, BusStop{
+			ID: TrimBusStopID(stopID),
 			Name: stopName,
 			Longitude: longitude,
 			Latitude: latitude,
@@ -144,141 +169,254 @@ func GetBusRoute(lineName string, direction string, operatorID string) (Route, e
 		})
 	}
 
-	route.Stops = busStops
-
-	return route, nil
+	return routes, rows.Err()
 }
 
-const insertOperatorSQL string = "INSERT INTO operator(id, name, short_name) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING"
+const getRoutePathsWithinBounds = `SELECT
+	journey_stop.line_id AS lineID,
+	journey_stop.route_id AS routeID,
+	line.name AS lineName,
+	journey.direction AS direction,
+	line.operator_id AS operatorID,
+	bus_stop.longitude AS longitude,
+	bus_stop.latitude AS latitude
+FROM
+	journey_stop
+INNER JOIN bus_stop ON journey_stop.bus_stop_id = bus_stop.id
+INNER JOIN journey ON journey_stop.line_id = journey.line_id AND journey_stop.route_id = journey.route_id
+INNER JOIN line ON journey_stop.line_id = line.id
+WHERE (journey_stop.line_id, journey_stop.route_id) IN (
+	SELECT DISTINCT route_stop.line_id, route_stop.route_id
+	FROM journey_stop AS route_stop
+	INNER JOIN bus_stop AS route_bus_stop ON route_stop.bus_stop_id = route_bus_stop.id
+	WHERE route_bus_stop.longitude >= $1 AND route_bus_stop.latitude >= $2 AND route_bus_stop.longitude <= $3 AND route_bus_stop.latitude <= $4
+)
+ORDER BY journey_stop.line_id, journey_stop.route_id, journey_stop.stop_number`
 
-func (BusRoutes *BusRoutes) InsertOperators(operators []Operator) error {
+// GetRoutePathsWithinBounds gets every route calling at a stop within a
+// bounding box. Each route has a Path of every stop coordinate in place of its
+// Stops, including those outside the bounding box
+func GetRoutePathsWithinBounds(minLongitude float32, minLatitude float32, maxLongitude float32, maxLatitude float32) ([]Route, error) {
 	connectionString := os.Getenv("DATABASE_URL")
 
 	db, err := sql.Open("postgres", connectionString)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "Failed to connect to db", err)
 
-		return err
+		return nil, err
 	}
 	defer db.Close()
 
-	stmt, err := db.Prepare(insertOperatorSQL)
+	rows, err := db.Query(getRoutePathsWithinBounds, minLongitude, minLatitude, maxLongitude, maxLatitude)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Failed to prepare insert operator statement", err)
+		fmt.Fprintln(os.Stderr, "Failed to execute select route paths statement", err)
 
-		return err
+		return nil, err
 	}
-	defer stmt.Close()
+	defer rows.Close()
 
-	for _, operator := range operators {
-		_, err := stmt.Exec(operator.ID, operator.Name, operator.ShortName)
+	routes := make([]Route, 0)
+
+	for rows.Next() {
+		var lineID, routeID, lineName, direction, operatorID string
+		var coordinate types.Coordinate
+
+		err = rows.Scan(&lineID, &routeID, &lineName, &direction, &operatorID, &coordinate.Longitude, &coordinate.Latitude)
 		if err != nil {
-			fmt.Fprintln(os.Stderr, "Failed to execute insert operator statement", err)
+			fmt.Fprintln(os.Stderr, err)
 
-			return err
+			return nil, err
 		}
+
+		// rows are ordered by route so a new route starts when the route changes
+		if len(routes) == 0 || routes[len(routes) - 1].LineID != lineID || routes[len(routes) - 1].RouteID != routeID {
+			routes = append(routes, Route{
+				LineID: lineID,
+				RouteID: routeID,
+				OperatorID: operatorID,
+				Name: lineName,
+				Direction: direction,
+				Path: make([]types.Coordinate, 0),
+			})
+		}
+
+		route := &routes[len(routes) - 1]
+		route.Path = append(route.Path, coordinate)
 	}
 
-	return nil
+	return routes, rows.Err()
 }
 
-const insertLineSQL string = "INSERT INTO line(id, name, operator_id) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING"
-
-func (BusRoutes *BusRoutes) InsertLines(lines []Line) error {
+const insertOperatorSQL string = "INSERT INTO operator(id, name, short_name) VALUES ($1, $2, $3) ON CONFLICT (id) DO UPDATE SET (name, short_name) = ($2, $3)"
+const insertLineSQL string = "INSERT INTO line(id, name, operator_id, dataset_id, file_name) VALUES ($1, $2, $3, $4, $5) ON CONFLICT (id) DO UPDATE SET (name, operator_id, file_name) = ($2, $3, $5) WHERE line.dataset_id = $4"
+const insertJourneySQL string = "INSERT INTO journey(line_id, route_id, direction, description, dataset_id, file_name) VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT (line_id, route_id) DO UPDATE SET (direction, description, file_name) = ($3, $4, $6) WHERE journey.dataset_id = $5"
+const insertJourneyStopSQL string = `INSERT INTO journey_stop(line_id, route_id, stop_number, bus_stop_id, dataset_id)
+SELECT $1, $2, $3, $4, $5 WHERE EXISTS (SELECT 1 FROM bus_stop WHERE id = $4)
+ON CONFLICT (line_id, route_id, stop_number) DO UPDATE SET bus_stop_id = $4`
+const insertTripSQL string = `INSERT INTO trip(line_id, route_id, vehicle_journey_code, departure_time, days, start_date, end_date, stop_offsets, dataset_id, file_name)
+VALUES ($1, $2, $3, $4, $5, $6::date, NULLIF($7, '')::date, $8, $9, $10)`
+const insertTimetableFileSQL string = "INSERT INTO timetable_file(dataset_id, file_name, schema_version, revision, created_at, modified_at) VALUES ($1, $2, $3, $4, NULLIF($5, '')::timestamp, NULLIF($6, '')::timestamp)"
+
+// The previous version of a dataset is removed before the new version is
+// inserted. Line IDs are prefixed with their dataset, but lines and journeys
+// imported before they were are kept while another dataset still uses them.
+const deleteDatasetTripsSQL string = "DELETE FROM trip WHERE dataset_id = $1"
+const deleteDatasetJourneyStopsSQL string = "DELETE FROM journey_stop WHERE dataset_id = $1"
+const deleteDatasetJourneysSQL string = "DELETE FROM journey WHERE dataset_id = $1 AND NOT EXISTS (SELECT 1 FROM journey_stop WHERE journey_stop.line_id = journey.line_id AND journey_stop.route_id = journey.route_id) AND NOT EXISTS (SELECT 1 FROM trip WHERE trip.line_id = journey.line_id AND trip.route_id = journey.route_id)"
+const deleteDatasetLinesSQL string = "DELETE FROM line WHERE dataset_id = $1 AND NOT EXISTS (SELECT 1 FROM journey WHERE journey.line_id = line.id)"
+const deleteDatasetFilesSQL string = "DELETE FROM timetable_file WHERE dataset_id = $1"
+
+// ReplaceDataset replaces the previous version of a dataset's lines, journeys,
+// journey stops and trips in a single transaction and records the imported
+// version. Journey stops which aren't in the bus_stop table are skipped and
+// returned as import errors. Nothing is changed if an error is returned,
+// including when ctx is cancelled.
+func (BusRoutes *BusRoutes) ReplaceDataset(ctx context.Context, datasetID uint, timetable Timetable) ([]ImportError, error) {
 	connectionString := os.Getenv("DATABASE_URL")
 
 	db, err := sql.Open("postgres", connectionString)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "Failed to connect to db", err)
 
-		return err
+		return nil, err
 	}
 	defer db.Close()
 
-	stmt, err := db.Prepare(insertLineSQL)
+	txn, err := db.BeginTx(ctx, nil)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Failed to prepare insert line statement", err)
+		fmt.Fprintln(os.Stderr, "Couldn't create database transaction", err)
 
-		return err
+		return nil, err
 	}
-	defer stmt.Close()
 
-	for _, line := range lines {
-		_, err := stmt.Exec(line.ID, line.Name, line.OperatorID)
-		if err != nil {
-			fmt.Fprintln(os.Stderr, "Failed to execute insert line statement", err)
+	rejectedStops, err := replaceDataset(txn, datasetID, timetable)
+	if err != nil {
+		txn.Rollback()
 
-			return err
-		}
+		return nil, err
 	}
 
-	return nil
-}
+	if err := txn.Commit(); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to commit dataset", datasetID, err)
+
+		txn.Rollback()
+		return nil, err
+	}
 
-const insertJourneysSQL string = "INSERT INTO journey(line_id, route_id, direction, description) VALUES ($1, $2, $3, $4) ON CONFLICT DO NOTHING"
+	return rejectedStops, nil
+}
 
-func (BusRoutesBR *BusRoutes) InsertJourneys(journeys []Journey) error {
-	connectionString := os.Getenv("DATABASE_URL")
+func replaceDataset(txn *sql.Tx, datasetID uint, timetable Timetable) ([]ImportError, error) {
+	deleteStatements := []string{
+		deleteDatasetTripsSQL,
+		deleteDatasetJourneyStopsSQL,
+		deleteDatasetJourneysSQL,
+		deleteDatasetLinesSQL,
+		deleteDatasetFilesSQL,
+	}
 
-	db, err := sql.Open("postgres", connectionString)
+	dataset := timetable.Dataset
+	_, err := txn.Exec(insertDatasetSQL, datasetID, dataset.Name, dataset.OperatorName, dataset.Modified, dataset.Revision)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Failed to connect to db", err)
+		fmt.Fprintln(os.Stderr, "Failed to execute insert dataset statement", datasetID, err)
 
-		return err
+		return nil, err
 	}
-	defer db.Close()
 
-	stmt, err := db.Prepare(insertJourneysSQL)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "Failed to prepare insert journey statement", err)
+	for _, deleteStatement := range deleteStatements {
+		if _, err := txn.Exec(deleteStatement, datasetID); err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to delete previous dataset", datasetID, err)
 
-		return err
+			return nil, err
+		}
 	}
-	defer stmt.Close()
 
-	for _, journey := range journeys {
-		_, err := stmt.Exec(journey.LineID, journey.RouteID, journey.Direction, journey.Description)
+	for _, operator := range timetable.Operators {
+		if _, err := txn.Exec(insertOperatorSQL, operator.ID, operator.Name, operator.ShortName); err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to execute insert operator statement", err)
+
+			return nil, err
+		}
+	}
+
+	// a line or journey is only updated while it belongs to this dataset, so a
+	// dataset never takes over the rows of another
+	for _, line := range timetable.Lines {
+		result, err := txn.Exec(insertLineSQL, line.ID, line.Name, line.OperatorID, datasetID, line.FileName)
 		if err != nil {
-			fmt.Fprintln(os.Stderr, "Failed to execute insert journey statement", err)
+			fmt.Fprintln(os.Stderr, "Failed to execute insert line statement", err)
+
+			return nil, err
+		}
 
-			return err
+		if rowsAffected, err := result.RowsAffected(); err == nil && rowsAffected == 0 {
+			return nil, fmt.Errorf("Line %v belongs to another dataset", line.ID)
 		}
 	}
 
-	return nil
-}
+	for _, journey := range timetable.Journeys {
+		result, err := txn.Exec(insertJourneySQL, journey.LineID, journey.RouteID, journey.Direction, journey.Description, datasetID, journey.FileName)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to execute insert journey statement", err)
 
-const insertJourneyStopsSQL string = "INSERT INTO journey_stop(line_id, route_id, stop_number, bus_stop_id) VALUES ($1, $2, $3, $4) ON CONFLICT DO NOTHING"
+			return nil, err
+		}
 
-func (BusRoutes *BusRoutes) InsertJourneyStops(journeyStops []JourneyStop) error {
-	connectionString := os.Getenv("DATABASE_URL")
+		if rowsAffected, err := result.RowsAffected(); err == nil && rowsAffected == 0 {
+			return nil, fmt.Errorf("Journey %v %v belongs to another dataset", journey.LineID, journey.RouteID)
+		}
+	}
 
-	db, err := sql.Open("postgres", connectionString)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "Failed to connect to db", err)
+	rejectedStops := make([]ImportError, 0)
+
+	for _, journeyStop := range timetable.JourneyStops {
+		result, err := txn.Exec(insertJourneyStopSQL, journeyStop.LineID, journeyStop.RouteID, journeyStop.StopNumber, journeyStop.BusStopID, datasetID)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to execute insert journey stop statement", journeyStop.BusStopID, err)
 
-		return err
+			return nil, err
+		}
+
+		if rowsAffected, err := result.RowsAffected(); err == nil && rowsAffected == 0 {
+			rejectedStops = append(rejectedStops, ImportError{
+				DatasetID: datasetID,
+				FileName: journeyStop.FileName,
+				ElementID: journeyStop.BusStopID,
+				Reason: fmt.Sprintf("Bus stop not found for route %v stop %v", journeyStop.RouteID, journeyStop.StopNumber),
+			})
+		}
 	}
-	defer db.Close()
 
-	stmt, err := db.Prepare(insertJourneyStopsSQL)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "Failed to prepare insert journey stop statement", err)
+	for _, trip := range timetable.Trips {
+		stopOffsets := make([]int64, len(trip.StopOffsets))
+		for stopIndex, stopOffset := range trip.StopOffsets {
+			stopOffsets[stopIndex] = int64(stopOffset)
+		}
+
+		_, err := txn.Exec(
+			insertTripSQL, trip.LineID, trip.RouteID, trip.VehicleJourneyCode, trip.DepartureTime,
+			trip.Days, trip.StartDate, trip.EndDate, pq.Array(stopOffsets), datasetID, trip.FileName,
+		)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to execute insert trip statement", trip.VehicleJourneyCode, err)
 
-		return err
+			return nil, err
+		}
 	}
-	defer stmt.Close()
 
-	for _, journeyStop := range journeyStops {
-		_, err := stmt.Exec(journeyStop.LineID, journeyStop.RouteID, journeyStop.StopNumber, journeyStop.BusStopID)
+	for _, timetableFile := range timetable.Files {
+		_, err := txn.Exec(
+			insertTimetableFileSQL, datasetID, timetableFile.FileName, timetableFile.SchemaVersion,
+			timetableFile.Revision, timetableFile.CreatedAt, timetableFile.ModifiedAt,
+		)
 		if err != nil {
-			fmt.Fprintln(os.Stderr, "Failed to execute insert journey stop statement", journeyStop.BusStopID, err)
+			fmt.Fprintln(os.Stderr, "Failed to execute insert timetable file statement", err)
 
-			// Consume error. Best to continue than exit
+			return nil, err
 		}
 	}
 
-	return nil
+	return rejectedStops, nil
 }
 
 type Operator struct {
@@ -287,25 +425,52 @@ type Operator struct {
 	ShortName string
 }
 
-// Line will have an outbound and inbound service
+// Line will have an outbound and inbound service. FileName is the file the line
+// was imported from
 type Line struct {
 	ID         string
 	OperatorID string
 	Name       string
+	FileName   string
 }
 
-// LineID, RouteID used as primary keys
+// LineID, RouteID used as primary keys. FileName is the file the journey was
+// imported from
 type Journey struct {
 	LineID      string
 	RouteID     string
 	Direction   string
 	Description string
+	FileName    string
 }
 
-// LineID, RouteID and StopNumber used as primary keys
+// LineID, RouteID and StopNumber used as primary keys. FileName is the file the
+// stop was imported from
 type JourneyStop struct {
 	LineID     string
 	RouteID    string
 	StopNumber uint
 	BusStopID  string
+	FileName   string
+}
+
+// TimetableFile is a TransXChange file imported from a dataset. DatasetID and
+// FileName used as primary keys. CreatedAt and ModifiedAt are the file's
+// CreationDateTime and ModificationDateTime, empty when not given
+type TimetableFile struct {
+	DatasetID     uint
+	FileName      string
+	SchemaVersion string
+	Revision      uint
+	CreatedAt     string
+	ModifiedAt    string
+}
+
+// ImportError is a file or record which was skipped while importing a dataset.
+// ElementID is empty when the whole file was skipped.
+type ImportError struct {
+	DatasetID uint
+	FileName  string
+	ElementID string
+	Reason    string
 }
\ No newline at end of file
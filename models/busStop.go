@@ -5,6 +5,7 @@ import (
 	"os"
 	"log"
 	"fmt"
+	"strings"
 	"database/sql"
 	"github.com/lib/pq"
 )
@@ -23,7 +24,7 @@ type BusStop struct {
 	Bearing   float32
 }
 
-const selectNaptanByID = "SELECT name, longitude, latitude, bearing FROM bus_stop WHERE atcoCode = $1"
+const selectNaptanByID = "SELECT id, name, longitude, latitude, bearing FROM bus_stop WHERE id = $1"
 const selectStopsWithinBounds = "SELECT id, name, longitude, latitude, bearing FROM bus_stop WHERE longitude >= $1 AND latitude >= $2 AND longitude <= $3 AND latitude <= $4 LIMIT 200"
 
 func GetBusStopWithinBounds(minLongitude float32, minLatitude float32, maxLongitude float32, maxLatitude float32) ([]BusStop, error) {
@@ -56,7 +57,7 @@ func GetBusStopWithinBounds(minLongitude float32, minLatitude float32, maxLongit
 		}
 
 		busStops = append(busStops, BusStop{
-			ID: id,
+			ID: TrimBusStopID(id),
 			Name: name,
 			Longitude: longitude,
 			Latitude: latitude,
@@ -67,7 +68,56 @@ func GetBusStopWithinBounds(minLongitude float32, minLatitude float32, maxLongit
 	return busStops, nil
 }
 
-func GetLocationFromNaPTAN(id string) (BusStop, error) {
+const selectEveryStopWithinBounds = "SELECT id, name, longitude, latitude, bearing FROM bus_stop WHERE longitude >= $1 AND latitude >= $2 AND longitude <= $3 AND latitude <= $4 ORDER BY id"
+
+// GetEveryBusStopWithinBounds gets every bus stop within a bounding box without
+// a limit, for drawing map tiles
+func GetEveryBusStopWithinBounds(minLongitude float32, minLatitude float32, maxLongitude float32, maxLatitude float32) ([]BusStop, error) {
+	connectionString := os.Getenv("DATABASE_URL")
+
+	db, err := sql.Open("postgres", connectionString)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to connect to db", err)
+
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(selectEveryStopWithinBounds, minLongitude, minLatitude, maxLongitude, maxLatitude)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to execute select bus stops statement", err)
+
+		return nil, err
+	}
+	defer rows.Close()
+
+	busStops := make([]BusStop, 0)
+
+	for rows.Next() {
+		var busStop BusStop
+
+		err := rows.Scan(&busStop.ID, &busStop.Name, &busStop.Longitude, &busStop.Latitude, &busStop.Bearing)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+
+			return nil, err
+		}
+
+		busStop.ID = TrimBusStopID(busStop.ID)
+		busStops = append(busStops, busStop)
+	}
+
+	return busStops, rows.Err()
+}
+
+// TrimBusStopID removes the padding from a bus stop ID stored as CHAR(12)
+func TrimBusStopID(id string) string {
+	return strings.TrimRight(id, " ")
+}
+
+// GetBusStop gets the bus stop with the ATCO code id. Returns sql.ErrNoRows
+// when there isn't a bus stop with the code
+func GetBusStop(id string) (BusStop, error) {
 	connectionString, _ := os.LookupEnv("DATABASE_URL")
 
 	db, err := sql.Open("postgres", connectionString)
@@ -78,18 +128,21 @@ func GetLocationFromNaPTAN(id string) (BusStop, error) {
 	defer db.Close()
 
 	var (
+		atcoCode string
 		name string
 		longitude float64
 		latitude float64
 		bearing float64
 	)
-	if err := db.QueryRow(selectNaptanByID, id).Scan(&name, &longitude, &latitude, &bearing); err != nil {
-		fmt.Fprintln(os.Stderr, err)
+	if err := db.QueryRow(selectNaptanByID, id).Scan(&atcoCode, &name, &longitude, &latitude, &bearing); err != nil {
+		if err != sql.ErrNoRows {
+			fmt.Fprintln(os.Stderr, err)
+		}
 		return BusStop{}, err
 	}
 
 	return BusStop{
-		ID: id,
+		ID: TrimBusStopID(atcoCode),
 		Name: name,
 		Longitude: float32(longitude),
 		Latitude: float32(latitude),
@@ -100,8 +153,10 @@ func GetLocationFromNaPTAN(id string) (BusStop, error) {
 const countBusStopsSQL string = "SELECT COUNT(name) FROM bus_stop"
 const insertBusStopSQL = "INSERT INTO bus_stop(id, name, longitude, latitude, bearing) VALUES ($1, $2, $3, $4, $5) ON CONFLICT (id) DO UPDATE SET (name, longitude, latitude, bearing) = ($2, $3, $4, $5)"
 
-func UpdateBusStops(busStops []BusStop, db *sql.DB) error {
-	ctx := context.Background()
+// UpdateBusStops inserts the bus stops, or updates them when bus stops have
+// already been inserted, in a single transaction. Nothing is changed if an error
+// is returned, including when ctx is cancelled
+func UpdateBusStops(ctx context.Context, busStops []BusStop, db *sql.DB) error {
 	var count int
 
 	txn, err := db.BeginTx(ctx, nil)
@@ -117,74 +172,54 @@ func UpdateBusStops(busStops []BusStop, db *sql.DB) error {
 		return err
 	}
 
+	// copying is much faster than inserting when there are no bus stops to update
+	insertSQL := insertBusStopSQL
 	if count == 0 {
-		stmt, err := txn.Prepare(pq.CopyIn("bus_stop", "id", "name", "longitude", "latitude", "bearing"))
-		if err != nil {
-			fmt.Fprintln(os.Stderr, err)
-
-			txn.Rollback()
-			return err
-		}
+		insertSQL = pq.CopyIn("bus_stop", "id", "name", "longitude", "latitude", "bearing")
+	}
 
-		for _, busStop := range busStops {
-			_, err := stmt.Exec(busStop.ID, busStop.Name, busStop.Longitude, busStop.Latitude, busStop.Bearing)
-			if err != nil {
-				fmt.Fprintln(os.Stderr, err)
+	stmt, err := txn.Prepare(insertSQL)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
 
-				stmt.Close()
-				txn.Rollback()
-				return err
-			}
-		}
+		txn.Rollback()
+		return err
+	}
 
-		if _, err := stmt.Exec(); err != nil {
+	for _, busStop := range busStops {
+		_, err := stmt.Exec(busStop.ID, busStop.Name, busStop.Longitude, busStop.Latitude, busStop.Bearing)
+		if err != nil {
 			fmt.Fprintln(os.Stderr, err)
 
 			stmt.Close()
 			txn.Rollback()
 			return err
 		}
+	}
 
-		if err := stmt.Close(); err != nil {
-			fmt.Fprintln(os.Stderr, err)
-
-			txn.Rollback()
-			return err
-		}
-
-		if err := txn.Commit(); err != nil {
+	if count == 0 {
+		if _, err := stmt.Exec(); err != nil {
 			fmt.Fprintln(os.Stderr, err)
 
+			stmt.Close()
 			txn.Rollback()
 			return err
 		}
-
-		return nil
 	}
 
-	// don't block the database while updating
-	if err := txn.Commit(); err != nil {
+	if err := stmt.Close(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
 
+		txn.Rollback()
 		return err
 	}
 
-	stmt, err := db.Prepare(insertBusStopSQL)
-	if err != nil {
+	if err := txn.Commit(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
 
+		txn.Rollback()
 		return err
 	}
-	defer stmt.Close()
-
-	for _, busStop := range busStops {
-		_, err := stmt.Exec(busStop.ID, busStop.Name, busStop.Longitude, busStop.Latitude, busStop.Bearing)
-		if err != nil {
-			fmt.Fprintln(os.Stderr, err)
-
-			return err
-		}
-	}
 
 	return nil
 }
\ No newline at end of file
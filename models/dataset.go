@@ -0,0 +1,105 @@
+package models
+
+import (
+	"time"
+	"os"
+	"fmt"
+	"database/sql"
+	_ "github.com/lib/pq"
+)
+
+// Dataset
+// | ID      | Name       | OperatorName | Modified   | Revision | ImportedAt |
+// | ------- | ---------- | ------------ | ---------- | -------- | ---------- |
+// | PK Uint | String     | String       | Timestamp  | Uint     | Timestamp  |
+// | 2022    | SCEK 953.. | Stagecoach   | 2021-02... | 67       | 2021-04... |
+
+// Dataset is a BODS timetable dataset which lines, journeys and journey stops
+// were imported from. Modified is the BODS modified time of the imported
+// version and Revision is the highest RevisionNumber of its files
+type Dataset struct {
+	ID           uint
+	Name         string
+	OperatorName string
+	Modified     time.Time
+	Revision     uint
+	ImportedAt   time.Time
+}
+
+const selectDatasets string = "SELECT id, name, operator_name, modified, revision, imported_at FROM dataset ORDER BY id"
+const selectDatasetByID string = "SELECT id, name, operator_name, modified, revision, imported_at FROM dataset WHERE id = $1"
+const insertDatasetSQL string = `INSERT INTO dataset(id, name, operator_name, modified, revision) VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (id) DO UPDATE SET (name, operator_name, modified, revision, imported_at) = ($2, $3, $4, $5, NOW())`
+
+// GetDatasets gets every imported dataset
+func GetDatasets() ([]Dataset, error) {
+	connectionString := os.Getenv("DATABASE_URL")
+
+	db, err := sql.Open("postgres", connectionString)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to connect to db", err)
+
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(selectDatasets)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to execute select datasets statement", err)
+
+		return nil, err
+	}
+	defer rows.Close()
+
+	datasets := make([]Dataset, 0)
+
+	for rows.Next() {
+		var dataset Dataset
+
+		err := rows.Scan(
+			&dataset.ID, &dataset.Name, &dataset.OperatorName,
+			&dataset.Modified, &dataset.Revision, &dataset.ImportedAt,
+		)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+
+			return nil, err
+		}
+
+		datasets = append(datasets, dataset)
+	}
+
+	return datasets, rows.Err()
+}
+
+// GetDataset gets the imported version of a dataset. found is false when the
+// dataset has never been imported
+func (BusRoutes *BusRoutes) GetDataset(datasetID uint) (Dataset, bool, error) {
+	connectionString := os.Getenv("DATABASE_URL")
+
+	db, err := sql.Open("postgres", connectionString)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to connect to db", err)
+
+		return Dataset{}, false, err
+	}
+	defer db.Close()
+
+	var dataset Dataset
+
+	err = db.QueryRow(selectDatasetByID, datasetID).Scan(
+		&dataset.ID, &dataset.Name, &dataset.OperatorName,
+		&dataset.Modified, &dataset.Revision, &dataset.ImportedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return Dataset{}, false, nil
+		}
+
+		fmt.Fprintln(os.Stderr, "Failed to execute select dataset statement", err)
+
+		return Dataset{}, false, err
+	}
+
+	return dataset, true, nil
+}
@@ -0,0 +1,283 @@
+package models
+
+import (
+	"context"
+	"time"
+	"os"
+	"fmt"
+	"database/sql"
+	"github.com/lib/pq"
+)
+
+// GTFS Export
+// | JobID      | Feed  | CreatedAt  |
+// | ---------- | ----- | ---------- |
+// | PK FK Uint | Bytes | Timestamp  |
+// | 12         | PK... | 2021-04... |
+
+// GTFSTimetable is every operator, line, used bus stop and trip to export as a
+// GTFS feed
+type GTFSTimetable struct {
+	Operators []Operator
+	Lines     []Line
+	BusStops  []BusStop
+	Trips     []GTFSTrip
+}
+
+// GTFSTrip is a trip with its operating days and stops. StartDate and EndDate
+// are formatted as 2006-01-02 and EndDate is empty when the trip runs
+// indefinitely
+type GTFSTrip struct {
+	ID            uint
+	LineID        string
+	RouteID       string
+	Direction     string
+	DepartureTime uint
+	Days          uint8
+	StartDate     string
+	EndDate       string
+	DatasetID     uint
+	Stops         []TripStop
+}
+
+const selectGTFSLines = "SELECT id, name, operator_id, file_name FROM line ORDER BY id"
+const selectGTFSBusStops = "SELECT id, name, longitude, latitude, bearing FROM bus_stop WHERE id IN (SELECT bus_stop_id FROM journey_stop) ORDER BY id"
+const selectGTFSTrips = `SELECT
+	trip.id AS tripID,
+	trip.line_id AS lineID,
+	trip.route_id AS routeID,
+	journey.direction AS direction,
+	trip.departure_time AS departureTime,
+	trip.days AS days,
+	trip.start_date AS startDate,
+	trip.end_date AS endDate,
+	trip.stop_offsets AS stopOffsets,
+	trip.dataset_id AS datasetID
+FROM
+	trip
+INNER JOIN journey ON trip.line_id = journey.line_id AND trip.route_id = journey.route_id
+ORDER BY trip.id`
+
+const deleteGTFSExports = "DELETE FROM gtfs_export"
+const insertGTFSExport = "INSERT INTO gtfs_export(job_id, feed) VALUES ($1, $2)"
+const selectLatestGTFSExport = "SELECT feed, created_at FROM gtfs_export ORDER BY created_at DESC LIMIT 1"
+
+// GetGTFSTimetable gets every operator, line and trip, and every bus stop called
+// at by a journey
+func GetGTFSTimetable() (GTFSTimetable, error) {
+	operators, err := GetOperators()
+	if err != nil {
+		return GTFSTimetable{}, err
+	}
+
+	connectionString := os.Getenv("DATABASE_URL")
+
+	db, err := sql.Open("postgres", connectionString)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to connect to db", err)
+
+		return GTFSTimetable{}, err
+	}
+	defer db.Close()
+
+	lines, err := getGTFSLines(db)
+	if err != nil {
+		return GTFSTimetable{}, err
+	}
+
+	busStops, err := getGTFSBusStops(db)
+	if err != nil {
+		return GTFSTimetable{}, err
+	}
+
+	trips, err := getGTFSTrips(db)
+	if err != nil {
+		return GTFSTimetable{}, err
+	}
+
+	return GTFSTimetable{
+		Operators: operators,
+		Lines: lines,
+		BusStops: busStops,
+		Trips: trips,
+	}, nil
+}
+
+func getGTFSLines(db *sql.DB) ([]Line, error) {
+	rows, err := db.Query(selectGTFSLines)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to execute select lines statement", err)
+
+		return nil, err
+	}
+	defer rows.Close()
+
+	lines := make([]Line, 0)
+
+	for rows.Next() {
+		var line Line
+
+		if err := rows.Scan(&line.ID, &line.Name, &line.OperatorID, &line.FileName); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+
+			return nil, err
+		}
+
+		lines = append(lines, line)
+	}
+
+	return lines, rows.Err()
+}
+
+func getGTFSBusStops(db *sql.DB) ([]BusStop, error) {
+	rows, err := db.Query(selectGTFSBusStops)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to execute select bus stops statement", err)
+
+		return nil, err
+	}
+	defer rows.Close()
+
+	busStops := make([]BusStop, 0)
+
+	for rows.Next() {
+		var busStop BusStop
+
+		err := rows.Scan(&busStop.ID, &busStop.Name, &busStop.Longitude, &busStop.Latitude, &busStop.Bearing)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+
+			return nil, err
+		}
+
+		busStop.ID = TrimBusStopID(busStop.ID)
+		busStops = append(busStops, busStop)
+	}
+
+	return busStops, rows.Err()
+}
+
+func getGTFSTrips(db *sql.DB) ([]GTFSTrip, error) {
+	routeStops, _, err := getRouteStops(db, selectRouteStops)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(selectGTFSTrips)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to execute select trips statement", err)
+
+		return nil, err
+	}
+	defer rows.Close()
+
+	trips := make([]GTFSTrip, 0)
+
+	for rows.Next() {
+		var trip GTFSTrip
+		var startDate time.Time
+		var endDate sql.NullTime
+		var stopOffsets []int64
+
+		err := rows.Scan(
+			&trip.ID, &trip.LineID, &trip.RouteID, &trip.Direction,
+			&trip.DepartureTime, &trip.Days, &startDate, &endDate,
+			pq.Array(&stopOffsets), &trip.DatasetID,
+		)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+
+			return nil, err
+		}
+
+		trip.StartDate = startDate.Format("2006-01-02")
+		if endDate.Valid {
+			trip.EndDate = endDate.Time.Format("2006-01-02")
+		}
+
+		// stops which weren't imported leave gaps in the stop numbers
+		trip.Stops = make([]TripStop, 0)
+		for _, stop := range routeStops[trip.LineID + "\x00" + trip.RouteID] {
+			if int(stop.stopNumber) < len(stopOffsets) {
+				trip.Stops = append(trip.Stops, TripStop{
+					BusStopID: stop.busStopID,
+					Offset: uint(stopOffsets[stop.stopNumber]),
+				})
+			}
+		}
+
+		trips = append(trips, trip)
+	}
+
+	return trips, rows.Err()
+}
+
+// SaveGTFSExport replaces the previous GTFS feed with a feed exported by a job.
+// Nothing is changed if ctx is cancelled before the feed is saved
+func SaveGTFSExport(ctx context.Context, jobID uint, feed []byte) error {
+	connectionString := os.Getenv("DATABASE_URL")
+
+	db, err := sql.Open("postgres", connectionString)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to connect to db", err)
+
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Couldn't create database transaction", err)
+
+		return err
+	}
+
+	if _, err := tx.Exec(deleteGTFSExports); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to execute delete GTFS exports statement", err)
+
+		tx.Rollback()
+		return err
+	}
+
+	if _, err := tx.Exec(insertGTFSExport, jobID, feed); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to execute insert GTFS export statement", err)
+
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		fmt.Fprintln(os.Stderr, "Transaction failed", err)
+
+		return err
+	}
+
+	return nil
+}
+
+// GetLatestGTFSExport gets the most recently exported GTFS feed. Returns
+// sql.ErrNoRows when a feed hasn't been exported
+func GetLatestGTFSExport() ([]byte, time.Time, error) {
+	connectionString := os.Getenv("DATABASE_URL")
+
+	db, err := sql.Open("postgres", connectionString)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to connect to db", err)
+
+		return nil, time.Time{}, err
+	}
+	defer db.Close()
+
+	var feed []byte
+	var createdAt time.Time
+
+	if err := db.QueryRow(selectLatestGTFSExport).Scan(&feed, &createdAt); err != nil {
+		if err != sql.ErrNoRows {
+			fmt.Fprintln(os.Stderr, "Failed to execute select GTFS export statement", err)
+		}
+
+		return nil, time.Time{}, err
+	}
+
+	return feed, createdAt, nil
+}
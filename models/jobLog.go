@@ -0,0 +1,132 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+)
+
+// JobLogEntry is a line logged by a background job. Fields are the details of
+// the line, such as the DatasetID or FileName being imported
+type JobLogEntry struct {
+	ID        uint
+	Level     string
+	Message   string
+	Fields    JobLogFields `json:",omitempty"`
+	CreatedAt time.Time
+}
+
+// JobLogFields are the structured details of a job log entry
+type JobLogFields map[string]interface{}
+
+// JobLogLevels are the levels of job log entries, least severe first
+var JobLogLevels = []string{"DEBUG", "INFO", "WARN", "ERROR"}
+
+const selectJobStatus string = "SELECT status FROM background_job WHERE id = $1"
+const insertJobLog string = "INSERT INTO job_log(job_id, level, message, fields, created_at) VALUES($1, $2, $3, $4, $5)"
+const selectJobLogs string = `SELECT id, level, message, fields, created_at FROM job_log
+WHERE job_id = $1 AND id > $2 AND level >= $3::log_level ORDER BY id LIMIT $4`
+const selectJobLogsTail string = `SELECT id, level, message, fields, created_at FROM (
+	SELECT id, level, message, fields, created_at FROM job_log
+	WHERE job_id = $1 AND id > $2 AND level >= $3::log_level ORDER BY id DESC LIMIT $4
+) AS tail ORDER BY id`
+
+// InsertJobLogs saves the log entries of a job in one transaction
+func InsertJobLogs(jobID uint, entries []JobLogEntry, db *sql.DB) error {
+	ctx := context.Background()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Println("Couldn't create database transaction")
+		return err
+	}
+
+	stmt, err := tx.Prepare(insertJobLog)
+	if err != nil {
+		log.Println("Couldn't prepare job log statement", err)
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	for _, entry := range entries {
+		// lib/pq sends []byte as bytea, so the fields are sent as a string
+		var fieldsJSON sql.NullString
+		if len(entry.Fields) > 0 {
+			encoded, err := json.Marshal(entry.Fields)
+			if err != nil {
+				log.Println("Failed to marshal job log fields", err)
+			} else {
+				fieldsJSON = sql.NullString{String: string(encoded), Valid: true}
+			}
+		}
+
+		_, err := stmt.Exec(jobID, entry.Level, entry.Message, fieldsJSON, entry.CreatedAt.UTC())
+		if err != nil {
+			log.Println("Error inserting job log to db", err)
+			tx.Rollback()
+			return errors.New("Error inserting into job_log")
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetJobStatus gets the status of a job without its datasets and import
+// errors. Returns sql.ErrNoRows when there is no job with the id
+func GetJobStatus(jobID uint, db sqlDB) (string, error) {
+	var status string
+
+	if err := db.QueryRow(selectJobStatus, jobID).Scan(&status); err != nil {
+		if err != sql.ErrNoRows {
+			log.Println("Error getting background job status from db", err)
+		}
+
+		return "", err
+	}
+
+	return status, nil
+}
+
+// GetJobLogs gets up to limit log entries of a job at level or above, logged
+// after the entry with the id of afterID. When tail is true the last limit
+// entries are got instead of the first. Entries are oldest first
+func GetJobLogs(jobID uint, level string, afterID uint, limit uint, tail bool, db sqlDB) ([]JobLogEntry, error) {
+	query := selectJobLogs
+	if tail {
+		query = selectJobLogsTail
+	}
+
+	rows, err := db.Query(query, jobID, afterID, level, limit)
+	if err != nil {
+		log.Println("Error getting job logs from db", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make([]JobLogEntry, 0)
+
+	for rows.Next() {
+		var entry JobLogEntry
+		var fieldsJSON []byte
+
+		err := rows.Scan(&entry.ID, &entry.Level, &entry.Message, &fieldsJSON, &entry.CreatedAt)
+		if err != nil {
+			log.Println("Error scanning job log", err)
+			return nil, err
+		}
+
+		if fieldsJSON != nil {
+			if err := json.Unmarshal(fieldsJSON, &entry.Fields); err != nil {
+				log.Println("Error unmarshalling job log fields", err)
+				return nil, err
+			}
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
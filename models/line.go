@@ -0,0 +1,191 @@
+package models
+
+import (
+	"os"
+	"fmt"
+	"database/sql"
+	"github.com/lib/pq"
+)
+
+// LineSummary is a line with its operator and the directions its routes run in.
+// The Name, OperatorID and Direction can be used to get the line's routes
+type LineSummary struct {
+	ID           string
+	Name         string
+	OperatorID   string
+	OperatorName string
+	Directions   []string
+}
+
+const selectLinesColumns = `SELECT
+	line.id AS lineID,
+	line.name AS lineName,
+	operator.id AS operatorID,
+	operator.name AS operatorName,
+	operator.short_name AS operatorShortName,
+	array_agg(DISTINCT journey.direction::text ORDER BY journey.direction::text) AS directions
+FROM
+	line
+INNER JOIN operator ON line.operator_id = operator.id
+INNER JOIN journey ON journey.line_id = line.id
+`
+
+const selectLinesByOperator = selectLinesColumns + `WHERE operator.id = $1
+GROUP BY line.id, operator.id
+ORDER BY line.name, line.id`
+
+const selectLinesWithinBounds = selectLinesColumns + `WHERE EXISTS (
+	SELECT 1 FROM journey_stop
+	INNER JOIN bus_stop ON journey_stop.bus_stop_id = bus_stop.id
+	WHERE journey_stop.line_id = line.id
+	AND bus_stop.longitude >= $1 AND bus_stop.latitude >= $2 AND bus_stop.longitude <= $3 AND bus_stop.latitude <= $4
+)
+GROUP BY line.id, operator.id
+ORDER BY line.name, operator.id, line.id`
+
+// GetOperatorLines gets every line run by the operator with the national
+// operator code noc
+func GetOperatorLines(noc string) ([]LineSummary, error) {
+	return getLines(selectLinesByOperator, noc)
+}
+
+// GetLinesWithinBounds gets every line which serves a bus stop within the
+// bounds
+func GetLinesWithinBounds(minLongitude float32, minLatitude float32, maxLongitude float32, maxLatitude float32) ([]LineSummary, error) {
+	return getLines(selectLinesWithinBounds, minLongitude, minLatitude, maxLongitude, maxLatitude)
+}
+
+func getLines(query string, args ...interface{}) ([]LineSummary, error) {
+	connectionString := os.Getenv("DATABASE_URL")
+
+	db, err := sql.Open("postgres", connectionString)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to connect to db", err)
+
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to execute select lines statement", err)
+
+		return nil, err
+	}
+	defer rows.Close()
+
+	lines := make([]LineSummary, 0)
+
+	for rows.Next() {
+		var line LineSummary
+		var operatorShortName string
+
+		err := rows.Scan(
+			&line.ID, &line.Name, &line.OperatorID, &line.OperatorName,
+			&operatorShortName, pq.Array(&line.Directions),
+		)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+
+			return nil, err
+		}
+
+		if line.OperatorName == "" {
+			line.OperatorName = operatorShortName
+		}
+
+		lines = append(lines, line)
+	}
+
+	return lines, rows.Err()
+}
+
+// BusStopLine is a route which calls at a bus stop. StopNumber is the position
+// of the stop in the route starting from 0, out of StopCount stops
+type BusStopLine struct {
+	LineID       string
+	RouteID      string
+	Name         string
+	Direction    string
+	Description  string
+	Destination  string
+	OperatorID   string
+	OperatorName string
+	StopNumber   uint
+	StopCount    uint
+}
+
+const selectBusStopLines = `SELECT
+	line.id AS lineID,
+	journey.route_id AS routeID,
+	line.name AS lineName,
+	journey.direction AS direction,
+	journey.description AS description,
+	destination.name AS destination,
+	operator.id AS operatorID,
+	operator.name AS operatorName,
+	operator.short_name AS operatorShortName,
+	journey_stop.stop_number AS stopNumber,
+	route_stops.stop_count AS stopCount
+FROM
+	journey_stop
+INNER JOIN journey ON journey_stop.line_id = journey.line_id AND journey_stop.route_id = journey.route_id
+INNER JOIN line ON journey_stop.line_id = line.id
+INNER JOIN operator ON line.operator_id = operator.id
+INNER JOIN LATERAL (
+	SELECT COUNT(*) AS stop_count, MAX(stop_number) AS last_stop_number
+	FROM journey_stop AS route_stop
+	WHERE route_stop.line_id = journey_stop.line_id AND route_stop.route_id = journey_stop.route_id
+) AS route_stops ON true
+INNER JOIN journey_stop AS last_stop ON last_stop.line_id = journey_stop.line_id AND last_stop.route_id = journey_stop.route_id AND last_stop.stop_number = route_stops.last_stop_number
+INNER JOIN bus_stop AS destination ON last_stop.bus_stop_id = destination.id
+WHERE journey_stop.bus_stop_id = $1
+ORDER BY line.name, operator.id, journey.direction, journey.route_id, journey_stop.stop_number`
+
+// GetBusStopLines gets every route which calls at the bus stop atcoCode with
+// the position of the stop in the route
+func GetBusStopLines(atcoCode string) ([]BusStopLine, error) {
+	connectionString := os.Getenv("DATABASE_URL")
+
+	db, err := sql.Open("postgres", connectionString)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to connect to db", err)
+
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(selectBusStopLines, atcoCode)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to execute select bus stop lines statement", err)
+
+		return nil, err
+	}
+	defer rows.Close()
+
+	lines := make([]BusStopLine, 0)
+
+	for rows.Next() {
+		var line BusStopLine
+		var operatorShortName string
+
+		err := rows.Scan(
+			&line.LineID, &line.RouteID, &line.Name, &line.Direction, &line.Description,
+			&line.Destination, &line.OperatorID, &line.OperatorName, &operatorShortName,
+			&line.StopNumber, &line.StopCount,
+		)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+
+			return nil, err
+		}
+
+		if line.OperatorName == "" {
+			line.OperatorName = operatorShortName
+		}
+
+		lines = append(lines, line)
+	}
+
+	return lines, rows.Err()
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"os"
+	"fmt"
+	"database/sql"
+	_ "github.com/lib/pq"
+)
+
+const selectOperators string = "SELECT id, name, short_name FROM operator ORDER BY name, id"
+const selectOperatorByID string = "SELECT id, name, short_name FROM operator WHERE id = $1"
+
+// GetOperators gets every operator
+func GetOperators() ([]Operator, error) {
+	connectionString := os.Getenv("DATABASE_URL")
+
+	db, err := sql.Open("postgres", connectionString)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to connect to db", err)
+
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(selectOperators)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to execute select operators statement", err)
+
+		return nil, err
+	}
+	defer rows.Close()
+
+	operators := make([]Operator, 0)
+
+	for rows.Next() {
+		var operator Operator
+
+		if err := rows.Scan(&operator.ID, &operator.Name, &operator.ShortName); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+
+			return nil, err
+		}
+
+		operators = append(operators, operator)
+	}
+
+	return operators, rows.Err()
+}
+
+// GetOperator gets the operator with the national operator code noc. Returns
+// sql.ErrNoRows when there isn't an operator with the code
+func GetOperator(noc string) (Operator, error) {
+	connectionString := os.Getenv("DATABASE_URL")
+
+	db, err := sql.Open("postgres", connectionString)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to connect to db", err)
+
+		return Operator{}, err
+	}
+	defer db.Close()
+
+	var operator Operator
+
+	err = db.QueryRow(selectOperatorByID, noc).Scan(&operator.ID, &operator.Name, &operator.ShortName)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			fmt.Fprintln(os.Stderr, "Failed to execute select operator statement", err)
+		}
+
+		return Operator{}, err
+	}
+
+	return operator, nil
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Schedule queues a background job of Type each time its Cron expression is
+// due. Jobs updating routes update the dataset DatasetID, or every published
+// dataset filtered by NOC and AdminArea. NextRun is zero when the expression
+// is never due, and LastJob is the URI of the job queued when it was last due,
+// or LastError why it couldn't be queued
+type Schedule struct {
+	Name      string
+	Cron      string
+	Type      string
+	DatasetID uint       `json:",omitempty"`
+	NOC       string     `json:",omitempty"`
+	AdminArea string     `json:",omitempty"`
+	Force     bool       `json:",omitempty"`
+	NextRun   time.Time
+	LastRun   *time.Time `json:",omitempty"`
+	LastJob   string     `json:",omitempty"`
+	LastError string     `json:",omitempty"`
+}
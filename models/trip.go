@@ -0,0 +1,278 @@
+package models
+
+import (
+	"time"
+	"os"
+	"fmt"
+	"database/sql"
+	"github.com/lib/pq"
+)
+
+// Trip
+// | ID      | LineID       | RouteID         | VehicleJourneyCode | DepartureTime | Days  | StartDate  | EndDate    | StopOffsets   | DatasetID    |
+// | ------- | ------------ | --------------- | ------------------ | ------------- | ----- | ---------- | ---------- | ------------- | ------------ |
+// | PK Uint | FK JourneyID | FK JourneyID    | String             | Uint          | Uint8 | Date       | Date       | Uint[]        | FK DatasetID |
+// | 1       | 2022:SCEK... | RT131           | VJ964              | 29700         | 31    | 2021-03-07 | NULL       | {0,120,420..} | 2022         |
+
+// Days of the week a trip runs on
+const (
+	Monday uint8 = 1 << iota
+	Tuesday
+	Wednesday
+	Thursday
+	Friday
+	Saturday
+	Sunday
+
+	EveryDay = Monday | Tuesday | Wednesday | Thursday | Friday | Saturday | Sunday
+)
+
+// WeekdayBit gets the Days bit of a day of the week
+func WeekdayBit(weekday time.Weekday) uint8 {
+	return 1 << ((uint(weekday) + 6) % 7)
+}
+
+// Trip is a scheduled vehicle journey along a journey (line and route).
+// DepartureTime is in seconds after midnight. StopOffsets are the seconds after
+// the DepartureTime the trip departs each stop of the route, indexed by stop
+// number. StartDate and EndDate are formatted as 2006-01-02 and EndDate is
+// empty when the trip runs indefinitely
+type Trip struct {
+	LineID             string
+	RouteID            string
+	VehicleJourneyCode string
+	DepartureTime      uint
+	Days               uint8
+	StartDate          string
+	EndDate            string
+	StopOffsets        []uint
+	FileName           string
+}
+
+// TimetableTrip is a trip running on a date with its stops and line. The bus
+// stops are returned separately keyed by ID, as most are called at by many trips
+type TimetableTrip struct {
+	ID                 uint
+	LineID             string
+	RouteID            string
+	VehicleJourneyCode string
+	LineName           string
+	Direction          string
+	OperatorID         string
+	OperatorName       string
+	DepartureTime      uint
+	Stops              []TripStop
+}
+
+// TripStop is a stop of a trip departed Offset seconds after the trip's
+// DepartureTime
+type TripStop struct {
+	BusStopID string
+	Offset    uint
+}
+
+const selectTripsOnDate = `SELECT
+	trip.id AS tripID,
+	trip.line_id AS lineID,
+	trip.route_id AS routeID,
+	trip.vehicle_journey_code AS vehicleJourneyCode,
+	line.name AS lineName,
+	journey.direction AS direction,
+	operator.id AS operatorID,
+	operator.name AS operatorName,
+	operator.short_name AS operatorShortName,
+	trip.departure_time AS departureTime,
+	trip.stop_offsets AS stopOffsets
+FROM
+	trip
+INNER JOIN journey ON trip.line_id = journey.line_id AND trip.route_id = journey.route_id
+INNER JOIN line ON trip.line_id = line.id
+INNER JOIN operator ON line.operator_id = operator.id
+WHERE (trip.days & $1) != 0 AND trip.start_date <= $2 AND (trip.end_date IS NULL OR trip.end_date >= $2)
+ORDER BY trip.departure_time, trip.id`
+
+const selectRouteStops = `SELECT
+	journey_stop.line_id AS lineID,
+	journey_stop.route_id AS routeID,
+	journey_stop.stop_number AS stopNumber,
+	bus_stop.id AS stopID,
+	bus_stop.name AS stopName,
+	bus_stop.longitude AS longitude,
+	bus_stop.latitude AS latitude,
+	bus_stop.bearing AS bearing
+FROM
+	journey_stop
+INNER JOIN bus_stop ON journey_stop.bus_stop_id = bus_stop.id
+ORDER BY journey_stop.line_id, journey_stop.route_id, journey_stop.stop_number`
+
+// selectRouteStopsOnDate only selects the stops of routes with a trip running
+// on the date, using the same conditions as selectTripsOnDate
+const selectRouteStopsOnDate = `SELECT
+	journey_stop.line_id AS lineID,
+	journey_stop.route_id AS routeID,
+	journey_stop.stop_number AS stopNumber,
+	bus_stop.id AS stopID,
+	bus_stop.name AS stopName,
+	bus_stop.longitude AS longitude,
+	bus_stop.latitude AS latitude,
+	bus_stop.bearing AS bearing
+FROM
+	journey_stop
+INNER JOIN bus_stop ON journey_stop.bus_stop_id = bus_stop.id
+WHERE EXISTS (
+	SELECT 1 FROM trip
+	WHERE trip.line_id = journey_stop.line_id AND trip.route_id = journey_stop.route_id
+	AND (trip.days & $1) != 0 AND trip.start_date <= $2 AND (trip.end_date IS NULL OR trip.end_date >= $2)
+)
+ORDER BY journey_stop.line_id, journey_stop.route_id, journey_stop.stop_number`
+
+type routeStop struct {
+	stopNumber uint
+	busStopID  string
+}
+
+// GetTripsOnDate gets every trip running on the date with its stops, and the bus
+// stops they call at keyed by ID. Only the days of the week and operating
+// period of a trip are checked, so a trip which doesn't run on bank holidays or
+// school holidays is still returned on them
+func GetTripsOnDate(date time.Time) ([]TimetableTrip, map[string]BusStop, error) {
+	connectionString := os.Getenv("DATABASE_URL")
+
+	db, err := sql.Open("postgres", connectionString)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to connect to db", err)
+
+		return nil, nil, err
+	}
+	defer db.Close()
+
+	weekday := WeekdayBit(date.Weekday())
+	serviceDate := date.Format("2006-01-02")
+
+	routeStops, busStops, err := getRouteStops(db, selectRouteStopsOnDate, weekday, serviceDate)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rows, err := db.Query(selectTripsOnDate, weekday, serviceDate)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to execute select trips statement", err)
+
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	trips := make([]TimetableTrip, 0)
+
+	for rows.Next() {
+		var trip TimetableTrip
+		var operatorShortName string
+		var stopOffsets []int64
+
+		err := rows.Scan(
+			&trip.ID, &trip.LineID, &trip.RouteID, &trip.VehicleJourneyCode, &trip.LineName, &trip.Direction,
+			&trip.OperatorID, &trip.OperatorName, &operatorShortName,
+			&trip.DepartureTime, pq.Array(&stopOffsets),
+		)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+
+			return nil, nil, err
+		}
+
+		if trip.OperatorName == "" {
+			trip.OperatorName = operatorShortName
+		}
+
+		// stops which weren't imported leave gaps in the stop numbers
+		trip.Stops = make([]TripStop, 0)
+		for _, stop := range routeStops[trip.LineID + "\x00" + trip.RouteID] {
+			if int(stop.stopNumber) < len(stopOffsets) {
+				trip.Stops = append(trip.Stops, TripStop{
+					BusStopID: stop.busStopID,
+					Offset: uint(stopOffsets[stop.stopNumber]),
+				})
+			}
+		}
+
+		trips = append(trips, trip)
+	}
+
+	return trips, busStops, rows.Err()
+}
+
+// getRouteStops gets the ordered stops of the routes selected by query keyed by
+// line and route ID, and each bus stop they call at once keyed by ID
+func getRouteStops(db *sql.DB, query string, args ...interface{}) (map[string][]routeStop, map[string]BusStop, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to execute select route stops statement", err)
+
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	routeStops := make(map[string][]routeStop)
+	busStops := make(map[string]BusStop)
+
+	for rows.Next() {
+		var lineID, routeID string
+		var stop routeStop
+		var busStop BusStop
+
+		err := rows.Scan(
+			&lineID, &routeID, &stop.stopNumber, &busStop.ID, &busStop.Name,
+			&busStop.Longitude, &busStop.Latitude, &busStop.Bearing,
+		)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+
+			return nil, nil, err
+		}
+
+		busStop.ID = TrimBusStopID(busStop.ID)
+		if _, ok := busStops[busStop.ID]; !ok {
+			busStops[busStop.ID] = busStop
+		}
+
+		// the ID of the stored bus stop is shared by every route calling at it
+		stop.busStopID = busStops[busStop.ID].ID
+
+		key := lineID + "\x00" + routeID
+		routeStops[key] = append(routeStops[key], stop)
+	}
+
+	return routeStops, busStops, rows.Err()
+}
+
+// Itinerary is a planned journey between two bus stops made up of one leg per
+// bus
+type Itinerary struct {
+	DepartureTime time.Time
+	ArrivalTime   time.Time
+	Changes       uint
+	Legs          []JourneyLeg
+}
+
+// JourneyLeg is a trip from boarding to alighting. Stops includes the boarding
+// and alighting stops
+type JourneyLeg struct {
+	TripID        uint
+	LineID        string
+	RouteID       string
+	LineName      string
+	Direction     string
+	OperatorID    string
+	OperatorName  string
+	DepartureTime time.Time
+	ArrivalTime   time.Time
+	Stops         []BusStop
+}
+
+// ReachableStop is a bus stop reachable from another by bus and walking,
+// arriving at the ArrivalTime after Minutes of travelling
+type ReachableStop struct {
+	BusStop     BusStop
+	ArrivalTime time.Time
+	Minutes     uint
+}
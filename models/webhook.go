@@ -0,0 +1,294 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+	"github.com/lib/pq"
+)
+
+// Webhook is a URL which is sent the final state of each background job of
+// Types which finishes with one of Statuses. Empty Types or Statuses match
+// every type or final status. Secret signs the payloads, and is only returned
+// when the webhook is created
+type Webhook struct {
+	ID        uint
+	URI       string
+	URL       string
+	Types     []string
+	Statuses  []string
+	Secret    string `json:",omitempty"`
+	CreatedAt time.Time
+}
+
+// WebhookDelivery is an attempt to send a webhook the final state of a job.
+// StatusCode is 0 when no response was received, and Error is why the attempt
+// wasn't Delivered. An attempt which hasn't been sent yet is sent at
+// NextAttemptAt
+type WebhookDelivery struct {
+	ID            uint
+	WebhookID     uint
+	JobID         uint
+	Event         string
+	Attempt       uint
+	StatusCode    int
+	Error         string     `json:",omitempty"`
+	Delivered     bool
+	NextAttemptAt *time.Time `json:",omitempty"`
+	CreatedAt     time.Time
+}
+
+// WebhookStatuses are the final statuses of a background job which webhooks
+// can be sent
+var WebhookStatuses = []string{"COMPLETE", "FAILED", "CANCELLED"}
+
+const insertWebhook string = `INSERT INTO webhook(url, job_types, statuses, secret) VALUES($1, $2::VARCHAR(64)[], $3::status[], $4)
+RETURNING id, url, job_types, statuses, secret, created_at`
+const selectWebhooks string = "SELECT id, url, job_types, statuses, created_at FROM webhook ORDER BY id"
+const selectWebhookByID string = "SELECT id, url, job_types, statuses, created_at FROM webhook WHERE id = $1"
+const selectJobWebhooks string = `SELECT id, url, job_types, statuses, secret, created_at FROM webhook
+WHERE (cardinality(job_types) = 0 OR $1::VARCHAR(64) = ANY(job_types)) AND (cardinality(statuses) = 0 OR $2::status = ANY(statuses))
+ORDER BY id`
+const deleteWebhookSQL string = "DELETE FROM webhook WHERE id = $1"
+const insertWebhookDeliverySQL string = `INSERT INTO webhook_delivery(webhook_id, job_id, event, attempt, delivered, next_attempt_at)
+VALUES($1, $2, $3, $4, FALSE, NOW() + make_interval(secs => $5))`
+const claimWebhookDeliverySQL string = `WITH claimed AS (
+	UPDATE webhook_delivery SET next_attempt_at = NOW() + make_interval(secs => $1)
+	WHERE id = (
+		SELECT id FROM webhook_delivery WHERE next_attempt_at <= NOW()
+		ORDER BY next_attempt_at, id LIMIT 1 FOR UPDATE SKIP LOCKED
+	) RETURNING id, webhook_id, job_id, event, attempt
+)
+SELECT claimed.id, claimed.webhook_id, claimed.job_id, claimed.event, claimed.attempt, webhook.url, webhook.secret
+FROM claimed JOIN webhook ON webhook.id = claimed.webhook_id`
+const finishWebhookDeliverySQL string = `UPDATE webhook_delivery SET (status_code, error, delivered, next_attempt_at, created_at) = ($1, $2, $3, NULL, NOW())
+WHERE id = $4`
+const selectWebhookDeliveries string = `SELECT id, webhook_id, job_id, event, attempt, status_code, error, delivered, next_attempt_at, created_at
+FROM webhook_delivery WHERE webhook_id = $1 ORDER BY id DESC LIMIT $2`
+
+// CreateWebhook registers a webhook signed with secret
+func CreateWebhook(url string, types []string, statuses []string, secret string, db *sql.DB) (Webhook, error) {
+	var webhook Webhook
+
+	err := db.QueryRow(insertWebhook, url, pq.Array(types), pq.Array(statuses), secret).Scan(
+		&webhook.ID, &webhook.URL, pq.Array(&webhook.Types), pq.Array(&webhook.Statuses),
+		&webhook.Secret, &webhook.CreatedAt,
+	)
+	if err != nil {
+		log.Println("Error inserting webhook to db", err)
+		return Webhook{}, errors.New("Error inserting into webhook")
+	}
+
+	webhook.URI = fmt.Sprintf("/api/webhooks/%v", webhook.ID)
+
+	return webhook, nil
+}
+
+// GetWebhooks gets every webhook without its secret
+func GetWebhooks(db *sql.DB) ([]Webhook, error) {
+	rows, err := db.Query(selectWebhooks)
+	if err != nil {
+		log.Println("Error getting webhooks from db", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := make([]Webhook, 0)
+
+	for rows.Next() {
+		var webhook Webhook
+
+		err := rows.Scan(
+			&webhook.ID, &webhook.URL, pq.Array(&webhook.Types), pq.Array(&webhook.Statuses),
+			&webhook.CreatedAt,
+		)
+		if err != nil {
+			log.Println("Error scanning webhook", err)
+			return nil, err
+		}
+
+		webhook.URI = fmt.Sprintf("/api/webhooks/%v", webhook.ID)
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks, rows.Err()
+}
+
+// GetWebhook gets a webhook without its secret. Returns sql.ErrNoRows when
+// there is no webhook with the id
+func GetWebhook(id uint, db *sql.DB) (Webhook, error) {
+	var webhook Webhook
+
+	err := db.QueryRow(selectWebhookByID, id).Scan(
+		&webhook.ID, &webhook.URL, pq.Array(&webhook.Types), pq.Array(&webhook.Statuses),
+		&webhook.CreatedAt,
+	)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Println("Error getting webhook from db", err)
+		}
+
+		return Webhook{}, err
+	}
+
+	webhook.URI = fmt.Sprintf("/api/webhooks/%v", webhook.ID)
+
+	return webhook, nil
+}
+
+// GetJobWebhooks gets the webhooks, with their secrets, which are sent jobs of
+// a type finishing with a status
+func GetJobWebhooks(jobType string, status string, db *sql.DB) ([]Webhook, error) {
+	rows, err := db.Query(selectJobWebhooks, jobType, status)
+	if err != nil {
+		log.Println("Error getting job webhooks from db", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := make([]Webhook, 0)
+
+	for rows.Next() {
+		var webhook Webhook
+
+		err := rows.Scan(
+			&webhook.ID, &webhook.URL, pq.Array(&webhook.Types), pq.Array(&webhook.Statuses),
+			&webhook.Secret, &webhook.CreatedAt,
+		)
+		if err != nil {
+			log.Println("Error scanning webhook", err)
+			return nil, err
+		}
+
+		webhook.URI = fmt.Sprintf("/api/webhooks/%v", webhook.ID)
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks, rows.Err()
+}
+
+// DeleteWebhook removes a webhook and its deliveries. deleted is false when
+// there is no webhook with the id
+func DeleteWebhook(id uint, db *sql.DB) (bool, error) {
+	result, err := db.Exec(deleteWebhookSQL, id)
+	if err != nil {
+		log.Println("Error deleting webhook from db", err)
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected > 0, nil
+}
+
+// QueueWebhookDelivery queues an attempt to send a webhook a job, which is sent
+// once delay has passed
+func QueueWebhookDelivery(delivery WebhookDelivery, delay time.Duration, db *sql.DB) error {
+	_, err := db.Exec(insertWebhookDeliverySQL, delivery.WebhookID, delivery.JobID, delivery.Event, delivery.Attempt, delay.Seconds())
+	if err != nil {
+		log.Println("Error inserting webhook delivery to db", err)
+		return errors.New("Error inserting into webhook_delivery")
+	}
+
+	return nil
+}
+
+// ClaimWebhookDelivery claims the next queued attempt which is due, returning
+// it with its webhook and secret. The attempt is due again after lease unless
+// it is finished first, so it is still sent if the process sending it stops.
+// Returns sql.ErrNoRows when no attempt is due
+func ClaimWebhookDelivery(lease time.Duration, db *sql.DB) (WebhookDelivery, Webhook, error) {
+	var delivery WebhookDelivery
+	var webhook Webhook
+
+	err := db.QueryRow(claimWebhookDeliverySQL, lease.Seconds()).Scan(
+		&delivery.ID, &delivery.WebhookID, &delivery.JobID, &delivery.Event, &delivery.Attempt,
+		&webhook.URL, &webhook.Secret,
+	)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Println("Error claiming webhook delivery", err)
+		}
+
+		return WebhookDelivery{}, Webhook{}, err
+	}
+
+	webhook.ID = delivery.WebhookID
+	webhook.URI = fmt.Sprintf("/api/webhooks/%v", webhook.ID)
+
+	return delivery, webhook, nil
+}
+
+// FinishWebhookDelivery records the outcome of a claimed attempt. When it
+// wasn't Delivered and retry is true, the next attempt is queued to be sent
+// after retryDelay
+func FinishWebhookDelivery(delivery WebhookDelivery, retry bool, retryDelay time.Duration, db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		log.Println("Couldn't create database transaction", err)
+		return err
+	}
+
+	_, err = tx.Exec(finishWebhookDeliverySQL, delivery.StatusCode, delivery.Error, delivery.Delivered, delivery.ID)
+	if err != nil {
+		log.Println("Error updating webhook delivery in db", err)
+		tx.Rollback()
+		return errors.New("Error updating webhook_delivery")
+	}
+
+	if !delivery.Delivered && retry {
+		_, err := tx.Exec(insertWebhookDeliverySQL, delivery.WebhookID, delivery.JobID, delivery.Event, delivery.Attempt + 1, retryDelay.Seconds())
+		if err != nil {
+			log.Println("Error inserting webhook delivery to db", err)
+			tx.Rollback()
+			return errors.New("Error inserting into webhook_delivery")
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println("Transaction failed", err)
+		return errors.New("Transaction failed")
+	}
+
+	return nil
+}
+
+// GetWebhookDeliveries gets up to limit of the latest deliveries of a webhook,
+// newest first
+func GetWebhookDeliveries(webhookID uint, limit uint, db *sql.DB) ([]WebhookDelivery, error) {
+	rows, err := db.Query(selectWebhookDeliveries, webhookID, limit)
+	if err != nil {
+		log.Println("Error getting webhook deliveries from db", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := make([]WebhookDelivery, 0)
+
+	for rows.Next() {
+		var delivery WebhookDelivery
+		var nextAttemptAt sql.NullTime
+
+		err := rows.Scan(
+			&delivery.ID, &delivery.WebhookID, &delivery.JobID, &delivery.Event, &delivery.Attempt,
+			&delivery.StatusCode, &delivery.Error, &delivery.Delivered, &nextAttemptAt, &delivery.CreatedAt,
+		)
+		if err != nil {
+			log.Println("Error scanning webhook delivery", err)
+			return nil, err
+		}
+
+		if nextAttemptAt.Valid {
+			delivery.NextAttemptAt = &nextAttemptAt.Time
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
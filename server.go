@@ -3,6 +3,7 @@ package main
 import (
 	"server/utils"
 	"server/handlers"
+	"server/controllers"
 	"fmt"
 	"net/http"
 	"os"
@@ -16,6 +17,16 @@ func main() {
 		os.Exit(1)
 	}
 
+	// requeue or fail jobs left running when the server last stopped, then start
+	// running queued jobs
+	controllers.RecoverBackgroundJobs()
+	controllers.StartBackgroundJobWorkers()
+
+	if err := controllers.StartScheduler(); err != nil {
+		fmt.Fprintln(os.Stderr, "Invalid schedules", err)
+		os.Exit(1)
+	}
+
 	router := http.NewServeMux()
 
 	// file server
@@ -31,12 +42,27 @@ func main() {
 	// api routes
 	router.HandleFunc("/api/bus-locations", handlers.BusLocation)
 	router.HandleFunc("/api/bus-stops", handlers.BusStop)
+	router.HandleFunc("/api/bus-stops/", handlers.BusStop)
 	router.HandleFunc("/api/job", handlers.BackgroundJob)
 	router.HandleFunc("/api/job/", handlers.BackgroundJob)
+	router.HandleFunc("/api/schedules", handlers.Schedules)
+	router.HandleFunc("/api/webhooks", handlers.Webhooks)
+	router.HandleFunc("/api/webhooks/", handlers.Webhooks)
 	router.HandleFunc("/api/bus-routes", handlers.BusRoutes)
 	router.HandleFunc("/api/bus-routes/", handlers.BusRoutes)
+	router.HandleFunc("/api/datasets", handlers.Datasets)
+	router.HandleFunc("/api/operators", handlers.Operators)
+	router.HandleFunc("/api/operators/", handlers.Operators)
+	router.HandleFunc("/api/lines", handlers.Lines)
+	router.HandleFunc("/api/journeys", handlers.Journeys)
+	router.HandleFunc("/api/reachability", handlers.Reachability)
+	router.HandleFunc("/api/export/", handlers.Export)
+	router.HandleFunc("/api/gtfs-rt/", handlers.GTFSRealtime)
 	router.HandleFunc("/api/health-check", handlers.HealthCheck)
 
+	// tile routes
+	router.HandleFunc("/tiles/", handlers.Tiles)
+
 	// html routes
 	router.HandleFunc("/", handlers.Index)
 
@@ -0,0 +1,35 @@
+package transformers
+
+import (
+	"server/types"
+	"time"
+)
+
+// VehiclePosition transforms a siri vehicle activity to a GTFS Realtime vehicle
+// position without a trip. The entity ID is the operator and vehicle reference
+// as vehicle references are only unique to an operator
+func VehiclePosition(vehicleActivity types.VehicleActivity) (types.GTFSRealtimeFeedEntity, error) {
+	recordedAt, err := time.Parse(time.RFC3339, vehicleActivity.RecorderAtTime)
+	if err != nil {
+		return types.GTFSRealtimeFeedEntity{}, err
+	}
+
+	journey := vehicleActivity.MonitoredVehicleJourney
+	vehicleID := journey.OperatorRef + ":" + journey.VehicleRef
+
+	return types.GTFSRealtimeFeedEntity{
+		ID: vehicleID,
+		Vehicle: types.GTFSRealtimeVehiclePosition{
+			Vehicle: types.GTFSRealtimeVehicleDescriptor{
+				ID: vehicleID,
+				Label: journey.VehicleRef,
+			},
+			Position: types.GTFSRealtimePosition{
+				Latitude: journey.VehicleLocation.Latitude,
+				Longitude: journey.VehicleLocation.Longitude,
+				Bearing: journey.Bearing,
+			},
+			Timestamp: uint64(recordedAt.Unix()),
+		},
+	}, nil
+}
@@ -0,0 +1,8 @@
+package types
+
+// BoundingBox contains the south west (Min) and north east (Max) corners of an
+// area
+type BoundingBox struct {
+	Min Coordinate
+	Max Coordinate
+}
@@ -0,0 +1,21 @@
+package types
+
+// GeoJSONFeature is a GeoJSON (RFC 7946) feature
+type GeoJSONFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   GeoJSONGeometry        `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// GeoJSONFeatureCollection is a GeoJSON (RFC 7946) feature collection
+type GeoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []GeoJSONFeature `json:"features"`
+}
+
+// GeoJSONGeometry is a GeoJSON geometry. The Coordinates are nested
+// [longitude, latitude] positions depending on the Type
+type GeoJSONGeometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
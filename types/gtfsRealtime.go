@@ -0,0 +1,56 @@
+package types
+
+// GTFSRealtimeFeedMessage is a GTFS Realtime feed. The JSON field names match
+// the GTFS Realtime protocol buffer field names
+type GTFSRealtimeFeedMessage struct {
+	Header GTFSRealtimeFeedHeader   `json:"header"`
+	Entity []GTFSRealtimeFeedEntity `json:"entity"`
+}
+
+// GTFSRealtimeFeedHeader describes a GTFS Realtime feed. Timestamp is in
+// seconds since the Unix epoch
+type GTFSRealtimeFeedHeader struct {
+	GTFSRealtimeVersion string `json:"gtfs_realtime_version"`
+	Incrementality      string `json:"incrementality"`
+	Timestamp           uint64 `json:"timestamp"`
+}
+
+// GTFSRealtimeFeedEntity is a vehicle position within a GTFS Realtime feed
+type GTFSRealtimeFeedEntity struct {
+	ID      string                      `json:"id"`
+	Vehicle GTFSRealtimeVehiclePosition `json:"vehicle"`
+}
+
+// GTFSRealtimeVehiclePosition is the position of a vehicle and the trip it is
+// running. Trip is nil when the vehicle's line can't be matched. Timestamp is
+// when the position was recorded in seconds since the Unix epoch
+type GTFSRealtimeVehiclePosition struct {
+	Trip      *GTFSRealtimeTripDescriptor   `json:"trip,omitempty"`
+	Vehicle   GTFSRealtimeVehicleDescriptor `json:"vehicle"`
+	Position  GTFSRealtimePosition          `json:"position"`
+	Timestamp uint64                        `json:"timestamp"`
+}
+
+// GTFSRealtimeTripDescriptor identifies a GTFS trip and route. TripID,
+// StartTime and StartDate are empty when the trip can't be matched
+type GTFSRealtimeTripDescriptor struct {
+	TripID      string  `json:"trip_id,omitempty"`
+	RouteID     string  `json:"route_id,omitempty"`
+	DirectionID *uint32 `json:"direction_id,omitempty"`
+	StartTime   string  `json:"start_time,omitempty"`
+	StartDate   string  `json:"start_date,omitempty"`
+}
+
+// GTFSRealtimeVehicleDescriptor identifies a vehicle
+type GTFSRealtimeVehicleDescriptor struct {
+	ID    string `json:"id"`
+	Label string `json:"label,omitempty"`
+}
+
+// GTFSRealtimePosition is a WGS84 position and bearing in degrees clockwise
+// from north
+type GTFSRealtimePosition struct {
+	Latitude  float32 `json:"latitude"`
+	Longitude float32 `json:"longitude"`
+	Bearing   float32 `json:"bearing"`
+}
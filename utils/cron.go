@@ -0,0 +1,221 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronSchedule is when a cron expression is due. Each field is a set of the
+// minutes, hours, days of the month, months and days of the week it matches
+type CronSchedule struct {
+	minutes  uint64
+	hours    uint64
+	days     uint64
+	months   uint64
+	weekdays uint64
+	// anyDay and anyWeekday are true when the field was *. When both fields are
+	// restricted a time matches either of them, as in cron
+	anyDay     bool
+	anyWeekday bool
+	location   *time.Location
+}
+
+// cronDescriptors are the shorthand expressions cron accepts
+var cronDescriptors = map[string]string{
+	"@yearly": "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly": "0 0 1 * *",
+	"@weekly": "0 0 * * 0",
+	"@daily": "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly": "0 * * * *",
+}
+
+var cronMonthNames = map[string]uint{
+	"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6,
+	"JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12,
+}
+
+var cronWeekdayNames = map[string]uint{
+	"SUN": 0, "MON": 1, "TUE": 2, "WED": 3, "THU": 4, "FRI": 5, "SAT": 6,
+}
+
+// cronSearchYears is how far ahead Next looks for a due time before deciding
+// the expression is never due, such as the 30th of February
+const cronSearchYears = 5
+
+// ParseCronSchedule parses a five field cron expression (minute, hour, day of
+// month, month and day of week) in a time zone. Fields are *, a value, a range
+// such as 1-5, a step such as */15 or 0-30/10, or a list of them such as 1,15.
+// Months and days of the week can be named, such as JAN or MON, and Sunday is
+// 0 or 7. The @yearly, @monthly, @weekly, @daily and @hourly shorthands are
+// also accepted
+func ParseCronSchedule(expression string, location *time.Location) (CronSchedule, error) {
+	if descriptor, ok := cronDescriptors[strings.ToLower(strings.TrimSpace(expression))]; ok {
+		expression = descriptor
+	}
+
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return CronSchedule{}, fmt.Errorf("Cron expression %q must have 5 fields", expression)
+	}
+
+	schedule := CronSchedule{
+		anyDay: fields[2] == "*",
+		anyWeekday: fields[4] == "*",
+		location: location,
+	}
+
+	var err error
+	if schedule.minutes, err = parseCronField(fields[0], 0, 59, nil); err != nil {
+		return CronSchedule{}, fmt.Errorf("Invalid minute: %v", err)
+	}
+	if schedule.hours, err = parseCronField(fields[1], 0, 23, nil); err != nil {
+		return CronSchedule{}, fmt.Errorf("Invalid hour: %v", err)
+	}
+	if schedule.days, err = parseCronField(fields[2], 1, 31, nil); err != nil {
+		return CronSchedule{}, fmt.Errorf("Invalid day of month: %v", err)
+	}
+	if schedule.months, err = parseCronField(fields[3], 1, 12, cronMonthNames); err != nil {
+		return CronSchedule{}, fmt.Errorf("Invalid month: %v", err)
+	}
+	if schedule.weekdays, err = parseCronField(fields[4], 0, 7, cronWeekdayNames); err != nil {
+		return CronSchedule{}, fmt.Errorf("Invalid day of week: %v", err)
+	}
+
+	// Sunday is both 0 and 7
+	if schedule.weekdays & (1 << 7) != 0 {
+		schedule.weekdays |= 1
+	}
+
+	return schedule, nil
+}
+
+// parseCronField parses a comma separated list of values, ranges and steps
+// between min and max into a set of the values it matches
+func parseCronField(field string, min uint, max uint, names map[string]uint) (uint64, error) {
+	var set uint64
+
+	for _, part := range strings.Split(field, ",") {
+		valueRange, step := part, uint(1)
+
+		if slash := strings.Index(part, "/"); slash >= 0 {
+			parsedStep, err := strconv.ParseUint(part[slash + 1:], 10, 8)
+			if err != nil || parsedStep == 0 {
+				return 0, fmt.Errorf("%q has an invalid step", part)
+			}
+
+			valueRange, step = part[:slash], uint(parsedStep)
+		}
+
+		var from, to uint
+		switch {
+			case valueRange == "*":
+				from, to = min, max
+			case strings.Contains(valueRange, "-"):
+				bounds := strings.SplitN(valueRange, "-", 2)
+
+				var err error
+				if from, err = parseCronValue(bounds[0], min, max, names); err != nil {
+					return 0, err
+				}
+				if to, err = parseCronValue(bounds[1], min, max, names); err != nil {
+					return 0, err
+				}
+				if from > to {
+					return 0, fmt.Errorf("%q starts after it ends", part)
+				}
+			default:
+				value, err := parseCronValue(valueRange, min, max, names)
+				if err != nil {
+					return 0, err
+				}
+
+				// a value with a step, such as 5/15, runs from the value to max
+				from, to = value, value
+				if step > 1 {
+					to = max
+				}
+		}
+
+		for value := from; value <= to; value += step {
+			set |= 1 << value
+		}
+	}
+
+	return set, nil
+}
+
+func parseCronValue(value string, min uint, max uint, names map[string]uint) (uint, error) {
+	if named, ok := names[strings.ToUpper(value)]; ok {
+		return named, nil
+	}
+
+	parsed, err := strconv.ParseUint(value, 10, 8)
+	if err != nil {
+		return 0, fmt.Errorf("%q isn't a number", value)
+	}
+
+	if uint(parsed) < min || uint(parsed) > max {
+		return 0, fmt.Errorf("%d isn't between %d and %d", parsed, min, max)
+	}
+
+	return uint(parsed), nil
+}
+
+// Next gets the first minute after after when the schedule is due, in the
+// schedule's time zone. Times skipped by the clocks going forward aren't due.
+// The zero time is returned when the schedule is never due, such as on the
+// 30th of February
+func (schedule CronSchedule) Next(after time.Time) time.Time {
+	location := schedule.location
+	if location == nil {
+		location = time.UTC
+	}
+
+	next := after.In(location).Truncate(time.Minute).Add(time.Minute)
+	limit := next.AddDate(cronSearchYears, 0, 0)
+
+	for next.Before(limit) {
+		year, month, day := next.Date()
+
+		if schedule.months & (1 << uint(month)) == 0 {
+			next = time.Date(year, month + 1, 1, 0, 0, 0, 0, location)
+			continue
+		}
+
+		if !schedule.matchesDay(next) {
+			next = time.Date(year, month, day + 1, 0, 0, 0, 0, location)
+			continue
+		}
+
+		if schedule.hours & (1 << uint(next.Hour())) == 0 {
+			next = time.Date(year, month, day, next.Hour() + 1, 0, 0, 0, location)
+			continue
+		}
+
+		// minutes are added rather than set so the clock going back doesn't
+		// return a time before after
+		if schedule.minutes & (1 << uint(next.Minute())) == 0 {
+			next = next.Add(time.Minute)
+			continue
+		}
+
+		return next
+	}
+
+	return time.Time{}
+}
+
+func (schedule CronSchedule) matchesDay(date time.Time) bool {
+	dayMatches := schedule.days & (1 << uint(date.Day())) != 0
+	weekdayMatches := schedule.weekdays & (1 << uint(date.Weekday())) != 0
+
+	if !schedule.anyDay && !schedule.anyWeekday {
+		return dayMatches || weekdayMatches
+	}
+
+	return dayMatches && weekdayMatches
+}
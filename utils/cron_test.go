@@ -0,0 +1,174 @@
+package utils
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func Test_ParseCronSchedule(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		wantErr    bool
+	}{
+		{
+			name: "Parses every minute",
+			expression: "* * * * *",
+			wantErr: false,
+		},
+		{
+			name: "Parses lists, ranges, steps and names",
+			expression: "0,30 1-5 */2 JAN-MAR mon-fri",
+			wantErr: false,
+		},
+		{
+			name: "Parses a shorthand",
+			expression: "@daily",
+			wantErr: false,
+		},
+		{
+			name: "Rejects too few fields",
+			expression: "0 2 * *",
+			wantErr: true,
+		},
+		{
+			name: "Rejects a minute out of range",
+			expression: "60 2 * * *",
+			wantErr: true,
+		},
+		{
+			name: "Rejects a day of month of 0",
+			expression: "0 2 0 * *",
+			wantErr: true,
+		},
+		{
+			name: "Rejects a range which starts after it ends",
+			expression: "0 5-1 * * *",
+			wantErr: true,
+		},
+		{
+			name: "Rejects a step of 0",
+			expression: "*/0 * * * *",
+			wantErr: true,
+		},
+		{
+			name: "Rejects an unknown name",
+			expression: "0 2 * * MONDAY",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseCronSchedule(tt.expression, time.UTC)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseCronSchedule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCronSchedule_Next(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		expression string
+		location   *time.Location
+		after      time.Time
+		want       time.Time
+	}{
+		{
+			name: "Gets the next minute",
+			expression: "* * * * *",
+			location: time.UTC,
+			after: time.Date(2021, 4, 6, 21, 33, 48, 0, time.UTC),
+			want: time.Date(2021, 4, 6, 21, 34, 0, 0, time.UTC),
+		},
+		{
+			name: "Gets the next night when tonight's time has passed",
+			expression: "0 2 * * *",
+			location: time.UTC,
+			after: time.Date(2021, 4, 6, 2, 0, 0, 0, time.UTC),
+			want: time.Date(2021, 4, 7, 2, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "Gets the next step",
+			expression: "*/15 * * * *",
+			location: time.UTC,
+			after: time.Date(2021, 4, 6, 21, 33, 48, 0, time.UTC),
+			want: time.Date(2021, 4, 6, 21, 45, 0, 0, time.UTC),
+		},
+		{
+			name: "Gets the next weekday",
+			expression: "30 3 * * MON-FRI",
+			location: time.UTC,
+			after: time.Date(2021, 4, 9, 12, 0, 0, 0, time.UTC),
+			want: time.Date(2021, 4, 12, 3, 30, 0, 0, time.UTC),
+		},
+		{
+			name: "Treats 7 as Sunday",
+			expression: "0 0 * * 7",
+			location: time.UTC,
+			after: time.Date(2021, 4, 6, 0, 0, 0, 0, time.UTC),
+			want: time.Date(2021, 4, 11, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "Matches either the day of month or day of week when both are restricted",
+			expression: "0 0 15 * SUN",
+			location: time.UTC,
+			after: time.Date(2021, 4, 12, 0, 0, 0, 0, time.UTC),
+			want: time.Date(2021, 4, 15, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "Gets the next month",
+			expression: "@monthly",
+			location: time.UTC,
+			after: time.Date(2021, 12, 6, 0, 0, 0, 0, time.UTC),
+			want: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "Gets the next leap day",
+			expression: "0 0 29 2 *",
+			location: time.UTC,
+			after: time.Date(2021, 4, 6, 0, 0, 0, 0, time.UTC),
+			want: time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "Gets the zero time when never due",
+			expression: "0 0 30 2 *",
+			location: time.UTC,
+			after: time.Date(2021, 4, 6, 0, 0, 0, 0, time.UTC),
+			want: time.Time{},
+		},
+		{
+			name: "Runs in the schedule's time zone",
+			expression: "0 2 * * *",
+			location: london,
+			after: time.Date(2021, 4, 6, 12, 0, 0, 0, time.UTC),
+			want: time.Date(2021, 4, 7, 1, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "Skips a time in the hour the clocks go forward",
+			expression: "30 1 * * *",
+			location: london,
+			after: time.Date(2021, 3, 27, 12, 0, 0, 0, time.UTC),
+			want: time.Date(2021, 3, 29, 0, 30, 0, 0, time.UTC),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := ParseCronSchedule(tt.expression, tt.location)
+			if err != nil {
+				t.Fatalf("ParseCronSchedule() error = %v", err)
+			}
+
+			if got := schedule.Next(tt.after); !got.Equal(tt.want) {
+				t.Errorf("CronSchedule.Next() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
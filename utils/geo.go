@@ -0,0 +1,279 @@
+package utils
+
+import (
+	"math"
+	"server/types"
+	"sort"
+)
+
+// earthRadius is the mean radius of the earth in metres
+const earthRadius = 6371008.8
+
+func toRadians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
+
+func toDegrees(radians float64) float64 {
+	return radians * 180 / math.Pi
+}
+
+// Distance gets the great circle distance in metres between two coordinates
+func Distance(from types.Coordinate, to types.Coordinate) float64 {
+	fromLatitude := toRadians(float64(from.Latitude))
+	toLatitude := toRadians(float64(to.Latitude))
+	deltaLatitude := toLatitude - fromLatitude
+	deltaLongitude := toRadians(float64(to.Longitude - from.Longitude))
+
+	a := math.Sin(deltaLatitude / 2) * math.Sin(deltaLatitude / 2) +
+		math.Cos(fromLatitude) * math.Cos(toLatitude) *
+		math.Sin(deltaLongitude / 2) * math.Sin(deltaLongitude / 2)
+
+	return 2 * earthRadius * math.Atan2(math.Sqrt(a), math.Sqrt(1 - a))
+}
+
+// metresPerDegreeLatitude is the distance in metres of a degree of latitude
+const metresPerDegreeLatitude = earthRadius * math.Pi / 180
+
+// CircleArea is the area within Radius metres of a coordinate
+type CircleArea struct {
+	Centre types.Coordinate
+	Radius float64
+}
+
+type gridPoint struct {
+	x int
+	y int
+}
+
+type gridEdge struct {
+	from gridPoint
+	to   gridPoint
+}
+
+// UnionCircles approximates the union of circles as polygons made of square
+// grid cells of cellSize metres, filling each cell whose centre is in a circle.
+// Each polygon is a counter-clockwise exterior ring followed by the clockwise
+// rings of its holes, as GeoJSON polygons must be. Polygons may touch at a
+// corner but never overlap
+func UnionCircles(circles []CircleArea, cellSize float64) [][][]types.Coordinate {
+	polygons := make([][][]types.Coordinate, 0)
+	if len(circles) == 0 {
+		return polygons
+	}
+
+	// coordinates are projected onto a grid in metres from the first circle,
+	// which is accurate enough over the area walked to from a few stops
+	origin := circles[0].Centre
+	metresPerDegreeLongitude := metresPerDegreeLatitude * math.Cos(toRadians(float64(origin.Latitude)))
+
+	cells := make(map[gridPoint]bool)
+	for _, circle := range circles {
+		x := float64(circle.Centre.Longitude - origin.Longitude) * metresPerDegreeLongitude / cellSize
+		y := float64(circle.Centre.Latitude - origin.Latitude) * metresPerDegreeLatitude / cellSize
+		radius := circle.Radius / cellSize
+
+		cells[gridPoint{int(math.Floor(x)), int(math.Floor(y))}] = true
+		for cellX := int(math.Floor(x - radius)); cellX <= int(math.Floor(x + radius)); cellX++ {
+			for cellY := int(math.Floor(y - radius)); cellY <= int(math.Floor(y + radius)); cellY++ {
+				if math.Hypot(float64(cellX) + 0.5 - x, float64(cellY) + 0.5 - y) <= radius {
+					cells[gridPoint{cellX, cellY}] = true
+				}
+			}
+		}
+	}
+
+	toCoordinate := func(point gridPoint) types.Coordinate {
+		return types.Coordinate{
+			Longitude: origin.Longitude + float32(float64(point.x) * cellSize / metresPerDegreeLongitude),
+			Latitude: origin.Latitude + float32(float64(point.y) * cellSize / metresPerDegreeLatitude),
+		}
+	}
+
+	exteriors := make([][]gridPoint, 0)
+	holes := make([][]gridPoint, 0)
+	for _, ring := range boundaryRings(cells) {
+		if ringArea(ring) > 0 {
+			exteriors = append(exteriors, ring)
+		} else {
+			holes = append(holes, ring)
+		}
+	}
+
+	polygonRings := make([][][]gridPoint, len(exteriors))
+	for exteriorIndex, exterior := range exteriors {
+		polygonRings[exteriorIndex] = [][]gridPoint{exterior}
+	}
+
+	// each hole belongs to the smallest exterior ring around the centre of the
+	// empty cell to the right of its first corner
+	for _, hole := range holes {
+		directionX := sign(hole[1].x - hole[0].x)
+		directionY := sign(hole[1].y - hole[0].y)
+		x := float64(hole[0].x) + float64(directionX + directionY) / 2
+		y := float64(hole[0].y) + float64(directionY - directionX) / 2
+
+		smallest := -1
+		for exteriorIndex, exterior := range exteriors {
+			if ringContains(exterior, x, y) && (smallest == -1 || ringArea(exterior) < ringArea(exteriors[smallest])) {
+				smallest = exteriorIndex
+			}
+		}
+
+		if smallest != -1 {
+			polygonRings[smallest] = append(polygonRings[smallest], hole)
+		}
+	}
+
+	for _, rings := range polygonRings {
+		polygon := make([][]types.Coordinate, 0)
+		for _, ring := range rings {
+			coordinates := make([]types.Coordinate, 0)
+			for _, point := range ring {
+				coordinates = append(coordinates, toCoordinate(point))
+			}
+
+			polygon = append(polygon, coordinates)
+		}
+
+		polygons = append(polygons, polygon)
+	}
+
+	return polygons
+}
+
+// boundaryRings traces the edges between filled and empty grid cells into
+// closed rings without repeated corners, each with the filled cells on its left.
+// At a corner shared by two diagonal cells the left turn is taken, so the cells
+// are kept in separate rings
+func boundaryRings(cells map[gridPoint]bool) [][]gridPoint {
+	filledCells := make([]gridPoint, 0)
+	for cell := range cells {
+		filledCells = append(filledCells, cell)
+	}
+
+	sort.Slice(filledCells, func(i int, j int) bool {
+		if filledCells[i].y != filledCells[j].y {
+			return filledCells[i].y < filledCells[j].y
+		}
+
+		return filledCells[i].x < filledCells[j].x
+	})
+
+	edges := make([]gridEdge, 0)
+	for _, cell := range filledCells {
+		x, y := cell.x, cell.y
+		if !cells[gridPoint{x, y - 1}] {
+			edges = append(edges, gridEdge{gridPoint{x, y}, gridPoint{x + 1, y}})
+		}
+		if !cells[gridPoint{x + 1, y}] {
+			edges = append(edges, gridEdge{gridPoint{x + 1, y}, gridPoint{x + 1, y + 1}})
+		}
+		if !cells[gridPoint{x, y + 1}] {
+			edges = append(edges, gridEdge{gridPoint{x + 1, y + 1}, gridPoint{x, y + 1}})
+		}
+		if !cells[gridPoint{x - 1, y}] {
+			edges = append(edges, gridEdge{gridPoint{x, y + 1}, gridPoint{x, y}})
+		}
+	}
+
+	outgoing := make(map[gridPoint][]int)
+	for edgeIndex, edge := range edges {
+		outgoing[edge.from] = append(outgoing[edge.from], edgeIndex)
+	}
+
+	used := make([]bool, len(edges))
+	rings := make([][]gridPoint, 0)
+	for startIndex, start := range edges {
+		// rings start at a corner only one ring passes through
+		if used[startIndex] || len(outgoing[start.from]) > 1 {
+			continue
+		}
+
+		used[startIndex] = true
+		points := []gridPoint{start.from}
+		edge := start
+		for edge.to != start.from {
+			direction := gridPoint{edge.to.x - edge.from.x, edge.to.y - edge.from.y}
+			left := gridPoint{-direction.y, direction.x}
+
+			next := -1
+			for _, edgeIndex := range outgoing[edge.to] {
+				if used[edgeIndex] {
+					continue
+				}
+
+				nextDirection := gridPoint{edges[edgeIndex].to.x - edges[edgeIndex].from.x, edges[edgeIndex].to.y - edges[edgeIndex].from.y}
+				if next == -1 || nextDirection == left {
+					next = edgeIndex
+				}
+			}
+
+			used[next] = true
+			points = append(points, edge.to)
+			edge = edges[next]
+		}
+
+		rings = append(rings, removeStraightPoints(points))
+	}
+
+	return rings
+}
+
+// removeStraightPoints removes the points of a ring which aren't corners, and
+// closes the ring by repeating its first point
+func removeStraightPoints(points []gridPoint) []gridPoint {
+	ring := make([]gridPoint, 0)
+	for pointIndex, point := range points {
+		previous := points[(pointIndex + len(points) - 1) % len(points)]
+		next := points[(pointIndex + 1) % len(points)]
+
+		// the ring only has horizontal and vertical edges
+		if (previous.x == point.x && point.x == next.x) || (previous.y == point.y && point.y == next.y) {
+			continue
+		}
+
+		ring = append(ring, point)
+	}
+
+	return append(ring, ring[0])
+}
+
+// ringArea gets the signed area of a closed ring, which is positive when the
+// ring is counter-clockwise
+func ringArea(ring []gridPoint) float64 {
+	area := 0
+	for pointIndex := 0; pointIndex < len(ring) - 1; pointIndex++ {
+		area += ring[pointIndex].x * ring[pointIndex + 1].y - ring[pointIndex + 1].x * ring[pointIndex].y
+	}
+
+	return float64(area) / 2
+}
+
+// ringContains checks whether a point, which must not be on the ring, is inside
+// a closed ring
+func ringContains(ring []gridPoint, x float64, y float64) bool {
+	inside := false
+	for pointIndex := 0; pointIndex < len(ring) - 1; pointIndex++ {
+		from := ring[pointIndex]
+		to := ring[pointIndex + 1]
+
+		if (float64(from.y) > y) != (float64(to.y) > y) {
+			crossing := float64(from.x) + (y - float64(from.y)) * float64(to.x - from.x) / float64(to.y - from.y)
+			if x < crossing {
+				inside = !inside
+			}
+		}
+	}
+
+	return inside
+}
+
+func sign(value int) int {
+	if value < 0 {
+		return -1
+	} else if value > 0 {
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,212 @@
+package utils
+
+import (
+	"math"
+	"server/types"
+	"testing"
+)
+
+func Test_Distance(t *testing.T) {
+	type args struct {
+		from types.Coordinate
+		to   types.Coordinate
+	}
+	tests := []struct {
+		name string
+		args args
+		want float64
+	}{
+		{
+			name: "Gets the distance between Canterbury bus station and Darwin College",
+			args: args{
+				from: types.Coordinate{Longitude: 1.0813389, Latitude: 51.276302},
+				to: types.Coordinate{Longitude: 1.0713621, Latitude: 51.29914},
+			},
+			want: 2633,
+		},
+		{
+			name: "Gets no distance between the same coordinate",
+			args: args{
+				from: types.Coordinate{Longitude: 1.0813389, Latitude: 51.276302},
+				to: types.Coordinate{Longitude: 1.0813389, Latitude: 51.276302},
+			},
+			want: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Distance(tt.args.from, tt.args.to); math.Abs(got - tt.want) > 1 {
+				t.Errorf("Distance() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// coordinateArea gets the signed area of a closed ring, which is positive when
+// the ring is counter-clockwise
+func coordinateArea(ring []types.Coordinate) float64 {
+	area := 0.0
+	for pointIndex := 0; pointIndex < len(ring) - 1; pointIndex++ {
+		area += float64(ring[pointIndex].Longitude) * float64(ring[pointIndex + 1].Latitude) -
+			float64(ring[pointIndex + 1].Longitude) * float64(ring[pointIndex].Latitude)
+	}
+
+	return area / 2
+}
+
+func coordinateInRing(ring []types.Coordinate, point types.Coordinate) bool {
+	inside := false
+	for pointIndex := 0; pointIndex < len(ring) - 1; pointIndex++ {
+		from := ring[pointIndex]
+		to := ring[pointIndex + 1]
+
+		if (from.Latitude > point.Latitude) != (to.Latitude > point.Latitude) &&
+			point.Longitude < from.Longitude + (point.Latitude - from.Latitude) * (to.Longitude - from.Longitude) / (to.Latitude - from.Latitude) {
+			inside = !inside
+		}
+	}
+
+	return inside
+}
+
+// circleOfCircles gets count circles of radius metres spaced around a circle of
+// distance metres
+func circleOfCircles(centre types.Coordinate, distance float64, radius float64, count int) []CircleArea {
+	circles := make([]CircleArea, 0)
+	for circleIndex := 0; circleIndex < count; circleIndex++ {
+		angle := 2 * math.Pi * float64(circleIndex) / float64(count)
+		circles = append(circles, CircleArea{
+			Centre: types.Coordinate{
+				Longitude: centre.Longitude + float32(distance * math.Sin(angle) / (metresPerDegreeLatitude * math.Cos(toRadians(float64(centre.Latitude))))),
+				Latitude: centre.Latitude + float32(distance * math.Cos(angle) / metresPerDegreeLatitude),
+			},
+			Radius: radius,
+		})
+	}
+
+	return circles
+}
+
+func Test_UnionCircles(t *testing.T) {
+	busStation := types.Coordinate{Longitude: 1.0813389, Latitude: 51.276302}
+	darwinCollege := types.Coordinate{Longitude: 1.0713621, Latitude: 51.29914}
+	nearBusStation := types.Coordinate{Longitude: 1.0843389, Latitude: 51.276302}
+
+	type args struct {
+		circles  []CircleArea
+		cellSize float64
+	}
+	tests := []struct {
+		name      string
+		args      args
+		wantHoles []int
+		outside   []types.Coordinate
+	}{
+		{
+			name: "Gets no polygons without circles",
+			args: args{
+				circles: []CircleArea{},
+				cellSize: 50,
+			},
+			wantHoles: []int{},
+		},
+		{
+			name: "Merges overlapping circles into one polygon",
+			args: args{
+				circles: []CircleArea{
+					{Centre: busStation, Radius: 400},
+					{Centre: nearBusStation, Radius: 200},
+				},
+				cellSize: 50,
+			},
+			wantHoles: []int{0},
+			outside: []types.Coordinate{darwinCollege},
+		},
+		{
+			name: "Gets a polygon for each separate circle",
+			args: args{
+				circles: []CircleArea{
+					{Centre: busStation, Radius: 400},
+					{Centre: darwinCollege, Radius: 400},
+				},
+				cellSize: 50,
+			},
+			wantHoles: []int{0, 0},
+		},
+		{
+			name: "Gets a hole inside a ring of circles",
+			args: args{
+				circles: circleOfCircles(busStation, 400, 100, 16),
+				cellSize: 20,
+			},
+			wantHoles: []int{1},
+			outside: []types.Coordinate{busStation},
+		},
+		{
+			name: "Gets a polygon around a circle smaller than a cell",
+			args: args{
+				circles: []CircleArea{{Centre: busStation, Radius: 10}},
+				cellSize: 50,
+			},
+			wantHoles: []int{0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := UnionCircles(tt.args.circles, tt.args.cellSize)
+
+			if len(got) != len(tt.wantHoles) {
+				t.Fatalf("UnionCircles() = %v polygons, want %v", len(got), len(tt.wantHoles))
+			}
+
+			for polygonIndex, polygon := range got {
+				if len(polygon) != tt.wantHoles[polygonIndex] + 1 {
+					t.Errorf("UnionCircles() polygon %v has %v holes, want %v", polygonIndex, len(polygon) - 1, tt.wantHoles[polygonIndex])
+				}
+
+				for ringIndex, ring := range polygon {
+					if len(ring) < 5 || ring[0] != ring[len(ring) - 1] {
+						t.Fatalf("UnionCircles() polygon %v ring %v = %v, want a closed ring", polygonIndex, ringIndex, ring)
+					}
+
+					if area := coordinateArea(ring); ringIndex == 0 && area <= 0 {
+						t.Errorf("UnionCircles() polygon %v exterior ring is clockwise, want counter-clockwise", polygonIndex)
+					} else if ringIndex > 0 && area >= 0 {
+						t.Errorf("UnionCircles() polygon %v hole %v is counter-clockwise, want clockwise", polygonIndex, ringIndex)
+					}
+				}
+			}
+
+			inArea := func(point types.Coordinate) bool {
+				for _, polygon := range got {
+					if !coordinateInRing(polygon[0], point) {
+						continue
+					}
+
+					inHole := false
+					for _, hole := range polygon[1:] {
+						inHole = inHole || coordinateInRing(hole, point)
+					}
+
+					if !inHole {
+						return true
+					}
+				}
+
+				return false
+			}
+
+			for _, circle := range tt.args.circles {
+				if !inArea(circle.Centre) {
+					t.Errorf("UnionCircles() doesn't contain the centre %v", circle.Centre)
+				}
+			}
+
+			for _, point := range tt.outside {
+				if inArea(point) {
+					t.Errorf("UnionCircles() contains %v, want it outside", point)
+				}
+			}
+		})
+	}
+}
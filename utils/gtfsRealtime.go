@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"server/types"
+)
+
+// gtfsRealtimeIncrementality are the values of the FeedHeader Incrementality
+// enum
+var gtfsRealtimeIncrementality = map[string]uint64{
+	"FULL_DATASET": 0,
+	"DIFFERENTIAL": 1,
+}
+
+// EncodeGTFSRealtimeFeed encodes a feed as a GTFS Realtime FeedMessage protocol
+// buffer
+func EncodeGTFSRealtimeFeed(feed types.GTFSRealtimeFeedMessage) []byte {
+	header := make([]byte, 0)
+	header = appendLengthDelimited(header, 1, []byte(feed.Header.GTFSRealtimeVersion))
+	header = appendVarintField(header, 2, gtfsRealtimeIncrementality[feed.Header.Incrementality])
+	header = appendVarintField(header, 3, feed.Header.Timestamp)
+
+	encoded := appendLengthDelimited(make([]byte, 0), 1, header)
+	for _, entity := range feed.Entity {
+		encodedEntity := make([]byte, 0)
+		encodedEntity = appendLengthDelimited(encodedEntity, 1, []byte(entity.ID))
+		encodedEntity = appendLengthDelimited(encodedEntity, 4, encodeGTFSRealtimeVehiclePosition(entity.Vehicle))
+
+		encoded = appendLengthDelimited(encoded, 2, encodedEntity)
+	}
+
+	return encoded
+}
+
+func encodeGTFSRealtimeVehiclePosition(vehicle types.GTFSRealtimeVehiclePosition) []byte {
+	encoded := make([]byte, 0)
+
+	if vehicle.Trip != nil {
+		trip := make([]byte, 0)
+		if vehicle.Trip.TripID != "" {
+			trip = appendLengthDelimited(trip, 1, []byte(vehicle.Trip.TripID))
+		}
+		if vehicle.Trip.StartTime != "" {
+			trip = appendLengthDelimited(trip, 2, []byte(vehicle.Trip.StartTime))
+		}
+		if vehicle.Trip.StartDate != "" {
+			trip = appendLengthDelimited(trip, 3, []byte(vehicle.Trip.StartDate))
+		}
+		if vehicle.Trip.RouteID != "" {
+			trip = appendLengthDelimited(trip, 5, []byte(vehicle.Trip.RouteID))
+		}
+		if vehicle.Trip.DirectionID != nil {
+			trip = appendVarintField(trip, 6, uint64(*vehicle.Trip.DirectionID))
+		}
+
+		encoded = appendLengthDelimited(encoded, 1, trip)
+	}
+
+	position := make([]byte, 0)
+	position = appendFloat32Field(position, 1, vehicle.Position.Latitude)
+	position = appendFloat32Field(position, 2, vehicle.Position.Longitude)
+	position = appendFloat32Field(position, 3, vehicle.Position.Bearing)
+	encoded = appendLengthDelimited(encoded, 2, position)
+
+	encoded = appendVarintField(encoded, 5, vehicle.Timestamp)
+
+	descriptor := make([]byte, 0)
+	descriptor = appendLengthDelimited(descriptor, 1, []byte(vehicle.Vehicle.ID))
+	if vehicle.Vehicle.Label != "" {
+		descriptor = appendLengthDelimited(descriptor, 2, []byte(vehicle.Vehicle.Label))
+	}
+
+	return appendLengthDelimited(encoded, 8, descriptor)
+}
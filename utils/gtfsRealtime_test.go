@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"reflect"
+	"server/types"
+	"testing"
+)
+
+func Test_EncodeGTFSRealtimeFeed(t *testing.T) {
+	directionID := uint32(1)
+
+	type args struct {
+		feed types.GTFSRealtimeFeedMessage
+	}
+	tests := []struct {
+		name string
+		args args
+		want []byte
+	}{
+		{
+			name: "Encodes a feed with a vehicle position",
+			args: args{
+				feed: types.GTFSRealtimeFeedMessage{
+					Header: types.GTFSRealtimeFeedHeader{
+						GTFSRealtimeVersion: "2.0",
+						Incrementality: "FULL_DATASET",
+						Timestamp: 300,
+					},
+					Entity: []types.GTFSRealtimeFeedEntity{
+						types.GTFSRealtimeFeedEntity{
+							ID: "V1",
+							Vehicle: types.GTFSRealtimeVehiclePosition{
+								Trip: &types.GTFSRealtimeTripDescriptor{
+									RouteID: "L1",
+									DirectionID: &directionID,
+								},
+								Vehicle: types.GTFSRealtimeVehicleDescriptor{ID: "V1"},
+								Position: types.GTFSRealtimePosition{Latitude: 1, Longitude: 2},
+								Timestamp: 300,
+							},
+						},
+					},
+				},
+			},
+			want: []byte{
+				0x0a, 0x0a, // header
+				0x0a, 0x03, '2', '.', '0', // version
+				0x10, 0x00, // full dataset
+				0x18, 0xac, 0x02, // timestamp
+				0x12, 0x28, // entity
+				0x0a, 0x02, 'V', '1', // id
+				0x22, 0x22, // vehicle position
+				0x0a, 0x06, // trip
+				0x2a, 0x02, 'L', '1', // route id
+				0x30, 0x01, // direction id
+				0x12, 0x0f, // position
+				0x0d, 0x00, 0x00, 0x80, 0x3f, // latitude
+				0x15, 0x00, 0x00, 0x00, 0x40, // longitude
+				0x1d, 0x00, 0x00, 0x00, 0x00, // bearing
+				0x28, 0xac, 0x02, // timestamp
+				0x42, 0x04, // vehicle
+				0x0a, 0x02, 'V', '1', // id
+			},
+		},
+		{
+			name: "Encodes a feed without vehicles",
+			args: args{
+				feed: types.GTFSRealtimeFeedMessage{
+					Header: types.GTFSRealtimeFeedHeader{
+						GTFSRealtimeVersion: "2.0",
+						Incrementality: "FULL_DATASET",
+						Timestamp: 1,
+					},
+				},
+			},
+			want: []byte{
+				0x0a, 0x09,
+				0x0a, 0x03, '2', '.', '0',
+				0x10, 0x00,
+				0x18, 0x01,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EncodeGTFSRealtimeFeed(tt.args.feed); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("EncodeGTFSRealtimeFeed() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
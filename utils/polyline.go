@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"math"
+	"server/types"
+	"strings"
+)
+
+// EncodePolyline encodes a path with the Google encoded polyline algorithm
+// format to a precision of 5 decimal places
+func EncodePolyline(points []types.Coordinate) string {
+	var encoded strings.Builder
+
+	previousLatitude, previousLongitude := 0, 0
+	for _, point := range points {
+		latitude := int(math.Round(float64(point.Latitude) * 1e5))
+		longitude := int(math.Round(float64(point.Longitude) * 1e5))
+
+		encodePolylineValue(&encoded, latitude - previousLatitude)
+		encodePolylineValue(&encoded, longitude - previousLongitude)
+
+		previousLatitude, previousLongitude = latitude, longitude
+	}
+
+	return encoded.String()
+}
+
+// encodePolylineValue writes a signed value as 5 bit chunks, least significant
+// first, each offset by 63 and with 0x20 set when another chunk follows
+func encodePolylineValue(encoded *strings.Builder, value int) {
+	shifted := value << 1
+	if value < 0 {
+		shifted = ^shifted
+	}
+
+	for shifted >= 0x20 {
+		encoded.WriteByte(byte((0x20 | (shifted & 0x1f)) + 63))
+		shifted >>= 5
+	}
+
+	encoded.WriteByte(byte(shifted + 63))
+}
@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"server/types"
+	"testing"
+)
+
+func Test_EncodePolyline(t *testing.T) {
+	type args struct {
+		points []types.Coordinate
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			name: "Encodes the example path of the polyline algorithm format",
+			args: args{
+				points: []types.Coordinate{
+					types.Coordinate{Longitude: -120.2, Latitude: 38.5},
+					types.Coordinate{Longitude: -120.95, Latitude: 40.7},
+					types.Coordinate{Longitude: -126.453, Latitude: 43.252},
+				},
+			},
+			want: "_p~iF~ps|U_ulLnnqC_mqNvxq`@",
+		},
+		{
+			name: "Encodes an empty path",
+			args: args{
+				points: []types.Coordinate{},
+			},
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EncodePolyline(tt.args.points); got != tt.want {
+				t.Errorf("EncodePolyline() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
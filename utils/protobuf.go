@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"math"
+)
+
+// Protocol buffer wire types
+const (
+	wireTypeVarint          = 0
+	wireTypeFloat64         = 1
+	wireTypeLengthDelimited = 2
+	wireTypeFloat32         = 5
+)
+
+func zigZag(value int) uint64 {
+	return uint64((value << 1) ^ (value >> 63))
+}
+
+func appendVarint(encoded []byte, value uint64) []byte {
+	for value >= 0x80 {
+		encoded = append(encoded, byte(value) | 0x80)
+		value >>= 7
+	}
+
+	return append(encoded, byte(value))
+}
+
+func appendTag(encoded []byte, field uint64, wireType uint64) []byte {
+	return appendVarint(encoded, field << 3 | wireType)
+}
+
+func appendVarintField(encoded []byte, field uint64, value uint64) []byte {
+	return appendVarint(appendTag(encoded, field, wireTypeVarint), value)
+}
+
+func appendLengthDelimited(encoded []byte, field uint64, value []byte) []byte {
+	encoded = appendTag(encoded, field, wireTypeLengthDelimited)
+	encoded = appendVarint(encoded, uint64(len(value)))
+
+	return append(encoded, value...)
+}
+
+func appendPacked(encoded []byte, field uint64, values []uint64) []byte {
+	packed := make([]byte, 0)
+	for _, value := range values {
+		packed = appendVarint(packed, value)
+	}
+
+	return appendLengthDelimited(encoded, field, packed)
+}
+
+func appendFloat32Field(encoded []byte, field uint64, value float32) []byte {
+	encoded = appendTag(encoded, field, wireTypeFloat32)
+	bits := math.Float32bits(value)
+
+	return append(encoded, byte(bits), byte(bits >> 8), byte(bits >> 16), byte(bits >> 24))
+}
+
+func appendFloat64Field(encoded []byte, field uint64, value float64) []byte {
+	encoded = appendTag(encoded, field, wireTypeFloat64)
+	bits := math.Float64bits(value)
+	for byteIndex := 0; byteIndex < 8; byteIndex++ {
+		encoded = append(encoded, byte(bits >> (8 * byteIndex)))
+	}
+
+	return encoded
+}
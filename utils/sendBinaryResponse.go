@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"os"
+	"fmt"
+	"compress/gzip"
+	"net/http"
+)
+
+// SendBinaryResponse sends back an encoded body of a content type and
+// compresses using gzip where possible
+func SendBinaryResponse(
+	w http.ResponseWriter, successStatus int, compress bool, contentType string, body []byte,
+) {
+	w.Header().Set("Content-Type", contentType)
+
+	if !compress {
+		w.WriteHeader(successStatus)
+
+		if _, err := w.Write(body); err != nil {
+			fmt.Fprint(os.Stderr, "Error writing (binary-plain) body", err)
+		}
+
+		return
+	}
+
+	w.Header().Set("Content-Encoding", "gzip")
+	w.WriteHeader(successStatus)
+
+	gz := gzip.NewWriter(w)
+	defer gz.Close()
+	if _, err := gz.Write(body); err != nil {
+		fmt.Fprint(os.Stderr, "Error writing (binary-gzip) body", err)
+	}
+}
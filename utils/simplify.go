@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"math"
+	"server/types"
+)
+
+// metresPerPixelAtEquator is the width in metres of a pixel of a 256 pixel web
+// mercator tile at zoom level 0 on the equator
+const metresPerPixelAtEquator = 156543.03392
+
+// MaximumZoom is the most detailed web mercator zoom level
+const MaximumZoom = 22
+
+// ZoomTolerance gets the width in metres of a pixel at a web mercator zoom level
+// and latitude, which is the largest error not visible at that zoom
+func ZoomTolerance(zoom uint, latitude float32) float64 {
+	return metresPerPixelAtEquator * math.Cos(toRadians(float64(latitude))) / math.Pow(2, float64(zoom))
+}
+
+// Simplify removes points from a path using the Douglas-Peucker algorithm so no
+// removed point is further than tolerance metres from the simplified path. The
+// first and last points are always kept
+func Simplify(points []types.Coordinate, tolerance float64) []types.Coordinate {
+	if len(points) < 3 {
+		return append([]types.Coordinate{}, points...)
+	}
+
+	keep := make([]bool, len(points))
+	keep[0] = true
+	keep[len(points) - 1] = true
+
+	// sections of the path still to simplify, as start and end indexes
+	sections := [][2]int{{0, len(points) - 1}}
+	for len(sections) > 0 {
+		section := sections[len(sections) - 1]
+		sections = sections[:len(sections) - 1]
+
+		furthestIndex := -1
+		furthestDistance := tolerance
+		for pointIndex := section[0] + 1; pointIndex < section[1]; pointIndex++ {
+			distance := segmentDistance(points[pointIndex], points[section[0]], points[section[1]])
+			if distance > furthestDistance {
+				furthestIndex = pointIndex
+				furthestDistance = distance
+			}
+		}
+
+		if furthestIndex == -1 {
+			continue
+		}
+
+		keep[furthestIndex] = true
+		sections = append(sections, [2]int{section[0], furthestIndex}, [2]int{furthestIndex, section[1]})
+	}
+
+	simplified := make([]types.Coordinate, 0)
+	for pointIndex, point := range points {
+		if keep[pointIndex] {
+			simplified = append(simplified, point)
+		}
+	}
+
+	return simplified
+}
+
+// segmentDistance gets the distance in metres from a point to the nearest point
+// of a segment. Coordinates are projected onto a flat plane around the start of
+// the segment which is accurate over the length of a route section
+func segmentDistance(point types.Coordinate, start types.Coordinate, end types.Coordinate) float64 {
+	metresPerDegree := earthRadius * math.Pi / 180
+	longitudeScale := math.Cos(toRadians(float64(start.Latitude)))
+
+	project := func(coordinate types.Coordinate) (float64, float64) {
+		return float64(coordinate.Longitude - start.Longitude) * metresPerDegree * longitudeScale,
+			float64(coordinate.Latitude - start.Latitude) * metresPerDegree
+	}
+
+	pointX, pointY := project(point)
+	endX, endY := project(end)
+
+	lengthSquared := endX * endX + endY * endY
+	if lengthSquared == 0 {
+		return math.Hypot(pointX, pointY)
+	}
+
+	// position of the nearest point along the segment from 0 (start) to 1 (end)
+	position := math.Max(0, math.Min(1, (pointX * endX + pointY * endY) / lengthSquared))
+
+	return math.Hypot(pointX - position * endX, pointY - position * endY)
+}
@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"math"
+	"reflect"
+	"server/types"
+	"testing"
+)
+
+func Test_Simplify(t *testing.T) {
+	// about 70 metres between each longitude step at this latitude
+	straight := []types.Coordinate{
+		types.Coordinate{Longitude: 1.000, Latitude: 51.0},
+		types.Coordinate{Longitude: 1.001, Latitude: 51.0},
+		types.Coordinate{Longitude: 1.002, Latitude: 51.0},
+		types.Coordinate{Longitude: 1.003, Latitude: 51.0},
+	}
+	// the third point is about 111 metres north of the line
+	detour := []types.Coordinate{
+		types.Coordinate{Longitude: 1.000, Latitude: 51.000},
+		types.Coordinate{Longitude: 1.001, Latitude: 51.0001},
+		types.Coordinate{Longitude: 1.002, Latitude: 51.001},
+		types.Coordinate{Longitude: 1.003, Latitude: 51.000},
+		types.Coordinate{Longitude: 1.004, Latitude: 51.000},
+	}
+
+	type args struct {
+		points    []types.Coordinate
+		tolerance float64
+	}
+	tests := []struct {
+		name string
+		args args
+		want []types.Coordinate
+	}{
+		{
+			name: "Removes points on a straight line",
+			args: args{
+				points: straight,
+				tolerance: 1,
+			},
+			want: []types.Coordinate{straight[0], straight[3]},
+		},
+		{
+			name: "Keeps points further than the tolerance",
+			args: args{
+				points: detour,
+				tolerance: 50,
+			},
+			want: []types.Coordinate{detour[0], detour[2], detour[4]},
+		},
+		{
+			name: "Removes points within the tolerance",
+			args: args{
+				points: detour,
+				tolerance: 200,
+			},
+			want: []types.Coordinate{detour[0], detour[4]},
+		},
+		{
+			name: "Keeps every point with no tolerance",
+			args: args{
+				points: detour,
+				tolerance: 0,
+			},
+			want: detour,
+		},
+		{
+			name: "Keeps a path of two points",
+			args: args{
+				points: straight[:2],
+				tolerance: 1000,
+			},
+			want: straight[:2],
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Simplify(tt.args.points, tt.args.tolerance); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Simplify() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_ZoomTolerance(t *testing.T) {
+	type args struct {
+		zoom     uint
+		latitude float32
+	}
+	tests := []struct {
+		name string
+		args args
+		want float64
+	}{
+		{
+			name: "Gets the pixel width at zoom 0 on the equator",
+			args: args{
+				zoom: 0,
+				latitude: 0,
+			},
+			want: 156543.03,
+		},
+		{
+			name: "Gets the pixel width at zoom 16 in Canterbury",
+			args: args{
+				zoom: 16,
+				latitude: 51.28,
+			},
+			want: 1.49,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ZoomTolerance(tt.args.zoom, tt.args.latitude); math.Abs(got - tt.want) > 0.01 {
+				t.Errorf("ZoomTolerance() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
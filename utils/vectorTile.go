@@ -0,0 +1,239 @@
+package utils
+
+import (
+	"math"
+	"server/types"
+	"sort"
+)
+
+// VectorTileExtent is the width and height of a vector tile in tile coordinates
+const VectorTileExtent = 4096
+
+// Vector tile geometry types
+const (
+	VectorTilePoint      uint64 = 1
+	VectorTileLineString uint64 = 2
+)
+
+// Vector tile geometry commands
+const (
+	commandMoveTo = 1
+	commandLineTo = 2
+)
+
+// VectorTileLayer is a named layer of features in a vector tile
+type VectorTileLayer struct {
+	Name     string
+	Features []VectorTileFeature
+}
+
+// VectorTileFeature is a point or line string feature of a vector tile layer.
+// A point feature has a single part of one or more points and a line string
+// feature has a part for each line. Properties are strings, bools, ints, uints
+// or float64s
+type VectorTileFeature struct {
+	Type       uint64
+	Geometry   [][]TilePoint
+	Properties map[string]interface{}
+}
+
+// TilePoint is a position within a vector tile, with 0, 0 in the top left
+// corner and VectorTileExtent, VectorTileExtent in the bottom right corner
+type TilePoint struct {
+	X int
+	Y int
+}
+
+// TileBounds gets the longitudes and latitudes of the corners of a web
+// mercator tile
+func TileBounds(zoom uint, x uint, y uint) types.BoundingBox {
+	tiles := math.Pow(2, float64(zoom))
+
+	longitude := func(x float64) float32 {
+		return float32(x / tiles * 360 - 180)
+	}
+	latitude := func(y float64) float32 {
+		return float32(toDegrees(math.Atan(math.Sinh(math.Pi * (1 - 2 * y / tiles)))))
+	}
+
+	return types.BoundingBox{
+		Min: types.Coordinate{Longitude: longitude(float64(x)), Latitude: latitude(float64(y + 1))},
+		Max: types.Coordinate{Longitude: longitude(float64(x + 1)), Latitude: latitude(float64(y))},
+	}
+}
+
+// TilePosition gets the position of a coordinate within a web mercator tile.
+// Coordinates outside the tile have positions outside of the extent
+func TilePosition(coordinate types.Coordinate, zoom uint, x uint, y uint) TilePoint {
+	tiles := math.Pow(2, float64(zoom))
+	latitude := toRadians(float64(coordinate.Latitude))
+
+	worldX := (float64(coordinate.Longitude) + 180) / 360 * tiles
+	worldY := (1 - math.Log(math.Tan(latitude) + 1 / math.Cos(latitude)) / math.Pi) / 2 * tiles
+
+	return TilePoint{
+		X: int(math.Round((worldX - float64(x)) * VectorTileExtent)),
+		Y: int(math.Round((worldY - float64(y)) * VectorTileExtent)),
+	}
+}
+
+// EncodeVectorTile encodes layers as a Mapbox Vector Tile (version 2)
+// protocol buffer. Layers without features are left out
+func EncodeVectorTile(layers []VectorTileLayer) []byte {
+	tile := make([]byte, 0)
+	for _, layer := range layers {
+		if len(layer.Features) == 0 {
+			continue
+		}
+
+		tile = appendLengthDelimited(tile, 3, encodeVectorTileLayer(layer))
+	}
+
+	return tile
+}
+
+func encodeVectorTileLayer(layer VectorTileLayer) []byte {
+	keys := make([]string, 0)
+	keyIndexes := make(map[string]uint64)
+	values := make([]interface{}, 0)
+	valueIndexes := make(map[interface{}]uint64)
+
+	encoded := make([]byte, 0)
+	encoded = appendVarintField(encoded, 15, 2)
+	encoded = appendLengthDelimited(encoded, 1, []byte(layer.Name))
+
+	for _, feature := range layer.Features {
+		geometry := encodeVectorTileGeometry(feature.Type, feature.Geometry)
+		if len(geometry) == 0 {
+			continue
+		}
+
+		// properties are tagged in key order so tiles are repeatable
+		propertyKeys := make([]string, 0)
+		for key := range feature.Properties {
+			propertyKeys = append(propertyKeys, key)
+		}
+		sort.Strings(propertyKeys)
+
+		tags := make([]uint64, 0)
+		for _, key := range propertyKeys {
+			value := feature.Properties[key]
+
+			keyIndex, ok := keyIndexes[key]
+			if !ok {
+				keyIndex = uint64(len(keys))
+				keyIndexes[key] = keyIndex
+				keys = append(keys, key)
+			}
+
+			valueIndex, ok := valueIndexes[value]
+			if !ok {
+				valueIndex = uint64(len(values))
+				valueIndexes[value] = valueIndex
+				values = append(values, value)
+			}
+
+			tags = append(tags, keyIndex, valueIndex)
+		}
+
+		encodedFeature := make([]byte, 0)
+		if len(tags) > 0 {
+			encodedFeature = appendPacked(encodedFeature, 2, tags)
+		}
+		encodedFeature = appendVarintField(encodedFeature, 3, feature.Type)
+		encodedFeature = appendPacked(encodedFeature, 4, geometry)
+
+		encoded = appendLengthDelimited(encoded, 2, encodedFeature)
+	}
+
+	for _, key := range keys {
+		encoded = appendLengthDelimited(encoded, 3, []byte(key))
+	}
+
+	for _, value := range values {
+		encoded = appendLengthDelimited(encoded, 4, encodeVectorTileValue(value))
+	}
+
+	return appendVarintField(encoded, 5, VectorTileExtent)
+}
+
+// encodeVectorTileGeometry encodes the parts of a feature as commands relative
+// to the previous point. Repeated points and line strings of fewer than two
+// points are left out
+func encodeVectorTileGeometry(geometryType uint64, parts [][]TilePoint) []uint64 {
+	geometry := make([]uint64, 0)
+	cursor := TilePoint{}
+
+	appendPoint := func(point TilePoint) {
+		geometry = append(geometry, zigZag(point.X - cursor.X), zigZag(point.Y - cursor.Y))
+		cursor = point
+	}
+
+	if geometryType == VectorTilePoint {
+		points := make([]TilePoint, 0)
+		for _, part := range parts {
+			points = append(points, part...)
+		}
+
+		if len(points) == 0 {
+			return geometry
+		}
+
+		geometry = append(geometry, command(commandMoveTo, len(points)))
+		for _, point := range points {
+			appendPoint(point)
+		}
+
+		return geometry
+	}
+
+	for _, part := range parts {
+		line := make([]TilePoint, 0)
+		for _, point := range part {
+			if len(line) == 0 || line[len(line) - 1] != point {
+				line = append(line, point)
+			}
+		}
+
+		if len(line) < 2 {
+			continue
+		}
+
+		geometry = append(geometry, command(commandMoveTo, 1))
+		appendPoint(line[0])
+
+		geometry = append(geometry, command(commandLineTo, len(line) - 1))
+		for _, point := range line[1:] {
+			appendPoint(point)
+		}
+	}
+
+	return geometry
+}
+
+func encodeVectorTileValue(value interface{}) []byte {
+	encoded := make([]byte, 0)
+
+	switch typedValue := value.(type) {
+	case string:
+		encoded = appendLengthDelimited(encoded, 1, []byte(typedValue))
+	case float64:
+		encoded = appendFloat64Field(encoded, 3, typedValue)
+	case uint:
+		encoded = appendVarintField(encoded, 5, uint64(typedValue))
+	case int:
+		encoded = appendVarintField(encoded, 6, zigZag(typedValue))
+	case bool:
+		boolValue := uint64(0)
+		if typedValue {
+			boolValue = 1
+		}
+		encoded = appendVarintField(encoded, 7, boolValue)
+	}
+
+	return encoded
+}
+
+func command(id int, count int) uint64 {
+	return uint64((id & 0x7) | (count << 3))
+}
@@ -0,0 +1,231 @@
+package utils
+
+import (
+	"math"
+	"reflect"
+	"server/types"
+	"testing"
+)
+
+func Test_EncodeVectorTile(t *testing.T) {
+	type args struct {
+		layers []VectorTileLayer
+	}
+	tests := []struct {
+		name string
+		args args
+		want []byte
+	}{
+		{
+			name: "Encodes a layer with a point feature",
+			args: args{
+				layers: []VectorTileLayer{
+					VectorTileLayer{
+						Name: "stops",
+						Features: []VectorTileFeature{
+							VectorTileFeature{
+								Type: VectorTilePoint,
+								Geometry: [][]TilePoint{{{X: 25, Y: 17}}},
+								Properties: map[string]interface{}{"name": "A"},
+							},
+						},
+					},
+				},
+			},
+			want: []byte{
+				0x1a, 0x24, // layer
+				0x78, 0x02, // version 2
+				0x0a, 0x05, 's', 't', 'o', 'p', 's', // name
+				0x12, 0x0b, // feature
+				0x12, 0x02, 0x00, 0x00, // tags
+				0x18, 0x01, // point
+				0x22, 0x03, 0x09, 0x32, 0x22, // geometry
+				0x1a, 0x04, 'n', 'a', 'm', 'e', // key
+				0x22, 0x03, 0x0a, 0x01, 'A', // string value
+				0x28, 0x80, 0x20, // extent 4096
+			},
+		},
+		{
+			name: "Leaves out layers without features",
+			args: args{
+				layers: []VectorTileLayer{
+					VectorTileLayer{Name: "routes"},
+				},
+			},
+			want: []byte{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EncodeVectorTile(tt.args.layers); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("EncodeVectorTile() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_encodeVectorTileGeometry(t *testing.T) {
+	type args struct {
+		geometryType uint64
+		parts        [][]TilePoint
+	}
+	tests := []struct {
+		name string
+		args args
+		want []uint64
+	}{
+		{
+			name: "Encodes a point",
+			args: args{
+				geometryType: VectorTilePoint,
+				parts: [][]TilePoint{{{X: 25, Y: 17}}},
+			},
+			want: []uint64{9, 50, 34},
+		},
+		{
+			name: "Encodes multiple points",
+			args: args{
+				geometryType: VectorTilePoint,
+				parts: [][]TilePoint{{{X: 5, Y: 7}, {X: 3, Y: 2}}},
+			},
+			want: []uint64{17, 10, 14, 3, 9},
+		},
+		{
+			name: "Encodes a line string",
+			args: args{
+				geometryType: VectorTileLineString,
+				parts: [][]TilePoint{{{X: 2, Y: 2}, {X: 2, Y: 10}, {X: 10, Y: 10}}},
+			},
+			want: []uint64{9, 4, 4, 18, 0, 16, 16, 0},
+		},
+		{
+			name: "Encodes multiple line strings relative to the previous line",
+			args: args{
+				geometryType: VectorTileLineString,
+				parts: [][]TilePoint{
+					{{X: 2, Y: 2}, {X: 2, Y: 10}, {X: 10, Y: 10}},
+					{{X: 1, Y: 1}, {X: 3, Y: 5}},
+				},
+			},
+			want: []uint64{9, 4, 4, 18, 0, 16, 16, 0, 9, 17, 17, 10, 4, 8},
+		},
+		{
+			name: "Leaves out repeated points and lines of one point",
+			args: args{
+				geometryType: VectorTileLineString,
+				parts: [][]TilePoint{
+					{{X: 2, Y: 2}, {X: 2, Y: 2}},
+					{{X: 2, Y: 2}, {X: 2, Y: 2}, {X: 2, Y: 10}},
+				},
+			},
+			want: []uint64{9, 4, 4, 10, 0, 16},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := encodeVectorTileGeometry(tt.args.geometryType, tt.args.parts); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("encodeVectorTileGeometry() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_TileBounds(t *testing.T) {
+	type args struct {
+		zoom uint
+		x    uint
+		y    uint
+	}
+	tests := []struct {
+		name string
+		args args
+		want types.BoundingBox
+	}{
+		{
+			name: "Gets the bounds of the world tile",
+			args: args{zoom: 0, x: 0, y: 0},
+			want: types.BoundingBox{
+				Min: types.Coordinate{Longitude: -180, Latitude: -85.05113},
+				Max: types.Coordinate{Longitude: 180, Latitude: 85.05113},
+			},
+		},
+		{
+			name: "Gets the bounds of a tile in Canterbury",
+			args: args{zoom: 14, x: 8241, y: 5464},
+			want: types.BoundingBox{
+				Min: types.Coordinate{Longitude: 1.0766602, Latitude: 51.275662},
+				Max: types.Coordinate{Longitude: 1.0986328, Latitude: 51.289406},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := TileBounds(tt.args.zoom, tt.args.x, tt.args.y)
+
+			for _, pair := range [][2]float32{
+				{got.Min.Longitude, tt.want.Min.Longitude},
+				{got.Min.Latitude, tt.want.Min.Latitude},
+				{got.Max.Longitude, tt.want.Max.Longitude},
+				{got.Max.Latitude, tt.want.Max.Latitude},
+			} {
+				if math.Abs(float64(pair[0] - pair[1])) > 0.0001 {
+					t.Errorf("TileBounds() = %v, want %v", got, tt.want)
+
+					break
+				}
+			}
+		})
+	}
+}
+
+func Test_TilePosition(t *testing.T) {
+	type args struct {
+		coordinate types.Coordinate
+		zoom       uint
+		x          uint
+		y          uint
+	}
+	tests := []struct {
+		name string
+		args args
+		want TilePoint
+	}{
+		{
+			name: "Gets the centre of the world tile",
+			args: args{
+				coordinate: types.Coordinate{Longitude: 0, Latitude: 0},
+				zoom: 0,
+				x: 0,
+				y: 0,
+			},
+			want: TilePoint{X: 2048, Y: 2048},
+		},
+		{
+			name: "Gets the top left corner of a tile",
+			args: args{
+				coordinate: types.Coordinate{Longitude: 0, Latitude: 0},
+				zoom: 1,
+				x: 1,
+				y: 1,
+			},
+			want: TilePoint{X: 0, Y: 0},
+		},
+		{
+			name: "Gets a position outside of a tile",
+			args: args{
+				coordinate: types.Coordinate{Longitude: -90, Latitude: 0},
+				zoom: 1,
+				x: 1,
+				y: 1,
+			},
+			want: TilePoint{X: -2048, Y: 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TilePosition(tt.args.coordinate, tt.args.zoom, tt.args.x, tt.args.y); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TilePosition() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}